@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -22,6 +23,8 @@ type Config struct {
 	RatedDBName     string `envconfig:"POSTGRES_RATED_DB" required:"true"`
 
 	HTTPPort string `envconfig:"HTTP_PORT" default:"4242"`
+	// Maximale Wartezeit beim Herunterfahren (HTTP-Drain und laufende Fetch-Jobs)
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
 
 	PubMedBaseURL          string `envconfig:"PUBMED_BASE_URL" default:"https://eutils.ncbi.nlm.nih.gov/entrez/eutils"`
 	PubMedAPIKey           string `envconfig:"PUBMED_API_KEY"`
@@ -45,6 +48,64 @@ type Config struct {
 
 	// Provider-Konfiguration
 	EnabledProviders string `envconfig:"ENABLED_PROVIDERS" default:"pubmed,europepmc"`
+	// Reihenfolge beim Zusammenführen von Provider-Treffern (leer = Reihenfolge aus ENABLED_PROVIDERS)
+	ProviderPrecedence string `envconfig:"PROVIDER_PRECEDENCE"`
+	// Abweichende Reihenfolge je Feld, z.B. "abstract=europepmc,pubmed;download_link=europepmc,pubmed"
+	FieldPrecedence string `envconfig:"FIELD_PRECEDENCE"`
+
+	// Embeddings (OpenAI-kompatible API, z.B. https://api.openai.com/v1 oder ein lokaler Server); leer = deaktiviert
+	EmbeddingBaseURL    string `envconfig:"EMBEDDING_BASE_URL"`
+	EmbeddingAPIKey     string `envconfig:"EMBEDDING_API_KEY"`
+	EmbeddingModel      string `envconfig:"EMBEDDING_MODEL" default:"text-embedding-3-small"`
+	EmbeddingDimensions int    `envconfig:"EMBEDDING_DIMENSIONS" default:"0"`
+	EmbeddingBatchSize  int    `envconfig:"EMBEDDING_BATCH_SIZE" default:"64"`
+	EmbeddingSchedule   string `envconfig:"EMBEDDING_SCHEDULE" default:"@every 1h"`
+
+	// Erkennung inhaltlicher Duplikate (Shingling/MinHash auf Titel und Abstract)
+	DedupThreshold float64 `envconfig:"DEDUP_THRESHOLD" default:"0.7"`
+	DedupSchedule  string  `envconfig:"DEDUP_SCHEDULE" default:"@daily"`
+
+	// Befüllen von paper_links aus Referenzlisten und Zitierungen (Europe PMC, PubMed ELink, PMC JATS)
+	CitationLinkSchedule string        `envconfig:"CITATION_LINK_SCHEDULE" default:"@daily"`
+	CitationLinkMaxAge   time.Duration `envconfig:"CITATION_LINK_MAX_AGE" default:"720h"`
+
+	// Snowball-Suche ausgehend von hoch bewerteten rated_papers (leerer Zeitplan = nur manuell über die API)
+	SnowballSchedule  string  `envconfig:"SNOWBALL_SCHEDULE" default:"@weekly"`
+	SnowballMinRating float64 `envconfig:"SNOWBALL_MIN_RATING" default:"8"`
+	SnowballDepth     int     `envconfig:"SNOWBALL_DEPTH" default:"1"`
+	SnowballMaxPapers int     `envconfig:"SNOWBALL_MAX_PAPERS" default:"200"`
+
+	// Aktuelle Prompt-Version der KI-Bewertung; ältere Bewertungen werden beim Start zur Neubewertung markiert (leer = aus)
+	RatingPromptVersion string `envconfig:"RATING_PROMPT_VERSION"`
+
+	// Mehrere Rater je Paper: Aggregation (mean, median, trust_weighted) und Rating-Spanne, ab der Rater als uneinig gelten
+	RatingAggregation          string  `envconfig:"RATING_AGGREGATION" default:"mean"`
+	RaterDisagreementThreshold float64 `envconfig:"RATER_DISAGREEMENT_THRESHOLD" default:"2"`
+
+	// Validierung von POST /rated-papers: strict lehnt ungültige Bewertungen ab, lenient stellt sie in Quarantäne.
+	// Erlaubte Kategorien kommagetrennt (leer = beliebig)
+	RatedPaperValidation string `envconfig:"RATED_PAPER_VALIDATION" default:"strict"`
+	RatedPaperCategories string `envconfig:"RATED_PAPER_CATEGORIES"`
+
+	// Content-Workflows: Übergänge "zustand=folge1,folge2;…" (* = Anfangszustände) und Guards
+	// "zustand=pflichtfeld1,…" (leer = Standard)
+	ContentArticleTransitions string `envconfig:"CONTENT_ARTICLE_TRANSITIONS"`
+	ContentArticleGuards      string `envconfig:"CONTENT_ARTICLE_GUARDS"`
+	RatedPaperTransitions     string `envconfig:"RATED_PAPER_TRANSITIONS"`
+	RatedPaperGuards          string `envconfig:"RATED_PAPER_GUARDS"`
+
+	// Arbeitsvergabe an n8n-Worker (POST /work/claim): Standard-Laufzeit eines Leases und Fehlversuche,
+	// nach denen ein Eintrag nicht mehr vergeben wird
+	WorkLeaseTTL    time.Duration `envconfig:"WORK_LEASE_TTL" default:"15m"`
+	WorkMaxAttempts int           `envconfig:"WORK_MAX_ATTEMPTS" default:"5"`
+
+	// Ausgehende Webhooks: Takt des Zustellers, Versuche je Zustellung, exponentieller Backoff
+	// (Start, Obergrenze) und Timeout je Versuch
+	WebhookSchedule    string        `envconfig:"WEBHOOK_SCHEDULE" default:"@every 10s"`
+	WebhookMaxAttempts int           `envconfig:"WEBHOOK_MAX_ATTEMPTS" default:"8"`
+	WebhookBackoff     time.Duration `envconfig:"WEBHOOK_BACKOFF" default:"30s"`
+	WebhookBackoffMax  time.Duration `envconfig:"WEBHOOK_BACKOFF_MAX" default:"1h"`
+	WebhookTimeout     time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
 
 	// API Security
 	APISecretKey string `envconfig:"API_SECRET_KEY"`
@@ -0,0 +1,111 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"paper-hand/config"
+)
+
+// Embedder erzeugt Embedding-Vektoren für Texte. Die Reihenfolge der Vektoren entspricht der Eingabe.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model identifiziert das Modell; Vektoren verschiedener Modelle werden nie verglichen.
+	Model() string
+}
+
+// OpenAIEmbedder spricht die OpenAI-kompatible /embeddings-API an (OpenAI, Ollama, LocalAI, vLLM, ...).
+type OpenAIEmbedder struct {
+	BaseURL    string
+	APIKey     string
+	ModelName  string
+	Dimensions int // 0 = Standard des Modells
+	Logger     *zap.Logger
+	client     *http.Client
+}
+
+// NewOpenAIEmbedder erstellt einen Embedder aus EMBEDDING_BASE_URL, EMBEDDING_API_KEY und EMBEDDING_MODEL.
+func NewOpenAIEmbedder(cfg *config.Config, logger *zap.Logger) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		BaseURL:    strings.TrimRight(cfg.EmbeddingBaseURL, "/"),
+		APIKey:     cfg.EmbeddingAPIKey,
+		ModelName:  cfg.EmbeddingModel,
+		Dimensions: cfg.EmbeddingDimensions,
+		Logger:     logger,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Model liefert den Modellnamen.
+func (e *OpenAIEmbedder) Model() string {
+	return e.ModelName
+}
+
+// Embed bettet alle Texte mit einem Request ein.
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(embeddingRequest{Model: e.ModelName, Input: texts, Dimensions: e.Dimensions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.BaseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var er embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range er.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response has invalid index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embedding response is missing input %d", i)
+		}
+	}
+	e.Logger.Debug("Embeddings erzeugt", zap.String("model", e.ModelName), zap.Int("count", len(texts)))
+	return vectors, nil
+}
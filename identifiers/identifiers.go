@@ -0,0 +1,191 @@
+// Package identifiers normalisiert Paper-Identifier (DOI, PMID, PMCID, arXiv), damit sie
+// überall gleich verglichen werden.
+package identifiers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kind ist die Art eines Identifiers.
+type Kind string
+
+const (
+	DOI   Kind = "doi"
+	PMID  Kind = "pmid"
+	PMCID Kind = "pmcid"
+	ArXiv Kind = "arxiv"
+)
+
+// ID ist ein normalisierter Identifier.
+type ID struct {
+	Kind  Kind   `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Normalize normalisiert einen Wert der angegebenen Art; leer, wenn ungültig.
+func Normalize(kind Kind, s string) string {
+	switch kind {
+	case DOI:
+		return NormalizeDOI(s)
+	case PMID:
+		return NormalizePMID(s)
+	case PMCID:
+		return NormalizePMCID(s)
+	case ArXiv:
+		return NormalizeArXiv(s)
+	}
+	return ""
+}
+
+var (
+	doiPattern      = regexp.MustCompile(`10\.\d{4,9}/\S+`)
+	digitsPattern   = regexp.MustCompile(`^\d{1,9}$`)
+	pmcidPattern    = regexp.MustCompile(`^PMC\d+$`)
+	arxivNewPattern = regexp.MustCompile(`^\d{4}\.\d{4,5}$`)
+	arxivOldPattern = regexp.MustCompile(`^[a-z-]+(\.[a-z]{2})?/\d{7}$`)
+	arxivVersion    = regexp.MustCompile(`v\d+$`)
+)
+
+// NormalizeDOI liefert die DOI in Kleinbuchstaben ohne URL-/"doi:"-Präfix und ohne angehängte Satzzeichen.
+func NormalizeDOI(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi.org/", "doi:", "doi "} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "10.") {
+		return ""
+	}
+	s = strings.TrimRight(s, ".,;:)]}>\"'")
+	if !doiPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// NormalizePMID liefert die PMID als reine Ziffernfolge (ohne "PMID:"-Präfix oder PubMed-URL).
+func NormalizePMID(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, prefix := range []string{"https://pubmed.ncbi.nlm.nih.gov/", "http://pubmed.ncbi.nlm.nih.gov/", "https://www.ncbi.nlm.nih.gov/pubmed/", "pmid:", "pmid"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.Trim(strings.TrimSpace(s), "/")
+	s = strings.TrimLeft(s, "0")
+	if !digitsPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// NormalizePMCID liefert die PMCID in der Form "PMC1234567".
+func NormalizePMCID(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, prefix := range []string{"HTTPS://WWW.NCBI.NLM.NIH.GOV/PMC/ARTICLES/", "HTTPS://PMC.NCBI.NLM.NIH.GOV/ARTICLES/", "PMCID:"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.Trim(strings.TrimSpace(s), "/")
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "PMC" + s
+	}
+	if !pmcidPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// NormalizeArXiv liefert die arXiv-ID ohne URL-/"arXiv:"-Präfix und ohne Versionssuffix.
+func NormalizeArXiv(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, prefix := range []string{"https://arxiv.org/abs/", "http://arxiv.org/abs/", "https://arxiv.org/pdf/", "http://arxiv.org/pdf/", "arxiv:"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), ".pdf")
+	s = arxivVersion.ReplaceAllString(s, "")
+	if !arxivNewPattern.MatchString(s) && !arxivOldPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+var (
+	europePMCPattern = regexp.MustCompile(`(?i)europepmc\.org/(?:article|abstract)/(MED|PMC)/(PMC)?(\d+)`)
+	pmidTextPattern  = regexp.MustCompile(`(?i)\bPMID:?\s*(\d{1,9})\b`)
+	pmcidTextPattern = regexp.MustCompile(`(?i)\bPMC\d{3,10}\b`)
+	arxivTextPattern = regexp.MustCompile(`(?i)\barxiv:\s*(\d{4}\.\d{4,5}(?:v\d+)?|[a-z-]+(?:\.[a-z]{2})?/\d{7})`)
+)
+
+// Detect erkennt die Art eines unbekannten Identifiers (DOI, PMID, PMCID, arXiv, auch als URL).
+func Detect(s string) (ID, bool) {
+	raw := strings.TrimSpace(s)
+	lower := strings.ToLower(raw)
+	if m := europePMCPattern.FindStringSubmatch(raw); m != nil {
+		if strings.EqualFold(m[1], "PMC") {
+			return ID{Kind: PMCID, Value: "PMC" + m[3]}, true
+		}
+		return ID{Kind: PMID, Value: NormalizePMID(m[3])}, true
+	}
+	switch {
+	case strings.Contains(lower, "arxiv"):
+		if v := NormalizeArXiv(raw); v != "" {
+			return ID{Kind: ArXiv, Value: v}, true
+		}
+	case strings.Contains(lower, "pmc"):
+		if v := NormalizePMCID(raw); v != "" {
+			return ID{Kind: PMCID, Value: v}, true
+		}
+	case strings.Contains(lower, "pubmed") || strings.HasPrefix(lower, "pmid"):
+		if v := NormalizePMID(raw); v != "" {
+			return ID{Kind: PMID, Value: v}, true
+		}
+	}
+	if v := NormalizeDOI(raw); v != "" {
+		return ID{Kind: DOI, Value: v}, true
+	}
+	if m := doiPattern.FindString(lower); m != "" {
+		if v := NormalizeDOI(m); v != "" {
+			return ID{Kind: DOI, Value: v}, true
+		}
+	}
+	if v := NormalizePMID(raw); v != "" {
+		return ID{Kind: PMID, Value: v}, true
+	}
+	if v := NormalizeArXiv(raw); v != "" {
+		return ID{Kind: ArXiv, Value: v}, true
+	}
+	return ID{}, false
+}
+
+// Extract findet alle Identifier in einem Freitext (z.B. einer Literaturangabe), ohne Duplikate.
+func Extract(text string) []ID {
+	var ids []ID
+	add := func(id ID) {
+		if id.Value == "" {
+			return
+		}
+		for _, known := range ids {
+			if known == id {
+				return
+			}
+		}
+		ids = append(ids, id)
+	}
+	for _, m := range doiPattern.FindAllString(text, -1) {
+		add(ID{Kind: DOI, Value: NormalizeDOI(m)})
+	}
+	for _, m := range pmidTextPattern.FindAllStringSubmatch(text, -1) {
+		add(ID{Kind: PMID, Value: NormalizePMID(m[1])})
+	}
+	for _, m := range pmcidTextPattern.FindAllString(text, -1) {
+		add(ID{Kind: PMCID, Value: NormalizePMCID(m)})
+	}
+	for _, m := range arxivTextPattern.FindAllStringSubmatch(text, -1) {
+		add(ID{Kind: ArXiv, Value: NormalizeArXiv(m[1])})
+	}
+	for _, m := range europePMCPattern.FindAllString(text, -1) {
+		if id, ok := Detect(m); ok {
+			add(id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,71 @@
+package identifiers
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		kind Kind
+		in   string
+		want string
+	}{
+		{"doi plain", DOI, "10.1000/XYZ123", "10.1000/xyz123"},
+		{"doi url", DOI, "https://doi.org/10.1000/ABC", "10.1000/abc"},
+		{"doi dx url", DOI, "http://dx.doi.org/10.1000/abc", "10.1000/abc"},
+		{"doi prefix", DOI, " doi:10.1000/abc ", "10.1000/abc"},
+		{"doi trailing punctuation", DOI, "10.1000/abc).", "10.1000/abc"},
+		{"doi without registrant", DOI, "10.1/abc", ""},
+		{"doi not a doi", DOI, "curcumin", ""},
+		{"doi empty", DOI, "", ""},
+		{"pmid plain", PMID, "12345678", "12345678"},
+		{"pmid prefix", PMID, "PMID: 123", "123"},
+		{"pmid url", PMID, "https://pubmed.ncbi.nlm.nih.gov/31452104/", "31452104"},
+		{"pmid leading zeros", PMID, "000123", "123"},
+		{"pmid letters", PMID, "12a45", ""},
+		{"pmid too long", PMID, "1234567890", ""},
+		{"pmcid plain", PMCID, "PMC123456", "PMC123456"},
+		{"pmcid lowercase", PMCID, "pmc123456", "PMC123456"},
+		{"pmcid digits only", PMCID, "123456", "PMC123456"},
+		{"pmcid url", PMCID, "https://www.ncbi.nlm.nih.gov/pmc/articles/PMC123456/", "PMC123456"},
+		{"pmcid invalid", PMCID, "PMCabc", ""},
+		{"arxiv new", ArXiv, "2101.01234", "2101.01234"},
+		{"arxiv version", ArXiv, "arXiv:2101.01234v3", "2101.01234"},
+		{"arxiv pdf url", ArXiv, "https://arxiv.org/pdf/2101.01234v2.pdf", "2101.01234"},
+		{"arxiv old", ArXiv, "hep-th/9901001", "hep-th/9901001"},
+		{"arxiv invalid", ArXiv, "2101.012", ""},
+		{"unknown kind", Kind("isbn"), "978-3-16-148410-0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.kind, tt.in); got != tt.want {
+				t.Errorf("Normalize(%s, %q) = %q, want %q", tt.kind, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   ID
+		wantOK bool
+	}{
+		{"https://doi.org/10.1000/ABC", ID{Kind: DOI, Value: "10.1000/abc"}, true},
+		{"PMID: 123", ID{Kind: PMID, Value: "123"}, true},
+		{"https://pubmed.ncbi.nlm.nih.gov/31452104/", ID{Kind: PMID, Value: "31452104"}, true},
+		{"PMC123456", ID{Kind: PMCID, Value: "PMC123456"}, true},
+		{"https://europepmc.org/article/MED/31452104", ID{Kind: PMID, Value: "31452104"}, true},
+		{"https://europepmc.org/article/PMC/PMC123456", ID{Kind: PMCID, Value: "PMC123456"}, true},
+		{"arXiv:2101.01234v1", ID{Kind: ArXiv, Value: "2101.01234"}, true},
+		{"31452104", ID{Kind: PMID, Value: "31452104"}, true},
+		{"not an identifier", ID{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := Detect(tt.in)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Detect(%q) = %+v, %v, want %+v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
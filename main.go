@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os/signal"
 	"paper-hand/config"
+	"paper-hand/embeddings"
+	"paper-hand/identifiers"
 	"paper-hand/models"
 	"paper-hand/providers"
 	"paper-hand/providers/europepmc"
@@ -15,19 +19,19 @@ import (
 	"paper-hand/providers/unpaywall"
 	"paper-hand/services"
 	"paper-hand/storage"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -90,16 +94,51 @@ func main() {
 	// Auto-Migration
 	if gin.Mode() == gin.DebugMode {
 		logging.Info("Debug mode detected. Dropping tables for fresh start.")
-		rawDB.Migrator().DropTable(&models.Paper{}, &models.Substance{}, &models.SearchFilter{})
-		ratedDB.Migrator().DropTable(&models.RatedPaper{}, &models.ContentArticle{})
+		rawDB.Migrator().DropTable(&models.WorkLease{}, &models.DuplicateCluster{}, &models.Embedding{}, &models.PaperIdentifier{}, &models.PaperSubstance{}, &models.PaperFilter{}, &models.Paper{}, "substance_search_filters", &models.Substance{}, &models.SearchFilter{})
+		ratedDB.Migrator().DropTable(&models.WebhookDelivery{}, &models.WebhookEvent{}, &models.WebhookEndpoint{}, &models.WorkLease{}, &models.Embedding{}, &models.ContentTransition{}, &models.RatedPaperQuarantine{}, &models.AppraisalOutcome{}, &models.Appraisal{}, &models.RatedPaperAssessment{}, &models.Rater{}, &models.RatedPaperRevision{}, &models.RatedPaper{}, &models.ContentArticle{})
 	}
 	logging.Info("Running database auto-migration...")
-	rawDB.AutoMigrate(&models.Paper{}, &models.Substance{}, &models.SearchFilter{}, &models.PaperLink{})
-	ratedDB.AutoMigrate(&models.RatedPaper{}, &models.ContentArticle{})
+	if err := services.PrepareIdentityMigration(rawDB); err != nil {
+		logging.Fatal("Failed to prepare paper identity migration", zap.Error(err))
+	}
+	// Eigene Join-Modelle für die n:m-Klassifikation (mit created_at)
+	rawDB.SetupJoinTable(&models.Paper{}, "Substances", &models.PaperSubstance{})
+	rawDB.SetupJoinTable(&models.Paper{}, "MatchedFilters", &models.PaperFilter{})
+	rawDB.AutoMigrate(&models.Paper{}, &models.Substance{}, &models.SearchFilter{}, &models.PaperSubstance{}, &models.PaperFilter{}, &models.PaperIdentifier{}, &models.PaperLink{}, &models.PaperLinkEvidence{}, &models.FetchJob{}, &models.Embedding{}, &models.DuplicateCluster{}, &models.WorkLease{})
+	ratedDB.AutoMigrate(&models.RatedPaper{}, &models.RatedPaperRevision{}, &models.Rater{}, &models.RatedPaperAssessment{}, &models.Appraisal{}, &models.AppraisalOutcome{}, &models.RatedPaperQuarantine{}, &models.ContentArticle{}, &models.ContentTransition{}, &models.Embedding{}, &models.WorkLease{}, &models.WebhookEndpoint{}, &models.WebhookEvent{}, &models.WebhookDelivery{})
+	if err := services.MigrateFullTextSearch(rawDB, ratedDB); err != nil {
+		logging.Fatal("Failed to migrate full-text search columns", zap.Error(err))
+	}
 
 	// Seeding
 	seedDefaultSubstances(rawDB, logging)
 	seedDefaultSearchFilters(rawDB, logging)
+	if err := services.BackfillPaperClassification(rawDB); err != nil {
+		logging.Warn("Failed to backfill paper classification", zap.Error(err))
+	}
+	if err := services.BackfillPaperIdentity(rawDB, logging); err != nil {
+		logging.Warn("Failed to backfill paper identity", zap.Error(err))
+	}
+	if err := services.MigrateSubstanceLockKeys(rawDB); err != nil {
+		logging.Warn("Failed to migrate substance lock keys", zap.Error(err))
+	}
+	if err := services.BackfillRatedDOIs(ratedDB, logging); err != nil {
+		logging.Warn("Failed to normalize rated DOIs", zap.Error(err))
+	}
+	if _, err := services.ClassifyPaperLinks(rawDB, false); err != nil {
+		logging.Warn("Failed to classify citation intents", zap.Error(err))
+	}
+	if _, err := services.BackfillRatedPaperRevisions(ratedDB); err != nil {
+		logging.Warn("Failed to backfill rated paper revisions", zap.Error(err))
+	}
+	if cfg.RatingPromptVersion != "" {
+		marked, err := services.MarkRatedPapersForReevaluation(ratedDB.Model(&models.RatedPaper{}), cfg.RatingPromptVersion)
+		if err != nil {
+			logging.Warn("Failed to mark rated papers for re-evaluation", zap.Error(err))
+		} else if marked > 0 {
+			logging.Info("Rated papers marked for re-evaluation", zap.String("prompt_version", cfg.RatingPromptVersion), zap.Int64("marked", marked))
+		}
+	}
 
 	// Setup Providers
 	enabledProviderNames := strings.Split(cfg.EnabledProviders, ",")
@@ -126,6 +165,128 @@ func main() {
 	}
 	unpaywallFetcher := unpaywall.NewFetcher(cfg, logging)
 	fetchService := services.NewFetchService(cfg, rawDB, s3Client, logging, enabledProviders, unpaywallFetcher)
+	var embedder embeddings.Embedder
+	if cfg.EmbeddingBaseURL != "" {
+		embedder = embeddings.NewOpenAIEmbedder(cfg, logging)
+		logging.Info("Embeddings enabled", zap.String("model", cfg.EmbeddingModel))
+	}
+	embeddingService := services.NewEmbeddingService(rawDB, ratedDB, embedder, cfg.EmbeddingBatchSize, logging)
+	duplicateDetector := services.NewDuplicateDetector(rawDB, cfg.DedupThreshold, logging)
+	citationLinker := services.NewCitationLinker(rawDB, enabledProviders, cfg.CitationLinkMaxAge, logging)
+	snowballService := services.NewSnowballService(fetchService, ratedDB, services.SnowballOptions{
+		MinRating: cfg.SnowballMinRating,
+		Depth:     cfg.SnowballDepth,
+		MaxPapers: cfg.SnowballMaxPapers,
+	})
+	assessmentService, err := services.NewAssessmentService(ratedDB, cfg.RatingAggregation, cfg.RaterDisagreementThreshold)
+	if err != nil {
+		logging.Fatal("Invalid rater aggregation settings", zap.Error(err))
+	}
+	if _, err := assessmentService.Backfill(); err != nil {
+		logging.Warn("Failed to backfill rater assessments", zap.Error(err))
+	}
+	if !slices.Contains(services.ValidationModes, cfg.RatedPaperValidation) {
+		logging.Fatal("Invalid RATED_PAPER_VALIDATION", zap.String("mode", cfg.RatedPaperValidation))
+	}
+	var ratedPaperCategories []string
+	for _, category := range strings.Split(cfg.RatedPaperCategories, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			ratedPaperCategories = append(ratedPaperCategories, category)
+		}
+	}
+	contentArticleWorkflow := services.ContentArticleWorkflow()
+	if err := contentArticleWorkflow.Configure(cfg.ContentArticleTransitions, cfg.ContentArticleGuards); err != nil {
+		logging.Fatal("Invalid content article workflow", zap.Error(err))
+	}
+	ratedPaperWorkflow := services.RatedPaperWorkflow()
+	if err := ratedPaperWorkflow.Configure(cfg.RatedPaperTransitions, cfg.RatedPaperGuards); err != nil {
+		logging.Fatal("Invalid rated paper workflow", zap.Error(err))
+	}
+	ratedPaperSchema := services.RatedPaperSchema(ratedPaperCategories, ratedPaperWorkflow)
+	workService, err := services.NewWorkService(cfg.WorkLeaseTTL, cfg.WorkMaxAttempts)
+	if err != nil {
+		logging.Fatal("Invalid work queue configuration", zap.Error(err))
+	}
+	webhookService, err := services.NewWebhookService(ratedDB, logging, cfg.WebhookMaxAttempts, cfg.WebhookBackoff, cfg.WebhookBackoffMax, cfg.WebhookTimeout)
+	if err != nil {
+		logging.Fatal("Invalid webhook configuration", zap.Error(err))
+	}
+	fetchService.UseWebhooks(webhookService)
+	// Statuswechsel von Content-Artikeln landen in derselben Transaktion in der Webhook-Outbox
+	contentArticleWorkflow.OnTransition = func(tx *gorm.DB, model any, transition *models.ContentTransition) error {
+		// Neu laden, damit per SQL gesetzte Zeitstempel (published_at) im Ereignis stehen
+		if err := tx.First(model).Error; err != nil {
+			return err
+		}
+		_, err := webhookService.EmitTx(tx, models.EventContentArticleStatusChanged, map[string]any{
+			"id": transition.EntityID, "from": transition.FromState, "to": transition.ToState,
+			"actor": transition.Actor, "reason": transition.Reason, "article": model,
+		})
+		return err
+	}
+
+	// Root-Kontext für alle Hintergrundarbeiten; wird beim Shutdown abgebrochen
+	rootCtx, cancelRoot := context.WithCancel(context.Background())
+	defer cancelRoot()
+
+	// Setup Scheduler (globaler Cron + Zeitpläne pro Substanz)
+	scheduler := services.NewScheduler(rootCtx, fetchService, cfg.CronSchedule, logging, func(count int) {
+		newPapersCounter.Add(float64(count))
+	})
+	if embeddingService.Enabled() {
+		err := scheduler.AddFunc(cfg.EmbeddingSchedule, func() {
+			if _, err := embeddingService.Refresh(rootCtx); err != nil && !errors.Is(err, services.ErrEmbeddingRefreshRunning) {
+				logging.Error("Scheduled embedding refresh failed", zap.Error(err))
+			}
+		})
+		if err != nil {
+			logging.Fatal("Invalid EMBEDDING_SCHEDULE", zap.String("schedule", cfg.EmbeddingSchedule), zap.Error(err))
+		}
+	}
+	err = scheduler.AddFunc(cfg.DedupSchedule, func() {
+		if _, err := duplicateDetector.Scan(rootCtx, "", 0); err != nil && !errors.Is(err, services.ErrDedupRunning) {
+			logging.Error("Scheduled duplicate scan failed", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logging.Fatal("Invalid DEDUP_SCHEDULE", zap.String("schedule", cfg.DedupSchedule), zap.Error(err))
+	}
+	err = scheduler.AddFunc(cfg.CitationLinkSchedule, func() {
+		if _, err := citationLinker.Run(rootCtx, nil); err != nil && !errors.Is(err, services.ErrLinkingRunning) {
+			logging.Error("Scheduled citation linking failed", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logging.Fatal("Invalid CITATION_LINK_SCHEDULE", zap.String("schedule", cfg.CitationLinkSchedule), zap.Error(err))
+	}
+	err = scheduler.AddFunc(cfg.WebhookSchedule, func() {
+		if _, err := webhookService.Dispatch(rootCtx); err != nil && !errors.Is(err, services.ErrWebhookDispatchRunning) {
+			logging.Error("Scheduled webhook dispatch failed", zap.Error(err))
+		}
+	})
+	if err != nil {
+		logging.Fatal("Invalid WEBHOOK_SCHEDULE", zap.String("schedule", cfg.WebhookSchedule), zap.Error(err))
+	}
+	if cfg.SnowballSchedule != "" {
+		err := scheduler.AddFunc(cfg.SnowballSchedule, func() {
+			result, err := snowballService.Run(rootCtx, services.SnowballOptions{}, services.TriggerCron)
+			var running *services.JobRunningError
+			switch {
+			case errors.As(err, &running):
+				logging.Info("Snowball search already running, scheduled run skipped")
+			case err != nil:
+				logging.Error("Scheduled snowball search failed", zap.Error(err))
+			default:
+				newPapersCounter.Add(float64(result.NewPapers))
+			}
+		})
+		if err != nil {
+			logging.Fatal("Invalid SNOWBALL_SCHEDULE", zap.String("schedule", cfg.SnowballSchedule), zap.Error(err))
+		}
+	}
+	if err := scheduler.Start(); err != nil {
+		logging.Fatal("Failed to start scheduler", zap.Error(err))
+	}
 
 	// Setup Router
 	router := gin.Default()
@@ -134,30 +295,25 @@ func main() {
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Setup Routes
-	setupPaperRoutes(router, rawDB, logging)
-	setupSubstanceRoutes(router, rawDB, logging)
-	setupSearchFilterRoutes(router, rawDB, logging)
-	setupSearchRoutes(router, fetchService)
-	setupRatedPaperRoutes(router, ratedDB, rawDB, logging)
-	setupContentArticleRoutes(router, ratedDB, logging)
+	setupPaperRoutes(router, rawDB, workService, logging)
+	setupSubstanceRoutes(router, rawDB, scheduler, logging)
+	setupSearchFilterRoutes(router, rawDB, fetchService, logging)
+	setupSearchRoutes(router, rootCtx, fetchService, snowballService)
+	setupRatedPaperRoutes(router, ratedDB, rawDB, assessmentService, ratedPaperWorkflow, ratedPaperSchema, cfg.RatedPaperValidation, workService, webhookService, logging)
+	setupContentArticleRoutes(router, ratedDB, rawDB, contentArticleWorkflow, logging)
+	setupWorkflowRoutes(router, ratedDB, contentArticleWorkflow, ratedPaperWorkflow, logging)
+	setupWorkRoutes(router, workService, logging)
+	setupWebhookRoutes(router, ratedDB, webhookService, logging)
 	setupCitationRoutes(router, logging)
 	setupTextRoutes(router, logging)
-	setupGraphRoutes(router, rawDB, logging)
+	setupGraphRoutes(router, rootCtx, rawDB, services.NewGraphService(rawDB, ratedDB), citationLinker, logging)
+	setupIdentifierRoutes(router, rawDB, ratedDB, logging)
+	setupEmbeddingRoutes(router, rootCtx, embeddingService, logging)
+	setupDuplicateRoutes(router, rootCtx, duplicateDetector, logging)
 	setupAnswerRoutes(router, logging)
 
-	// Setup Cron
-	cronScheduler := cron.New()
-	cronScheduler.AddFunc(cfg.CronSchedule, func() {
-		logging.Info("Running scheduled fetch job...")
-		count, err := fetchService.RunAllSubstances(context.Background())
-		if err != nil {
-			logging.Error("Cron job failed", zap.Error(err))
-		} else {
-			logging.Info("Cron job completed", zap.Int("new_papers", count))
-			newPapersCounter.Add(float64(count))
-		}
-	})
-	cronScheduler.Start()
+	// Unterbrochene Jobs aus dem letzten Lauf fortsetzen
+	fetchService.ResumeInterrupted(rootCtx)
 
 	logging.Info("Starting server", zap.String("port", cfg.HTTPPort))
 	srv := &http.Server{
@@ -168,33 +324,114 @@ func main() {
 		WriteTimeout:      60 * time.Second,
 		IdleTimeout:       120 * time.Second,
 	}
-	if err := srv.ListenAndServe(); err != nil {
-		logging.Fatal("Failed to run server", zap.Error(err))
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serverErr:
+		logging.Error("Failed to run server", zap.Error(err))
+	case <-signalCtx.Done():
+		logging.Info("Shutdown signal received")
+	}
+
+	shutdown(logging, srv, scheduler, cancelRoot, fetchService, cfg.ShutdownTimeout)
+}
+
+// shutdown beendet den Dienst geordnet: HTTP-Requests auslaufen lassen, Cron stoppen,
+// Root-Kontext abbrechen und auf die Checkpoints der laufenden Fetch-Jobs warten.
+func shutdown(logging *zap.Logger, srv *http.Server, scheduler *services.Scheduler, cancelRoot context.CancelFunc, fetchService *services.FetchService, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logging.Warn("HTTP server shutdown incomplete", zap.Error(err))
+	}
+	cronDone := scheduler.Stop()
+	cancelRoot()
+
+	jobsDone := make(chan struct{})
+	go func() {
+		<-cronDone.Done()
+		fetchService.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+		logging.Info("Shutdown complete")
+	case <-ctx.Done():
+		logging.Warn("Shutdown timeout reached, background jobs may not have checkpointed")
 	}
 }
 
-func setupPaperRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
+func setupPaperRoutes(router *gin.Engine, db *gorm.DB, work *services.WorkService, log *zap.Logger) {
 	rg := router.Group("/papers")
 
 	// Einfacher GET-Endpunkt, um alle Paper abzurufen (ohne Filter)
 	rg.GET("/", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		var papers []models.Paper
-		if err := db.Find(&papers).Error; err != nil {
-			log.Error("Database query for all papers failed", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		page, err := services.Paginate(db.Model(&models.Paper{}), paperListSpec, params, &papers)
+		if err != nil {
+			respondListError(c, log, err, "Database query for all papers failed")
 			return
 		}
-		c.JSON(http.StatusOK, papers)
+		respondList(c, log, papers, page, params)
 	})
 
+	// Strukturierte Filter, gemeinsam für /query und /search
+	type PaperFilter struct {
+		Substance    string   `json:"substance"`
+		Substances   []string `json:"substances"`    // mind. eine der Substanzen (n:m)
+		StudyDesigns []string `json:"study_designs"` // mind. eines der Studiendesigns (Filter-Namen, n:m)
+		TransferN8N  *bool    `json:"transfer_n8n"`
+		CloudStored  *bool    `json:"cloud_stored"`
+		NoPDFFound   *bool    `json:"no_pdf_found"`
+		Provenance   *string  `json:"provenance"` // "snowball" oder "" (Provider-Suche)
+	}
+	applyFilter := func(query *gorm.DB, f PaperFilter) *gorm.DB {
+		if f.Substance != "" {
+			f.Substances = append(f.Substances, f.Substance)
+		}
+		query = query.Scopes(services.PaperClassificationScope(f.Substances, f.StudyDesigns))
+		if f.TransferN8N != nil {
+			query = query.Where("transfer_n8n = ?", *f.TransferN8N)
+		}
+		if f.CloudStored != nil {
+			query = query.Where("cloud_stored = ?", *f.CloudStored)
+		}
+		if f.NoPDFFound != nil {
+			query = query.Where("no_pdf_found = ?", *f.NoPDFFound)
+		}
+		if f.Provenance != nil {
+			query = query.Where("provenance = ?", *f.Provenance)
+		}
+		return query
+	}
+
+	// Queue "rating" für POST /work/claim, filterbar wie /papers/query
+	work.Register(services.RatingQueue(db, func(query *gorm.DB, raw json.RawMessage) (*gorm.DB, error) {
+		var f PaperFilter
+		if err := services.DecodeWorkFilter(raw, &f); err != nil {
+			return nil, err
+		}
+		return applyFilter(query, f), nil
+	}))
+
 	// Neuer, body-gesteuerter Endpunkt für komplexe Abfragen
 	rg.POST("/query", func(c *gin.Context) {
 		type PaperQuery struct {
-			Substance   string `json:"substance"`
-			TransferN8N *bool  `json:"transfer_n8n"`
-			CloudStored *bool  `json:"cloud_stored"`
-			NoPDFFound  *bool  `json:"no_pdf_found"`
-			Limit       int    `json:"limit"`
+			PaperFilter
+			services.ListParams
 		}
 
 		var req PaperQuery
@@ -203,32 +440,89 @@ func setupPaperRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
 			return
 		}
 
-		query := db.Model(&models.Paper{})
+		query := applyFilter(db.Model(&models.Paper{}), req.PaperFilter)
 
-		if req.Substance != "" {
-			query = query.Where("substance = ?", req.Substance)
-		}
-		if req.TransferN8N != nil {
-			query = query.Where("transfer_n8n = ?", *req.TransferN8N)
+		spec := paperListSpec
+		spec.Preloads = []string{"Substances", "MatchedFilters"}
+		var papers []models.Paper
+		page, err := services.Paginate(query, spec, req.ListParams, &papers)
+		if err != nil {
+			respondListError(c, log, err, "Database query for papers failed")
+			return
 		}
-		if req.CloudStored != nil {
-			query = query.Where("cloud_stored = ?", *req.CloudStored)
+		respondList(c, log, papers, page, req.ListParams)
+	})
+
+	// POST - Volltextsuche über Titel, MeSH-Terme und Abstract (nach Relevanz sortiert)
+	rg.POST("/search", func(c *gin.Context) {
+		type PaperSearch struct {
+			services.FullTextQuery
+			PaperFilter
+			services.ListParams
 		}
-		if req.NoPDFFound != nil {
-			query = query.Where("no_pdf_found = ?", *req.NoPDFFound)
+
+		var req PaperSearch
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
 		}
-		if req.Limit > 0 {
-			query = query.Limit(req.Limit)
+
+		spec := paperListSpec
+		spec.Preloads = []string{"Substances", "MatchedFilters"}
+		hits, page, err := services.SearchPapers(applyFilter(db.Model(&models.Paper{}), req.PaperFilter), req.FullTextQuery, spec, req.ListParams)
+		if err != nil {
+			respondListError(c, log, err, "Full-text search for papers failed")
+			return
 		}
+		respondList(c, log, hits, page, req.ListParams)
+	})
 
-		var papers []models.Paper
-		if err := query.Order("created_at desc").Find(&papers).Error; err != nil {
-			log.Error("Database query for papers failed", zap.Error(err))
+	// GET - Alle Identifier (Aliase) eines Papers
+	rg.GET("/:id/identifiers", func(c *gin.Context) {
+		var paper models.Paper
+		if err := db.First(&paper, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "paper not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 			return
 		}
+		var aliases []models.PaperIdentifier
+		if err := db.Where("paper_id = ?", paper.ID).Order("kind, value").Find(&aliases).Error; err != nil {
+			log.Error("Failed to load paper identifiers", zap.Uint("id", paper.ID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"paper_id": paper.ID, "identifiers": aliases})
+	})
 
-		c.JSON(http.StatusOK, papers)
+	// POST - Duplikate in ein kanonisches Paper zusammenführen
+	rg.POST("/merge", func(c *gin.Context) {
+		var req struct {
+			CanonicalID  uint   `json:"canonical_id" binding:"required"`
+			DuplicateIDs []uint `json:"duplicate_ids" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "canonical_id and duplicate_ids required"})
+			return
+		}
+		paper, err := services.MergePapers(db, req.CanonicalID, req.DuplicateIDs)
+		if err != nil {
+			if errors.Is(err, services.ErrNothingToMerge) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "paper not found"})
+				return
+			}
+			log.Error("Failed to merge papers", zap.Uint("canonical_id", req.CanonicalID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge papers"})
+			return
+		}
+		log.Info("Papers merged", zap.Uint("canonical_id", paper.ID), zap.Uints("duplicate_ids", req.DuplicateIDs))
+		c.JSON(http.StatusOK, paper)
 	})
 
 	// PUT-Endpunkt zum Aktualisieren bleibt gleich
@@ -260,830 +554,2927 @@ func setupPaperRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update paper"})
 			return
 		}
+		// Geänderte PMID/DOI/PMCID als Aliase übernehmen
+		if duplicates, err := services.RegisterPaperIdentifiers(db, &paper); err != nil {
+			log.Warn("Failed to register paper identifiers", zap.Uint("id", paper.ID), zap.Error(err))
+		} else if len(duplicates) > 0 {
+			log.Warn("Updated paper shares identifiers with other papers", zap.Uint("id", paper.ID), zap.Uints("duplicate_ids", duplicates))
+		}
 
 		c.JSON(http.StatusOK, paper)
 	})
 }
 
-func setupSubstanceRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
+func setupSubstanceRoutes(router *gin.Engine, db *gorm.DB, scheduler *services.Scheduler, log *zap.Logger) {
 	rg := router.Group("/substances")
-	rg.POST("/", func(c *gin.Context) {
+
+	// Eingabe für POST/PUT: Pointer-Felder, damit nur gesendete Felder geändert werden
+	type SubstanceInput struct {
+		Name       *string `json:"name"`
+		Active     *bool   `json:"active"`
+		Priority   *int    `json:"priority"`
+		Schedule   *string `json:"schedule"`
+		MaxResults *int    `json:"max_results"`
+		FilterIDs  *[]uint `json:"filter_ids"`
+	}
+
+	// applyInput übernimmt die Eingabe in sub und ersetzt ggf. das Filter-Set
+	applyInput := func(tx *gorm.DB, sub *models.Substance, in SubstanceInput) (int, error) {
+		updates := map[string]any{}
+		if in.Name != nil {
+			if strings.TrimSpace(*in.Name) == "" {
+				return http.StatusBadRequest, errors.New("name must not be empty")
+			}
+			updates["name"] = strings.TrimSpace(*in.Name)
+		}
+		if in.Active != nil {
+			updates["active"] = *in.Active
+		}
+		if in.Priority != nil {
+			updates["priority"] = *in.Priority
+		}
+		if in.Schedule != nil {
+			schedule := strings.TrimSpace(*in.Schedule)
+			if schedule != "" {
+				if err := services.ValidateSchedule(schedule); err != nil {
+					return http.StatusBadRequest, fmt.Errorf("invalid schedule: %w", err)
+				}
+			}
+			updates["schedule"] = schedule
+		}
+		if in.MaxResults != nil {
+			if *in.MaxResults < 0 {
+				return http.StatusBadRequest, errors.New("max_results must be >= 0")
+			}
+			updates["max_results"] = *in.MaxResults
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(sub).Updates(updates).Error; err != nil {
+				if isDuplicateKey(tx, err) {
+					return http.StatusConflict, errors.New("substance name already exists")
+				}
+				return http.StatusInternalServerError, err
+			}
+		}
+		if in.FilterIDs != nil {
+			var filters []models.SearchFilter
+			if len(*in.FilterIDs) > 0 {
+				if err := tx.Find(&filters, *in.FilterIDs).Error; err != nil {
+					return http.StatusInternalServerError, err
+				}
+				if len(filters) != len(*in.FilterIDs) {
+					return http.StatusBadRequest, errors.New("unknown filter id in filter_ids")
+				}
+			}
+			if err := tx.Model(sub).Association("Filters").Replace(filters); err != nil {
+				return http.StatusInternalServerError, err
+			}
+			// Das Filter-Set gehört zum Stand der Substanz, den andere Replikate über updated_at abgleichen
+			if err := tx.Model(sub).UpdateColumn("updated_at", time.Now()).Error; err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		return http.StatusOK, nil
+	}
+
+	// Nach Änderungen Zeitpläne neu laden und aktuellen Stand ausliefern
+	respond := func(c *gin.Context, status int, id uint) {
+		if err := scheduler.Reload(); err != nil {
+			log.Error("Failed to reload substance schedules", zap.Error(err))
+		}
 		var sub models.Substance
-		if err := c.ShouldBindJSON(&sub); err != nil {
+		if err := db.Preload("Filters").First(&sub, id).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(status, sub)
+	}
+
+	rg.POST("/", func(c *gin.Context) {
+		var in SubstanceInput
+		if err := c.ShouldBindJSON(&in); err != nil || in.Name == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
-		if err := db.Create(&sub).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create substance"})
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		var sub models.Substance
+		status := http.StatusCreated
+		err := db.Transaction(func(tx *gorm.DB) error {
+			sub = models.Substance{Name: name}
+			if err := tx.Create(&sub).Error; err != nil {
+				if isDuplicateKey(tx, err) {
+					status = http.StatusConflict
+					return errors.New("substance name already exists")
+				}
+				status = http.StatusInternalServerError
+				return err
+			}
+			code, err := applyInput(tx, &sub, in)
+			if err != nil {
+				status = code
+			}
+			return err
+		})
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Error("Failed to create substance", zap.Error(err))
+				c.JSON(status, gin.H{"error": "failed to create substance"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusCreated, sub)
+		respond(c, http.StatusCreated, sub.ID)
 	})
 	rg.GET("/", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		var subs []models.Substance
-		if err := db.Find(&subs).Error; err != nil {
+		page, err := services.Paginate(db.Model(&models.Substance{}), substanceListSpec, params, &subs)
+		if err != nil {
+			respondListError(c, log, err, "Database query for substances failed")
+			return
+		}
+		respondList(c, log, subs, page, params)
+	})
+	rg.GET("/:id", func(c *gin.Context) {
+		var sub models.Substance
+		if err := db.Preload("Filters").First(&sub, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 			return
 		}
-		c.JSON(http.StatusOK, subs)
+		c.JSON(http.StatusOK, sub)
 	})
-}
-
-func setupSearchFilterRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
-	rg := router.Group("/search-filters")
-	rg.POST("/", func(c *gin.Context) {
-		var filter models.SearchFilter
-		if err := c.ShouldBindJSON(&filter); err != nil {
+	rg.PUT("/:id", func(c *gin.Context) {
+		var sub models.Substance
+		if err := db.First(&sub, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		var in SubstanceInput
+		if err := c.ShouldBindJSON(&in); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
-		if err := db.Create(&filter).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create filter"})
+		status := http.StatusOK
+		err := db.Transaction(func(tx *gorm.DB) error {
+			code, err := applyInput(tx, &sub, in)
+			status = code
+			return err
+		})
+		if err != nil {
+			if status == http.StatusInternalServerError {
+				log.Error("Failed to update substance", zap.Uint("id", sub.ID), zap.Error(err))
+				c.JSON(status, gin.H{"error": "failed to update substance"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusCreated, filter)
+		respond(c, http.StatusOK, sub.ID)
 	})
-	rg.GET("/", func(c *gin.Context) {
-		var filters []models.SearchFilter
-		if err := db.Find(&filters).Error; err != nil {
+	rg.DELETE("/:id", func(c *gin.Context) {
+		var sub models.Substance
+		if err := db.First(&sub, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 			return
 		}
-		c.JSON(http.StatusOK, filters)
-	})
-}
-
-func setupSearchRoutes(router *gin.Engine, fetchService *services.FetchService) {
-	rg := router.Group("/search")
-	rg.POST("/all", func(c *gin.Context) {
-		go func() {
-			count, err := fetchService.RunAllSubstances(context.Background())
-			if err != nil {
-				fetchService.Logger.Error("Async all-substance fetch failed", zap.Error(err))
-			} else {
-				newPapersCounter.Add(float64(count))
-				fetchService.Logger.Info("Async all-substance fetch completed", zap.Int("total_new_papers", count))
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&sub).Association("Filters").Clear(); err != nil {
+				return err
 			}
-		}()
-		c.JSON(http.StatusAccepted, gin.H{"message": "Search for all substances triggered."})
-	})
-	rg.POST("/substance/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var sub models.Substance
-		if err := fetchService.DB.First(&sub, id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
+			if err := tx.Where("substance_id = ?", sub.ID).Delete(&models.PaperSubstance{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&sub).Error
+		})
+		if err != nil {
+			log.Error("Failed to delete substance", zap.Uint("id", sub.ID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete substance"})
 			return
 		}
-		var filters []models.SearchFilter
-		fetchService.DB.Find(&filters)
-
-		go func() {
-			count, err := fetchService.RunForSubstance(context.Background(), sub, filters)
-			if err != nil {
-				fetchService.Logger.Error("Async single fetch failed", zap.Error(err))
-			} else {
-				newPapersCounter.Add(float64(count))
-				fetchService.Logger.Info("Async single fetch completed", zap.Int("new_papers", count), zap.String("substance", sub.Name))
-			}
-		}()
-		c.JSON(http.StatusAccepted, gin.H{"message": fmt.Sprintf("Search for substance %s triggered.", sub.Name)})
+		if err := scheduler.Reload(); err != nil {
+			log.Error("Failed to reload substance schedules", zap.Error(err))
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "substance deleted", "id": sub.ID})
 	})
 }
 
-// setupGraphRoutes konfiguriert Paper-Graph-Endpoints
-func setupGraphRoutes(router *gin.Engine, rawDB *gorm.DB, log *zap.Logger) {
-	rg := router.Group("/graph/paper-links")
+func setupSearchFilterRoutes(router *gin.Engine, db *gorm.DB, fetchService *services.FetchService, log *zap.Logger) {
+	rg := router.Group("/search-filters")
 
-	// DOI/PMID Normalisierung (einfach; optional: robustere Varianten)
-	doiNorm := func(s string) string {
-		s = strings.TrimSpace(strings.ToLower(s))
-		// Entferne URL-Präfixe
-		s = strings.TrimPrefix(s, "https://doi.org/")
-		s = strings.TrimPrefix(s, "http://doi.org/")
-		s = strings.TrimPrefix(s, "doi:")
-		return strings.TrimSpace(s)
-	}
-	pmidNorm := func(s string) string {
-		s = strings.TrimSpace(s)
-		// nur Ziffern extrahieren
-		var out strings.Builder
-		for _, r := range s {
-			if r >= '0' && r <= '9' {
-				out.WriteRune(r)
-			}
+	// POST - Dry-Run: Filter gegen alle Provider testen, ohne zu ingestieren
+	rg.POST("/preview", func(c *gin.Context) {
+		var req struct {
+			Substance   string `json:"substance"`
+			FilterQuery string `json:"filter_query"`
+			FilterID    uint   `json:"filter_id"`   // alternativ: bestehenden Filter testen
+			SampleSize  int    `json:"sample_size"` // Default 10, max 50
+			MaxIDs      int    `json:"max_ids"`     // Default 5000, max pubmed.MaxESearchIDs
 		}
-		return out.String()
-	}
-
-	type LinkInput struct {
-		Source struct {
-			DOI  string `json:"doi"`
-			PMID string `json:"pmid"`
-		} `json:"source"`
-		Citations []struct {
-			DOI         string         `json:"doi"`
-			PMID        string         `json:"pmid"`
-			Evidence    map[string]any `json:"evidence"`
-			TargetTable string         `json:"target_table"`
-		} `json:"citations"`
-		SourceTable string `json:"source_table"`
-	}
-
-	// POST - Upsert links
-	rg.POST("/upsert", func(c *gin.Context) {
-		var req LinkInput
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
-		srcDOI, srcPMID := doiNorm(req.Source.DOI), pmidNorm(req.Source.PMID)
-		if srcDOI == "" && srcPMID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "source doi or pmid required"})
+		req.Substance = strings.TrimSpace(req.Substance)
+		if req.Substance == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "substance is required"})
 			return
 		}
-		type upResult struct {
-			Inserted int
-			Updated  int
-		}
-		res := upResult{}
-		for _, cit := range req.Citations {
-			tgtDOI, tgtPMID := doiNorm(cit.DOI), pmidNorm(cit.PMID)
-			if tgtDOI == "" && tgtPMID == "" {
-				continue
-			}
-
-			link := models.PaperLink{
-				SourceDOINorm: srcDOI, SourcePMIDNorm: srcPMID,
-				TargetDOINorm: tgtDOI, TargetPMIDNorm: tgtPMID,
-				SourceDOI: req.Source.DOI, SourcePMID: req.Source.PMID,
-				TargetDOI: cit.DOI, TargetPMID: cit.PMID,
-				SourceTable: req.SourceTable, TargetTable: cit.TargetTable,
-			}
-			// Evidence mergen (bestehende JSON ergänzen)
-			if len(cit.Evidence) > 0 {
-				b, _ := json.Marshal(cit.Evidence)
-				link.Evidence = b
-			}
-			// Upsert auf Unique-Edge
-			if err := rawDB.Clauses(clause.OnConflict{
-				Columns: []clause.Column{{Name: "source_doi_norm"}, {Name: "source_pmid_norm"}, {Name: "target_doi_norm"}, {Name: "target_pmid_norm"}},
-				DoUpdates: clause.Assignments(map[string]any{
-					"source_doi":   link.SourceDOI,
-					"source_pmid":  link.SourcePMID,
-					"target_doi":   link.TargetDOI,
-					"target_pmid":  link.TargetPMID,
-					"source_table": link.SourceTable,
-					"target_table": link.TargetTable,
-					"evidence":     link.Evidence,
-					"updated_at":   gorm.Expr("NOW()"),
-				}),
-			}).Create(&link).Error; err != nil {
-				log.Error("Failed to upsert paper link", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		if strings.TrimSpace(req.FilterQuery) == "" && req.FilterID != 0 {
+			var filter models.SearchFilter
+			if err := db.First(&filter, req.FilterID).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "filter not found"})
 				return
 			}
-			// GORM liefert RowsAffected in db-Objekt, hier approximieren wir Insert/Update nicht fein-granular
-			res.Updated++
+			req.FilterQuery = filter.FilterQuery
+		}
+		if strings.TrimSpace(req.FilterQuery) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter_query or filter_id is required"})
+			return
 		}
-		c.JSON(http.StatusOK, gin.H{"success": true, "updated": res.Updated, "inserted": res.Inserted})
+		if req.SampleSize <= 0 {
+			req.SampleSize = 10
+		}
+		req.SampleSize = min(req.SampleSize, 50)
+		if req.MaxIDs <= 0 {
+			req.MaxIDs = 5000
+		}
+		req.MaxIDs = min(req.MaxIDs, pubmed.MaxESearchIDs)
+
+		preview, err := fetchService.PreviewFilter(c.Request.Context(), req.Substance, req.FilterQuery, req.MaxIDs, req.SampleSize)
+		if err != nil {
+			log.Error("Search filter preview failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "preview failed"})
+			return
+		}
+		c.JSON(http.StatusOK, preview)
 	})
 
-	// GET by DOI
-	rg.GET("/by-doi/:doi", func(c *gin.Context) {
-		doi := doiNorm(c.Param("doi"))
-		if doi == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doi"})
+	rg.POST("/", func(c *gin.Context) {
+		var filter models.SearchFilter
+		if err := c.ShouldBindJSON(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
-		var links []models.PaperLink
-		if err := rawDB.Where("source_doi_norm = ? OR target_doi_norm = ?", doi, doi).Find(&links).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		if err := db.Create(&filter).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create filter"})
 			return
 		}
-		c.JSON(http.StatusOK, links)
+		c.JSON(http.StatusCreated, filter)
 	})
-	// GET by PMID
-	rg.GET("/by-pmid/:pmid", func(c *gin.Context) {
-		pmid := pmidNorm(c.Param("pmid"))
-		if pmid == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pmid"})
+	rg.GET("/", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		var links []models.PaperLink
-		if err := rawDB.Where("source_pmid_norm = ? OR target_pmid_norm = ?", pmid, pmid).Find(&links).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		var filters []models.SearchFilter
+		page, err := services.Paginate(db.Model(&models.SearchFilter{}), searchFilterListSpec, params, &filters)
+		if err != nil {
+			respondListError(c, log, err, "Database query for search filters failed")
 			return
 		}
-		c.JSON(http.StatusOK, links)
+		respondList(c, log, filters, page, params)
 	})
-}
-
-func setupRatedPaperRoutes(router *gin.Engine, ratedDB *gorm.DB, rawDB *gorm.DB, log *zap.Logger) {
-	rg := router.Group("/rated-papers")
-	saveRatedPaperHandler := func(c *gin.Context) {
-		// Rohdaten lesen, damit wir ggf. pmid nutzen können
-		raw := map[string]any{}
-		if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
-			log.Error("Invalid request body (raw)", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	rg.GET("/:id", func(c *gin.Context) {
+		var filter models.SearchFilter
+		if err := db.First(&filter, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "filter not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 			return
 		}
-		// Helper: Coercion
-		coerceString := func(v any) string {
-			switch t := v.(type) {
-			case string:
-				return strings.TrimSpace(t)
-			case float64:
-				return strings.TrimSpace(strconv.FormatFloat(t, 'f', -1, 64))
-			case int:
-				return strings.TrimSpace(strconv.Itoa(t))
-			case bool:
-				if t {
-					return "true"
-				}
-				return "false"
-			default:
-				return ""
+		c.JSON(http.StatusOK, filter)
+	})
+	rg.PUT("/:id", func(c *gin.Context) {
+		var filter models.SearchFilter
+		if err := db.First(&filter, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "filter not found"})
+				return
 			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
 		}
-		coerceFloat := func(v any) float64 {
-			switch t := v.(type) {
-			case float64:
-				return t
-			case string:
-				s := strings.TrimSpace(t)
-				if s == "" {
-					return 0
-				}
-				if f, err := strconv.ParseFloat(s, 64); err == nil {
-					return f
-				}
-				return 0
-			case int:
-				return float64(t)
-			default:
-				return 0
-			}
+		var req struct {
+			Name        *string `json:"name"`
+			FilterQuery *string `json:"filter_query"`
 		}
-		coerceBool := func(v any) bool {
-			switch t := v.(type) {
-			case bool:
-				return t
-			case string:
-				s := strings.ToLower(strings.TrimSpace(t))
-				return s == "true" || s == "1" || s == "yes" || s == "on"
-			case float64:
-				return t != 0
-			case int:
-				return t != 0
-			default:
-				return false
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		updates := map[string]any{}
+		if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+			updates["name"] = strings.TrimSpace(*req.Name)
+		}
+		if req.FilterQuery != nil && strings.TrimSpace(*req.FilterQuery) != "" {
+			updates["filter_query"] = *req.FilterQuery
+		}
+		if len(updates) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
+			return
+		}
+		if err := db.Model(&filter).Updates(updates).Error; err != nil {
+			log.Error("Failed to update search filter", zap.Uint("id", filter.ID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update filter"})
+			return
+		}
+		c.JSON(http.StatusOK, filter)
+	})
+	rg.DELETE("/:id", func(c *gin.Context) {
+		var filter models.SearchFilter
+		if err := db.First(&filter, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "filter not found"})
+				return
 			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
 		}
-
-		// DOI direkt oder via PMID/pmid_pdf_id aus rawDB ermitteln
-		doi := coerceString(raw["doi"])
-		if doi == "" {
-			pmid := coerceString(raw["pmid"])
-			if pmid == "" {
-				pmid = coerceString(raw["pmid_pdf_id"])
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Zuordnungen zu Substanzen entfernen (Join-Tabelle)
+			if err := tx.Exec("DELETE FROM substance_search_filters WHERE search_filter_id = ?", filter.ID).Error; err != nil {
+				return err
 			}
-			if pmid != "" {
-				var paper models.Paper
-				if err := rawDB.Where("pmid = ?", pmid).First(&paper).Error; err == nil {
-					doi = strings.TrimSpace(paper.DOI)
-				}
+			if err := tx.Where("search_filter_id = ?", filter.ID).Delete(&models.PaperFilter{}).Error; err != nil {
+				return err
 			}
-		}
-		if doi == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "doi is required (or provide pmid to resolve)"})
+			return tx.Delete(&filter).Error
+		})
+		if err != nil {
+			log.Error("Failed to delete search filter", zap.Uint("id", filter.ID), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete filter"})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "filter deleted", "id": filter.ID})
+	})
+}
 
-		// RatedPaper aus raw map befüllen (tolerant gegenüber Typen)
-		ratedPaper := models.RatedPaper{
-			DOI:              doi,
-			S3Link:           coerceString(raw["s3_link"]),
-			Rating:           coerceFloat(raw["rating"]),
-			ConfidenceScore:  coerceFloat(raw["confidence_score"]),
-			Category:         coerceString(raw["category"]),
-			AiSummary:        coerceString(raw["ai_summary"]),
-			KeyFindings:      coerceString(raw["key_findings"]),
-			StudyStrengths:   coerceString(raw["study_strengths"]),
-			StudyLimitations: coerceString(raw["study_limitations"]),
-			ContentIdea:      coerceString(raw["content_idea"]),
-			ContentStatus:    coerceString(raw["content_status"]),
-			ContentURL:       coerceString(raw["content_url"]),
-			Processed:        coerceBool(raw["processed"]),
-			AddedRag:         coerceBool(raw["added_rag"]),
-			Outline:          coerceString(raw["outline"]),
-			Citations:        coerceString(raw["citations"]),
-			DeepResearch:     coerceString(raw["deep_research"]),
+func setupSearchRoutes(router *gin.Engine, rootCtx context.Context, fetchService *services.FetchService, snowball *services.SnowballService) {
+	rg := router.Group("/search")
+
+	// Antwort, wenn bereits ein Lauf aktiv ist (ggf. auf einem anderen Replikat)
+	respondIfRunning := func(c *gin.Context, err error) bool {
+		var running *services.JobRunningError
+		if !errors.As(err, &running) {
+			return false
 		}
+		c.JSON(http.StatusConflict, gin.H{"error": "fetch already running", "lock_key": running.Key, "job": running.Job})
+		return true
+	}
 
-		// Versuche: vorhandenen Datensatz per DOI finden und selektiv updaten; sonst neu erstellen
-		var existing models.RatedPaper
-		findErr := ratedDB.Where("doi = ?", ratedPaper.DOI).First(&existing).Error
-		if findErr == nil {
-			updates := map[string]any{
-				"s3_link":           ratedPaper.S3Link,
-				"rating":            ratedPaper.Rating,
-				"confidence_score":  ratedPaper.ConfidenceScore,
-				"category":          ratedPaper.Category,
-				"ai_summary":        ratedPaper.AiSummary,
-				"key_findings":      ratedPaper.KeyFindings,
-				"study_strengths":   ratedPaper.StudyStrengths,
-				"study_limitations": ratedPaper.StudyLimitations,
-				"content_idea":      ratedPaper.ContentIdea,
-				"content_status":    ratedPaper.ContentStatus,
-				"content_url":       ratedPaper.ContentURL,
-				"processed":         ratedPaper.Processed,
-				"added_rag":         ratedPaper.AddedRag,
-				// neue Content-Felder
-				"outline":       ratedPaper.Outline,
-				"citations":     ratedPaper.Citations,
-				"deep_research": ratedPaper.DeepResearch,
-			}
-			if err := ratedDB.Model(&existing).Updates(updates).Error; err != nil {
-				log.Error("Failed to update existing rated paper", zap.String("doi", ratedPaper.DOI), zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
-				return
+	rg.POST("/all", func(c *gin.Context) {
+		job, err := fetchService.StartAllSubstances(rootCtx, services.TriggerAPI, func(count int, err error) {
+			if err != nil {
+				fetchService.Logger.Error("Async all-substance fetch failed", zap.Error(err))
+			} else {
+				newPapersCounter.Add(float64(count))
+				fetchService.Logger.Info("Async all-substance fetch completed", zap.Int("total_new_papers", count))
 			}
-			// lade aktualisierten Datensatz
-			var saved models.RatedPaper
-			_ = ratedDB.Where("doi = ?", ratedPaper.DOI).First(&saved).Error
-			c.JSON(http.StatusOK, saved)
+		})
+		if respondIfRunning(c, err) {
 			return
 		}
-		if !errors.Is(findErr, gorm.ErrRecordNotFound) {
-			log.Error("Failed to query rated paper by DOI", zap.String("doi", ratedPaper.DOI), zap.Error(findErr))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
+		if err != nil {
+			fetchService.Logger.Error("Failed to start all-substance fetch", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start fetch"})
 			return
 		}
-
-		if err := ratedDB.Create(&ratedPaper).Error; err != nil {
-			log.Error("Failed to create rated paper", zap.String("doi", ratedPaper.DOI), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
+		c.JSON(http.StatusAccepted, gin.H{"message": "Search for all substances triggered.", "job": job})
+	})
+	rg.POST("/substance/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var sub models.Substance
+		if err := fetchService.DB.First(&sub, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "substance not found"})
 			return
 		}
-		c.JSON(http.StatusOK, ratedPaper)
-	}
-	// Unterstütze beide Pfade: mit und ohne abschließenden Slash
-	rg.POST("/", saveRatedPaperHandler)
-	rg.POST("", saveRatedPaperHandler)
-	// NEU: General Update Endpoint
-	rg.PATCH("/", func(c *gin.Context) {
-		// Payload mit allen optionalen Feldern
-		var payload struct {
-			DOI           string  `json:"doi"`
-			PMID          string  `json:"pmid"`
-			ContentStatus *string `json:"content_status"`
-			ContentURL    *string `json:"content_url"`
-			Processed     *bool   `json:"processed"`
-			AddedRag      *bool   `json:"added_rag"`
-			Outline       *string `json:"outline"`
-			Citations     *string `json:"citations"`
-			DeepResearch  *string `json:"deep_research"`
+		job, err := fetchService.StartSubstance(rootCtx, sub, services.TriggerAPI, func(count int, err error) {
+			if err != nil {
+				fetchService.Logger.Error("Async single fetch failed", zap.Error(err))
+			} else {
+				newPapersCounter.Add(float64(count))
+				fetchService.Logger.Info("Async single fetch completed", zap.Int("new_papers", count), zap.String("substance", sub.Name))
+			}
+		})
+		if respondIfRunning(c, err) {
+			return
 		}
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid fields"})
+		if err != nil {
+			fetchService.Logger.Error("Failed to start single fetch", zap.String("substance", sub.Name), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start fetch"})
 			return
 		}
+		c.JSON(http.StatusAccepted, gin.H{"message": fmt.Sprintf("Search for substance %s triggered.", sub.Name), "job": job})
+	})
 
-		// Map nur mit den mitgesendeten Feldern befüllen
-		updates := map[string]interface{}{}
-		if payload.ContentStatus != nil {
-			updates["content_status"] = *payload.ContentStatus
+	// POST - Snowball-Suche: Referenzen (backward) und zitierende Arbeiten (forward) hoch bewerteter rated_papers
+	rg.POST("/snowball", func(c *gin.Context) {
+		var opts services.SnowballOptions
+		if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
 		}
-		if payload.ContentURL != nil {
-			updates["content_url"] = *payload.ContentURL
+		job, err := snowball.Start(rootCtx, opts, services.TriggerAPI, func(result *services.SnowballResult, err error) {
+			if err != nil {
+				fetchService.Logger.Error("Async snowball search failed", zap.Error(err))
+			} else {
+				newPapersCounter.Add(float64(result.NewPapers))
+			}
+		})
+		if respondIfRunning(c, err) {
+			return
 		}
-		if payload.Processed != nil {
-			updates["processed"] = *payload.Processed
+		if errors.Is(err, services.ErrInvalidSnowball) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		if payload.AddedRag != nil {
-			updates["added_rag"] = *payload.AddedRag
+		if err != nil {
+			fetchService.Logger.Error("Failed to start snowball search", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start snowball search"})
+			return
 		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Snowball search triggered.", "job": job})
+	})
 
-		if payload.Outline != nil {
-			updates["outline"] = *payload.Outline
+	// GET - Fetch-Jobs auflisten (optional ?status=running&lock_key=snowball)
+	rg.GET("/jobs", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		if payload.Citations != nil {
-			updates["citations"] = *payload.Citations
+		query := fetchService.DB.Model(&models.FetchJob{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
 		}
-		if payload.DeepResearch != nil {
-			updates["deep_research"] = *payload.DeepResearch
+		if key := c.Query("lock_key"); key != "" {
+			query = query.Where("lock_key = ?", key)
+		}
+		var jobs []models.FetchJob
+		page, err := services.Paginate(query, fetchJobListSpec, params, &jobs)
+		if err != nil {
+			respondListError(c, fetchService.Logger, err, "Database query for fetch jobs failed")
+			return
 		}
+		respondList(c, fetchService.Logger, jobs, page, params)
+	})
+}
 
-		if payload.Outline != nil {
-			updates["outline"] = *payload.Outline
+// setupIdentifierRoutes konfiguriert die Auflösung gemischter Identifier (DOI, PMID, PMCID, URLs, Zitate)
+func setupIdentifierRoutes(router *gin.Engine, rawDB *gorm.DB, ratedDB *gorm.DB, log *zap.Logger) {
+	resolver := services.NewIdentifierResolver(rawDB, ratedDB)
+	rg := router.Group("/identifiers")
+
+	rg.POST("/resolve", func(c *gin.Context) {
+		var req struct {
+			Inputs []string `json:"inputs" binding:"required"`
 		}
-		if payload.Citations != nil {
-			updates["citations"] = *payload.Citations
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "inputs (array of strings) required"})
+			return
 		}
-		if payload.DeepResearch != nil {
-			updates["deep_research"] = *payload.DeepResearch
+		if len(req.Inputs) > services.MaxResolveInputs {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d inputs per request", services.MaxResolveInputs)})
+			return
 		}
-
-		if len(updates) == 0 {
+		results, err := resolver.Resolve(req.Inputs)
+		if err != nil {
+			log.Error("Failed to resolve identifiers", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		resolved := 0
+		for _, r := range results {
+			if r.Resolved {
+				resolved++
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"results":    results,
+			"resolved":   resolved,
+			"unresolved": len(results) - resolved,
+		})
+	})
+}
+
+// setupEmbeddingRoutes konfiguriert die semantische Ähnlichkeitssuche und die Embedding-Verwaltung
+func setupEmbeddingRoutes(router *gin.Engine, rootCtx context.Context, embeddingService *services.EmbeddingService, log *zap.Logger) {
+	// POST - "More like this" zu einem Paper oder semantische Freitextsuche
+	router.POST("/papers/similar", func(c *gin.Context) {
+		var req services.SimilarRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		result, err := embeddingService.Similar(c.Request.Context(), req)
+		switch {
+		case errors.Is(err, services.ErrEmbeddingsDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidSimilarRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "paper not found"})
+		case err != nil:
+			log.Error("Similarity search failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "similarity search failed"})
+		default:
+			c.JSON(http.StatusOK, result)
+		}
+	})
+
+	rg := router.Group("/embeddings")
+
+	// GET - Anzahl gespeicherter Embeddings und laufende Aktualisierung
+	rg.GET("/status", func(c *gin.Context) {
+		status, err := embeddingService.Status()
+		if err != nil {
+			log.Error("Failed to load embedding status", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	})
+
+	// POST - Neue und geänderte Papers/Analysen im Hintergrund einbetten
+	rg.POST("/refresh", func(c *gin.Context) {
+		err := embeddingService.StartRefresh(rootCtx, func(count int, err error) {
+			if err != nil {
+				log.Error("Async embedding refresh failed", zap.Error(err))
+			}
+		})
+		switch {
+		case errors.Is(err, services.ErrEmbeddingsDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmbeddingRefreshRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusAccepted, gin.H{"message": "Embedding refresh triggered."})
+		}
+	})
+}
+
+// setupDuplicateRoutes konfiguriert die Vorschläge inhaltlicher Duplikate (Scan, Annahme, Ablehnung)
+func setupDuplicateRoutes(router *gin.Engine, rootCtx context.Context, detector *services.DuplicateDetector, log *zap.Logger) {
+	rg := router.Group("/duplicates")
+
+	respondDecisionError := func(c *gin.Context, err error, msg string) {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "cluster or paper not found"})
+		case errors.Is(err, services.ErrClusterResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidDuplicateDecision), errors.Is(err, services.ErrNothingToMerge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Error(msg, zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+	}
+
+	// GET - Cluster auflisten (optional ?status=pending&substance=curcumin)
+	rg.GET("/", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query := detector.DB.Model(&models.DuplicateCluster{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if substance := c.Query("substance"); substance != "" {
+			query = query.Where("substance = ?", substance)
+		}
+		var clusters []models.DuplicateCluster
+		page, err := services.Paginate(query, duplicateClusterListSpec, params, &clusters)
+		if err != nil {
+			respondListError(c, log, err, "Database query for duplicate clusters failed")
+			return
+		}
+		respondList(c, log, clusters, page, params)
+	})
+
+	// GET - Cluster mit den beteiligten Papern
+	rg.GET("/:id", func(c *gin.Context) {
+		var cluster models.DuplicateCluster
+		if err := detector.DB.First(&cluster, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		var papers []models.Paper
+		if err := detector.DB.Where("id IN ?", cluster.PaperIDs).Order("id").Find(&papers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"cluster": cluster, "papers": papers})
+	})
+
+	// POST - Scan im Hintergrund starten (optional nur eine Substanz, eigener Schwellwert)
+	rg.POST("/scan", func(c *gin.Context) {
+		var req struct {
+			Substance string  `json:"substance"`
+			Threshold float64 `json:"threshold"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if req.Threshold < 0 || req.Threshold > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be between 0 and 1"})
+			return
+		}
+		err := detector.StartScan(rootCtx, req.Substance, req.Threshold, func(result *services.DedupScanResult, err error) {
+			if err != nil {
+				log.Error("Async duplicate scan failed", zap.Error(err))
+			}
+		})
+		if errors.Is(err, services.ErrDedupRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Duplicate scan triggered."})
+	})
+
+	// POST - Zusammenführung annehmen
+	rg.POST("/:id/accept", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cluster id"})
+			return
+		}
+		var req struct {
+			CanonicalID uint   `json:"canonical_id"` // Standard: kleinste Paper-ID
+			PaperIDs    []uint `json:"paper_ids"`    // Standard: alle Paper des Clusters
+			Note        string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		cluster, remainder, paper, err := detector.Accept(uint(id), req.CanonicalID, req.PaperIDs, req.Note)
+		if err != nil {
+			respondDecisionError(c, err, "Failed to accept duplicate cluster")
+			return
+		}
+		resp := gin.H{"cluster": cluster, "paper": paper}
+		if remainder != nil {
+			resp["remainder"] = remainder
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// POST - Zusammenführung ablehnen (verschiedene Studien)
+	rg.POST("/:id/reject", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cluster id"})
+			return
+		}
+		var req struct {
+			Note string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		cluster, err := detector.Reject(uint(id), req.Note)
+		if err != nil {
+			respondDecisionError(c, err, "Failed to reject duplicate cluster")
+			return
+		}
+		c.JSON(http.StatusOK, cluster)
+	})
+}
+
+// setupGraphRoutes konfiguriert Paper-Graph-Endpoints
+func setupGraphRoutes(router *gin.Engine, rootCtx context.Context, rawDB *gorm.DB, graphService *services.GraphService, linker *services.CitationLinker, log *zap.Logger) {
+	rg := router.Group("/graph/paper-links")
+
+	type LinkInput struct {
+		Source struct {
+			DOI  string `json:"doi"`
+			PMID string `json:"pmid"`
+		} `json:"source"`
+		Citations []struct {
+			DOI            string                   `json:"doi"`
+			PMID           string                   `json:"pmid"`
+			Evidence       map[string]any           `json:"evidence"`
+			Contexts       []models.CitationContext `json:"contexts"`
+			EvidenceSource string                   `json:"evidence_source"`
+			TargetTable    string                   `json:"target_table"`
+		} `json:"citations"`
+		SourceTable string `json:"source_table"`
+		// Herkunft der Aussagen (Default "n8n"), je Zitation überschreibbar
+		EvidenceSource string `json:"evidence_source"`
+	}
+
+	// POST - Upsert links
+	rg.POST("/upsert", func(c *gin.Context) {
+		var req LinkInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if identifiers.NormalizeDOI(req.Source.DOI) == "" && identifiers.NormalizePMID(req.Source.PMID) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source doi or pmid required"})
+			return
+		}
+		defaultSource := strings.TrimSpace(req.EvidenceSource)
+		if defaultSource == "" {
+			defaultSource = models.EvidenceSourceN8N
+		}
+		counts := map[string]int{}
+		edges := make([]*services.EdgeUpsertResult, 0, len(req.Citations))
+		for _, cit := range req.Citations {
+			if identifiers.NormalizeDOI(cit.DOI) == "" && identifiers.NormalizePMID(cit.PMID) == "" {
+				counts["skipped"]++
+				continue
+			}
+			source := strings.TrimSpace(cit.EvidenceSource)
+			if source == "" {
+				source = defaultSource
+			}
+			evidence := services.EvidenceFromMap(source, cit.Evidence)
+			evidence.Contexts = append(evidence.Contexts, cit.Contexts...)
+			result, err := services.UpsertPaperLink(rawDB, services.EdgeInput{
+				Source:      services.LinkEndpoint{DOI: req.Source.DOI, PMID: req.Source.PMID},
+				Target:      services.LinkEndpoint{DOI: cit.DOI, PMID: cit.PMID},
+				SourceTable: req.SourceTable,
+				TargetTable: cit.TargetTable,
+				Evidence:    []services.EdgeEvidence{evidence},
+			})
+			if err != nil {
+				log.Error("Failed to upsert paper link", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+				return
+			}
+			counts[result.Result]++
+			edges = append(edges, result)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":   true,
+			"inserted":  counts[services.EdgeInserted],
+			"updated":   counts[services.EdgeUpdated],
+			"unchanged": counts[services.EdgeUnchanged],
+			"skipped":   counts["skipped"],
+			"edges":     edges,
+		})
+	})
+
+	// listQuery liest eine kommagetrennte bzw. wiederholte Query-Liste (?intent=a,b&intent=c)
+	listQuery := func(c *gin.Context, name string) []string {
+		var out []string
+		for _, v := range c.QueryArray(name) {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					out = append(out, item)
+				}
+			}
+		}
+		return out
+	}
+	// intentScope filtert Kanten nach ?intent=
+	intentScope := func(c *gin.Context) (func(*gorm.DB) *gorm.DB, bool) {
+		intents := listQuery(c, "intent")
+		for _, intent := range intents {
+			if !slices.Contains(services.CitationIntents, intent) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid intent: " + intent, "allowed": services.CitationIntents})
+				return nil, false
+			}
+		}
+		return func(db *gorm.DB) *gorm.DB {
+			if len(intents) == 0 {
+				return db
+			}
+			return db.Where("intent IN ?", intents)
+		}, true
+	}
+
+	// GET by DOI (?intent=contrasting,supporting)
+	rg.GET("/by-doi/:doi", func(c *gin.Context) {
+		doi := identifiers.NormalizeDOI(c.Param("doi"))
+		if doi == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid doi"})
+			return
+		}
+		scope, ok := intentScope(c)
+		if !ok {
+			return
+		}
+		var links []models.PaperLink
+		if err := rawDB.Preload("Evidences").Scopes(scope).Where("source_doi_norm = ? OR target_doi_norm = ?", doi, doi).Find(&links).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, links)
+	})
+	// GET by PMID (?intent=)
+	rg.GET("/by-pmid/:pmid", func(c *gin.Context) {
+		pmid := identifiers.NormalizePMID(c.Param("pmid"))
+		if pmid == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pmid"})
+			return
+		}
+		scope, ok := intentScope(c)
+		if !ok {
+			return
+		}
+		var links []models.PaperLink
+		if err := rawDB.Preload("Evidences").Scopes(scope).Where("source_pmid_norm = ? OR target_pmid_norm = ?", pmid, pmid).Find(&links).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, links)
+	})
+
+	// POST - Kanten aus Referenzlisten und Zitierungen der Provider abrufen (optional nur bestimmte Paper)
+	rg.POST("/populate", func(c *gin.Context) {
+		var req struct {
+			PaperIDs []uint `json:"paper_ids"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		err := linker.StartRun(rootCtx, req.PaperIDs, func(result *services.LinkRunResult, err error) {
+			if err != nil {
+				log.Error("Async citation linking failed", zap.Error(err))
+			}
+		})
+		if errors.Is(err, services.ErrLinkingRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Error("Failed to start citation linking", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start citation linking"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Citation linking triggered."})
+	})
+
+	// POST - Zitierabsicht aller Kanten mit Zitierkontext neu bestimmen (z.B. nach Änderung der Signalphrasen)
+	rg.POST("/classify", func(c *gin.Context) {
+		changed, err := services.ClassifyPaperLinks(rawDB, true)
+		if err != nil {
+			log.Error("Failed to classify citation intents", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"changed": changed})
+	})
+
+	// Traversierung & Analyse über den gesamten Zitationsgraphen
+	graph := router.Group("/graph")
+
+	respondGraphError := func(c *gin.Context, err error) {
+		switch {
+		case errors.Is(err, services.ErrNodeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidGraphQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Error("Failed to query citation graph", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+	}
+	// lookup löst ?<prefix>doi= / ?<prefix>pmid= zu einem Knoten auf
+	lookup := func(c *gin.Context, g *services.CitationGraph, prefix string) (int, bool) {
+		idx, err := g.Lookup(c.Query(prefix+"doi"), c.Query(prefix+"pmid"))
+		if err != nil {
+			respondGraphError(c, err)
+			return 0, false
+		}
+		return idx, true
+	}
+	intQuery := func(c *gin.Context, name string, def int) (int, bool) {
+		v := c.Query(name)
+		if v == "" {
+			return def, true
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+			return 0, false
+		}
+		return n, true
+	}
+	boolQuery := func(c *gin.Context, name string) (*bool, bool) {
+		v := c.Query(name)
+		if v == "" {
+			return nil, true
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+			return nil, false
+		}
+		return &b, true
+	}
+
+	// GET - Nachbarn bis Tiefe N (?doi=|pmid=&depth=2&direction=out|in|both&intent=contrasting&limit=500)
+	graph.GET("/neighbors", func(c *gin.Context) {
+		g, err := graphService.Graph()
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		idx, ok := lookup(c, g, "")
+		if !ok {
+			return
+		}
+		depth, ok := intQuery(c, "depth", 1)
+		if !ok {
+			return
+		}
+		limit, ok := intQuery(c, "limit", 500)
+		if !ok {
+			return
+		}
+		sub, err := g.Neighbors(idx, depth, c.DefaultQuery("direction", services.DirectionBoth), listQuery(c, "intent"), limit)
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sub)
+	})
+
+	// GET - Kürzester Pfad (?from_doi=|from_pmid=&to_doi=|to_pmid=&direction=out|in|both)
+	graph.GET("/path", func(c *gin.Context) {
+		g, err := graphService.Graph()
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		from, ok := lookup(c, g, "from_")
+		if !ok {
+			return
+		}
+		to, ok := lookup(c, g, "to_")
+		if !ok {
+			return
+		}
+		path, err := g.ShortestPath(from, to, c.DefaultQuery("direction", services.DirectionBoth))
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, path)
+	})
+
+	// GET - Co-Zitation & bibliographische Kopplung (?a_doi=|a_pmid=&b_doi=|b_pmid=)
+	graph.GET("/similarity", func(c *gin.Context) {
+		g, err := graphService.Graph()
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		a, ok := lookup(c, g, "a_")
+		if !ok {
+			return
+		}
+		b, ok := lookup(c, g, "b_")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, g.Similarity(a, b))
+	})
+
+	// GET - Rangliste nach PageRank oder Zitierungen (?metric=pagerank|citations&in_papers=false&in_rated_papers=&min_cited_by=&limit=50)
+	graph.GET("/rankings", func(c *gin.Context) {
+		g, err := graphService.Graph()
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		var (
+			filter services.RankingFilter
+			ok     bool
+		)
+		if filter.InPapers, ok = boolQuery(c, "in_papers"); !ok {
+			return
+		}
+		if filter.InRatedPapers, ok = boolQuery(c, "in_rated_papers"); !ok {
+			return
+		}
+		if filter.MinCitedBy, ok = intQuery(c, "min_cited_by", 0); !ok {
+			return
+		}
+		limit, ok := intQuery(c, "limit", 50)
+		if !ok {
+			return
+		}
+		if limit == 0 || limit > services.MaxGraphNodes {
+			limit = services.MaxGraphNodes
+		}
+		nodes, err := g.Rankings(c.DefaultQuery("metric", "pagerank"), filter, limit)
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"total_nodes": len(g.Nodes), "results": nodes})
+	})
+
+	// GET - Export für Gephi/Cytoscape (?format=graphml|gexf|cytoscape&substance=&min_rating=&depth=1&direction=both&intent=&limit=)
+	graph.GET("/export", func(c *gin.Context) {
+		opts := services.GraphExportOptions{
+			Substance: strings.TrimSpace(c.Query("substance")),
+			Direction: c.DefaultQuery("direction", services.DirectionBoth),
+			Intents:   listQuery(c, "intent"),
+		}
+		var ok bool
+		if opts.Depth, ok = intQuery(c, "depth", 1); !ok {
+			return
+		}
+		if opts.MaxNodes, ok = intQuery(c, "limit", 0); !ok {
+			return
+		}
+		if v := c.Query("min_rating"); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_rating"})
+				return
+			}
+			opts.MinRating = f
+		}
+		format := c.DefaultQuery("format", services.ExportGraphML)
+		if format != services.ExportGraphML && format != services.ExportGEXF && format != services.ExportCytoscape {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be graphml, gexf or cytoscape"})
+			return
+		}
+
+		sub, err := graphService.Export(opts)
+		if err != nil {
+			respondGraphError(c, err)
+			return
+		}
+		if sub.Truncated {
+			c.Header("X-Graph-Truncated", "true")
+		}
+		switch format {
+		case services.ExportCytoscape:
+			c.JSON(http.StatusOK, services.ToCytoscape(sub))
+		case services.ExportGEXF:
+			c.Header("Content-Disposition", `attachment; filename="citations.gexf"`)
+			c.Header("Content-Type", "application/gexf+xml; charset=utf-8")
+			c.Status(http.StatusOK)
+			if err := services.WriteGEXF(c.Writer, sub); err != nil {
+				log.Error("Failed to write GEXF export", zap.Error(err))
+			}
+		default:
+			c.Header("Content-Disposition", `attachment; filename="citations.graphml"`)
+			c.Header("Content-Type", "application/graphml+xml; charset=utf-8")
+			c.Status(http.StatusOK)
+			if err := services.WriteGraphML(c.Writer, sub); err != nil {
+				log.Error("Failed to write GraphML export", zap.Error(err))
+			}
+		}
+	})
+}
+
+func setupRatedPaperRoutes(router *gin.Engine, ratedDB *gorm.DB, rawDB *gorm.DB, assessments *services.AssessmentService, workflow *services.Workflow, ratedPaperSchema services.PayloadSchema, validationMode string, work *services.WorkService, webhooks *services.WebhookService, log *zap.Logger) {
+	rg := router.Group("/rated-papers")
+	// respondValidationError antwortet bei services.ValidationErrors mit 400 und der Fehlerliste
+	respondValidationError := func(c *gin.Context, err error) bool {
+		var verrs services.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrValidation.Error(), "errors": verrs})
+		return true
+	}
+	// saveRatedPaper speichert einen validierten Payload in tx (Upsert per DOI, Revision, Rater-Einschätzung,
+	// Appraisal, Webhook-Ereignis). Eine nicht auflösbare DOI liefert ValidationErrors.
+	saveRatedPaper := func(tx *gorm.DB, raw map[string]any) (*models.RatedPaper, error) {
+		// Helper: Coercion
+		coerceString := func(v any) string {
+			switch t := v.(type) {
+			case string:
+				return strings.TrimSpace(t)
+			case float64:
+				return strings.TrimSpace(strconv.FormatFloat(t, 'f', -1, 64))
+			case int:
+				return strings.TrimSpace(strconv.Itoa(t))
+			case bool:
+				if t {
+					return "true"
+				}
+				return "false"
+			default:
+				return ""
+			}
+		}
+		coerceFloat := func(v any) float64 {
+			switch t := v.(type) {
+			case float64:
+				return t
+			case string:
+				s := strings.TrimSpace(t)
+				if s == "" {
+					return 0
+				}
+				if f, err := strconv.ParseFloat(s, 64); err == nil {
+					return f
+				}
+				return 0
+			case int:
+				return float64(t)
+			default:
+				return 0
+			}
+		}
+		coerceBool := func(v any) bool {
+			switch t := v.(type) {
+			case bool:
+				return t
+			case string:
+				s := strings.ToLower(strings.TrimSpace(t))
+				return s == "true" || s == "1" || s == "yes" || s == "on"
+			case float64:
+				return t != 0
+			case int:
+				return t != 0
+			default:
+				return false
+			}
+		}
+
+		// DOI direkt oder via PMID/pmid_pdf_id aus rawDB ermitteln
+		doi := services.RatedDOI(coerceString(raw["doi"]))
+		if doi == "" {
+			pmid := coerceString(raw["pmid"])
+			if pmid == "" {
+				pmid = coerceString(raw["pmid_pdf_id"])
+			}
+			if pmid != "" {
+				if paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.PMID, Value: pmid}); err == nil {
+					doi = services.RatedDOI(paper.DOI)
+				}
+			}
+		}
+		if doi == "" {
+			return nil, services.ValidationErrors{{Field: "doi", Code: "required", Message: "doi is required (or provide pmid to resolve)"}}
+		}
+
+		// Strukturierte Bewertungsmatrix (optional)
+		var appraisal *models.Appraisal
+		if v, ok := raw["appraisal"]; ok && v != nil {
+			a, err := services.DecodeAppraisal(v, "appraisal")
+			if err != nil {
+				return nil, err
+			}
+			appraisal = a
+		}
+
+		// RatedPaper aus raw map befüllen (tolerant gegenüber Typen)
+		ratedPaper := models.RatedPaper{
+			DOI:              doi,
+			S3Link:           coerceString(raw["s3_link"]),
+			Rating:           coerceFloat(raw["rating"]),
+			ConfidenceScore:  coerceFloat(raw["confidence_score"]),
+			Category:         coerceString(raw["category"]),
+			AiSummary:        coerceString(raw["ai_summary"]),
+			KeyFindings:      coerceString(raw["key_findings"]),
+			StudyStrengths:   coerceString(raw["study_strengths"]),
+			StudyLimitations: coerceString(raw["study_limitations"]),
+			ContentIdea:      coerceString(raw["content_idea"]),
+			ContentStatus:    coerceString(raw["content_status"]),
+			ContentURL:       coerceString(raw["content_url"]),
+			Processed:        coerceBool(raw["processed"]),
+			AddedRag:         coerceBool(raw["added_rag"]),
+			Outline:          coerceString(raw["outline"]),
+			Citations:        coerceString(raw["citations"]),
+			DeepResearch:     coerceString(raw["deep_research"]),
+		}
+		if v, ok := raw["references_json"]; ok && v != nil {
+			ratedPaper.ReferencesJSON, _ = json.Marshal(v)
+		}
+		// Der Content-Status wird nicht überschrieben, sondern als Übergang im Content-Workflow angefordert
+		requestedStatus := ratedPaper.ContentStatus
+		ratedPaper.ContentStatus = ""
+		fromStatus := ""
+
+		// Vorhandenen Datensatz per DOI finden und updaten, sonst neu erstellen; jede Bewertung wird
+		// zusätzlich als unveränderliche Revision mit dem vollständigen Body gespeichert
+		err := func() error {
+			var existing models.RatedPaper
+			findErr := tx.Where("doi = ?", ratedPaper.DOI).First(&existing).Error
+			if findErr == nil {
+				updates := map[string]any{
+					"s3_link":           ratedPaper.S3Link,
+					"rating":            ratedPaper.Rating,
+					"confidence_score":  ratedPaper.ConfidenceScore,
+					"category":          ratedPaper.Category,
+					"ai_summary":        ratedPaper.AiSummary,
+					"key_findings":      ratedPaper.KeyFindings,
+					"study_strengths":   ratedPaper.StudyStrengths,
+					"study_limitations": ratedPaper.StudyLimitations,
+					"content_idea":      ratedPaper.ContentIdea,
+					"content_url":       ratedPaper.ContentURL,
+					"processed":         ratedPaper.Processed,
+					"added_rag":         ratedPaper.AddedRag,
+					// neue Content-Felder
+					"outline":       ratedPaper.Outline,
+					"citations":     ratedPaper.Citations,
+					"deep_research": ratedPaper.DeepResearch,
+				}
+				if ratedPaper.ReferencesJSON != nil {
+					updates["references_json"] = ratedPaper.ReferencesJSON
+				}
+				if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+					return err
+				}
+				fromStatus = existing.ContentStatus
+				// lade aktualisierten Datensatz
+				if err := tx.Where("doi = ?", ratedPaper.DOI).First(&ratedPaper).Error; err != nil {
+					return err
+				}
+			} else if !errors.Is(findErr, gorm.ErrRecordNotFound) {
+				return findErr
+			} else if err := tx.Create(&ratedPaper).Error; err != nil {
+				return err
+			}
+			// Neue Paper starten im angeforderten Zustand; bei bestehenden bleibt ein nicht erlaubter
+			// Wechsel (z.B. "idee" bei einer Neubewertung) folgenlos
+			if requestedStatus != "" && requestedStatus != fromStatus {
+				if fromStatus != "" && !slices.Contains(workflow.Allowed(fromStatus), requestedStatus) {
+					log.Warn("Keeping content status of re-rated paper",
+						zap.String("doi", ratedPaper.DOI), zap.String("status", fromStatus), zap.String("requested", requestedStatus))
+				} else {
+					transition := services.TransitionInput{To: requestedStatus, Actor: coerceString(raw["rater"]), Reason: "rating saved"}
+					if _, err := workflow.Apply(tx, &ratedPaper, ratedPaper.ID, fromStatus, transition); err != nil {
+						if errors.Is(err, services.ErrInvalidTransition) || errors.Is(err, services.ErrUnknownState) {
+							return services.ValidationErrors{{Field: "content_status", Code: "transition", Message: err.Error()}}
+						}
+						return err
+					}
+					if err := tx.First(&ratedPaper, ratedPaper.ID).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if _, _, err := services.RecordRatedPaperRevision(tx, &ratedPaper, raw); err != nil {
+				return err
+			}
+			// Einschätzung des bewertenden Modells (oder des angegebenen Raters) für die Aggregation
+			assessment := services.AIAssessment(&ratedPaper)
+			if rater := coerceString(raw["rater"]); rater != "" {
+				assessment.Rater = rater
+			}
+			if _, err := assessments.Record(tx, &ratedPaper, assessment); err != nil {
+				return err
+			}
+			if appraisal != nil {
+				appraisal.RevisionID = ratedPaper.CurrentRevisionID
+				if err := services.SaveAppraisal(tx, &ratedPaper, appraisal); err != nil {
+					return err
+				}
+			}
+			_, err := webhooks.EmitTx(tx, models.EventRatedPaperSaved, &ratedPaper)
+			return err
+		}()
+		if err != nil {
+			return nil, err
+		}
+		return &ratedPaper, nil
+	}
+
+	saveRatedPaperHandler := func(c *gin.Context) {
+		raw := map[string]any{}
+		if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+			log.Error("Invalid request body (raw)", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		mode := c.DefaultQuery("validation", validationMode)
+		if !slices.Contains(services.ValidationModes, mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation must be one of " + strings.Join(services.ValidationModes, ", ")})
+			return
+		}
+		// quarantine stellt eine ungültige Einreichung im lenient-Modus zurück (202 mit Fehlerliste)
+		quarantine := func(verrs services.ValidationErrors) {
+			entry, err := services.QuarantineRatedPaper(ratedDB, raw, verrs)
+			if err != nil {
+				log.Error("Failed to quarantine rated paper", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
+				return
+			}
+			log.Warn("Rated paper quarantined", zap.Uint("quarantine_id", entry.ID), zap.String("doi", entry.DOI), zap.Int("errors", len(verrs)))
+			c.JSON(http.StatusAccepted, gin.H{"quarantined": true, "quarantine_id": entry.ID, "errors": verrs})
+		}
+
+		payload, verrs := ratedPaperSchema.Validate(raw)
+		if len(verrs) > 0 {
+			if mode == services.ValidationLenient {
+				quarantine(verrs)
+				return
+			}
+			respondValidationError(c, verrs)
+			return
+		}
+		var saved *models.RatedPaper
+		err := ratedDB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			saved, err = saveRatedPaper(tx, payload)
+			return err
+		})
+		if errors.As(err, &verrs) && mode == services.ValidationLenient {
+			quarantine(verrs)
+			return
+		}
+		if err != nil {
+			if respondValidationError(c, err) {
+				return
+			}
+			log.Error("Failed to save rated paper", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
+			return
+		}
+		c.JSON(http.StatusOK, saved)
+	}
+	// Unterstütze beide Pfade: mit und ohne abschließenden Slash
+	rg.POST("/", saveRatedPaperHandler)
+	rg.POST("", saveRatedPaperHandler)
+	// NEU: General Update Endpoint
+	rg.PATCH("/", func(c *gin.Context) {
+		// Payload mit allen optionalen Feldern
+		var payload struct {
+			DOI           string  `json:"doi"`
+			PMID          string  `json:"pmid"`
+			ContentStatus *string `json:"content_status"` // Übergang im Content-Workflow
+			Actor         string  `json:"actor"`
+			Reason        string  `json:"reason"`
+			ContentURL    *string `json:"content_url"`
+			Processed     *bool   `json:"processed"`
+			AddedRag      *bool   `json:"added_rag"`
+			Outline       *string `json:"outline"`
+			Citations     *string `json:"citations"`
+			DeepResearch  *string `json:"deep_research"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid fields"})
+			return
+		}
+
+		// Map nur mit den mitgesendeten Feldern befüllen
+		updates := map[string]interface{}{}
+		if payload.ContentURL != nil {
+			updates["content_url"] = *payload.ContentURL
+		}
+		if payload.Processed != nil {
+			updates["processed"] = *payload.Processed
+		}
+		if payload.AddedRag != nil {
+			updates["added_rag"] = *payload.AddedRag
+		}
+
+		if payload.Outline != nil {
+			updates["outline"] = *payload.Outline
+		}
+		if payload.Citations != nil {
+			updates["citations"] = *payload.Citations
+		}
+		if payload.DeepResearch != nil {
+			updates["deep_research"] = *payload.DeepResearch
+		}
+
+		if payload.Outline != nil {
+			updates["outline"] = *payload.Outline
+		}
+		if payload.Citations != nil {
+			updates["citations"] = *payload.Citations
+		}
+		if payload.DeepResearch != nil {
+			updates["deep_research"] = *payload.DeepResearch
+		}
+
+		if len(updates) == 0 && payload.ContentStatus == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
+			return
+		}
+
+		// Ziel-DOI festlegen: payload.DOI oder via PMID aus rawDB
+		targetDOI := services.RatedDOI(payload.DOI)
+		if targetDOI == "" && strings.TrimSpace(payload.PMID) != "" {
+			if paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.PMID, Value: strings.TrimSpace(payload.PMID)}); err == nil {
+				targetDOI = services.RatedDOI(paper.DOI)
+			}
+		}
+		if targetDOI == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Target DOI missing; provide doi or pmid"})
+			return
+		}
+
+		// Felder und Statuswechsel gemeinsam: Guards sehen die neuen Werte, ein abgelehnter Wechsel
+		// verwirft auch die Feldänderungen
+		err := ratedDB.Transaction(func(tx *gorm.DB) error {
+			var paper models.RatedPaper
+			if err := tx.Where("doi = ?", targetDOI).First(&paper).Error; err != nil {
+				return err
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&paper).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if payload.ContentStatus == nil {
+				return nil
+			}
+			if err := tx.First(&paper, paper.ID).Error; err != nil {
+				return err
+			}
+			in := services.TransitionInput{To: *payload.ContentStatus, Actor: payload.Actor, Reason: payload.Reason}
+			_, err := workflow.Apply(tx, &paper, paper.ID, paper.ContentStatus, in)
+			return err
+		})
+		if err != nil {
+			respondWorkflowError(c, log, err, "Failed to update rated paper")
+			return
+		}
+		if payload.ContentStatus != nil {
+			updates["content_status"] = strings.TrimSpace(*payload.ContentStatus)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "updated fields",
+			"updates": updates,
+		})
+		// GET by PMID -> via rawDB DOI auflösen und RatedPaper liefern
+		rg.GET("/by-pmid/:pmid", func(c *gin.Context) {
+			pmid := strings.TrimSpace(c.Param("pmid"))
+			if pmid == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pmid"})
+				return
+			}
+			paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.PMID, Value: pmid})
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "paper not found in raw db"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error (raw)"})
+				return
+			}
+			doi := services.RatedDOI(paper.DOI)
+			if doi == "" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "paper has no DOI in raw db"})
+				return
+			}
+			var ratedPaper models.RatedPaper
+			if err := ratedDB.Where("doi = ?", doi).First(&ratedPaper).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			c.JSON(http.StatusOK, ratedPaper)
+		})
+	})
+	rg.GET("/:doi", func(c *gin.Context) {
+		doi := services.RatedDOI(c.Param("doi"))
+		var ratedPaper models.RatedPaper
+		if err := ratedDB.Where("doi = ?", doi).First(&ratedPaper).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// PMID und Substance aus rawDB holen
+		type RatedPaperWithPMID struct {
+			models.RatedPaper
+			PMID      string `json:"pmid"`
+			Substance string `json:"substance"`
+		}
+
+		enrichedPaper := RatedPaperWithPMID{
+			RatedPaper: ratedPaper,
+			PMID:       "", // Default fallback
+			Substance:  "", // Default fallback
+		}
+
+		// PMID und Substance aus papers Tabelle holen (über DOI)
+		if ratedPaper.DOI != "" {
+			if paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.DOI, Value: ratedPaper.DOI}); err == nil {
+				enrichedPaper.PMID = paper.PMID
+				enrichedPaper.Substance = paper.Substance
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Warn("Failed to fetch PMID and substance for rated paper",
+					zap.String("doi", ratedPaper.DOI),
+					zap.Error(err))
+			}
+		}
+
+		c.JSON(http.StatusOK, enrichedPaper)
+	})
+
+	rg.PATCH("/added-rag", func(c *gin.Context) {
+		var req struct {
+			DOI            string          `json:"doi"`
+			PMID           string          `json:"pmid"`
+			AddedRag       *bool           `json:"added_rag"`
+			Processed      *bool           `json:"processed"`
+			LightRAGDocID  string          `json:"lightrag_doc_id"`
+			ReferencesJSON json.RawMessage `json:"references_json"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
+			return
+		}
+		// Ziel-DOI: direkter DOI oder via PMID aus rawDB
+		targetDOI := services.RatedDOI(req.DOI)
+		if targetDOI == "" && strings.TrimSpace(req.PMID) != "" {
+			if paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.PMID, Value: strings.TrimSpace(req.PMID)}); err == nil {
+				targetDOI = services.RatedDOI(paper.DOI)
+			}
+		}
+		if targetDOI == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid DOI or resolvable PMID required"})
+			return
+		}
+		updates := map[string]any{}
+		if req.AddedRag != nil {
+			updates["added_rag"] = *req.AddedRag
+		}
+		if req.Processed != nil {
+			updates["processed"] = *req.Processed
+		}
+		if req.LightRAGDocID != "" {
+			updates["lightrag_doc_id"] = req.LightRAGDocID
+		}
+		if len(req.ReferencesJSON) > 0 && string(req.ReferencesJSON) != "null" {
+			// gleiche Formprüfung wie bei POST /rated-papers (Objekt oder Array, auch als JSON-String)
+			var doc any
+			_ = json.Unmarshal(req.ReferencesJSON, &doc)
+			normalized, verrs := services.PayloadSchema{Rules: []services.FieldRule{{Field: "references_json", Kind: services.FieldDocument}}}.
+				Validate(map[string]any{"references_json": doc})
+			if len(verrs) > 0 {
+				respondValidationError(c, verrs)
+				return
+			}
+			updates["references_json"], _ = json.Marshal(normalized["references_json"])
+		}
+		if len(updates) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
 			return
 		}
-
-		// Ziel-DOI festlegen: payload.DOI oder via PMID aus rawDB
-		targetDOI := strings.TrimSpace(payload.DOI)
-		if targetDOI == "" && strings.TrimSpace(payload.PMID) != "" {
-			var paper models.Paper
-			if err := rawDB.Where("pmid = ?", strings.TrimSpace(payload.PMID)).First(&paper).Error; err == nil {
-				targetDOI = strings.TrimSpace(paper.DOI)
+		if err := ratedDB.Model(&models.RatedPaper{}).
+			Where("doi = ?", targetDOI).
+			Updates(updates).Error; err != nil {
+			log.Error("Failed to update rated paper (added-rag)", zap.String("doi", targetDOI), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "updated", "updates": updates})
+	})
+
+	// Strukturierte Filter, gemeinsam für /query und /search
+	type RatedPaperFilter struct {
+		DOI               string   `json:"doi"`
+		MinRating         *float64 `json:"min_rating"`        // Rating >= MinRating
+		CategoryKeywords  []string `json:"category_keywords"` // OR-Suche in Category-Feld
+		ContentStatus     string   `json:"content_status"`
+		Processed         *bool    `json:"processed"`
+		AddedRag          *bool    `json:"added_rag"`
+		PromptVersion     string   `json:"prompt_version"`     // Prompt-Version der aktuellen Bewertung
+		NeedsReevaluation *bool    `json:"needs_reevaluation"` // zur Neubewertung markiert
+		NeedsReview       *bool    `json:"needs_review"`       // Rater uneinig, menschliche Prüfung offen
+		MinRaters         int      `json:"min_raters"`         // mindestens so viele Rater
+	}
+	applyFilter := func(query *gorm.DB, f RatedPaperFilter) *gorm.DB {
+		if f.DOI != "" {
+			query = query.Where("doi = ?", services.RatedDOI(f.DOI))
+		}
+		if f.MinRating != nil {
+			query = query.Where("rating >= ?", *f.MinRating)
+		}
+		if len(f.CategoryKeywords) > 0 {
+			// OR-Suche für Category-Keywords mit ILIKE (case-insensitive)
+			var conditions []string
+			var args []interface{}
+			for _, keyword := range f.CategoryKeywords {
+				conditions = append(conditions, "category ILIKE ?")
+				args = append(args, "%"+keyword+"%")
+			}
+			query = query.Where(strings.Join(conditions, " OR "), args...)
+		}
+		if f.ContentStatus != "" {
+			query = query.Where("content_status = ?", f.ContentStatus)
+		}
+		if f.Processed != nil {
+			if *f.Processed {
+				query = query.Where("processed = ?", true)
+			} else {
+				query = query.Where("(processed = ? OR processed IS NULL)", false)
+			}
+
+		}
+		if f.AddedRag != nil {
+			if *f.AddedRag {
+				// nur TRUE zulassen
+				query = query.Where("added_rag = ?", true)
+			} else {
+				// FALSE oder NULL zulassen
+				query = query.Where("(added_rag = ? OR added_rag IS NULL)", false)
+			}
+		}
+		if f.PromptVersion != "" {
+			query = query.Where("prompt_version = ?", f.PromptVersion)
+		}
+		if f.NeedsReevaluation != nil {
+			query = query.Where("needs_reevaluation = ?", *f.NeedsReevaluation)
+		}
+		if f.NeedsReview != nil {
+			query = query.Where("needs_review = ?", *f.NeedsReview)
+		}
+		if f.MinRaters > 0 {
+			query = query.Where("rater_count >= ?", f.MinRaters)
+		}
+		return query
+	}
+
+	// Queues "rag-ingest" und "article-writing" für POST /work/claim, filterbar wie /rated-papers/query;
+	// Artikel werden zu Papers im ersten Zustand des Content-Workflows (Standard: idee) geschrieben
+	ratedPaperWorkFilter := func(query *gorm.DB, raw json.RawMessage) (*gorm.DB, error) {
+		var f RatedPaperFilter
+		if err := services.DecodeWorkFilter(raw, &f); err != nil {
+			return nil, err
+		}
+		return applyFilter(query, f), nil
+	}
+	work.Register(services.RAGIngestQueue(ratedDB, ratedPaperWorkFilter))
+	work.Register(services.ArticleWritingQueue(ratedDB, workflow.Allowed("")[0], ratedPaperWorkFilter))
+
+	// lookupPaper holt PMID und Substance eines rated papers aus rawDB (über DOI)
+	lookupPaper := func(doi string) (pmid, substance string) {
+		if doi == "" {
+			return "", ""
+		}
+		paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.DOI, Value: doi})
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				// Nur loggen bei echten DB-Fehlern, nicht bei "not found"
+				log.Warn("Failed to fetch PMID and substance for rated paper",
+					zap.String("doi", doi),
+					zap.Error(err))
+			}
+			return "", ""
+		}
+		return paper.PMID, paper.Substance
+	}
+
+	// POST - Query rated papers with filters
+	rg.POST("/query", func(c *gin.Context) {
+		type RatedPaperQuery struct {
+			RatedPaperFilter
+			services.ListParams
+		}
+
+		var req RatedPaperQuery
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		query := applyFilter(ratedDB.Model(&models.RatedPaper{}), req.RatedPaperFilter)
+
+		var ratedPapers []models.RatedPaper
+		page, err := services.Paginate(query, ratedPaperListSpec, req.ListParams, &ratedPapers)
+		if err != nil {
+			respondListError(c, log, err, "Database query for rated papers failed")
+			return
+		}
+
+		// Erweiterte Response-Struktur mit PMID und Substance
+		type RatedPaperWithPMID struct {
+			models.RatedPaper
+			PMID      string `json:"pmid"`
+			Substance string `json:"substance"`
+		}
+
+		var enrichedPapers []RatedPaperWithPMID
+		for _, ratedPaper := range ratedPapers {
+			enrichedPaper := RatedPaperWithPMID{RatedPaper: ratedPaper}
+			enrichedPaper.PMID, enrichedPaper.Substance = lookupPaper(ratedPaper.DOI)
+			enrichedPapers = append(enrichedPapers, enrichedPaper)
+		}
+
+		respondList(c, log, enrichedPapers, page, req.ListParams)
+	})
+
+	// POST - Volltextsuche über ai_summary, key_findings, Kategorie, Stärken und Limitationen
+	rg.POST("/search", func(c *gin.Context) {
+		type RatedPaperSearch struct {
+			services.FullTextQuery
+			RatedPaperFilter
+			services.ListParams
+		}
+
+		var req RatedPaperSearch
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		query := applyFilter(ratedDB.Model(&models.RatedPaper{}), req.RatedPaperFilter)
+		hits, page, err := services.SearchRatedPapers(query, req.FullTextQuery, ratedPaperListSpec, req.ListParams)
+		if err != nil {
+			respondListError(c, log, err, "Full-text search for rated papers failed")
+			return
+		}
+
+		type RatedPaperHitWithPMID struct {
+			services.RatedPaperHit
+			PMID      string `json:"pmid"`
+			Substance string `json:"substance"`
+		}
+		enriched := make([]RatedPaperHitWithPMID, 0, len(hits))
+		for _, hit := range hits {
+			item := RatedPaperHitWithPMID{RatedPaperHit: hit}
+			item.PMID, item.Substance = lookupPaper(hit.DOI)
+			enriched = append(enriched, item)
+		}
+		respondList(c, log, enriched, page, req.ListParams)
+	})
+
+	// findRatedPaper lädt ein rated paper über doi oder (via rawDB) pmid; schreibt bei Fehlern die Antwort
+	findRatedPaper := func(c *gin.Context, doi, pmid string) (*models.RatedPaper, bool) {
+		doi, pmid = services.RatedDOI(doi), strings.TrimSpace(pmid)
+		if doi == "" && pmid != "" {
+			if paper, err := services.FindPaper(rawDB, identifiers.ID{Kind: identifiers.PMID, Value: pmid}); err == nil {
+				doi = services.RatedDOI(paper.DOI)
+			}
+		}
+		if doi == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "doi is required (or provide pmid to resolve)"})
+			return nil, false
+		}
+		var ratedPaper models.RatedPaper
+		if err := ratedDB.Where("doi = ?", doi).First(&ratedPaper).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
+				return nil, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return nil, false
+		}
+		return &ratedPaper, true
+	}
+	// POST - Content-Status über den Workflow wechseln: {doi|pmid, to, actor, reason}
+	rg.POST("/transition", func(c *gin.Context) {
+		var req struct {
+			DOI  string `json:"doi"`
+			PMID string `json:"pmid"`
+			services.TransitionInput
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		ratedPaper, ok := findRatedPaper(c, req.DOI, req.PMID)
+		if !ok {
+			return
+		}
+		var transition *models.ContentTransition
+		err := ratedDB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			transition, err = workflow.Apply(tx, ratedPaper, ratedPaper.ID, ratedPaper.ContentStatus, req.TransitionInput)
+			if err != nil {
+				return err
+			}
+			return tx.First(ratedPaper, ratedPaper.ID).Error
+		})
+		if err != nil {
+			respondWorkflowError(c, log, err, "Failed to change rated paper content status")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rated_paper": ratedPaper, "transition": transition})
+	})
+
+	// GET - Übergangsprotokoll des Content-Status (?doi= oder ?pmid=), neueste zuerst
+	rg.GET("/transitions", func(c *gin.Context) {
+		ratedPaper, ok := findRatedPaper(c, c.Query("doi"), c.Query("pmid"))
+		if !ok {
+			return
+		}
+		transitions, err := workflow.ListTransitions(ratedDB, ratedPaper.ID)
+		if err != nil {
+			log.Error("Failed to list rated paper transitions", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"doi": ratedPaper.DOI, "content_status": ratedPaper.ContentStatus, "allowed": workflow.Allowed(ratedPaper.ContentStatus), "transitions": transitions})
+	})
+
+	// GET - Paper in einem Content-Status (?state=idee, leer = ohne Status), paginiert wie /papers
+	rg.GET("/by-state", func(c *gin.Context) {
+		state, ok := c.GetQuery("state")
+		if !ok || (state != "" && !workflow.HasState(state)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "state must be one of " + strings.Join(workflow.States(), ", ")})
+			return
+		}
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query := ratedDB.Model(&models.RatedPaper{}).Where("COALESCE(content_status, '') = ?", state)
+		var papers []models.RatedPaper
+		page, err := services.Paginate(query, ratedPaperListSpec, params, &papers)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list rated papers by state")
+			return
+		}
+		respondList(c, log, papers, page, params)
+	})
+
+	respondRevisionError := func(c *gin.Context, err error) {
+		switch {
+		case errors.Is(err, services.ErrInvalidRevision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		default:
+			log.Error("Rated paper revision request failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+	}
+
+	// GET - Alle Revisionen der KI-Bewertung eines Papers (?doi= oder ?pmid=), neueste zuerst
+	rg.GET("/revisions", func(c *gin.Context) {
+		ratedPaper, ok := findRatedPaper(c, c.Query("doi"), c.Query("pmid"))
+		if !ok {
+			return
+		}
+		revisions, err := services.ListRatedPaperRevisions(ratedDB, ratedPaper.ID)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"doi":                 ratedPaper.DOI,
+			"current_revision_id": ratedPaper.CurrentRevisionID,
+			"needs_reevaluation":  ratedPaper.NeedsReevaluation,
+			"revisions":           revisions,
+		})
+	})
+
+	// GET - Feldweiser Vergleich zweier Revisionen (?from=&to=, Standard: Vorgänger gegen aktuelle)
+	rg.GET("/revisions/diff", func(c *gin.Context) {
+		var from, to int
+		for name, dest := range map[string]*int{"from": &from, "to": &to} {
+			if v := c.Query(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n <= 0 {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+					return
+				}
+				*dest = n
+			}
+		}
+		ratedPaper, ok := findRatedPaper(c, c.Query("doi"), c.Query("pmid"))
+		if !ok {
+			return
+		}
+		diff, err := services.DiffRatedPaperRevisions(ratedDB, ratedPaper, from, to)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, diff)
+	})
+
+	// POST - Eine frühere Revision wieder zur aktuellen Bewertung machen
+	rg.POST("/revisions/current", func(c *gin.Context) {
+		var req struct {
+			DOI      string `json:"doi"`
+			PMID     string `json:"pmid"`
+			Revision int    `json:"revision" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "revision is required"})
+			return
+		}
+		ratedPaper, ok := findRatedPaper(c, req.DOI, req.PMID)
+		if !ok {
+			return
+		}
+		revision, err := services.SetCurrentRatedPaperRevision(ratedDB, assessments, ratedPaper, req.Revision)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		log.Info("Rated paper revision restored", zap.String("doi", ratedPaper.DOI), zap.Int("revision", revision.Revision))
+		c.JSON(http.StatusOK, revision)
+	})
+
+	// POST - Paper, deren Bewertung nicht mit prompt_version erstellt wurde, zur Neubewertung markieren
+	rg.POST("/reevaluate", func(c *gin.Context) {
+		var req struct {
+			RatedPaperFilter
+			PromptVersion string `json:"prompt_version"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		// prompt_version ist hier die neue Version, kein Filter
+		req.RatedPaperFilter.PromptVersion = ""
+		query := applyFilter(ratedDB.Model(&models.RatedPaper{}), req.RatedPaperFilter)
+		marked, err := services.MarkRatedPapersForReevaluation(query, req.PromptVersion)
+		if err != nil {
+			respondRevisionError(c, err)
+			return
+		}
+		log.Info("Rated papers marked for re-evaluation", zap.String("prompt_version", req.PromptVersion), zap.Int64("marked", marked))
+		c.JSON(http.StatusOK, gin.H{"marked": marked, "prompt_version": req.PromptVersion})
+	})
+
+	respondAssessmentError := func(c *gin.Context, err error) {
+		if errors.Is(err, services.ErrInvalidAssessment) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error("Rater assessment request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	}
+
+	// GET - Einschätzungen aller Rater zu einem Paper samt Konsens (?doi= oder ?pmid=, ?aggregation=)
+	rg.GET("/assessments", func(c *gin.Context) {
+		ratedPaper, ok := findRatedPaper(c, c.Query("doi"), c.Query("pmid"))
+		if !ok {
+			return
+		}
+		list, summary, err := assessments.Assessments(ratedPaper, c.Query("aggregation"))
+		if err != nil {
+			respondAssessmentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"doi": ratedPaper.DOI, "summary": summary, "assessments": list})
+	})
+
+	// POST - Einschätzung eines weiteren Raters (LLM, Prompt-Variante oder Mensch) speichern
+	rg.POST("/assessments", func(c *gin.Context) {
+		var req struct {
+			DOI  string `json:"doi"`
+			PMID string `json:"pmid"`
+			services.AssessmentInput
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		ratedPaper, ok := findRatedPaper(c, req.DOI, req.PMID)
+		if !ok {
+			return
+		}
+		var assessment *models.RatedPaperAssessment
+		err := ratedDB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			assessment, err = assessments.Record(tx, ratedPaper, req.AssessmentInput)
+			return err
+		})
+		if err != nil {
+			respondAssessmentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"assessment": assessment, "paper": ratedPaper})
+	})
+
+	// GET - Alle Rater mit Art und Vertrauensgewicht
+	rg.GET("/raters", func(c *gin.Context) {
+		raters, err := assessments.Raters()
+		if err != nil {
+			respondAssessmentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, raters)
+	})
+
+	// PUT - Art und Vertrauensgewicht eines Raters setzen; betroffene Paper werden neu aggregiert
+	rg.PUT("/raters/:name", func(c *gin.Context) {
+		var req struct {
+			Kind  *string  `json:"kind"`
+			Trust *float64 `json:"trust"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		rater, refreshed, err := assessments.UpdateRater(c.Param("name"), req.Kind, req.Trust)
+		if err != nil {
+			respondAssessmentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rater": rater, "refreshed_papers": refreshed})
+	})
+
+	// GET - Strukturierte Bewertungsmatrix eines Papers (?doi= oder ?pmid=)
+	rg.GET("/appraisal", func(c *gin.Context) {
+		ratedPaper, ok := findRatedPaper(c, c.Query("doi"), c.Query("pmid"))
+		if !ok {
+			return
+		}
+		appraisal, err := services.FindAppraisal(ratedDB, ratedPaper.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Appraisal not found"})
+				return
+			}
+			log.Error("Failed to load appraisal", zap.String("doi", ratedPaper.DOI), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, appraisal)
+	})
+
+	// PUT - Bewertungsmatrix eines Papers ersetzen (ohne neue Revision der KI-Bewertung)
+	rg.PUT("/appraisal", func(c *gin.Context) {
+		var req struct {
+			DOI       string `json:"doi"`
+			PMID      string `json:"pmid"`
+			Appraisal any    `json:"appraisal"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Appraisal == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "appraisal is required"})
+			return
+		}
+		appraisal, err := services.DecodeAppraisal(req.Appraisal, "appraisal")
+		if err != nil {
+			respondValidationError(c, err)
+			return
+		}
+		ratedPaper, ok := findRatedPaper(c, req.DOI, req.PMID)
+		if !ok {
+			return
+		}
+		if err := ratedDB.Transaction(func(tx *gorm.DB) error {
+			return services.SaveAppraisal(tx, ratedPaper, appraisal)
+		}); err != nil {
+			log.Error("Failed to save appraisal", zap.String("doi", ratedPaper.DOI), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, appraisal)
+	})
+
+	// POST - Paper über die Bewertungsmatrix abfragen, z.B. RCTs mit geringem Verzerrungsrisiko und n ≥ 100
+	rg.POST("/appraisals/query", func(c *gin.Context) {
+		var req struct {
+			RatedPaperFilter
+			services.AppraisalFilter
+			Substances []string `json:"substances"`
+			services.ListParams
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		scope, err := services.AppraisalScope(req.AppraisalFilter)
+		if err != nil {
+			respondListError(c, log, err, "Invalid appraisal filter")
+			return
+		}
+		var ratedPapers []models.RatedPaper
+		var page *services.ListPage
+		run := func(tx *gorm.DB) error {
+			query := applyFilter(tx.Model(&models.RatedPaper{}), req.RatedPaperFilter).Scopes(scope)
+			if len(req.Substances) > 0 {
+				query = query.Where("doi IN (SELECT doi FROM " + services.ClassifiedPapersTable + " WHERE doi <> '')")
 			}
+			var err error
+			page, err = services.Paginate(query, ratedPaperAppraisalListSpec, req.ListParams, &ratedPapers)
+			return err
 		}
-		if targetDOI == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Target DOI missing; provide doi or pmid"})
+		if len(req.Substances) > 0 {
+			// Substanzen liegen in der Raw-DB: passende Paper dort auflösen und per DOI abgleichen
+			err = services.WithClassifiedPapers(rawDB, ratedDB, req.Substances, nil, run)
+		} else {
+			err = run(ratedDB)
+		}
+		if err != nil {
+			respondListError(c, log, err, "Appraisal query for rated papers failed")
 			return
 		}
+		type RatedPaperWithPMID struct {
+			models.RatedPaper
+			PMID      string `json:"pmid"`
+			Substance string `json:"substance"`
+		}
+		enriched := make([]RatedPaperWithPMID, 0, len(ratedPapers))
+		for _, ratedPaper := range ratedPapers {
+			item := RatedPaperWithPMID{RatedPaper: ratedPaper}
+			item.PMID, item.Substance = lookupPaper(ratedPaper.DOI)
+			enriched = append(enriched, item)
+		}
+		respondList(c, log, enriched, page, req.ListParams)
+	})
 
-		if err := ratedDB.
-			Model(&models.RatedPaper{}).
-			Where("doi = ?", targetDOI).
-			Updates(updates).
-			Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
-			} else {
-				log.Error("Failed to update rated paper", zap.Error(err))
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	// GET - Quarantäne ungültiger Einreichungen (?status=open|released|discarded, ?doi=)
+	rg.GET("/quarantine", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query := ratedDB.Model(&models.RatedPaperQuarantine{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if doi := strings.TrimSpace(c.Query("doi")); doi != "" {
+			query = query.Where("doi = ?", services.RatedDOI(doi))
+		}
+		var entries []models.RatedPaperQuarantine
+		page, err := services.Paginate(query, quarantineListSpec, params, &entries)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list quarantined rated papers")
+			return
+		}
+		respondList(c, log, entries, page, params)
+	})
+
+	// POST - Quarantäne-Einreichung freigeben: optional korrigierter payload, der strikt validiert wird
+	rg.POST("/quarantine/:id/release", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req struct {
+			Payload map[string]any `json:"payload"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		var saved *models.RatedPaper
+		var verrs services.ValidationErrors
+		err = ratedDB.Transaction(func(tx *gorm.DB) error {
+			entry, err := services.OpenQuarantineEntry(tx, uint(id))
+			if err != nil {
+				return err
+			}
+			raw := req.Payload
+			if raw == nil {
+				if err := json.Unmarshal(entry.Payload, &raw); err != nil {
+					return err
+				}
+			}
+			payload, errs := ratedPaperSchema.Validate(raw)
+			if len(errs) == 0 {
+				saved, err = saveRatedPaper(tx, payload)
+				if !errors.As(err, &errs) && err != nil {
+					return err
+				}
+			}
+			if len(errs) > 0 {
+				// weiterhin ungültig: korrigierten Stand und Fehler festhalten, Eintrag bleibt offen
+				verrs = errs
+				return services.RecheckQuarantineEntry(tx, entry, raw, errs)
 			}
+			return services.ResolveQuarantineEntry(tx, entry, models.QuarantineReleased, &saved.ID)
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quarantine entry not found"})
+		case errors.Is(err, services.ErrQuarantineResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			log.Error("Failed to release quarantined rated paper", zap.Uint64("id", id), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rated paper"})
+		case len(verrs) > 0:
+			respondValidationError(c, verrs)
+		default:
+			c.JSON(http.StatusOK, saved)
+		}
+	})
+
+	// DELETE - Quarantäne-Einreichung verwerfen (bleibt zur Nachvollziehbarkeit erhalten)
+	rg.DELETE("/quarantine/:id", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 			return
 		}
+		err = ratedDB.Transaction(func(tx *gorm.DB) error {
+			entry, err := services.OpenQuarantineEntry(tx, uint(id))
+			if err != nil {
+				return err
+			}
+			return services.ResolveQuarantineEntry(tx, entry, models.QuarantineDiscarded, nil)
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quarantine entry not found"})
+		case errors.Is(err, services.ErrQuarantineResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			log.Error("Failed to discard quarantined rated paper", zap.Uint64("id", id), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "discarded"})
+		}
+	})
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "updated fields",
-			"updates": updates,
+	// GET - Übereinstimmung der Rater (Fleiss'/Cohens Kappa, Rating-Varianz), optional ?rater=a&rater=b
+	rg.GET("/agreement", func(c *gin.Context) {
+		report, err := assessments.Agreement(c.QueryArray("rater"))
+		if err != nil {
+			respondAssessmentError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+}
+
+func setupContentArticleRoutes(router *gin.Engine, db *gorm.DB, rawDB *gorm.DB, workflow *services.Workflow, log *zap.Logger) {
+	rg := router.Group("/content-articles")
+
+	// POST - Create new content article
+	rg.POST("/", func(c *gin.Context) {
+		var article models.ContentArticle
+		if err := c.ShouldBindJSON(&article); err != nil {
+			log.Error("Invalid request body for content article creation", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		// Anlage ist der Übergang aus dem Start-Zustand; Guards gelten auch hier
+		status := strings.TrimSpace(article.ContentStatus)
+		if status == "" {
+			status = workflow.Initial
+		}
+		article.ContentStatus = status
+		article.DOI = services.RatedDOI(article.DOI)
+		article.StatusChangedAt, article.PublishedAt = nil, nil
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&article).Error; err != nil {
+				return err
+			}
+			if _, err := workflow.Apply(tx, &article, article.ID, "", services.TransitionInput{To: status, Actor: article.AuthorName, Reason: "created"}); err != nil {
+				return err
+			}
+			return tx.First(&article, article.ID).Error
 		})
-		// GET by PMID -> via rawDB DOI auflösen und RatedPaper liefern
-		rg.GET("/by-pmid/:pmid", func(c *gin.Context) {
-			pmid := strings.TrimSpace(c.Param("pmid"))
-			if pmid == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pmid"})
+		if err != nil {
+			respondWorkflowError(c, log, err, "Failed to create content article")
+			return
+		}
+
+		log.Info("Content article created successfully", zap.Uint("id", article.ID), zap.String("title", article.Title))
+		c.JSON(http.StatusCreated, article)
+	})
+
+	// PUT - Update content article by ID
+	rg.PUT("/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var article models.ContentArticle
+
+		// Check if article exists
+		if err := db.First(&article, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Content article not found"})
 				return
 			}
-			var paper models.Paper
-			if err := rawDB.Where("pmid = ?", pmid).First(&paper).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusNotFound, gin.H{"error": "paper not found in raw db"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error (raw)"})
-				return
+			log.Error("Database error while fetching content article", zap.String("id", id), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		// Bind new data; Status und Workflow-Zeitstempel sind nicht direkt schreibbar
+		from, statusChangedAt, publishedAt := article.ContentStatus, article.StatusChangedAt, article.PublishedAt
+		if err := c.ShouldBindJSON(&article); err != nil {
+			log.Error("Invalid request body for content article update", zap.String("id", id), zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		to := strings.TrimSpace(article.ContentStatus)
+		if to == "" {
+			to = from
+		}
+		article.ContentStatus, article.StatusChangedAt, article.PublishedAt = from, statusChangedAt, publishedAt
+		article.DOI = services.RatedDOI(article.DOI)
+
+		// Save updates; ein geänderter content_status läuft als Übergang durch den Workflow
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&article).Error; err != nil {
+				return err
 			}
-			doi := strings.TrimSpace(paper.DOI)
-			if doi == "" {
-				c.JSON(http.StatusNotFound, gin.H{"error": "paper has no DOI in raw db"})
-				return
+			if _, err := workflow.Apply(tx, &article, article.ID, from, services.TransitionInput{To: to, Actor: article.AuthorName}); err != nil {
+				return err
 			}
-			var ratedPaper models.RatedPaper
-			if err := ratedDB.Where("doi = ?", doi).First(&ratedPaper).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-				return
+			return tx.First(&article, article.ID).Error
+		})
+		if err != nil {
+			respondWorkflowError(c, log, err, "Failed to update content article")
+			return
+		}
+
+		log.Info("Content article updated successfully", zap.String("id", id), zap.String("title", article.Title))
+		c.JSON(http.StatusOK, article)
+	})
+
+	// POST - Status über den Workflow wechseln: {to, actor, reason}
+	rg.POST("/:id/transition", func(c *gin.Context) {
+		id := c.Param("id")
+		var in services.TransitionInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		var article models.ContentArticle
+		var transition *models.ContentTransition
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&article, id).Error; err != nil {
+				return err
 			}
-			c.JSON(http.StatusOK, ratedPaper)
+			var err error
+			if transition, err = workflow.Apply(tx, &article, article.ID, article.ContentStatus, in); err != nil {
+				return err
+			}
+			return tx.First(&article, article.ID).Error
 		})
+		if err != nil {
+			respondWorkflowError(c, log, err, "Failed to change content article status")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"article": article, "transition": transition})
 	})
-	rg.GET("/:doi", func(c *gin.Context) {
-		doi := c.Param("doi")
-		var ratedPaper models.RatedPaper
-		if err := ratedDB.Where("doi = ?", doi).First(&ratedPaper).Error; err != nil {
+
+	// GET - Übergangsprotokoll eines Artikels, neueste zuerst
+	rg.GET("/:id/transitions", func(c *gin.Context) {
+		var article models.ContentArticle
+		if err := db.First(&article, c.Param("id")).Error; err != nil {
+			respondWorkflowError(c, log, err, "Database error while fetching content article")
+			return
+		}
+		transitions, err := workflow.ListTransitions(db, article.ID)
+		if err != nil {
+			log.Error("Failed to list content article transitions", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": article.ID, "content_status": article.ContentStatus, "allowed": workflow.Allowed(article.ContentStatus), "transitions": transitions})
+	})
+
+	// GET - Artikel in einem Status (?state=review), paginiert wie /papers
+	rg.GET("/by-state", func(c *gin.Context) {
+		state := c.Query("state")
+		if !workflow.HasState(state) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "state must be one of " + strings.Join(workflow.States(), ", ")})
+			return
+		}
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var articles []models.ContentArticle
+		page, err := services.Paginate(db.Model(&models.ContentArticle{}).Where("content_status = ?", state), contentArticleListSpec, params, &articles)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list content articles by state")
+			return
+		}
+		respondList(c, log, articles, page, params)
+	})
+
+	// GET - Get content article by ID
+	rg.GET("/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		var article models.ContentArticle
+
+		if err := db.First(&article, id).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Rated paper not found"})
+				c.JSON(http.StatusNotFound, gin.H{"error": "Content article not found"})
 				return
 			}
+			log.Error("Database error while fetching content article", zap.String("id", id), zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 			return
 		}
 
-		// PMID und Substance aus rawDB holen
-		type RatedPaperWithPMID struct {
-			models.RatedPaper
-			PMID      string `json:"pmid"`
-			Substance string `json:"substance"`
+		c.JSON(http.StatusOK, article)
+	})
+
+	// POST - Query content articles with filters
+	rg.POST("/query", func(c *gin.Context) {
+		type ContentQuery struct {
+			Substance     string   `json:"substance"`
+			Substances    []string `json:"substances"`    // über die Paper-Klassifikation (n:m) aufgelöst
+			StudyDesigns  []string `json:"study_designs"` // über die Paper-Klassifikation (n:m) aufgelöst
+			PMID          string   `json:"pmid"`
+			DOI           string   `json:"doi"`
+			ContentStatus string   `json:"content_status"`
+			Category      string   `json:"category"`
+			AuthorName    string   `json:"author_name"`
+			StudyType     string   `json:"study_type"`
+			BlogPosted    *bool    `json:"blog_posted"`
+			services.ListParams
 		}
 
-		enrichedPaper := RatedPaperWithPMID{
-			RatedPaper: ratedPaper,
-			PMID:       "", // Default fallback
-			Substance:  "", // Default fallback
+		var req ContentQuery
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
 		}
 
-		// PMID und Substance aus papers Tabelle holen (über DOI)
-		if ratedPaper.DOI != "" {
-			var paper models.Paper
-			if err := rawDB.Where("doi = ?", ratedPaper.DOI).First(&paper).Error; err == nil {
-				enrichedPaper.PMID = paper.PMID
-				enrichedPaper.Substance = paper.Substance
-			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-				log.Warn("Failed to fetch PMID and substance for rated paper",
-					zap.String("doi", ratedPaper.DOI),
-					zap.Error(err))
+		classified := len(req.Substances) > 0 || len(req.StudyDesigns) > 0
+		var articles []models.ContentArticle
+		var page *services.ListPage
+		run := func(tx *gorm.DB) error {
+			query := tx.Model(&models.ContentArticle{})
+
+			if req.Substance != "" {
+				query = query.Where("substance = ?", req.Substance)
+			}
+			if classified {
+				// Klassifizierte Paper stehen in der temporären Tabelle, Abgleich über PMID/DOI
+				cond := db.Where("pmid IN (SELECT pmid FROM " + services.ClassifiedPapersTable + " WHERE pmid <> '')").
+					Or("doi IN (SELECT doi FROM " + services.ClassifiedPapersTable + " WHERE doi <> '')")
+				if len(req.Substances) > 0 && len(req.StudyDesigns) == 0 {
+					cond = cond.Or("substance IN ?", req.Substances)
+				}
+				query = query.Where(cond)
+			}
+			if req.PMID != "" {
+				query = query.Where("pmid = ?", req.PMID)
+			}
+			if req.DOI != "" {
+				query = query.Where("doi = ?", services.RatedDOI(req.DOI))
+			}
+			if req.ContentStatus != "" {
+				query = query.Where("content_status = ?", req.ContentStatus)
+			}
+			if req.Category != "" {
+				query = query.Where("category = ?", req.Category)
 			}
+			if req.AuthorName != "" {
+				query = query.Where("author_name = ?", req.AuthorName)
+			}
+			if req.StudyType != "" {
+				query = query.Where("study_type = ?", req.StudyType)
+			}
+			if req.BlogPosted != nil {
+				query = query.Where("blog_posted = ?", *req.BlogPosted)
+			}
+
+			var err error
+			page, err = services.Paginate(query, contentArticleListSpec, req.ListParams, &articles)
+			return err
+		}
+		var err error
+		if classified {
+			// Join-Tabellen liegen in der Raw-DB: passende Paper dort auflösen
+			err = services.WithClassifiedPapers(rawDB, db, req.Substances, req.StudyDesigns, run)
+		} else {
+			err = run(db)
+		}
+		if err != nil {
+			respondListError(c, log, err, "Database query for content articles failed")
+			return
+		}
+		respondList(c, log, articles, page, req.ListParams)
+	})
+}
+
+// setupWorkflowRoutes stellt die konfigurierten Content-Workflows samt Anzahl je Zustand bereit.
+func setupWorkflowRoutes(router *gin.Engine, db *gorm.DB, contentArticles, ratedPapers *services.Workflow, log *zap.Logger) {
+	workflows := map[string]struct {
+		workflow *services.Workflow
+		table    any
+	}{
+		contentArticles.Entity: {contentArticles, &models.ContentArticle{}},
+		ratedPapers.Entity:     {ratedPapers, &models.RatedPaper{}},
+	}
+	// GET - Workflow einer Entität (content_article, rated_paper)
+	router.GET("/workflows/:entity", func(c *gin.Context) {
+		entry, ok := workflows[c.Param("entity")]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown workflow"})
+			return
+		}
+		summary, err := entry.workflow.Summary(db, entry.table)
+		if err != nil {
+			log.Error("Failed to summarize workflow", zap.String("entity", c.Param("entity")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusOK, summary)
+	})
+}
+
+// setupWorkRoutes konfiguriert die Arbeitsvergabe an Worker (Claim mit Lease, complete/fail/extend).
+func setupWorkRoutes(router *gin.Engine, work *services.WorkService, log *zap.Logger) {
+	rg := router.Group("/work")
+
+	respondWorkError := func(c *gin.Context, err error) {
+		switch {
+		case errors.Is(err, services.ErrInvalidClaim):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUnknownQueue), errors.Is(err, services.ErrLeaseNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrLeaseResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Error("Work queue request failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+	}
+
+	// POST - bis zu limit Einträge einer Queue exklusiv übernehmen
+	rg.POST("/claim", func(c *gin.Context) {
+		var req services.ClaimRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		items, err := work.Claim(req)
+		if err != nil {
+			respondWorkError(c, err)
+			return
 		}
-
-		c.JSON(http.StatusOK, enrichedPaper)
+		c.JSON(http.StatusOK, gin.H{"queue": req.Queue, "items": items})
 	})
 
-	rg.PATCH("/added-rag", func(c *gin.Context) {
+	// POST - Eintrag erledigt: {token}
+	rg.POST("/complete", func(c *gin.Context) {
 		var req struct {
-			DOI            string          `json:"doi"`
-			PMID           string          `json:"pmid"`
-			AddedRag       *bool           `json:"added_rag"`
-			Processed      *bool           `json:"processed"`
-			LightRAGDocID  string          `json:"lightrag_doc_id"`
-			ReferencesJSON json.RawMessage `json:"references_json"`
+			Token string `json:"token" binding:"required"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
 			return
 		}
-		// Ziel-DOI: direkter DOI oder via PMID aus rawDB
-		targetDOI := strings.TrimSpace(req.DOI)
-		if targetDOI == "" && strings.TrimSpace(req.PMID) != "" {
-			var paper models.Paper
-			if err := rawDB.Where("pmid = ?", strings.TrimSpace(req.PMID)).First(&paper).Error; err == nil {
-				targetDOI = strings.TrimSpace(paper.DOI)
-			}
-		}
-		if targetDOI == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid DOI or resolvable PMID required"})
+		lease, err := work.Complete(req.Token)
+		if err != nil {
+			respondWorkError(c, err)
 			return
 		}
-		updates := map[string]any{}
-		if req.AddedRag != nil {
-			updates["added_rag"] = *req.AddedRag
+		c.JSON(http.StatusOK, lease)
+	})
+
+	// POST - Eintrag fehlgeschlagen: {token, error, retry_after_seconds}
+	rg.POST("/fail", func(c *gin.Context) {
+		var req struct {
+			Token             string `json:"token" binding:"required"`
+			Error             string `json:"error"`
+			RetryAfterSeconds int    `json:"retry_after_seconds"`
 		}
-		if req.Processed != nil {
-			updates["processed"] = *req.Processed
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
 		}
-		if req.LightRAGDocID != "" {
-			updates["lightrag_doc_id"] = req.LightRAGDocID
+		lease, err := work.Fail(req.Token, req.Error, time.Duration(req.RetryAfterSeconds)*time.Second)
+		if err != nil {
+			respondWorkError(c, err)
+			return
 		}
-		if len(req.ReferencesJSON) > 0 && string(req.ReferencesJSON) != "null" {
-			updates["references_json"] = req.ReferencesJSON
+		c.JSON(http.StatusOK, lease)
+	})
+
+	// POST - Lease verlängern: {token, lease_seconds}
+	rg.POST("/extend", func(c *gin.Context) {
+		var req struct {
+			Token        string `json:"token" binding:"required"`
+			LeaseSeconds int    `json:"lease_seconds"`
 		}
-		if len(updates) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No updatable fields provided"})
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
 			return
 		}
-		if err := ratedDB.Model(&models.RatedPaper{}).
-			Where("doi = ?", targetDOI).
-			Updates(updates).Error; err != nil {
-			log.Error("Failed to update rated paper (added-rag)", zap.String("doi", targetDOI), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		lease, err := work.Extend(req.Token, req.LeaseSeconds)
+		if err != nil {
+			respondWorkError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "updated", "updates": updates})
+		c.JSON(http.StatusOK, lease)
 	})
 
-	// POST - Query rated papers with filters
-	rg.POST("/query", func(c *gin.Context) {
-		type RatedPaperQuery struct {
-			DOI              string   `json:"doi"`
-			MinRating        *float64 `json:"min_rating"`        // Rating >= MinRating
-			CategoryKeywords []string `json:"category_keywords"` // OR-Suche in Category-Feld
-			ContentStatus    string   `json:"content_status"`
-			Processed        *bool    `json:"processed"`
-			AddedRag         *bool    `json:"added_rag"`
-			Limit            int      `json:"limit"`
+	// GET - Zählung je Queue (vergebbar, aktiv, abgelaufen, fehlgeschlagen, aufgegeben, erledigt)
+	rg.GET("/queues", func(c *gin.Context) {
+		stats, err := work.Stats()
+		if err != nil {
+			respondWorkError(c, err)
+			return
 		}
+		c.JSON(http.StatusOK, stats)
+	})
+}
 
-		var req RatedPaperQuery
+// setupWebhookRoutes konfiguriert ausgehende Webhooks: Endpoints, Ereignis- und Zustell-Log, Replay.
+func setupWebhookRoutes(router *gin.Engine, db *gorm.DB, webhooks *services.WebhookService, log *zap.Logger) {
+	rg := router.Group("/webhooks")
+
+	respondWebhookError := func(c *gin.Context, err error, msg string) {
+		switch {
+		case errors.Is(err, services.ErrInvalidWebhook):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		case errors.Is(err, services.ErrWebhookDispatchRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Error(msg, zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+	}
+	// webhookEndpointRequest ist der schreibbare Teil eines Endpoints; das Secret wird nur hier angenommen
+	type webhookEndpointRequest struct {
+		Name         string         `json:"name"`
+		URL          string         `json:"url"`
+		Events       []string       `json:"events"`
+		Filter       map[string]any `json:"filter"`
+		Active       *bool          `json:"active"`
+		Secret       string         `json:"secret"`        // leer = wird erzeugt (nur bei der Anlage)
+		RotateSecret bool           `json:"rotate_secret"` // PUT: neues Secret erzeugen
+	}
+	// webhookEndpointWithSecret zeigt das Secret einmalig bei Anlage bzw. Rotation
+	type webhookEndpointWithSecret struct {
+		models.WebhookEndpoint
+		Secret string `json:"secret"`
+	}
+
+	// POST - Endpoint registrieren: {name, url, events, filter, active, secret}
+	rg.POST("", func(c *gin.Context) {
+		var req webhookEndpointRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 			return
 		}
-
-		query := ratedDB.Model(&models.RatedPaper{})
-
-		if req.DOI != "" {
-			query = query.Where("doi = ?", req.DOI)
+		endpoint := models.WebhookEndpoint{
+			Name: strings.TrimSpace(req.Name), URL: req.URL, Events: req.Events, Filter: req.Filter,
+			Secret: strings.TrimSpace(req.Secret), Active: req.Active == nil || *req.Active,
 		}
-		if req.MinRating != nil {
-			query = query.Where("rating >= ?", *req.MinRating)
+		if endpoint.Secret == "" {
+			endpoint.Secret = services.NewWebhookSecret()
 		}
-		if len(req.CategoryKeywords) > 0 {
-			// OR-Suche für Category-Keywords mit ILIKE (case-insensitive)
-			var conditions []string
-			var args []interface{}
-			for _, keyword := range req.CategoryKeywords {
-				conditions = append(conditions, "category ILIKE ?")
-				args = append(args, "%"+keyword+"%")
-			}
-			query = query.Where(strings.Join(conditions, " OR "), args...)
+		if err := services.ValidateWebhookEndpoint(&endpoint); err != nil {
+			respondWebhookError(c, err, "Invalid webhook endpoint")
+			return
 		}
-		if req.ContentStatus != "" {
-			query = query.Where("content_status = ?", req.ContentStatus)
+		if err := db.Create(&endpoint).Error; err != nil {
+			respondWebhookError(c, err, "Failed to create webhook endpoint")
+			return
 		}
-		if req.Processed != nil {
-			if *req.Processed {
-				query = query.Where("processed = ?", true)
-			} else {
-				query = query.Where("processed = ? OR processed IS NULL)", false)
-			}
+		log.Info("Webhook endpoint registered", zap.Uint("id", endpoint.ID), zap.String("url", endpoint.URL))
+		c.JSON(http.StatusCreated, webhookEndpointWithSecret{endpoint, endpoint.Secret})
+	})
 
+	// GET - registrierte Endpoints
+	rg.GET("", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		if req.AddedRag != nil {
-			if *req.AddedRag {
-				// nur TRUE zulassen
-				query = query.Where("added_rag = ?", true)
-			} else {
-				// FALSE oder NULL zulassen
-				query = query.Where("(added_rag = ? OR added_rag IS NULL)", false)
-			}
+		query := db.Model(&models.WebhookEndpoint{})
+		if active := c.Query("active"); active != "" {
+			query = query.Where("active = ?", active == "true")
 		}
-
-		if req.Limit > 0 {
-			query = query.Limit(req.Limit)
+		var endpoints []models.WebhookEndpoint
+		page, err := services.Paginate(query, webhookEndpointListSpec, params, &endpoints)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list webhook endpoints")
+			return
 		}
+		respondList(c, log, endpoints, page, params)
+	})
 
-		var ratedPapers []models.RatedPaper
-		if err := query.Order("rating desc, created_at desc").Find(&ratedPapers).Error; err != nil {
-			log.Error("Database query for rated papers failed", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+	// GET - Zustellungen je Endpoint und Status
+	rg.GET("/stats", func(c *gin.Context) {
+		stats, err := webhooks.Stats()
+		if err != nil {
+			respondWebhookError(c, err, "Failed to count webhook deliveries")
 			return
 		}
+		c.JSON(http.StatusOK, stats)
+	})
 
-		// Erweiterte Response-Struktur mit PMID und Substance
-		type RatedPaperWithPMID struct {
-			models.RatedPaper
-			PMID      string `json:"pmid"`
-			Substance string `json:"substance"`
+	// POST - fällige Zustellungen sofort ausliefern (sonst per WEBHOOK_SCHEDULE)
+	rg.POST("/dispatch", func(c *gin.Context) {
+		result, err := webhooks.Dispatch(c.Request.Context())
+		if err != nil {
+			respondWebhookError(c, err, "Webhook dispatch failed")
+			return
 		}
+		c.JSON(http.StatusOK, result)
+	})
 
-		// PMID und Substance für jedes rated paper aus rawDB holen
-		var enrichedPapers []RatedPaperWithPMID
-		for _, ratedPaper := range ratedPapers {
-			enrichedPaper := RatedPaperWithPMID{
-				RatedPaper: ratedPaper,
-				PMID:       "", // Default fallback
-				Substance:  "", // Default fallback
-			}
-
-			// PMID und Substance aus papers Tabelle holen (über DOI)
-			if ratedPaper.DOI != "" {
-				var paper models.Paper
-				if err := rawDB.Where("doi = ?", ratedPaper.DOI).First(&paper).Error; err == nil {
-					enrichedPaper.PMID = paper.PMID
-					enrichedPaper.Substance = paper.Substance
-				} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-					// Nur loggen bei echten DB-Fehlern, nicht bei "not found"
-					log.Warn("Failed to fetch PMID and substance for rated paper",
-						zap.String("doi", ratedPaper.DOI),
-						zap.Error(err))
-				}
-			}
-
-			enrichedPapers = append(enrichedPapers, enrichedPaper)
+	// GET - Ereignis-Log, optional ?type=
+	rg.GET("/events", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-
-		c.JSON(http.StatusOK, enrichedPapers)
+		query := db.Model(&models.WebhookEvent{})
+		if eventType := c.Query("type"); eventType != "" {
+			query = query.Where("type = ?", eventType)
+		}
+		var events []models.WebhookEvent
+		page, err := services.Paginate(query, webhookEventListSpec, params, &events)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list webhook events")
+			return
+		}
+		respondList(c, log, events, page, params)
 	})
-}
-
-func setupContentArticleRoutes(router *gin.Engine, db *gorm.DB, log *zap.Logger) {
-	rg := router.Group("/content-articles")
 
-	// POST - Create new content article
-	rg.POST("/", func(c *gin.Context) {
-		var article models.ContentArticle
-		if err := c.ShouldBindJSON(&article); err != nil {
-			log.Error("Invalid request body for content article creation", zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	// GET - Ereignis samt seinen Zustellungen
+	rg.GET("/events/:id", func(c *gin.Context) {
+		var event models.WebhookEvent
+		if err := db.First(&event, c.Param("id")).Error; err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook event")
 			return
 		}
-
-		if err := db.Create(&article).Error; err != nil {
-			log.Error("Failed to create content article", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save content article"})
+		var deliveries []models.WebhookDelivery
+		if err := db.Where("event_id = ?", event.ID).Order("id").Find(&deliveries).Error; err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook deliveries")
 			return
 		}
-
-		log.Info("Content article created successfully", zap.Uint("id", article.ID), zap.String("title", article.Title))
-		c.JSON(http.StatusCreated, article)
+		c.JSON(http.StatusOK, gin.H{"event": event, "deliveries": deliveries})
 	})
 
-	// PUT - Update content article by ID
-	rg.PUT("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var article models.ContentArticle
-
-		// Check if article exists
-		if err := db.First(&article, id).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Content article not found"})
+	// POST - Ereignis erneut zustellen: {endpoint_ids} oder alle heute passenden aktiven Endpoints
+	rg.POST("/events/:id/replay", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req struct {
+			EndpointIDs []uint `json:"endpoint_ids"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 				return
 			}
-			log.Error("Database error while fetching content article", zap.String("id", id), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		deliveries, err := webhooks.ReplayEvent(uint(id), req.EndpointIDs)
+		if err != nil {
+			respondWebhookError(c, err, "Failed to replay webhook event")
 			return
 		}
+		c.JSON(http.StatusAccepted, gin.H{"deliveries": deliveries})
+	})
 
-		// Bind new data
-		if err := c.ShouldBindJSON(&article); err != nil {
-			log.Error("Invalid request body for content article update", zap.String("id", id), zap.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	// GET - Zustell-Log, optional ?endpoint_id=, ?event_id=, ?status=, ?event_type=
+	rg.GET("/deliveries", func(c *gin.Context) {
+		params, err := listParamsFromQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Save updates
-		if err := db.Save(&article).Error; err != nil {
-			log.Error("Failed to update content article", zap.String("id", id), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update content article"})
+		query := db.Model(&models.WebhookDelivery{})
+		if endpointID := c.Query("endpoint_id"); endpointID != "" {
+			query = query.Where("endpoint_id = ?", endpointID)
+		}
+		if eventID := c.Query("event_id"); eventID != "" {
+			query = query.Where("event_id = ?", eventID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if eventType := c.Query("event_type"); eventType != "" {
+			query = query.Where("event_id IN (?)", db.Model(&models.WebhookEvent{}).Select("id").Where("type = ?", eventType))
+		}
+		var deliveries []models.WebhookDelivery
+		page, err := services.Paginate(query, webhookDeliveryListSpec, params, &deliveries)
+		if err != nil {
+			respondListError(c, log, err, "Failed to list webhook deliveries")
 			return
 		}
-
-		log.Info("Content article updated successfully", zap.String("id", id), zap.String("title", article.Title))
-		c.JSON(http.StatusOK, article)
+		respondList(c, log, deliveries, page, params)
 	})
 
-	// GET - Get content article by ID
-	rg.GET("/:id", func(c *gin.Context) {
-		id := c.Param("id")
-		var article models.ContentArticle
-
-		if err := db.First(&article, id).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Content article not found"})
-				return
-			}
-			log.Error("Database error while fetching content article", zap.String("id", id), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	// GET - Zustellung samt Ereignis
+	rg.GET("/deliveries/:id", func(c *gin.Context) {
+		var delivery models.WebhookDelivery
+		if err := db.Preload("Event").First(&delivery, c.Param("id")).Error; err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook delivery")
 			return
 		}
-
-		c.JSON(http.StatusOK, article)
+		c.JSON(http.StatusOK, delivery)
 	})
 
-	// POST - Query content articles with filters
-	rg.POST("/query", func(c *gin.Context) {
-		type ContentQuery struct {
-			Substance     string `json:"substance"`
-			PMID          string `json:"pmid"`
-			DOI           string `json:"doi"`
-			ContentStatus string `json:"content_status"`
-			Category      string `json:"category"`
-			AuthorName    string `json:"author_name"`
-			StudyType     string `json:"study_type"`
-			BlogPosted    *bool  `json:"blog_posted"`
-			Limit         int    `json:"limit"`
+	// POST - Zustellung erneut ausführen (neuer Log-Eintrag mit replay_of)
+	rg.POST("/deliveries/:id/replay", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
 		}
-
-		var req ContentQuery
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		delivery, err := webhooks.Replay(uint(id))
+		if err != nil {
+			respondWebhookError(c, err, "Failed to replay webhook delivery")
 			return
 		}
+		c.JSON(http.StatusAccepted, delivery)
+	})
 
-		query := db.Model(&models.ContentArticle{})
-
-		if req.Substance != "" {
-			query = query.Where("substance = ?", req.Substance)
+	// GET - einzelner Endpoint
+	rg.GET("/:id", func(c *gin.Context) {
+		var endpoint models.WebhookEndpoint
+		if err := db.First(&endpoint, c.Param("id")).Error; err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook endpoint")
+			return
 		}
-		if req.PMID != "" {
-			query = query.Where("pmid = ?", req.PMID)
+		c.JSON(http.StatusOK, endpoint)
+	})
+
+	// PUT - Endpoint ändern; nicht angegebene Felder bleiben, rotate_secret erzeugt ein neues Secret
+	rg.PUT("/:id", func(c *gin.Context) {
+		var endpoint models.WebhookEndpoint
+		if err := db.First(&endpoint, c.Param("id")).Error; err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook endpoint")
+			return
 		}
-		if req.DOI != "" {
-			query = query.Where("doi = ?", req.DOI)
+		req := webhookEndpointRequest{
+			Name: endpoint.Name, URL: endpoint.URL, Events: endpoint.Events, Filter: endpoint.Filter, Active: &endpoint.Active,
 		}
-		if req.ContentStatus != "" {
-			query = query.Where("content_status = ?", req.ContentStatus)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
 		}
-		if req.Category != "" {
-			query = query.Where("category = ?", req.Category)
+		endpoint.Name, endpoint.URL, endpoint.Events, endpoint.Filter = strings.TrimSpace(req.Name), req.URL, req.Events, req.Filter
+		if req.Active != nil {
+			endpoint.Active = *req.Active
 		}
-		if req.AuthorName != "" {
-			query = query.Where("author_name = ?", req.AuthorName)
+		rotated := req.RotateSecret || strings.TrimSpace(req.Secret) != ""
+		switch {
+		case strings.TrimSpace(req.Secret) != "":
+			endpoint.Secret = strings.TrimSpace(req.Secret)
+		case req.RotateSecret:
+			endpoint.Secret = services.NewWebhookSecret()
 		}
-		if req.StudyType != "" {
-			query = query.Where("study_type = ?", req.StudyType)
+		if err := services.ValidateWebhookEndpoint(&endpoint); err != nil {
+			respondWebhookError(c, err, "Invalid webhook endpoint")
+			return
 		}
-		if req.BlogPosted != nil {
-			query = query.Where("blog_posted = ?", *req.BlogPosted)
+		if err := db.Save(&endpoint).Error; err != nil {
+			respondWebhookError(c, err, "Failed to update webhook endpoint")
+			return
 		}
-		if req.Limit > 0 {
-			query = query.Limit(req.Limit)
+		if rotated {
+			c.JSON(http.StatusOK, webhookEndpointWithSecret{endpoint, endpoint.Secret})
+			return
 		}
+		c.JSON(http.StatusOK, endpoint)
+	})
 
-		var articles []models.ContentArticle
-		if err := query.Order("created_at desc").Find(&articles).Error; err != nil {
-			log.Error("Database query for content articles failed", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+	// DELETE - Endpoint entfernen; offene Zustellungen werden als fehlgeschlagen abgeschlossen, das Log bleibt
+	rg.DELETE("/:id", func(c *gin.Context) {
+		var endpoint models.WebhookEndpoint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&endpoint, c.Param("id")).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.WebhookDelivery{}).
+				Where("endpoint_id = ? AND status = ?", endpoint.ID, models.DeliveryPending).
+				Updates(map[string]any{"status": models.DeliveryFailed, "last_error": "endpoint deleted"}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&endpoint).Error
+		})
+		if err != nil {
+			respondWebhookError(c, err, "Failed to delete webhook endpoint")
 			return
 		}
-
-		c.JSON(http.StatusOK, articles)
+		log.Info("Webhook endpoint deleted", zap.Uint("id", endpoint.ID))
+		c.JSON(http.StatusOK, gin.H{"message": "webhook endpoint deleted", "id": endpoint.ID})
 	})
 }
 
@@ -1384,6 +3775,167 @@ func setupAnswerRoutes(router *gin.Engine, log *zap.Logger) {
 	})
 }
 
+// Sortierfelder der List-/Query-Endpunkte (Whitelist)
+var (
+	paperListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "created_at": {Column: "created_at"}, "updated_at": {Column: "updated_at"},
+			"study_date": {Column: "study_date", Nullable: true}, "title": {Column: "title"}, "pmid": {Column: "pmid"},
+		},
+		DefaultSort: "-created_at",
+	}
+	ratedPaperListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "created_at": {Column: "created_at"}, "updated_at": {Column: "updated_at"},
+			"rating": {Column: "rating"}, "confidence_score": {Column: "confidence_score"},
+			"consensus_rating": {Column: "consensus_rating"}, "rating_variance": {Column: "rating_variance"},
+		},
+		DefaultSort: "-rating",
+		ExtraFields: []string{"pmid", "substance"},
+	}
+	quarantineListSpec = services.ListSpec{
+		Sorts:       map[string]services.SortField{"id": {Column: "id"}, "created_at": {Column: "created_at"}},
+		DefaultSort: "-created_at",
+	}
+	ratedPaperAppraisalListSpec = services.ListSpec{
+		Sorts:       ratedPaperListSpec.Sorts,
+		DefaultSort: ratedPaperListSpec.DefaultSort,
+		ExtraFields: ratedPaperListSpec.ExtraFields,
+		Preloads:    []string{"Appraisal", "Appraisal.Outcomes"},
+	}
+	contentArticleListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "created_at": {Column: "created_at"}, "updated_at": {Column: "updated_at"},
+			"published_at": {Column: "published_at", Nullable: true}, "rating": {Column: "rating"}, "title": {Column: "title"},
+			"status_changed_at": {Column: "status_changed_at", Nullable: true},
+		},
+		DefaultSort: "-created_at",
+	}
+	substanceListSpec = services.ListSpec{
+		Sorts:       map[string]services.SortField{"id": {Column: "id"}, "name": {Column: "name"}, "priority": {Column: "priority"}},
+		DefaultSort: "-priority",
+		Preloads:    []string{"Filters"},
+	}
+	searchFilterListSpec = services.ListSpec{
+		Sorts:       map[string]services.SortField{"id": {Column: "id"}, "name": {Column: "name"}},
+		DefaultSort: "id",
+	}
+	duplicateClusterListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "created_at": {Column: "created_at"}, "score": {Column: "score"},
+		},
+		DefaultSort: "-score",
+	}
+	fetchJobListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "started_at": {Column: "started_at"}, "created_at": {Column: "created_at"},
+		},
+		DefaultSort: "-started_at",
+	}
+	webhookEndpointListSpec = services.ListSpec{
+		Sorts:       map[string]services.SortField{"id": {Column: "id"}, "created_at": {Column: "created_at"}, "name": {Column: "name"}},
+		DefaultSort: "id",
+	}
+	webhookEventListSpec = services.ListSpec{
+		Sorts:       map[string]services.SortField{"id": {Column: "id"}, "created_at": {Column: "created_at"}},
+		DefaultSort: "-id",
+	}
+	webhookDeliveryListSpec = services.ListSpec{
+		Sorts: map[string]services.SortField{
+			"id": {Column: "id"}, "created_at": {Column: "created_at"}, "updated_at": {Column: "updated_at"},
+			"next_attempt_at": {Column: "next_attempt_at"}, "attempts": {Column: "attempts"},
+		},
+		DefaultSort: "-id",
+	}
+)
+
+// listParamsFromQuery liest limit, cursor, sort, fields (kommagetrennt) und envelope aus dem Query-String.
+func listParamsFromQuery(c *gin.Context) (services.ListParams, error) {
+	params := services.ListParams{
+		Cursor:   c.Query("cursor"),
+		Sort:     c.Query("sort"),
+		Envelope: c.Query("envelope") == "true" || c.Query("envelope") == "1",
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return params, fmt.Errorf("%w: limit must be an integer", services.ErrInvalidListParams)
+		}
+		params.Limit = limit
+	}
+	for _, v := range c.QueryArray("fields") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				params.Fields = append(params.Fields, f)
+			}
+		}
+	}
+	return params, nil
+}
+
+// respondList liefert eine Ergebnisseite. X-Total-Count und X-Next-Cursor stehen immer im Header;
+// der Body ist ein Array (kompatibel mit bestehenden n8n-Workflows) oder mit envelope ein Objekt.
+func respondList(c *gin.Context, log *zap.Logger, items any, page *services.ListPage, params services.ListParams) {
+	projected, err := services.ProjectFields(items, params.Fields)
+	if err != nil {
+		log.Error("Failed to project list fields", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	if !params.Envelope {
+		c.JSON(http.StatusOK, projected)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":       projected,
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"limit":       page.Limit,
+		"sort":        page.Sort,
+	})
+}
+
+// respondListError beantwortet Fehler aus services.Paginate (400 bei ungültigen Parametern).
+func respondListError(c *gin.Context, log *zap.Logger, err error, msg string) {
+	if errors.Is(err, services.ErrInvalidListParams) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Error(msg, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+}
+
+// isDuplicateKey erkennt Verletzungen eines Unique-Index über den Fehler-Übersetzer des Dialekts.
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// respondWorkflowError bildet Fehler eines Statuswechsels ab: fehlende Pflichtfelder (Guards) und
+// unbekannte Zustände 400, nicht erlaubte Übergänge 409.
+func respondWorkflowError(c *gin.Context, log *zap.Logger, err error, msg string) {
+	var verrs services.ValidationErrors
+	switch {
+	case errors.As(err, &verrs):
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrValidation.Error(), "errors": verrs})
+	case errors.Is(err, services.ErrUnknownState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	default:
+		log.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	}
+}
+
 func seedDefaultSubstances(db *gorm.DB, logger *zap.Logger) {
 	var count int64
 	db.Model(&models.Substance{}).Count(&count)
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Appraisal ist die strukturierte Bewertungsmatrix eines RatedPaper (Studiendesign, Eckdaten, GRADE,
+// Cochrane Risk of Bias, Endpunkte). Je Paper gibt es eine aktuelle Appraisal.
+type Appraisal struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RatedPaperID uint  `json:"rated_paper_id" gorm:"not null;uniqueIndex"`
+	RevisionID   *uint `json:"revision_id,omitempty"` // KI-Bewertung, mit der die Appraisal geliefert wurde
+
+	// Studiendesign und Eckdaten
+	StudyDesign   string   `json:"study_design" gorm:"size:32;index;not null"`
+	Blinding      string   `json:"blinding,omitempty" gorm:"size:16"`
+	SampleSize    *int     `json:"sample_size,omitempty" gorm:"index"`
+	DurationWeeks *float64 `json:"duration_weeks,omitempty" gorm:"index"`
+	Dose          string   `json:"dose,omitempty" gorm:"type:text"` // wie im Paper angegeben
+	DoseMgPerDay  *float64 `json:"dose_mg_per_day,omitempty"`
+	Intervention  string   `json:"intervention,omitempty" gorm:"index"`
+	Comparator    string   `json:"comparator,omitempty"`
+	Population    string   `json:"population,omitempty" gorm:"type:text"`
+
+	Grade      GradeAssessment `json:"grade" gorm:"embedded;embeddedPrefix:grade_"`
+	RiskOfBias RiskOfBias      `json:"risk_of_bias" gorm:"embedded;embeddedPrefix:rob_"`
+
+	Outcomes []AppraisalOutcome `json:"outcomes" gorm:"foreignKey:AppraisalID;constraint:OnDelete:CASCADE"`
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (Appraisal) TableName() string {
+	return "rated_paper_appraisals"
+}
+
+// GradeAssessment enthält die Vertrauenswürdigkeit der Evidenz nach GRADE und die Abwertungsdomänen
+// (not_serious, serious, very_serious).
+type GradeAssessment struct {
+	Certainty       string `json:"certainty,omitempty" gorm:"size:16;index"` // high, moderate, low, very_low
+	RiskOfBias      string `json:"risk_of_bias,omitempty" gorm:"size:16"`
+	Inconsistency   string `json:"inconsistency,omitempty" gorm:"size:16"`
+	Indirectness    string `json:"indirectness,omitempty" gorm:"size:16"`
+	Imprecision     string `json:"imprecision,omitempty" gorm:"size:16"`
+	PublicationBias string `json:"publication_bias,omitempty" gorm:"size:16"`
+}
+
+// RiskOfBias enthält die Domänen des Cochrane RoB 2 (low, some_concerns, high).
+type RiskOfBias struct {
+	Randomization      string `json:"randomization,omitempty" gorm:"size:16"`
+	Deviations         string `json:"deviations,omitempty" gorm:"size:16"` // Abweichungen von der geplanten Intervention
+	MissingData        string `json:"missing_data,omitempty" gorm:"size:16"`
+	Measurement        string `json:"measurement,omitempty" gorm:"size:16"`
+	SelectiveReporting string `json:"selective_reporting,omitempty" gorm:"size:16"`
+	Overall            string `json:"overall,omitempty" gorm:"size:16;index"`
+}
+
+// AppraisalOutcome ist ein berichteter Endpunkt mit Effektrichtung und Signifikanz.
+type AppraisalOutcome struct {
+	ID          uint `json:"id" gorm:"primaryKey"`
+	AppraisalID uint `json:"appraisal_id" gorm:"not null;index"`
+
+	Name          string   `json:"name" gorm:"not null"`
+	Primary       bool     `json:"primary" gorm:"column:is_primary;not null;default:false"`
+	Direction     string   `json:"direction" gorm:"size:16;index;not null"` // benefit, harm, no_effect, mixed
+	Significant   *bool    `json:"significant,omitempty" gorm:"index"`
+	PValue        *float64 `json:"p_value,omitempty"`
+	EffectMeasure string   `json:"effect_measure,omitempty" gorm:"size:32"` // z.B. SMD, MD, OR, RR
+	EffectSize    *float64 `json:"effect_size,omitempty"`
+	CILower       *float64 `json:"ci_lower,omitempty"`
+	CIUpper       *float64 `json:"ci_upper,omitempty"`
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (AppraisalOutcome) TableName() string {
+	return "rated_paper_appraisal_outcomes"
+}
@@ -26,10 +26,11 @@ type ContentArticle struct {
 	StudyReleaseDate *time.Time `json:"study_release_date,omitempty"`
 
 	// Content Management
-	ContentStatus string     `json:"content_status" gorm:"index;default:'draft'"` // draft, review, published, archived
-	PublishedAt   *time.Time `json:"published_at,omitempty"`
-	AuthorName    string     `json:"author_name,omitempty"`
-	BlogPosted    bool       `json:"blog_posted" gorm:"default:false"`
+	ContentStatus   string     `json:"content_status" gorm:"index;default:'draft'"` // Zustände laut Content-Workflow (Standard: draft, review, published, archived)
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	PublishedAt     *time.Time `json:"published_at,omitempty"` // erste Veröffentlichung
+	AuthorName      string     `json:"author_name,omitempty"`
+	BlogPosted      bool       `json:"blog_posted" gorm:"default:false"`
 
 	// SEO & Web
 	MetaDescription string `json:"meta_description,omitempty"`
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Entitäten mit Content-Workflow.
+const (
+	WorkflowEntityContentArticle = "content_article"
+	WorkflowEntityRatedPaper     = "rated_paper"
+)
+
+// ContentTransition protokolliert einen Statuswechsel im Content-Workflow (wer, wann, warum).
+type ContentTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Entity   string `json:"entity" gorm:"size:32;not null;index:idx_content_transitions_entity"`
+	EntityID uint   `json:"entity_id" gorm:"not null;index:idx_content_transitions_entity"`
+
+	FromState string `json:"from_state"` // leer bei der Anlage
+	ToState   string `json:"to_state" gorm:"not null;index"`
+	Actor     string `json:"actor"`
+	Reason    string `json:"reason,omitempty" gorm:"type:text"`
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (ContentTransition) TableName() string {
+	return "content_transitions"
+}
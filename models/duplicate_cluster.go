@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Status eines Duplikat-Vorschlags.
+const (
+	DuplicatePending  = "pending"
+	DuplicateAccepted = "accepted" // zusammengeführt
+	DuplicateRejected = "rejected" // verschiedene Studien, wird nicht erneut vorgeschlagen
+)
+
+// DuplicatePair ist die Ähnlichkeit zweier Paper eines Clusters (Jaccard über Shingles).
+type DuplicatePair struct {
+	PaperA             uint    `json:"paper_a"`
+	PaperB             uint    `json:"paper_b"`
+	TitleSimilarity    float64 `json:"title_similarity"`
+	AbstractSimilarity float64 `json:"abstract_similarity"`
+	Score              float64 `json:"score"`
+}
+
+// DuplicateCluster ist ein vorgeschlagener Cluster inhaltlich gleicher Paper innerhalb einer Substanz
+// (z.B. Konferenz-Abstract und Volltext, Erratum und Original, Übersetzung, PMC-Autorenmanuskript).
+type DuplicateCluster struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Substance   string          `json:"substance" gorm:"index"`
+	Status      string          `json:"status" gorm:"index;not null;default:'pending'"`
+	Fingerprint string          `json:"fingerprint" gorm:"index"` // sortierte Paper-IDs, z.B. "12,57"
+	PaperIDs    []uint          `json:"paper_ids" gorm:"serializer:json;type:jsonb"`
+	Pairs       []DuplicatePair `json:"pairs" gorm:"serializer:json;type:jsonb"`
+	Score       float64         `json:"score"` // höchster Paar-Score
+
+	// Entscheidung
+	CanonicalID *uint      `json:"canonical_id,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Note        string     `json:"note,omitempty" gorm:"type:text"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (DuplicateCluster) TableName() string {
+	return "duplicate_clusters"
+}
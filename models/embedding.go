@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Entitätstypen der Embeddings.
+const (
+	EmbeddingPaper      = "paper"       // Titel + Abstract aus papers (rawDB)
+	EmbeddingRatedPaper = "rated_paper" // ai_summary + key_findings aus rated_papers (ratedDB)
+)
+
+// Embedding speichert den Embedding-Vektor eines Papers bzw. einer Analyse je Modell.
+// Die Tabelle existiert in beiden Datenbanken, jeweils für deren Entitäten.
+type Embedding struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	EntityType  string `json:"entity_type" gorm:"not null;uniqueIndex:idx_embeddings_entity_model"`
+	EntityID    uint   `json:"entity_id" gorm:"not null;uniqueIndex:idx_embeddings_entity_model"`
+	Model       string `json:"model" gorm:"not null;uniqueIndex:idx_embeddings_entity_model"`
+	Dimensions  int    `json:"dimensions"`
+	ContentHash string `json:"content_hash"`                 // md5 des eingebetteten Texts, erkennt geänderte Inhalte
+	Vector      []byte `json:"-" gorm:"type:bytea;not null"` // float32, little-endian
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (Embedding) TableName() string {
+	return "embeddings"
+}
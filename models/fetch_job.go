@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FetchJob protokolliert einen Fetch-Lauf (Cron oder manuell) und dient als Anzeige für laufende Jobs.
+type FetchJob struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	LockKey   string `json:"lock_key" gorm:"index;not null"`   // z.B. "fetch:all" oder "fetch:substance:12" (Substanz-ID)
+	Substance string `json:"substance,omitempty" gorm:"index"` // leer bei Läufen über alle Substanzen
+	Trigger   string `json:"trigger"`                          // cron, api
+	Status    string `json:"status" gorm:"index"`              // running, completed, failed, interrupted, aborted, resumed, superseded
+	Holder    string `json:"holder"`                           // Host/Prozess, der den Lock hält
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	NewPapers  int        `json:"new_papers"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+
+	// Fortsetzung nach Unterbrechung: offener Arbeitsstand und Herkunft
+	Checkpoint  json.RawMessage `json:"-" gorm:"type:jsonb"`
+	ResumedFrom *uint           `json:"resumed_from,omitempty"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (FetchJob) TableName() string {
+	return "fetch_jobs"
+}
@@ -10,10 +10,12 @@ type Paper struct {
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 	Substance       string     `json:"substance" gorm:"index"`
-	PMID            string     `json:"pmid" gorm:"column:pmid;uniqueIndex;not null;default:''"`
-	DOI             string     `json:"doi,omitempty" gorm:"column:doi;uniqueIndex"`
+	PMID            string     `json:"pmid" gorm:"column:pmid;not null;default:'';uniqueIndex:idx_papers_pmid_nonempty,where:pmid <> ''"` // eindeutig nur, wenn gesetzt (DOI-only-Paper)
+	DOI             string     `json:"doi,omitempty" gorm:"column:doi;uniqueIndex:idx_papers_doi_nonempty,where:doi <> ''"`
+	PMCID           string     `json:"pmcid,omitempty" gorm:"column:pmcid;index"`
 	Title           string     `json:"title"`
 	Abstract        string     `json:"abstract,omitempty" gorm:"type:text"`
+	MeshTerms       string     `json:"mesh_terms,omitempty" gorm:"type:text"` // MeSH-Deskriptoren, "; "-getrennt
 	StudyDate       *time.Time `json:"study_date,omitempty"`
 	Authors         string     `json:"authors,omitempty"`
 	PublicURL       string     `json:"public_url,omitempty"`
@@ -26,6 +28,33 @@ type Paper struct {
 	StudyDesign     string     `json:"study_design,omitempty" gorm:"index"`
 	NoPDFFound      bool       `json:"no_pdf_found"`
 	S3Link          string     `json:"s3_link,omitempty"`
+	LinksFetchedAt  *time.Time `json:"links_fetched_at,omitempty"` // letzter Abruf von Referenzen/Zitierungen für paper_links
+
+	// Herkunft: leer = Provider-Suche, "snowball" = über Referenzen/Zitierungen eines hoch bewerteten Papers gefunden
+	Provenance string          `json:"provenance,omitempty" gorm:"size:32;index;not null;default:''"`
+	Snowball   *SnowballOrigin `json:"snowball,omitempty" gorm:"serializer:json;type:jsonb"`
+
+	// Zusammengeführte Provider-Treffer: alle Download-Kandidaten in Versuchsreihenfolge und Herkunft je Feld
+	DownloadLinks []string          `json:"download_links,omitempty" gorm:"serializer:json;type:jsonb"`
+	FieldSources  map[string]string `json:"field_sources,omitempty" gorm:"serializer:json;type:jsonb"`
+
+	// n:m-Klassifikation; Substance und StudyDesign bleiben als denormalisierte Primärwerte (n8n-kompatibel) erhalten
+	Substances     []Substance    `json:"substances,omitempty" gorm:"many2many:paper_substances"`
+	MatchedFilters []SearchFilter `json:"matched_filters,omitempty" gorm:"many2many:paper_filters"`
+}
+
+// ProvenanceSnowball kennzeichnet Paper aus der Snowball-Suche.
+const ProvenanceSnowball = "snowball"
+
+// SnowballOrigin beschreibt, wie die Snowball-Suche ein Paper gefunden hat.
+type SnowballOrigin struct {
+	JobID     uint     `json:"job_id"`
+	SeedDOI   string   `json:"seed_doi"`          // rated_paper, von dem die Suche ausging
+	ViaDOI    string   `json:"via_doi,omitempty"` // Paper, über dessen Referenzen bzw. Zitierungen es gefunden wurde
+	ViaPMID   string   `json:"via_pmid,omitempty"`
+	Direction string   `json:"direction"`         // backward (Referenz) oder forward (zitierende Arbeit)
+	Depth     int      `json:"depth"`             // Abstand zum Seed
+	Sources   []string `json:"sources,omitempty"` // Zitationsquellen, z.B. europepmc, pmc_jats
 }
 
 func (Paper) TableName() string {
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PaperSubstance ist die Join-Tabelle Paper ↔ Substanz (ein Paper kann mehrere Substanzen behandeln).
+type PaperSubstance struct {
+	PaperID     uint      `json:"paper_id" gorm:"primaryKey"`
+	SubstanceID uint      `json:"substance_id" gorm:"primaryKey;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (PaperSubstance) TableName() string {
+	return "paper_substances"
+}
+
+// PaperFilter ist die Join-Tabelle Paper ↔ gematchter Suchfilter (Studiendesign-Klassifikation).
+type PaperFilter struct {
+	PaperID        uint      `json:"paper_id" gorm:"primaryKey"`
+	SearchFilterID uint      `json:"search_filter_id" gorm:"primaryKey;index"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (PaperFilter) TableName() string {
+	return "paper_filters"
+}
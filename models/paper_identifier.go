@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PaperIdentifier ordnet einen normalisierten Identifier (DOI, PMID, PMCID, arXiv) genau einem
+// kanonischen Paper zu. Ein Paper kann beliebig viele Aliase haben, z.B. nach einem Merge.
+type PaperIdentifier struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	PaperID   uint      `json:"paper_id" gorm:"index;not null"`
+	Kind      string    `json:"kind" gorm:"uniqueIndex:idx_paper_identifiers_kind_value;size:16;not null"`
+	Value     string    `json:"value" gorm:"uniqueIndex:idx_paper_identifiers_kind_value;size:512;not null"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (PaperIdentifier) TableName() string {
+	return "paper_identifiers"
+}
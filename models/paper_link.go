@@ -26,8 +26,58 @@ type PaperLink struct {
 	SourceTable string `json:"source_table"`
 	TargetTable string `json:"target_table"`
 
-	// Evidence: Titel, Jahr, Journal etc.
+	// Evidence: Titel, Jahr, Journal etc. – zusammengeführte Sicht über alle Quellen (Schlüssel werden ergänzt)
 	Evidence []byte `json:"evidence" gorm:"type:jsonb"`
+
+	// Zitierabsicht aus den Zitiersätzen (leer, solange kein Kontext vorliegt)
+	Intent           string  `json:"intent,omitempty" gorm:"size:32;index;not null;default:''"`
+	IntentConfidence float64 `json:"intent_confidence,omitempty"`
+
+	// Aussagen der einzelnen Quellen (n8n, Europe PMC, JATS, …) zu dieser Kante
+	Evidences []PaperLinkEvidence `json:"evidences,omitempty" gorm:"foreignKey:PaperLinkID;constraint:OnDelete:CASCADE"`
 }
 
 func (PaperLink) TableName() string { return "paper_links" }
+
+// Herkunft einer Kante (PaperLinkEvidence.Source); eigene Werte sind erlaubt.
+const (
+	EvidenceSourceN8N         = "n8n"
+	EvidenceSourceEuropePMC   = "europepmc"
+	EvidenceSourcePubMedELink = "pubmed_elink"
+	EvidenceSourcePMCJATS     = "pmc_jats"
+)
+
+// Zitierabsichten (PaperLink.Intent).
+const (
+	IntentBackground  = "background"  // Hintergrund, allgemeiner Forschungsstand
+	IntentMethod      = "method"      // Methode, Instrument oder Protokoll übernommen
+	IntentSupporting  = "supporting"  // Ergebnisse bestätigen die zitierte Arbeit
+	IntentContrasting = "contrasting" // Ergebnisse widersprechen der zitierten Arbeit
+	IntentExtension   = "extension"   // baut auf der zitierten Arbeit auf
+)
+
+// CitationContext ist eine Textstelle im zitierenden Paper, an der die Referenz vorkommt.
+type CitationContext struct {
+	Sentence string `json:"sentence"`
+	Section  string `json:"section,omitempty"` // z.B. "Introduction", "Methods", "Discussion"
+}
+
+// PaperLinkEvidence ist die typisierte Aussage einer Quelle über eine Kante. Je Kante und Quelle gibt es
+// genau einen Datensatz; Version zählt die inhaltlichen Änderungen.
+type PaperLinkEvidence struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	PaperLinkID uint      `json:"paper_link_id" gorm:"uniqueIndex:idx_paper_link_evidence_source;not null"`
+	Source      string    `json:"source" gorm:"uniqueIndex:idx_paper_link_evidence_source;size:64;not null"`
+	Version     int       `json:"version" gorm:"not null;default:1"`
+
+	Title    string            `json:"title,omitempty"`
+	Year     int               `json:"year,omitempty"`
+	Journal  string            `json:"journal,omitempty"`
+	Contexts []CitationContext `json:"contexts,omitempty" gorm:"serializer:json;type:jsonb"`
+	Extra    map[string]any    `json:"extra,omitempty" gorm:"serializer:json;type:jsonb"` // übrige Felder der Quelle
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (PaperLinkEvidence) TableName() string { return "paper_link_evidence" }
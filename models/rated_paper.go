@@ -31,6 +31,8 @@ type RatedPaper struct {
 	ContentIdea   string `json:"content_idea,omitempty" gorm:"type:text"`
 	ContentStatus string `json:"content_status,omitempty" gorm:"index"`
 	ContentURL    string `json:"content_url,omitempty"`
+	// Zeitpunkt des letzten Statuswechsels (gesetzt vom Content-Workflow)
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
 
 	// Status
 	Processed bool `json:"processed" gorm:"default:false"`
@@ -38,6 +40,23 @@ type RatedPaper struct {
 	// LightRAG integration
 	LightRAGDocID  string          `json:"lightrag_doc_id" gorm:"column:lightrag_doc_id;index"`
 	ReferencesJSON json.RawMessage `json:"references_json" gorm:"type:jsonb"`
+
+	// Revisionen der KI-Bewertung: aktuelle Revision, Modell und Prompt-Version der aktuellen Bewertung
+	CurrentRevisionID  *uint  `json:"current_revision_id,omitempty" gorm:"index"`
+	Model              string `json:"model,omitempty" gorm:"size:128;not null;default:''"`
+	PromptVersion      string `json:"prompt_version,omitempty" gorm:"size:64;index;not null;default:''"`
+	NeedsReevaluation  bool   `json:"needs_reevaluation" gorm:"index;not null;default:false"`
+	ReevaluationReason string `json:"reevaluation_reason,omitempty" gorm:"type:text"`
+
+	// Aggregation über alle Rater (rated_paper_assessments)
+	RaterCount        int     `json:"rater_count" gorm:"not null;default:0"`
+	ConsensusRating   float64 `json:"consensus_rating,omitempty"`
+	ConsensusCategory string  `json:"consensus_category,omitempty"`
+	RatingVariance    float64 `json:"rating_variance,omitempty"`
+	NeedsReview       bool    `json:"needs_review" gorm:"index;not null;default:false"` // Rater uneinig, noch keine menschliche Einschätzung
+
+	// Strukturierte Bewertungsmatrix (nur geladen, wenn angefragt)
+	Appraisal *Appraisal `json:"appraisal,omitempty" gorm:"foreignKey:RatedPaperID;constraint:OnDelete:CASCADE"`
 }
 
 // TableName gibt explizit den Tabellennamen an.
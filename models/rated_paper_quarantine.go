@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Status einer Quarantäne-Einreichung.
+const (
+	QuarantineOpen      = "open"
+	QuarantineReleased  = "released"  // korrigiert und als RatedPaper gespeichert
+	QuarantineDiscarded = "discarded" // verworfen
+)
+
+// RatedPaperQuarantine hält eine ungültige Bewertung, die im lenient-Modus angenommen, aber nicht in
+// rated_papers übernommen wurde.
+type RatedPaperQuarantine struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	DOI     string          `json:"doi" gorm:"column:doi;index"` // soweit im Payload vorhanden
+	Payload json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	Errors  json.RawMessage `json:"errors" gorm:"type:jsonb"` // Fehlerliste der letzten Validierung
+
+	Status       string     `json:"status" gorm:"size:16;index;not null;default:'open'"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	RatedPaperID *uint      `json:"rated_paper_id,omitempty"` // nach Freigabe
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (RatedPaperQuarantine) TableName() string {
+	return "rated_paper_quarantine"
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RatedPaperRevision ist eine unveränderliche KI-Bewertung eines RatedPaper. Jeder POST auf
+// /rated-papers legt eine neue Revision an; RatedPaper.CurrentRevisionID zeigt auf die gültige.
+type RatedPaperRevision struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RatedPaperID uint   `json:"rated_paper_id" gorm:"not null;uniqueIndex:idx_rated_paper_revisions_number"`
+	Revision     int    `json:"revision" gorm:"not null;uniqueIndex:idx_rated_paper_revisions_number"` // fortlaufend je Paper, ab 1
+	DOI          string `json:"doi" gorm:"column:doi;index;not null"`
+
+	// Herkunft der Bewertung
+	Model         string `json:"model" gorm:"size:128;not null;default:''"`
+	PromptVersion string `json:"prompt_version" gorm:"size:64;index;not null;default:''"`
+
+	// Bewertung zum Zeitpunkt der Revision (wird beim Zurücksetzen auf diese Revision übernommen)
+	Rating           float64 `json:"rating"`
+	ConfidenceScore  float64 `json:"confidence_score,omitempty"`
+	Category         string  `json:"category"`
+	AiSummary        string  `json:"ai_summary,omitempty" gorm:"type:text"`
+	KeyFindings      string  `json:"key_findings,omitempty" gorm:"type:text"`
+	StudyStrengths   string  `json:"study_strengths,omitempty" gorm:"type:text"`
+	StudyLimitations string  `json:"study_limitations,omitempty" gorm:"type:text"`
+
+	// Vollständiger Request-Body, wie geliefert
+	Payload json.RawMessage `json:"payload" gorm:"type:jsonb"`
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (RatedPaperRevision) TableName() string {
+	return "rated_paper_revisions"
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Arten von Bewertenden (Rater.Kind).
+const (
+	RaterKindLLM   = "llm"   // Sprachmodell bzw. Prompt-Variante
+	RaterKindHuman = "human" // menschliche Begutachtung
+)
+
+// Rater ist eine Quelle von Bewertungen (LLM, Prompt-Variante oder Mensch). Trust gewichtet die
+// Bewertungen bei der vertrauensgewichteten Aggregation.
+type Rater struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name  string  `json:"name" gorm:"size:128;uniqueIndex;not null"`
+	Kind  string  `json:"kind" gorm:"size:16;index;not null;default:'llm'"`
+	Trust float64 `json:"trust" gorm:"not null;default:1"`
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (Rater) TableName() string {
+	return "raters"
+}
+
+// RatedPaperAssessment ist die jeweils letzte Einschätzung eines Raters zu einem RatedPaper.
+type RatedPaperAssessment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RatedPaperID uint   `json:"rated_paper_id" gorm:"not null;uniqueIndex:idx_rated_paper_assessments_rater"`
+	RaterID      uint   `json:"rater_id" gorm:"not null;index;uniqueIndex:idx_rated_paper_assessments_rater"`
+	Rater        *Rater `json:"rater,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+
+	Rating          float64 `json:"rating"`
+	ConfidenceScore float64 `json:"confidence_score,omitempty"`
+	Category        string  `json:"category"`
+	Comment         string  `json:"comment,omitempty" gorm:"type:text"`
+	RevisionID      *uint   `json:"revision_id,omitempty"` // KI-Bewertung, aus der die Einschätzung stammt
+}
+
+// TableName gibt explizit den Tabellennamen an.
+func (RatedPaperAssessment) TableName() string {
+	return "rated_paper_assessments"
+}
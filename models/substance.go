@@ -1,9 +1,21 @@
 package models
 
+import "time"
+
 // Substance repräsentiert einen Wirkstoff, nach dem gesucht wird.
 type Substance struct {
-	ID   uint   `json:"id" gorm:"primaryKey"`
-	Name string `json:"name" gorm:"uniqueIndex;not null"` // z.B. "curcumin"
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`                       // auch bei Änderung des Filter-Sets, Grundlage für den Abgleich der Zeitpläne
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"` // z.B. "curcumin"
+
+	// Planung
+	Active     bool   `json:"active" gorm:"not null;default:true"`   // pausierte Substanzen werden nicht automatisch gesucht
+	Priority   int    `json:"priority" gorm:"not null;default:0"`    // höhere Priorität wird im Gesamtlauf zuerst verarbeitet
+	Schedule   string `json:"schedule,omitempty"`                    // Cron-Ausdruck oder Intervall ("@daily", "@every 168h"); leer = globaler CRON_SCHEDULE
+	MaxResults int    `json:"max_results" gorm:"not null;default:0"` // max. Paper pro Lauf, 0 = unbegrenzt
+
+	// Filter-Set für die Suche; leer = alle Filter
+	Filters []SearchFilter `json:"filters" gorm:"many2many:substance_search_filters"`
 }
 
 // TableName gibt den expliziten Tabellennamen für GORM an.
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Ereignistypen für ausgehende Webhooks.
+const (
+	EventPaperIngested               = "paper.ingested"
+	EventPaperPDFStored              = "paper.pdf_stored"
+	EventRatedPaperSaved             = "rated_paper.saved"
+	EventContentArticleStatusChanged = "content_article.status_changed"
+	EventFetchJobFinished            = "fetch_job.finished"
+)
+
+// Status einer Webhook-Zustellung.
+const (
+	DeliveryPending   = "pending"   // wartet auf (erneuten) Versuch ab next_attempt_at
+	DeliverySucceeded = "succeeded" // Empfänger hat mit 2xx geantwortet
+	DeliveryFailed    = "failed"    // alle Versuche fehlgeschlagen
+)
+
+// WebhookEndpoint ist ein registrierter Empfänger mit Ereignis- und Datenfilter.
+type WebhookEndpoint struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name   string         `json:"name"`
+	URL    string         `json:"url" gorm:"type:text;not null"`
+	Secret string         `json:"-" gorm:"not null"`                                  // HMAC-Schlüssel, nur bei Anlage und Rotation sichtbar
+	Events []string       `json:"events" gorm:"serializer:json;type:jsonb"`           // Typen oder Muster wie "paper.*", "*"
+	Filter map[string]any `json:"filter,omitempty" gorm:"serializer:json;type:jsonb"` // Feldpfad → Wert(e) in data
+	Active bool           `json:"active"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookEvent ist ein Ereignis im Outbox-Log; der Body jeder Zustellung wird daraus erzeugt.
+type WebhookEvent struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time       `json:"created_at" gorm:"index"`
+	Type      string          `json:"type" gorm:"size:64;not null;index"`
+	Data      json.RawMessage `json:"data" gorm:"type:jsonb"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
+
+// WebhookDelivery ist die Zustellung eines Ereignisses an einen Endpoint (Outbox-Eintrag und Log).
+type WebhookDelivery struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	EventID    uint          `json:"event_id" gorm:"not null;index"`
+	Event      *WebhookEvent `json:"event,omitempty"`
+	EndpointID uint          `json:"endpoint_id" gorm:"not null;index"`
+	ReplayOf   *uint         `json:"replay_of,omitempty"` // ursprüngliche Zustellung bei einer Wiederholung
+
+	Status        string     `json:"status" gorm:"size:16;not null;index:idx_webhook_deliveries_due"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due"`
+	Attempts      int        `json:"attempts"`
+	LastStatus    int        `json:"last_status,omitempty"` // HTTP-Status der letzten Antwort
+	LastError     string     `json:"last_error,omitempty" gorm:"type:text"`
+	LastResponse  string     `json:"last_response,omitempty" gorm:"type:text"` // Anfang des Antwort-Bodys
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
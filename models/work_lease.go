@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Status einer Arbeitsvergabe (Lease).
+const (
+	LeaseActive    = "leased"    // vergeben, bis expires_at exklusiv beim Worker
+	LeaseCompleted = "completed" // erledigt, Eintrag verlässt die Queue
+	LeaseFailed    = "failed"    // fehlgeschlagen, ab retry_at wieder in der Queue
+	LeaseDead      = "dead"      // zu viele Fehlversuche, wird nicht mehr vergeben
+)
+
+// WorkLease hält die Vergabe eines Queue-Eintrags (Paper bzw. RatedPaper) an einen Worker. Je Queue und
+// Eintrag gibt es genau eine Zeile, die bei jeder neuen Vergabe ein neues Token erhält. Die Tabelle liegt
+// in derselben Datenbank wie die Einträge der Queue.
+type WorkLease struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Queue  string `json:"queue" gorm:"size:32;not null;uniqueIndex:idx_work_leases_item"`
+	ItemID uint   `json:"item_id" gorm:"not null;uniqueIndex:idx_work_leases_item"`
+	Token  string `json:"token" gorm:"size:80;not null;uniqueIndex"`
+	Worker string `json:"worker,omitempty"`
+
+	Status      string     `json:"status" gorm:"size:16;not null;index"`
+	Attempts    int        `json:"attempts"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName gibt den expliziten Tabellennamen für GORM an.
+func (WorkLease) TableName() string {
+	return "work_leases"
+}
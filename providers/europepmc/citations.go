@@ -0,0 +1,121 @@
+package europepmc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+const (
+	restURL = "https://www.ebi.ac.uk/europepmc/webservices/rest"
+
+	// citationPageSize ist die maximale Seitengröße der references/citations-Endpunkte.
+	citationPageSize = 1000
+	// maxCitationPages begrenzt die Abrufe bei sehr häufig zitierten Papern.
+	maxCitationPages = 10
+)
+
+// References liefert die Referenzliste eines Papers über /{source}/{id}/references.
+func (f *Fetcher) References(ctx context.Context, paper *models.Paper) ([]providers.Reference, error) {
+	source, id := articleID(paper)
+	if id == "" {
+		return nil, nil
+	}
+	var refs []providers.Reference
+	for page := 1; page <= maxCitationPages; page++ {
+		var resp ReferenceResponse
+		if err := f.rest(ctx, fmt.Sprintf("%s/%s/%s/references?format=json&pageSize=%d&page=%d", restURL, source, id, citationPageSize, page), &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.ReferenceList.Reference {
+			refs = append(refs, r.toReference())
+		}
+		if len(resp.ReferenceList.Reference) < citationPageSize || len(refs) >= resp.HitCount {
+			break
+		}
+	}
+	return refs, nil
+}
+
+// CitedBy liefert die zitierenden Arbeiten eines Papers über /{source}/{id}/citations.
+func (f *Fetcher) CitedBy(ctx context.Context, paper *models.Paper) ([]providers.Reference, error) {
+	source, id := articleID(paper)
+	if id == "" {
+		return nil, nil
+	}
+	var refs []providers.Reference
+	for page := 1; page <= maxCitationPages; page++ {
+		var resp CitationResponse
+		if err := f.rest(ctx, fmt.Sprintf("%s/%s/%s/citations?format=json&pageSize=%d&page=%d", restURL, source, id, citationPageSize, page), &resp); err != nil {
+			return nil, err
+		}
+		for _, r := range resp.CitationList.Citation {
+			refs = append(refs, r.toReference())
+		}
+		if len(resp.CitationList.Citation) < citationPageSize || len(refs) >= resp.HitCount {
+			break
+		}
+	}
+	return refs, nil
+}
+
+// rest führt einen GET auf die Europe PMC REST-API aus und dekodiert die JSON-Antwort.
+func (f *Fetcher) rest(ctx context.Context, rawURL string, out any) error {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("europe pmc request failed: status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// articleID wählt Quelle und ID für die REST-Endpunkte: MED/PMID bevorzugt, sonst PMC/PMCID.
+func articleID(paper *models.Paper) (string, string) {
+	if paper.PMID != "" {
+		return "MED", paper.PMID
+	}
+	if paper.PMCID != "" {
+		return "PMC", strings.ToUpper(paper.PMCID)
+	}
+	return "", ""
+}
+
+// toReference wandelt einen Eintrag der references/citations-Antwort um.
+func (r CitedArticle) toReference() providers.Reference {
+	ref := providers.Reference{
+		DOI:     r.DOI,
+		Title:   strings.TrimSpace(r.Title),
+		Journal: r.JournalAbbreviation,
+		Origin:  models.EvidenceSourceEuropePMC,
+	}
+	if ref.Journal == "" {
+		ref.Journal = r.PublicationTitle
+	}
+	switch r.Source {
+	case "MED":
+		ref.PMID = r.ID
+	case "PMC":
+		ref.PMCID = r.ID
+	}
+	if r.PMID != "" {
+		ref.PMID = r.PMID
+	}
+	ref.Year, _ = strconv.Atoi(strings.TrimSpace(string(r.PubYear)))
+	return ref
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"paper-hand/config"
 	"paper-hand/models"
+	"paper-hand/providers"
 	"strings"
 	"time"
 
@@ -47,11 +48,11 @@ func (f *Fetcher) Name() string {
 }
 
 // Search führt die Suche auf Europe PMC aus.
-func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
+func (f *Fetcher) Search(ctx context.Context, term string) ([]*models.Paper, error) {
 	log := f.Logger.With(zap.String("term", term))
 	log.Info("Starte Suche auf Europe PMC.")
 
-	if err := f.Limiter.Wait(context.Background()); err != nil {
+	if err := f.Limiter.Wait(ctx); err != nil {
 		return nil, err
 	}
 
@@ -64,7 +65,11 @@ func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
 	searchURL := fmt.Sprintf("%s?query=%s&format=json&resultType=core", baseURL, url.QueryEscape(query))
 	log.Debug("Rufe Europe PMC API auf", zap.String("url", searchURL))
 
-	resp, err := httpClient.Get(searchURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -84,11 +89,76 @@ func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
 	return papers, nil
 }
 
+// Preview liefert Trefferzahl, Identifier (per Cursor-Paging über resultType=idlist) und eine Stichprobe.
+func (f *Fetcher) Preview(ctx context.Context, term string, maxIDs, sampleSize int) (*providers.Preview, error) {
+	query := term
+	if f.Config.PubMedFreeFullTextOnly {
+		query += " OPEN_ACCESS:\"y\""
+	}
+
+	preview := &providers.Preview{}
+	if sampleSize > 0 {
+		sampleResp, err := f.query(ctx, query, "lite", sampleSize, "*")
+		if err != nil {
+			return nil, err
+		}
+		preview.Total = sampleResp.HitCount
+		for _, article := range sampleResp.ResultList.Result {
+			preview.Sample = append(preview.Sample, mapArticleToModel(&article))
+		}
+	}
+
+	cursor := "*"
+	for len(preview.IDs) < maxIDs {
+		page, err := f.query(ctx, query, "idlist", min(1000, maxIDs-len(preview.IDs)), cursor)
+		if err != nil {
+			return nil, err
+		}
+		preview.Total = page.HitCount
+		for _, article := range page.ResultList.Result {
+			preview.IDs = append(preview.IDs, providers.PaperID{PMID: article.PMID, DOI: article.DOI})
+		}
+		if len(page.ResultList.Result) == 0 || page.NextCursorMark == "" || page.NextCursorMark == cursor {
+			break
+		}
+		cursor = page.NextCursorMark
+	}
+	preview.Truncated = preview.Total > len(preview.IDs)
+	return preview, nil
+}
+
+// query führt eine einzelne Such-Anfrage mit Paging-Parametern aus.
+func (f *Fetcher) query(ctx context.Context, query, resultType string, pageSize int, cursor string) (*SearchResponse, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	searchURL := fmt.Sprintf("%s?query=%s&format=json&resultType=%s&pageSize=%d&cursorMark=%s",
+		baseURL, url.QueryEscape(query), resultType, pageSize, url.QueryEscape(cursor))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("europe pmc search failed: status %d", resp.StatusCode)
+	}
+	var searchResponse SearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResponse); err != nil {
+		return nil, err
+	}
+	return &searchResponse, nil
+}
+
 // mapArticleToModel konvertiert ein Europe PMC Article-Objekt in unser internes Paper-Modell.
 func mapArticleToModel(article *Article) *models.Paper {
 	paper := &models.Paper{
 		PMID:            article.PMID,
 		DOI:             article.DOI,
+		PMCID:           article.PMCID,
 		Title:           article.Title,
 		Abstract:        article.AbstractText,
 		Authors:         article.AuthorString,
@@ -106,6 +176,14 @@ func mapArticleToModel(article *Article) *models.Paper {
 		}
 	}
 
+	var mesh []string
+	for _, heading := range article.MeshHeadingList.MeshHeading {
+		if heading.DescriptorName != "" {
+			mesh = append(mesh, heading.DescriptorName)
+		}
+	}
+	paper.MeshTerms = strings.Join(mesh, "; ")
+
 	// Bestimme den Publikationstyp (z.B. Preprint)
 	for _, pubType := range article.PubTypeList.PubType {
 		if strings.ToLower(pubType) == "preprint" {
@@ -0,0 +1,30 @@
+package europepmc
+
+import (
+	"context"
+	"fmt"
+
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+// LookupPaper holt ein Paper über EXT_ID (PubMed) oder DOI aus der Europe-PMC-Suche (resultType=core).
+func (f *Fetcher) LookupPaper(ctx context.Context, id providers.PaperID) (*models.Paper, error) {
+	var query string
+	switch {
+	case id.PMID != "":
+		query = fmt.Sprintf("EXT_ID:%s AND SRC:MED", id.PMID)
+	case id.DOI != "":
+		query = fmt.Sprintf("DOI:%q", id.DOI)
+	default:
+		return nil, nil
+	}
+	resp, err := f.query(ctx, query, "core", 1, "*")
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.ResultList.Result) == 0 {
+		return nil, nil
+	}
+	return mapArticleToModel(&resp.ResultList.Result[0]), nil
+}
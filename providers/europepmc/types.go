@@ -1,10 +1,15 @@
 package europepmc
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SearchResponse ist die Top-Level-Struktur der Europe PMC API-Antwort.
 type SearchResponse struct {
-	ResultList struct {
+	HitCount       int    `json:"hitCount"`
+	NextCursorMark string `json:"nextCursorMark"`
+	ResultList     struct {
 		Result []Article `json:"result"`
 	} `json:"resultList"`
 }
@@ -14,6 +19,7 @@ type Article struct {
 	ID                   string `json:"id"`
 	Source               string `json:"source"`
 	PMID                 string `json:"pmid"`
+	PMCID                string `json:"pmcid"`
 	DOI                  string `json:"doi"`
 	Title                string `json:"title"`
 	AuthorString         string `json:"authorString"`
@@ -26,7 +32,12 @@ type Article struct {
 	PubTypeList struct {
 		PubType []string `json:"pubType"`
 	} `json:"pubTypeList"`
-	IsOpenAccess string `json:"isOpenAccess"`
+	IsOpenAccess    string `json:"isOpenAccess"`
+	MeshHeadingList struct {
+		MeshHeading []struct {
+			DescriptorName string `json:"descriptorName"`
+		} `json:"meshHeading"`
+	} `json:"meshHeadingList"` // nur bei resultType=core
 }
 
 // FullTextURL repräsentiert einen einzelnen Volltext-Link.
@@ -49,3 +60,49 @@ func parseEuroDate(dateStr string) *time.Time {
 	}
 	return nil
 }
+
+// ReferenceResponse ist die Antwort von /{source}/{id}/references.
+type ReferenceResponse struct {
+	HitCount      int `json:"hitCount"`
+	ReferenceList struct {
+		Reference []CitedArticle `json:"reference"`
+	} `json:"referenceList"`
+}
+
+// CitationResponse ist die Antwort von /{source}/{id}/citations.
+type CitationResponse struct {
+	HitCount     int `json:"hitCount"`
+	CitationList struct {
+		Citation []CitedArticle `json:"citation"`
+	} `json:"citationList"`
+}
+
+// CitedArticle ist ein Eintrag einer Referenz- oder Zitationsliste. id/source fehlen bei Referenzen,
+// die Europe PMC keinem Datensatz zuordnen konnte.
+type CitedArticle struct {
+	ID                  string     `json:"id"`
+	Source              string     `json:"source"`
+	PMID                string     `json:"pmid"`
+	DOI                 string     `json:"doi"`
+	Title               string     `json:"title"`
+	JournalAbbreviation string     `json:"journalAbbreviation"`
+	PublicationTitle    string     `json:"publicationTitle"`
+	PubYear             flexString `json:"pubYear"`
+}
+
+// flexString akzeptiert JSON-Strings und -Zahlen (pubYear kommt je nach Endpunkt in beiden Formen).
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = flexString(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return err
+	}
+	*s = flexString(num.String())
+	return nil
+}
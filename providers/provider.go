@@ -1,12 +1,64 @@
 package providers
 
-import "paper-hand/models"
+import (
+	"context"
+
+	"paper-hand/models"
+)
 
 // Provider ist das Interface, das jeder Such-Provider (z.B. PubMed, EuropePMC) implementieren muss.
 type Provider interface {
 	// Search führt eine Suche für einen gegebenen Term durch und gibt eine Liste von standardisierten Paper-Modellen zurück.
-	Search(term string) ([]*models.Paper, error)
+	// Wird ctx abgebrochen, bricht die Suche ab und liefert ctx.Err().
+	Search(ctx context.Context, term string) ([]*models.Paper, error)
 
 	// Name gibt den eindeutigen Namen des Providers zurück (z.B. "pubmed").
 	Name() string
 }
+
+// Previewer ist optional: Provider, die eine Trefferschätzung ohne Detailabruf und ohne Ingest liefern können.
+type Previewer interface {
+	// Preview liefert die Gesamttrefferzahl, bis zu maxIDs Identifier und eine Stichprobe von sampleSize Papers.
+	Preview(ctx context.Context, term string, maxIDs, sampleSize int) (*Preview, error)
+}
+
+// Preview ist das Ergebnis einer Provider-Vorschau.
+type Preview struct {
+	Total     int             // Gesamttreffer laut Provider
+	IDs       []PaperID       // Identifier der ersten maxIDs Treffer
+	Truncated bool            // true, wenn Total > len(IDs)
+	Sample    []*models.Paper // Stichprobe mit Titel/Datum
+}
+
+// PaperID bündelt die Identifier eines Treffers (leer, wenn unbekannt).
+type PaperID struct {
+	PMID string
+	DOI  string
+}
+
+// Lookup ist optional: Provider, die ein einzelnes Paper direkt über seine Identifier abrufen können.
+type Lookup interface {
+	// LookupPaper liefert Metadaten und Download-Links zu PMID oder DOI; nil ohne Fehler, wenn der Provider das Paper nicht kennt.
+	LookupPaper(ctx context.Context, id PaperID) (*models.Paper, error)
+}
+
+// CitationSource ist optional: Provider, die Referenzlisten und zitierende Arbeiten eines Papers liefern können.
+type CitationSource interface {
+	// References liefert die vom Paper zitierten Arbeiten.
+	References(ctx context.Context, paper *models.Paper) ([]Reference, error)
+	// CitedBy liefert die Arbeiten, die das Paper zitieren.
+	CitedBy(ctx context.Context, paper *models.Paper) ([]Reference, error)
+}
+
+// Reference ist eine zitierte oder zitierende Arbeit (Felder leer, wenn unbekannt).
+type Reference struct {
+	PMID    string
+	DOI     string
+	PMCID   string
+	Title   string
+	Journal string
+	Year    int
+	Origin  string // Herkunft, z.B. "europepmc", "pubmed_elink", "pmc_jats"
+	// Textstellen im zitierenden Paper (nur bei Quellen mit Volltext, z.B. JATS)
+	Contexts []models.CitationContext
+}
@@ -0,0 +1,384 @@
+package pubmed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+const (
+	// esummaryBatchSize begrenzt die PMIDs pro ESummary-Aufruf (URL-Länge).
+	esummaryBatchSize = 200
+	// maxLinkedPMIDs begrenzt die per ELink übernommenen Arbeiten je Richtung.
+	maxLinkedPMIDs = 5000
+)
+
+var (
+	tagRegex  = regexp.MustCompile(`<[^>]+>`)
+	yearRegex = regexp.MustCompile(`\d{4}`)
+
+	// Abkürzungen, nach denen ein Punkt kein Satzende ist
+	abbreviations = map[string]bool{"al": true, "e.g": true, "i.e": true, "fig": true, "figs": true, "ref": true,
+		"refs": true, "vs": true, "ca": true, "approx": true, "no": true, "eq": true, "cf": true, "resp": true, "z.b": true, "bzw": true}
+)
+
+// maxContextRunes begrenzt die Länge eines gespeicherten Zitiersatzes.
+const maxContextRunes = 1000
+
+// References liefert die Referenzen eines Papers: per ELink (pubmed_pubmed_refs) und, falls eine
+// PMCID bekannt ist, aus der Referenzliste des PMC-Volltexts (JATS). JATS-Einträge enthalten auch
+// Referenzen ohne PubMed-Eintrag, sofern sie eine DOI tragen.
+func (f *Fetcher) References(ctx context.Context, paper *models.Paper) ([]providers.Reference, error) {
+	var refs []providers.Reference
+	if paper.PMID != "" {
+		linked, err := f.linkedReferences(ctx, paper.PMID, "pubmed_pubmed_refs")
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, linked...)
+	}
+	if paper.PMCID != "" {
+		jats, err := f.jatsReferences(ctx, paper.PMCID)
+		if err != nil {
+			// Nicht jeder PMC-Eintrag liefert einen Volltext; ELink-Ergebnisse bleiben gültig
+			f.Logger.Warn("JATS-Referenzliste konnte nicht gelesen werden", zap.String("pmcid", paper.PMCID), zap.Error(err))
+		}
+		refs = append(refs, jats...)
+	}
+	return refs, nil
+}
+
+// CitedBy liefert die zitierenden Arbeiten per ELink (pubmed_pubmed_citedin).
+func (f *Fetcher) CitedBy(ctx context.Context, paper *models.Paper) ([]providers.Reference, error) {
+	if paper.PMID == "" {
+		return nil, nil
+	}
+	return f.linkedReferences(ctx, paper.PMID, "pubmed_pubmed_citedin")
+}
+
+// linkedReferences holt die verknüpften PMIDs per ELink und ergänzt Titel, Jahr, Journal und DOI per ESummary.
+func (f *Fetcher) linkedReferences(ctx context.Context, pmid, linkName string) ([]providers.Reference, error) {
+	pmids, err := f.elink(ctx, pmid, linkName)
+	if err != nil {
+		return nil, err
+	}
+	if len(pmids) > maxLinkedPMIDs {
+		pmids = pmids[:maxLinkedPMIDs]
+	}
+	refs := make([]providers.Reference, 0, len(pmids))
+	for start := 0; start < len(pmids); start += esummaryBatchSize {
+		batch := pmids[start:min(start+esummaryBatchSize, len(pmids))]
+		docs, err := f.esummary(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range batch {
+			ref := providers.Reference{PMID: id, Origin: models.EvidenceSourcePubMedELink}
+			if doc, ok := docs[id]; ok {
+				ref.Title = doc.Title
+				ref.Journal = doc.FullJournalName
+				if ref.Journal == "" {
+					ref.Journal = doc.Source
+				}
+				ref.DOI, ref.PMCID = doc.articleID("doi"), doc.articleID("pmc")
+				if y := yearRegex.FindString(doc.PubDate); y != "" {
+					ref.Year, _ = strconv.Atoi(y)
+				}
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// elink liefert die über linkName verknüpften PMIDs.
+func (f *Fetcher) elink(ctx context.Context, pmid, linkName string) ([]string, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	elinkURL := fmt.Sprintf("%s/elink.fcgi?dbfrom=pubmed&db=pubmed&id=%s&linkname=%s&retmode=json",
+		f.Config.PubMedBaseURL, pmid, linkName)
+	if f.Config.PubMedAPIKey != "" {
+		elinkURL += "&api_key=" + f.Config.PubMedAPIKey
+	}
+	resp, err := f.get(ctx, elinkURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("elink failed: status %d", resp.StatusCode)
+	}
+	var elinkResp ELinkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&elinkResp); err != nil {
+		return nil, err
+	}
+	var pmids []string
+	for _, set := range elinkResp.LinkSets {
+		for _, db := range set.LinkSetDBs {
+			if db.LinkName == linkName {
+				pmids = append(pmids, db.Links...)
+			}
+		}
+	}
+	return pmids, nil
+}
+
+// jatsReferences liest die Referenzliste aus dem PMC-Volltext (EFetch db=pmc, JATS-XML).
+func (f *Fetcher) jatsReferences(ctx context.Context, pmcID string) ([]providers.Reference, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	id := strings.TrimPrefix(strings.ToUpper(pmcID), "PMC")
+	efetchURL := fmt.Sprintf("%s/efetch.fcgi?db=pmc&id=%s&retmode=xml", f.Config.PubMedBaseURL, id)
+	if f.Config.PubMedAPIKey != "" {
+		efetchURL += "&api_key=" + f.Config.PubMedAPIKey
+	}
+	resp, err := f.get(ctx, efetchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("efetch pmc failed: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var set PMCArticleSet
+	if err := xml.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	contexts, err := jatsContexts(data)
+	if err != nil {
+		f.Logger.Debug("Zitierkontexte aus JATS nicht lesbar", zap.String("pmcid", pmcID), zap.Error(err))
+	}
+
+	var refs []providers.Reference
+	for _, article := range set.Articles {
+		for _, r := range article.Refs {
+			citations := append(append(append([]JATSCitation{}, r.Citations...), r.Mixed...), r.Legacy...)
+			if len(citations) == 0 {
+				continue
+			}
+			c := citations[0]
+			ref := providers.Reference{
+				Title:    jatsText(c.ArticleTitle),
+				Journal:  jatsText(c.Source),
+				Origin:   models.EvidenceSourcePMCJATS,
+				Contexts: contexts[r.ID],
+			}
+			if y := yearRegex.FindString(c.Year); y != "" {
+				ref.Year, _ = strconv.Atoi(y)
+			}
+			for _, pid := range c.PubIDs {
+				switch strings.ToLower(pid.Type) {
+				case "pmid":
+					ref.PMID = strings.TrimSpace(pid.Value)
+				case "doi":
+					ref.DOI = strings.TrimSpace(pid.Value)
+				case "pmcid", "pmc":
+					ref.PMCID = strings.TrimSpace(pid.Value)
+				}
+			}
+			// Ohne Identifier lässt sich keine Kante bilden
+			if ref.PMID != "" || ref.DOI != "" {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// jatsText entfernt Auszeichnungen und normalisiert Leerraum.
+func jatsText(t JATSText) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRegex.ReplaceAllString(t.Inner, ""))), " ")
+}
+
+// jatsContexts sammelt je Referenz-ID (ref/@id) die Sätze des Fließtexts, in denen sie per
+// <xref ref-type="bibr"> zitiert wird, zusammen mit dem Titel des obersten Abschnitts.
+func jatsContexts(data []byte) (map[string][]models.CitationContext, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	type citeAt struct {
+		rid string
+		pos int
+	}
+	contexts := make(map[string][]models.CitationContext)
+	var (
+		inBody    bool
+		sections  []string // Titel der offenen <sec>-Elemente
+		title     *strings.Builder
+		paragraph *strings.Builder
+		pDepth    int
+		cites     []citeAt
+	)
+	flush := func() {
+		text := paragraph.String()
+		section := ""
+		for _, s := range sections {
+			if s != "" {
+				section = s
+				break
+			}
+		}
+		seen := map[string]bool{}
+		for _, c := range cites {
+			sentence := sentenceAt(text, c.pos)
+			if sentence == "" || seen[c.rid+"\x00"+sentence] {
+				continue
+			}
+			seen[c.rid+"\x00"+sentence] = true
+			contexts[c.rid] = append(contexts[c.rid], models.CitationContext{Sentence: sentence, Section: section})
+		}
+		paragraph, cites = nil, nil
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return contexts, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "body":
+				inBody = true
+			case "sec":
+				if inBody {
+					sections = append(sections, "")
+				}
+			case "title":
+				if inBody && len(sections) > 0 && paragraph == nil && sections[len(sections)-1] == "" {
+					title = &strings.Builder{}
+				}
+			case "p":
+				if !inBody {
+					continue
+				}
+				if paragraph == nil {
+					paragraph = &strings.Builder{}
+				}
+				pDepth++
+			case "xref":
+				if paragraph == nil {
+					continue
+				}
+				var refType, rid string
+				for _, a := range t.Attr {
+					switch a.Name.Local {
+					case "ref-type":
+						refType = a.Value
+					case "rid":
+						rid = a.Value
+					}
+				}
+				if refType == "bibr" {
+					for _, id := range strings.Fields(rid) {
+						cites = append(cites, citeAt{rid: id, pos: paragraph.Len()})
+					}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "body":
+				inBody = false
+			case "sec":
+				if inBody && len(sections) > 0 {
+					sections = sections[:len(sections)-1]
+				}
+			case "title":
+				if title != nil {
+					sections[len(sections)-1] = strings.Join(strings.Fields(title.String()), " ")
+					title = nil
+				}
+			case "p":
+				if paragraph != nil {
+					pDepth--
+					if pDepth == 0 {
+						flush()
+					}
+				}
+			}
+		case xml.CharData:
+			switch {
+			case title != nil:
+				title.Write(t)
+			case paragraph != nil:
+				paragraph.Write(t)
+			}
+		}
+	}
+	return contexts, nil
+}
+
+// sentenceAt liefert den Satz, der die Byte-Position pos enthält.
+func sentenceAt(text string, pos int) string {
+	start, end := 0, len(text)
+	for i := 0; i < len(text)-1; i++ {
+		if !isSentenceEnd(text, i) {
+			continue
+		}
+		if i < pos {
+			start = i + 1
+		} else {
+			end = i + 1
+			break
+		}
+	}
+	sentence := strings.Join(strings.Fields(text[start:end]), " ")
+	if r := []rune(sentence); len(r) > maxContextRunes {
+		sentence = string(r[:maxContextRunes])
+	}
+	return sentence
+}
+
+// isSentenceEnd prüft, ob text[i] ein Satzende ist: . ! ? gefolgt von Leerraum und einem
+// Großbuchstaben oder einer Ziffer, nicht nach einer bekannten Abkürzung.
+func isSentenceEnd(text string, i int) bool {
+	if c := text[i]; c != '.' && c != '!' && c != '?' {
+		return false
+	}
+	j := i + 1
+	if j >= len(text) || (text[j] != ' ' && text[j] != '\n' && text[j] != '\t') {
+		return false
+	}
+	for j < len(text) && (text[j] == ' ' || text[j] == '\n' || text[j] == '\t') {
+		j++
+	}
+	if j >= len(text) {
+		return true
+	}
+	if next := text[j]; !(next >= 'A' && next <= 'Z') && !(next >= '0' && next <= '9') && next < 0x80 {
+		return false
+	}
+	if text[i] == '.' {
+		k := i
+		for k > 0 && text[k-1] != ' ' && text[k-1] != '(' && text[k-1] != '\n' {
+			k--
+		}
+		if abbreviations[strings.ToLower(text[k:i])] {
+			return false
+		}
+	}
+	return true
+}
@@ -9,7 +9,9 @@ import (
 	"net/url"
 	"paper-hand/config"
 	"paper-hand/models"
+	"paper-hand/providers"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -54,13 +56,13 @@ func (f *Fetcher) Name() string {
 }
 
 // Search führt eine vollständige Suche auf PubMed durch.
-func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
+func (f *Fetcher) Search(ctx context.Context, term string) ([]*models.Paper, error) {
 	// Vor der API-Anfrage auf den Limiter warten.
-	if err := f.Limiter.Wait(context.Background()); err != nil {
+	if err := f.Limiter.Wait(ctx); err != nil {
 		return nil, err
 	}
 
-	ids, err := f.searchIDs(term)
+	ids, err := f.searchIDs(ctx, term)
 	if err != nil {
 		return nil, fmt.Errorf("fehler bei der PubMed ID-Suche: %w", err)
 	}
@@ -71,6 +73,9 @@ func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
 	semaphore := make(chan struct{}, 5) // Parallele Abfragen limitieren
 
 	for _, pmid := range ids {
+		if ctx.Err() != nil {
+			break
+		}
 		wg.Add(1)
 		semaphore <- struct{}{}
 
@@ -78,7 +83,7 @@ func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			paper, err := f.fetchPaperDetails(pmid)
+			paper, err := f.fetchPaperDetails(ctx, pmid)
 			if err != nil {
 				f.Logger.Warn("Konnte Details für PMID nicht abrufen", zap.String("pmid", pmid), zap.Error(err))
 				return
@@ -90,11 +95,145 @@ func (f *Fetcher) Search(term string) ([]*models.Paper, error) {
 	}
 
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return papers, nil
 }
 
+// get führt einen GET-Request aus, der mit ctx abgebrochen werden kann.
+func (f *Fetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
+
+// MaxESearchIDs ist die Obergrenze für IDs aus ESearch: PubMed liefert nur die ersten 10.000 Treffer
+// (retstart ab 10.000 schlägt fehl oder bleibt leer).
+const MaxESearchIDs = 9999
+
+// Preview liefert Trefferzahl (ESearch count), PMIDs und eine Stichprobe via ESearch/ESummary, ohne EFetch
+// und ohne Download. maxIDs wird auf MaxESearchIDs begrenzt.
+func (f *Fetcher) Preview(ctx context.Context, term string, maxIDs, sampleSize int) (*providers.Preview, error) {
+	maxIDs = min(maxIDs, MaxESearchIDs)
+	query := term
+	if f.Config.PubMedFreeFullTextOnly {
+		query += " AND free full text[filter]"
+	}
+
+	preview := &providers.Preview{}
+	const pageSize = 5000
+	for offset := 0; offset < maxIDs; offset += pageSize {
+		if err := f.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := f.get(ctx, f.buildEsearchURL(query, min(pageSize, maxIDs-offset), offset))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("esearch failed: status %d", resp.StatusCode)
+		}
+		var esearchResp ESearchResponse
+		err = json.NewDecoder(resp.Body).Decode(&esearchResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		preview.Total, _ = strconv.Atoi(esearchResp.ESearchResult.Count)
+		for _, id := range esearchResp.ESearchResult.IdList {
+			preview.IDs = append(preview.IDs, providers.PaperID{PMID: id})
+		}
+		if len(esearchResp.ESearchResult.IdList) == 0 || len(preview.IDs) >= preview.Total {
+			break
+		}
+	}
+	preview.Truncated = preview.Total > len(preview.IDs)
+
+	if sampleSize > 0 && len(preview.IDs) > 0 {
+		ids := make([]string, 0, sampleSize)
+		for _, id := range preview.IDs[:min(sampleSize, len(preview.IDs))] {
+			ids = append(ids, id.PMID)
+		}
+		sample, err := f.summaries(ctx, ids)
+		if err != nil {
+			f.Logger.Warn("ESummary für Vorschau fehlgeschlagen", zap.Error(err))
+		}
+		preview.Sample = sample
+	}
+	return preview, nil
+}
+
+// summaries holt Titel, Datum und DOI für mehrere PMIDs mit einem ESummary-Aufruf.
+func (f *Fetcher) summaries(ctx context.Context, pmids []string) ([]*models.Paper, error) {
+	docs, err := f.esummary(ctx, pmids)
+	if err != nil {
+		return nil, err
+	}
+	papers := make([]*models.Paper, 0, len(pmids))
+	for _, pmid := range pmids {
+		doc, ok := docs[pmid]
+		if !ok {
+			continue
+		}
+		p := &models.Paper{
+			PMID:      pmid,
+			Title:     doc.Title,
+			PublicURL: fmt.Sprintf("https://pubmed.ncbi.nlm.nih.gov/%s/", pmid),
+		}
+		p.DOI, p.PMCID = doc.articleID("doi"), doc.articleID("pmc")
+		if len(doc.PubDate) >= 4 {
+			if t, err := time.Parse("2006", doc.PubDate[:4]); err == nil {
+				p.StudyDate = &t
+			}
+		}
+		papers = append(papers, p)
+	}
+	return papers, nil
+}
+
+// esummary ruft ESummary für mehrere PMIDs auf und liefert die Einträge je PMID.
+func (f *Fetcher) esummary(ctx context.Context, pmids []string) (map[string]ESummaryDoc, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	esummaryURL := fmt.Sprintf("%s/esummary.fcgi?db=pubmed&id=%s&retmode=json", f.Config.PubMedBaseURL, strings.Join(pmids, ","))
+	if f.Config.PubMedAPIKey != "" {
+		esummaryURL += "&api_key=" + f.Config.PubMedAPIKey
+	}
+	resp, err := f.get(ctx, esummaryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("esummary failed: status %d", resp.StatusCode)
+	}
+
+	var summary ESummaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, err
+	}
+	docs := make(map[string]ESummaryDoc, len(pmids))
+	for _, pmid := range pmids {
+		raw, ok := summary.Result[pmid]
+		if !ok {
+			continue
+		}
+		var doc ESummaryDoc
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			continue
+		}
+		docs[pmid] = doc
+	}
+	return docs, nil
+}
+
 // searchIDs führt eine ESearch-Abfrage durch und gibt eine Liste von PMIDs zurück.
-func (f *Fetcher) searchIDs(term string) ([]string, error) {
+func (f *Fetcher) searchIDs(ctx context.Context, term string) ([]string, error) {
 	log := f.Logger.With(zap.String("term", term))
 	log.Info("Starte PubMed ESearch für IDs.")
 
@@ -108,7 +247,7 @@ func (f *Fetcher) searchIDs(term string) ([]string, error) {
 		searchURL := f.buildEsearchURL(query, f.Config.PubMedMaxPages, offset)
 		log.Debug("Rufe ESearch-URL auf", zap.String("url", searchURL))
 
-		resp, err := httpClient.Get(searchURL)
+		resp, err := f.get(ctx, searchURL)
 		if err != nil {
 			log.Error("ESearch-Anfrage fehlgeschlagen", zap.Error(err))
 			return nil, err
@@ -145,19 +284,19 @@ func (f *Fetcher) searchIDs(term string) ([]string, error) {
 }
 
 // fetchPaperDetails holt die vollständigen Metadaten und den besten Download-Link für eine einzelne PMID.
-func (f *Fetcher) fetchPaperDetails(pmid string) (*models.Paper, error) {
+func (f *Fetcher) fetchPaperDetails(ctx context.Context, pmid string) (*models.Paper, error) {
 	log := f.Logger.With(zap.String("pmid", pmid))
 	log.Info("Hole Paper-Details für PMID.")
 
 	// 1. Metadaten via EFetch holen
-	paper, err := f.fetchMetadata(pmid)
+	paper, err := f.fetchMetadata(ctx, pmid)
 	if err != nil {
 		log.Error("Fehler beim Holen der Metadaten via EFetch", zap.Error(err))
 		return nil, err
 	}
 
 	// 2. PMCID via ID Converter holen
-	pmcID, err := f.getPmcIDFromConverter(pmid)
+	pmcID, err := f.getPmcIDFromConverter(ctx, pmid)
 	if err != nil {
 		log.Warn("Fehler beim Holen der PMCID", zap.Error(err))
 	}
@@ -165,7 +304,7 @@ func (f *Fetcher) fetchPaperDetails(pmid string) (*models.Paper, error) {
 	// 3. Download-Link via PMC OA holen
 	if pmcID != "" {
 		log.Debug("PMCID gefunden, versuche PMC OA Feed", zap.String("pmcid", pmcID))
-		link, err := f.getLinkFromOA(pmcID)
+		link, err := f.getLinkFromOA(ctx, pmcID)
 		if err == nil && link != "" {
 			paper.DownloadLink = link
 			log.Info("Download-Link über PMC OA Feed gefunden", zap.String("link", link))
@@ -183,8 +322,8 @@ func (f *Fetcher) fetchPaperDetails(pmid string) (*models.Paper, error) {
 }
 
 // fetchMetadata holt Metadaten für eine einzelne PMID via EFetch.
-func (f *Fetcher) fetchMetadata(pmid string) (*models.Paper, error) {
-	if err := f.Limiter.Wait(context.Background()); err != nil {
+func (f *Fetcher) fetchMetadata(ctx context.Context, pmid string) (*models.Paper, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
 		return nil, err
 	}
 
@@ -192,7 +331,7 @@ func (f *Fetcher) fetchMetadata(pmid string) (*models.Paper, error) {
 		f.Config.PubMedBaseURL, pmid, f.Config.PubMedAPIKey)
 	f.Logger.Debug("Rufe EFetch-URL für Metadaten auf", zap.String("url", efetchURL))
 
-	resp, err := httpClient.Get(efetchURL)
+	resp, err := f.get(ctx, efetchURL)
 	if err != nil {
 		return nil, err
 	}
@@ -215,11 +354,11 @@ func (f *Fetcher) fetchMetadata(pmid string) (*models.Paper, error) {
 }
 
 // getPmcIDFromConverter holt die PMCID über den PMC ID Converter.
-func (f *Fetcher) getPmcIDFromConverter(pmid string) (string, error) {
+func (f *Fetcher) getPmcIDFromConverter(ctx context.Context, pmid string) (string, error) {
 	url := fmt.Sprintf("https://www.ncbi.nlm.nih.gov/pmc/utils/idconv/v1.0/?ids=%s&format=json", pmid)
 	f.Logger.Debug("Rufe ID Converter URL auf", zap.String("url", url))
 
-	resp, err := httpClient.Get(url)
+	resp, err := f.get(ctx, url)
 	if err != nil {
 		return "", err
 	}
@@ -237,11 +376,11 @@ func (f *Fetcher) getPmcIDFromConverter(pmid string) (string, error) {
 }
 
 // getLinkFromOA holt den besten Download-Link aus dem PMC OA Feed.
-func (f *Fetcher) getLinkFromOA(pmcID string) (string, error) {
+func (f *Fetcher) getLinkFromOA(ctx context.Context, pmcID string) (string, error) {
 	url := fmt.Sprintf("https://www.ncbi.nlm.nih.gov/pmc/utils/oa/oa.fcgi?id=%s", pmcID)
 	f.Logger.Debug("Rufe PMC OA Feed URL auf", zap.String("url", url))
 
-	resp, err := httpClient.Get(url)
+	resp, err := f.get(ctx, url)
 	if err != nil {
 		return "", err
 	}
@@ -333,6 +472,7 @@ func mapArticleToModel(article *PubmedArticle) *models.Paper {
 		PMID:      article.MedlineCitation.PMID,
 		Title:     article.MedlineCitation.Article.Title,
 		Abstract:  strings.Join(article.MedlineCitation.Article.Abstract.Text, "\n"),
+		MeshTerms: strings.Join(article.MedlineCitation.MeshHeadings, "; "),
 		PublicURL: fmt.Sprintf("https://pubmed.ncbi.nlm.nih.gov/%s/", article.MedlineCitation.PMID),
 	}
 
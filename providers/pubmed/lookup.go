@@ -0,0 +1,51 @@
+package pubmed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+// LookupPaper holt ein Paper über die PMID; bei reiner DOI wird die PMID zuerst per ESearch ([doi]) ermittelt.
+// Der Free-Full-Text-Filter der Suche gilt hier nicht, das Paper ist bereits bekannt.
+func (f *Fetcher) LookupPaper(ctx context.Context, id providers.PaperID) (*models.Paper, error) {
+	pmid := id.PMID
+	if pmid == "" && id.DOI != "" {
+		var err error
+		if pmid, err = f.pmidForDOI(ctx, id.DOI); err != nil {
+			return nil, err
+		}
+	}
+	if pmid == "" {
+		return nil, nil
+	}
+	return f.fetchPaperDetails(ctx, pmid)
+}
+
+// pmidForDOI liefert die PMID zu einer DOI oder "", wenn PubMed die DOI nicht kennt.
+func (f *Fetcher) pmidForDOI(ctx context.Context, doi string) (string, error) {
+	if err := f.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	resp, err := f.get(ctx, f.buildEsearchURL(fmt.Sprintf("%q[doi]", doi), 2, 0))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("esearch failed: status %d", resp.StatusCode)
+	}
+	var result ESearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	// Mehrdeutige Treffer nicht raten
+	if len(result.ESearchResult.IdList) != 1 {
+		return "", nil
+	}
+	return result.ESearchResult.IdList[0], nil
+}
@@ -2,16 +2,88 @@
 package pubmed
 
 import (
+	"encoding/json"
 	"encoding/xml"
 )
 
 // ESearchResponse repräsentiert die JSON-Antwort von ESearch für die ID-Suche.
 type ESearchResponse struct {
 	ESearchResult struct {
+		Count  string   `json:"count"`
 		IdList []string `json:"idlist"`
 	} `json:"esearchresult"`
 }
 
+// ESummaryResponse repräsentiert die JSON-Antwort von ESummary (nur die für Vorschauen genutzten Felder).
+type ESummaryResponse struct {
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+// ESummaryDoc ist ein einzelner Eintrag der ESummary-Antwort.
+type ESummaryDoc struct {
+	UID             string `json:"uid"`
+	Title           string `json:"title"`
+	PubDate         string `json:"pubdate"`
+	Source          string `json:"source"` // Journal-Abkürzung
+	FullJournalName string `json:"fulljournalname"`
+	ArticleIDs      []struct {
+		IDType string `json:"idtype"`
+		Value  string `json:"value"`
+	} `json:"articleids"`
+}
+
+// articleID liefert den Identifier eines Typs ("doi", "pmc") oder "".
+func (d ESummaryDoc) articleID(idType string) string {
+	for _, id := range d.ArticleIDs {
+		if id.IDType == idType {
+			return id.Value
+		}
+	}
+	return ""
+}
+
+// ELinkResponse repräsentiert die JSON-Antwort von ELink.
+type ELinkResponse struct {
+	LinkSets []struct {
+		LinkSetDBs []struct {
+			LinkName string   `json:"linkname"`
+			Links    []string `json:"links"`
+		} `json:"linksetdbs"`
+	} `json:"linksets"`
+}
+
+// PMCArticleSet repräsentiert die JATS-Antwort von EFetch (db=pmc); genutzt wird nur die Referenzliste.
+type PMCArticleSet struct {
+	XMLName  xml.Name `xml:"pmc-articleset"`
+	Articles []struct {
+		Refs []JATSRef `xml:"back>ref-list>ref"`
+	} `xml:"article"`
+}
+
+// JATSRef ist ein Eintrag der Referenzliste; je nach Verlag als element-, mixed- oder citation-Element.
+type JATSRef struct {
+	ID        string         `xml:"id,attr"`
+	Citations []JATSCitation `xml:"element-citation"`
+	Mixed     []JATSCitation `xml:"mixed-citation"`
+	Legacy    []JATSCitation `xml:"citation"`
+}
+
+// JATSCitation enthält die bibliographischen Angaben einer Referenz.
+type JATSCitation struct {
+	ArticleTitle JATSText `xml:"article-title"`
+	Source       JATSText `xml:"source"`
+	Year         string   `xml:"year"`
+	PubIDs       []struct {
+		Type  string `xml:"pub-id-type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"pub-id"`
+}
+
+// JATSText hält den Inhalt eines Elements inklusive Auszeichnungen wie <italic>.
+type JATSText struct {
+	Inner string `xml:",innerxml"`
+}
+
 // IDConvResponse repräsentiert die JSON-Antwort des PMC ID Converters.
 type IDConvResponse struct {
 	Records []struct {
@@ -69,5 +141,6 @@ type PubmedArticle struct {
 				Value   string `xml:",chardata"`
 			} `xml:"ELocationID"`
 		} `xml:"Article"`
+		MeshHeadings []string `xml:"MeshHeadingList>MeshHeading>DescriptorName"`
 	} `xml:"MedlineCitation"`
 }
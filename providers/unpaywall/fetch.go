@@ -1,6 +1,7 @@
 package unpaywall
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -31,7 +32,7 @@ func NewFetcher(cfg *config.Config, logger *zap.Logger) *Fetcher {
 }
 
 // GetPDFLink holt einen freien PDF-Link via Unpaywall anhand der DOI.
-func (f *Fetcher) GetPDFLink(doi string) (string, error) {
+func (f *Fetcher) GetPDFLink(ctx context.Context, doi string) (string, error) {
 	if f.Config.UnpaywallEmail == "" {
 		return "", fmt.Errorf("unpaywall email ist nicht konfiguriert")
 	}
@@ -40,7 +41,11 @@ func (f *Fetcher) GetPDFLink(doi string) (string, error) {
 	log := f.Logger.With(zap.String("doi", doi), zap.String("url", url))
 	log.Debug("Rufe Unpaywall API auf.")
 
-	resp, err := httpClient.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"paper-hand/models"
+)
+
+// Zulässige Werte der Bewertungsmatrix.
+var (
+	AppraisalStudyDesigns = []string{
+		"meta_analysis", "systematic_review", "rct", "non_randomized_trial", "cohort", "case_control",
+		"cross_sectional", "case_series", "case_report", "animal", "in_vitro", "other",
+	}
+	AppraisalBlinding        = []string{"open_label", "single", "double", "triple"}
+	GradeCertainties         = []string{"very_low", "low", "moderate", "high"} // aufsteigend
+	GradeConcerns            = []string{"not_serious", "serious", "very_serious"}
+	RiskOfBiasLevels         = []string{"low", "some_concerns", "high"} // aufsteigend
+	AppraisalOutcomeEffects  = []string{"benefit", "harm", "no_effect", "mixed"}
+	significanceLevelDefault = 0.05
+)
+
+// DecodeAppraisal liest eine Appraisal aus dem JSON-Wert v (z.B. raw["appraisal"]) und validiert sie.
+// Unbekannte Felder und falsche Typen sind Fehler; Aufzählungswerte werden normalisiert ("Some concerns"
+// → some_concerns). Fehler werden mit dem Pfad prefix gemeldet und sind vom Typ ValidationErrors.
+func DecodeAppraisal(v any, prefix string) (*models.Appraisal, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, ValidationErrors{{Field: prefix, Code: "shape", Message: "must be an object"}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var a models.Appraisal
+	if err := dec.Decode(&a); err != nil {
+		return nil, ValidationErrors{decodeError(prefix, err)}
+	}
+	// Verwaltungsfelder kommen nie vom Client
+	a.ID, a.RatedPaperID, a.RevisionID = 0, 0, nil
+	for i := range a.Outcomes {
+		a.Outcomes[i].ID, a.Outcomes[i].AppraisalID = 0, 0
+	}
+	if errs := ValidateAppraisal(&a, prefix); len(errs) > 0 {
+		return nil, errs
+	}
+	return &a, nil
+}
+
+// decodeError übersetzt Fehler von encoding/json in einen FieldError.
+func decodeError(prefix string, err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return FieldError{Field: joinField(prefix, jsonPath(typeErr.Field)), Code: "type", Message: "must be " + jsonTypeName(typeErr.Type)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return FieldError{Field: joinField(prefix, name), Code: "unknown_field", Message: "unknown field"}
+	default:
+		return FieldError{Field: prefix, Code: "shape", Message: "must be an object"}
+	}
+}
+
+// jsonPath schreibt Array-Indizes von encoding/json ("outcomes.0.p_value") als outcomes[0].p_value.
+func jsonPath(field string) string {
+	var out strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			out.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			out.WriteByte('.')
+		}
+		out.WriteString(part)
+	}
+	return out.String()
+}
+
+// jsonTypeName benennt den erwarteten JSON-Typ eines Go-Typs.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func joinField(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	if field == "" {
+		return prefix
+	}
+	return prefix + "." + field
+}
+
+// ValidateAppraisal normalisiert die Aufzählungswerte, ergänzt abgeleitete Werte (RoB overall aus den
+// Domänen, Signifikanz aus dem p-Wert) und prüft alle Felder.
+func ValidateAppraisal(a *models.Appraisal, prefix string) ValidationErrors {
+	var errs ValidationErrors
+	field := func(name string) string { return joinField(prefix, name) }
+	enum := func(name string, value *string, allowed []string, required bool) {
+		*value = normalizeEnum(*value)
+		switch {
+		case *value == "" && required:
+			errs.add(field(name), "required", "required")
+		case *value != "" && !slices.Contains(allowed, *value):
+			errs.add(field(name), "enum", "must be one of "+strings.Join(allowed, ", "))
+		}
+	}
+
+	enum("study_design", &a.StudyDesign, AppraisalStudyDesigns, true)
+	// "double-blind" → double
+	a.Blinding = strings.TrimSuffix(strings.TrimSuffix(normalizeEnum(a.Blinding), "_blinded"), "_blind")
+	enum("blinding", &a.Blinding, AppraisalBlinding, false)
+	if a.SampleSize != nil && *a.SampleSize < 1 {
+		errs.add(field("sample_size"), "range", "must be at least 1")
+	}
+	if a.DurationWeeks != nil && *a.DurationWeeks <= 0 {
+		errs.add(field("duration_weeks"), "range", "must be positive")
+	}
+	if a.DoseMgPerDay != nil && *a.DoseMgPerDay < 0 {
+		errs.add(field("dose_mg_per_day"), "range", "must not be negative")
+	}
+	a.Dose, a.Intervention = strings.TrimSpace(a.Dose), strings.TrimSpace(a.Intervention)
+	a.Comparator, a.Population = strings.TrimSpace(a.Comparator), strings.TrimSpace(a.Population)
+
+	g := &a.Grade
+	enum("grade.certainty", &g.Certainty, GradeCertainties, false)
+	enum("grade.risk_of_bias", &g.RiskOfBias, GradeConcerns, false)
+	enum("grade.inconsistency", &g.Inconsistency, GradeConcerns, false)
+	enum("grade.indirectness", &g.Indirectness, GradeConcerns, false)
+	enum("grade.imprecision", &g.Imprecision, GradeConcerns, false)
+	enum("grade.publication_bias", &g.PublicationBias, GradeConcerns, false)
+
+	r := &a.RiskOfBias
+	domains := []struct {
+		Name  string
+		Value *string
+	}{
+		{"randomization", &r.Randomization}, {"deviations", &r.Deviations}, {"missing_data", &r.MissingData},
+		{"measurement", &r.Measurement}, {"selective_reporting", &r.SelectiveReporting},
+	}
+	worst := -1
+	for _, d := range domains {
+		enum("risk_of_bias."+d.Name, d.Value, RiskOfBiasLevels, false)
+		worst = max(worst, slices.Index(RiskOfBiasLevels, *d.Value))
+	}
+	enum("risk_of_bias.overall", &r.Overall, RiskOfBiasLevels, false)
+	if r.Overall == "" && worst >= 0 {
+		// RoB 2: Gesamturteil entspricht der schlechtesten Domäne
+		r.Overall = RiskOfBiasLevels[worst]
+	}
+
+	for i := range a.Outcomes {
+		o := &a.Outcomes[i]
+		name := func(n string) string { return fmt.Sprintf("outcomes[%d].%s", i, n) }
+		o.Name, o.EffectMeasure = strings.TrimSpace(o.Name), strings.TrimSpace(o.EffectMeasure)
+		if o.Name == "" {
+			errs.add(field(name("name")), "required", "required")
+		}
+		enum(name("direction"), &o.Direction, AppraisalOutcomeEffects, true)
+		if o.PValue != nil {
+			if *o.PValue < 0 || *o.PValue > 1 {
+				errs.add(field(name("p_value")), "range", "must be between 0 and 1")
+			} else if o.Significant == nil {
+				significant := *o.PValue < significanceLevelDefault
+				o.Significant = &significant
+			}
+		}
+		if o.CILower != nil && o.CIUpper != nil && *o.CILower > *o.CIUpper {
+			errs.add(field(name("ci_lower")), "range", "must not exceed ci_upper")
+		}
+	}
+	return errs
+}
+
+// SaveAppraisal ersetzt die Appraisal eines Papers (samt Endpunkten) durch a.
+func SaveAppraisal(tx *gorm.DB, paper *models.RatedPaper, a *models.Appraisal) error {
+	if err := tx.Where("appraisal_id IN (?)", tx.Model(&models.Appraisal{}).Select("id").Where("rated_paper_id = ?", paper.ID)).
+		Delete(&models.AppraisalOutcome{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("rated_paper_id = ?", paper.ID).Delete(&models.Appraisal{}).Error; err != nil {
+		return err
+	}
+	a.ID, a.RatedPaperID = 0, paper.ID
+	if err := tx.Create(a).Error; err != nil {
+		return err
+	}
+	paper.Appraisal = a
+	return nil
+}
+
+// FindAppraisal lädt die Appraisal eines Papers samt Endpunkten.
+func FindAppraisal(db *gorm.DB, paperID uint) (*models.Appraisal, error) {
+	var a models.Appraisal
+	if err := db.Preload("Outcomes").Where("rated_paper_id = ?", paperID).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// AppraisalFilter beschreibt Abfragen über die Bewertungsmatrix, z.B. "RCTs mit geringem
+// Verzerrungsrisiko und n ≥ 100".
+type AppraisalFilter struct {
+	StudyDesigns     []string `json:"study_designs"`
+	MaxRiskOfBias    string   `json:"max_risk_of_bias"` // RoB overall höchstens so hoch (low, some_concerns, high)
+	MinCertainty     string   `json:"min_certainty"`    // GRADE mindestens so hoch (very_low … high)
+	MinSampleSize    *int     `json:"min_sample_size"`
+	MinDurationWeeks *float64 `json:"min_duration_weeks"`
+	Intervention     string   `json:"intervention"` // Teilstring, case-insensitive
+	Blinding         []string `json:"blinding"`
+
+	// Endpunkt-Filter: alle Angaben müssen auf denselben Endpunkt zutreffen
+	Outcome          string `json:"outcome"` // Teilstring des Endpunkt-Namens
+	OutcomeDirection string `json:"outcome_direction"`
+	Significant      *bool  `json:"significant"`
+	PrimaryOnly      bool   `json:"primary_only"`
+}
+
+// AppraisalScope filtert rated_papers auf Paper, deren Appraisal zu f passt. Ungültige
+// Aufzählungswerte liefern ErrInvalidListParams (HTTP 400).
+func AppraisalScope(f AppraisalFilter) (func(*gorm.DB) *gorm.DB, error) {
+	invalid := func(name string, allowed []string) error {
+		return fmt.Errorf("%w: %s must be one of %s", ErrInvalidListParams, name, strings.Join(allowed, ", "))
+	}
+	designs := make([]string, 0, len(f.StudyDesigns))
+	for _, d := range f.StudyDesigns {
+		if d = normalizeEnum(d); !slices.Contains(AppraisalStudyDesigns, d) {
+			return nil, invalid("study_designs", AppraisalStudyDesigns)
+		}
+		designs = append(designs, d)
+	}
+	blinding := make([]string, 0, len(f.Blinding))
+	for _, b := range f.Blinding {
+		if b = normalizeEnum(b); !slices.Contains(AppraisalBlinding, b) {
+			return nil, invalid("blinding", AppraisalBlinding)
+		}
+		blinding = append(blinding, b)
+	}
+	var robLevels, certainties []string
+	if f.MaxRiskOfBias != "" {
+		i := slices.Index(RiskOfBiasLevels, normalizeEnum(f.MaxRiskOfBias))
+		if i < 0 {
+			return nil, invalid("max_risk_of_bias", RiskOfBiasLevels)
+		}
+		robLevels = RiskOfBiasLevels[:i+1]
+	}
+	if f.MinCertainty != "" {
+		i := slices.Index(GradeCertainties, normalizeEnum(f.MinCertainty))
+		if i < 0 {
+			return nil, invalid("min_certainty", GradeCertainties)
+		}
+		certainties = GradeCertainties[i:]
+	}
+	direction := normalizeEnum(f.OutcomeDirection)
+	if direction != "" && !slices.Contains(AppraisalOutcomeEffects, direction) {
+		return nil, invalid("outcome_direction", AppraisalOutcomeEffects)
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		sub := db.Session(&gorm.Session{NewDB: true}).Table("rated_paper_appraisals a").Select("1").
+			Where("a.rated_paper_id = rated_papers.id")
+		if len(designs) > 0 {
+			sub = sub.Where("a.study_design IN ?", designs)
+		}
+		if len(blinding) > 0 {
+			sub = sub.Where("a.blinding IN ?", blinding)
+		}
+		if robLevels != nil {
+			sub = sub.Where("a.rob_overall IN ?", robLevels)
+		}
+		if certainties != nil {
+			sub = sub.Where("a.grade_certainty IN ?", certainties)
+		}
+		if f.MinSampleSize != nil {
+			sub = sub.Where("a.sample_size >= ?", *f.MinSampleSize)
+		}
+		if f.MinDurationWeeks != nil {
+			sub = sub.Where("a.duration_weeks >= ?", *f.MinDurationWeeks)
+		}
+		if s := strings.TrimSpace(f.Intervention); s != "" {
+			sub = sub.Where("a.intervention ILIKE ?", "%"+s+"%")
+		}
+		if f.Outcome != "" || direction != "" || f.Significant != nil || f.PrimaryOnly {
+			outcomes := db.Session(&gorm.Session{NewDB: true}).Table("rated_paper_appraisal_outcomes o").Select("1").
+				Where("o.appraisal_id = a.id")
+			if s := strings.TrimSpace(f.Outcome); s != "" {
+				outcomes = outcomes.Where("o.name ILIKE ?", "%"+s+"%")
+			}
+			if direction != "" {
+				outcomes = outcomes.Where("o.direction = ?", direction)
+			}
+			if f.Significant != nil {
+				outcomes = outcomes.Where("o.significant = ?", *f.Significant)
+			}
+			if f.PrimaryOnly {
+				outcomes = outcomes.Where("o.is_primary = ?", true)
+			}
+			sub = sub.Where("EXISTS (?)", outcomes)
+		}
+		return db.Where("EXISTS (?)", sub)
+	}, nil
+}
@@ -0,0 +1,531 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// Aggregationsverfahren für die Konsens-Bewertung mehrerer Rater.
+const (
+	AggregationMean          = "mean"
+	AggregationMedian        = "median"
+	AggregationTrustWeighted = "trust_weighted"
+)
+
+// Aggregations sind die zulässigen Werte für RATING_AGGREGATION und ?aggregation=.
+var Aggregations = []string{AggregationMean, AggregationMedian, AggregationTrustWeighted}
+
+// DefaultRaterName wird verwendet, wenn eine KI-Bewertung weder rater noch model angibt.
+const DefaultRaterName = "default"
+
+// ErrInvalidAssessment kennzeichnet ungültige Einschätzungen oder Rater-Angaben (HTTP 400).
+var ErrInvalidAssessment = errors.New("invalid assessment")
+
+// AssessmentService verwaltet die Einschätzungen mehrerer Rater je RatedPaper und hält Konsens,
+// Streuung und Review-Markierung auf dem Paper aktuell.
+type AssessmentService struct {
+	DB                    *gorm.DB
+	Aggregation           string  // Standardverfahren für consensus_rating
+	DisagreementThreshold float64 // maximale Rating-Spanne, ab der Rater als uneinig gelten
+}
+
+// NewAssessmentService erstellt den Service und prüft das Aggregationsverfahren.
+func NewAssessmentService(db *gorm.DB, aggregation string, threshold float64) (*AssessmentService, error) {
+	if !slices.Contains(Aggregations, aggregation) {
+		return nil, fmt.Errorf("%w: unknown aggregation %q", ErrInvalidAssessment, aggregation)
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("%w: disagreement threshold must not be negative", ErrInvalidAssessment)
+	}
+	return &AssessmentService{DB: db, Aggregation: aggregation, DisagreementThreshold: threshold}, nil
+}
+
+// AssessmentInput ist die Einschätzung eines Raters.
+type AssessmentInput struct {
+	Rater           string   `json:"rater"`
+	Kind            string   `json:"kind"` // nur beim ersten Auftreten des Raters, Standard human
+	Rating          *float64 `json:"rating"`
+	ConfidenceScore float64  `json:"confidence_score"`
+	Category        string   `json:"category"`
+	Comment         string   `json:"comment"`
+	RevisionID      *uint    `json:"-"`
+}
+
+// Record speichert die Einschätzung eines Raters (eine je Rater und Paper, neuere ersetzen ältere)
+// und aktualisiert die Aggregation des Papers. Läuft in tx, damit es mit dem Speichern des Papers
+// zusammen gelingt oder scheitert.
+func (s *AssessmentService) Record(tx *gorm.DB, paper *models.RatedPaper, in AssessmentInput) (*models.RatedPaperAssessment, error) {
+	in.Rater = strings.TrimSpace(in.Rater)
+	if in.Rater == "" {
+		return nil, fmt.Errorf("%w: rater required", ErrInvalidAssessment)
+	}
+	if in.Rating == nil {
+		return nil, fmt.Errorf("%w: rating required", ErrInvalidAssessment)
+	}
+	if in.Kind == "" {
+		in.Kind = models.RaterKindHuman
+	}
+	if in.Kind != models.RaterKindLLM && in.Kind != models.RaterKindHuman {
+		return nil, fmt.Errorf("%w: kind must be %s or %s", ErrInvalidAssessment, models.RaterKindLLM, models.RaterKindHuman)
+	}
+	rater := models.Rater{Name: in.Rater, Kind: in.Kind, Trust: 1}
+	if err := tx.Where("name = ?", rater.Name).FirstOrCreate(&rater).Error; err != nil {
+		return nil, err
+	}
+
+	assessment := models.RatedPaperAssessment{
+		RatedPaperID: paper.ID, RaterID: rater.ID,
+		Rating: *in.Rating, ConfidenceScore: in.ConfidenceScore,
+		Category: strings.TrimSpace(in.Category), Comment: strings.TrimSpace(in.Comment), RevisionID: in.RevisionID,
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "rated_paper_id"}, {Name: "rater_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "rating", "confidence_score", "category", "comment", "revision_id"}),
+	}).Create(&assessment).Error
+	if err != nil {
+		return nil, err
+	}
+	assessment.Rater = &rater
+	return &assessment, s.refresh(tx, paper)
+}
+
+// AssessmentSummary fasst die Einschätzungen eines Papers zusammen.
+type AssessmentSummary struct {
+	Raters            int     `json:"raters"`
+	Aggregation       string  `json:"aggregation"`
+	Rating            float64 `json:"rating"` // Konsens nach Aggregation
+	Category          string  `json:"category"`
+	Mean              float64 `json:"mean"`
+	Median            float64 `json:"median"`
+	TrustWeighted     float64 `json:"trust_weighted"`
+	Variance          float64 `json:"variance"`
+	Spread            float64 `json:"spread"`             // höchstes minus niedrigstes Rating
+	CategoryAgreement float64 `json:"category_agreement"` // Anteil der häufigsten Kategorie (bei trust_weighted nach Trust gewichtet)
+	Disagreement      bool    `json:"disagreement"`
+	HumanReviewed     bool    `json:"human_reviewed"`
+	NeedsReview       bool    `json:"needs_review"`
+}
+
+// Summarize berechnet Konsens und Streuung; aggregation leer = Standardverfahren des Services.
+// Die Rater der Einschätzungen müssen geladen sein.
+func (s *AssessmentService) Summarize(assessments []models.RatedPaperAssessment, aggregation string) AssessmentSummary {
+	if aggregation == "" {
+		aggregation = s.Aggregation
+	}
+	sum := AssessmentSummary{Raters: len(assessments), Aggregation: aggregation}
+	if len(assessments) == 0 {
+		return sum
+	}
+
+	ratings := make([]float64, 0, len(assessments))
+	weightedSum, weights := 0.0, 0.0
+	votes, weightedVotes := map[string]float64{}, map[string]float64{}
+	labels := map[string]string{}
+	for _, a := range assessments {
+		ratings = append(ratings, a.Rating)
+		trust := 1.0
+		if a.Rater != nil {
+			trust = a.Rater.Trust
+			sum.HumanReviewed = sum.HumanReviewed || a.Rater.Kind == models.RaterKindHuman
+		}
+		weightedSum += trust * a.Rating
+		weights += trust
+		if key := categoryKey(a.Category); key != "" {
+			votes[key]++
+			weightedVotes[key] += trust
+			if _, ok := labels[key]; !ok {
+				labels[key] = strings.TrimSpace(a.Category)
+			}
+		}
+	}
+	sort.Float64s(ratings)
+	for _, r := range ratings {
+		sum.Mean += r
+	}
+	sum.Mean /= float64(len(ratings))
+	for _, r := range ratings {
+		sum.Variance += (r - sum.Mean) * (r - sum.Mean)
+	}
+	sum.Variance /= float64(len(ratings))
+	if n := len(ratings); n%2 == 1 {
+		sum.Median = ratings[n/2]
+	} else {
+		sum.Median = (ratings[n/2-1] + ratings[n/2]) / 2
+	}
+	sum.TrustWeighted = sum.Mean
+	if weights > 0 {
+		sum.TrustWeighted = weightedSum / weights
+	}
+	sum.Spread = ratings[len(ratings)-1] - ratings[0]
+
+	switch aggregation {
+	case AggregationMedian:
+		sum.Rating = sum.Median
+	case AggregationTrustWeighted:
+		sum.Rating = sum.TrustWeighted
+		votes = weightedVotes
+	default:
+		sum.Rating = sum.Mean
+	}
+	if key := pluralityCategory(votes); key != "" {
+		// Anteil an denselben Stimmen, nach denen die Kategorie gewählt wurde (bei trust_weighted gewichtet)
+		sum.Category = labels[key]
+		total := 0.0
+		for _, v := range votes {
+			total += v
+		}
+		if total > 0 {
+			sum.CategoryAgreement = roundTo(votes[key]/total, 2)
+		}
+	}
+	sum.Mean, sum.Median, sum.TrustWeighted = roundTo(sum.Mean, 2), roundTo(sum.Median, 2), roundTo(sum.TrustWeighted, 2)
+	sum.Rating, sum.Variance = roundTo(sum.Rating, 2), roundTo(sum.Variance, 3)
+
+	// uneinig: Ratings liegen zu weit auseinander oder keine Kategorie hat eine Mehrheit
+	sum.Disagreement = sum.Raters >= 2 &&
+		(sum.Spread > s.DisagreementThreshold || (sum.Category != "" && sum.CategoryAgreement <= 0.5))
+	sum.NeedsReview = sum.Disagreement && !sum.HumanReviewed
+	return sum
+}
+
+// refresh schreibt die Aggregation aller Einschätzungen auf das Paper.
+func (s *AssessmentService) refresh(tx *gorm.DB, paper *models.RatedPaper) error {
+	var assessments []models.RatedPaperAssessment
+	if err := tx.Preload("Rater").Where("rated_paper_id = ?", paper.ID).Find(&assessments).Error; err != nil {
+		return err
+	}
+	sum := s.Summarize(assessments, "")
+	paper.RaterCount, paper.ConsensusRating, paper.ConsensusCategory = sum.Raters, sum.Rating, sum.Category
+	paper.RatingVariance, paper.NeedsReview = sum.Variance, sum.NeedsReview
+	return tx.Model(paper).UpdateColumns(map[string]any{
+		"rater_count": sum.Raters, "consensus_rating": sum.Rating, "consensus_category": sum.Category,
+		"rating_variance": sum.Variance, "needs_review": sum.NeedsReview,
+	}).Error
+}
+
+// Assessments liefert die Einschätzungen eines Papers samt Zusammenfassung nach aggregation.
+func (s *AssessmentService) Assessments(paper *models.RatedPaper, aggregation string) ([]models.RatedPaperAssessment, AssessmentSummary, error) {
+	if aggregation != "" && !slices.Contains(Aggregations, aggregation) {
+		return nil, AssessmentSummary{}, fmt.Errorf("%w: unknown aggregation %q", ErrInvalidAssessment, aggregation)
+	}
+	var assessments []models.RatedPaperAssessment
+	if err := s.DB.Preload("Rater").Where("rated_paper_id = ?", paper.ID).Order("updated_at DESC").Find(&assessments).Error; err != nil {
+		return nil, AssessmentSummary{}, err
+	}
+	return assessments, s.Summarize(assessments, aggregation), nil
+}
+
+// Raters listet alle bekannten Rater.
+func (s *AssessmentService) Raters() ([]models.Rater, error) {
+	var raters []models.Rater
+	err := s.DB.Order("name").Find(&raters).Error
+	return raters, err
+}
+
+// UpdateRater setzt Art und/oder Vertrauensgewicht eines Raters (legt ihn bei Bedarf an) und berechnet
+// die Aggregation aller Paper neu, die er bewertet hat. Liefert die Anzahl neu berechneter Paper.
+func (s *AssessmentService) UpdateRater(name string, kind *string, trust *float64) (*models.Rater, int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, 0, fmt.Errorf("%w: rater name required", ErrInvalidAssessment)
+	}
+	if kind != nil && *kind != models.RaterKindLLM && *kind != models.RaterKindHuman {
+		return nil, 0, fmt.Errorf("%w: kind must be %s or %s", ErrInvalidAssessment, models.RaterKindLLM, models.RaterKindHuman)
+	}
+	if trust != nil && (*trust < 0 || math.IsNaN(*trust)) {
+		return nil, 0, fmt.Errorf("%w: trust must not be negative", ErrInvalidAssessment)
+	}
+	rater := models.Rater{Name: name, Kind: models.RaterKindHuman, Trust: 1}
+	refreshed := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", name).FirstOrCreate(&rater).Error; err != nil {
+			return err
+		}
+		updates := map[string]any{}
+		if kind != nil {
+			updates["kind"] = *kind
+		}
+		if trust != nil {
+			updates["trust"] = *trust
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&rater).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		var papers []models.RatedPaper
+		if err := tx.Where("id IN (?)", tx.Model(&models.RatedPaperAssessment{}).Select("rated_paper_id").Where("rater_id = ?", rater.ID)).
+			Find(&papers).Error; err != nil {
+			return err
+		}
+		for i := range papers {
+			if err := s.refresh(tx, &papers[i]); err != nil {
+				return err
+			}
+		}
+		refreshed = len(papers)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &rater, refreshed, nil
+}
+
+// RaterPairAgreement ist die Übereinstimmung zweier Rater auf den gemeinsam bewerteten Papern.
+type RaterPairAgreement struct {
+	RaterA            string   `json:"rater_a"`
+	RaterB            string   `json:"rater_b"`
+	Papers            int      `json:"papers"`
+	ObservedAgreement float64  `json:"observed_agreement"` // Anteil gleicher Kategorien
+	CohenKappa        *float64 `json:"cohen_kappa"`        // null, wenn nicht definiert
+	MeanRatingDiff    float64  `json:"mean_rating_diff"`   // mittlere absolute Rating-Differenz
+}
+
+// AgreementReport fasst die Übereinstimmung über alle Paper mit mindestens zwei Ratern zusammen.
+type AgreementReport struct {
+	Papers             int                  `json:"papers"`
+	Assessments        int                  `json:"assessments"`
+	FleissKappa        *float64             `json:"fleiss_kappa"`
+	MeanRatingVariance float64              `json:"mean_rating_variance"`
+	Disagreements      int                  `json:"disagreements"`
+	Pairs              []RaterPairAgreement `json:"pairs"`
+}
+
+// Agreement berechnet Fleiss' Kappa (mit variabler Rater-Zahl je Paper) und paarweise Cohens Kappa auf
+// den Kategorien sowie die mittlere Rating-Varianz. Mit raters werden nur deren Einschätzungen gezählt.
+func (s *AssessmentService) Agreement(raters []string) (*AgreementReport, error) {
+	query := s.DB.Preload("Rater").Order("rated_paper_id")
+	if len(raters) > 0 {
+		query = query.Where("rater_id IN (?)", s.DB.Model(&models.Rater{}).Select("id").Where("name IN ?", raters))
+	}
+	var all []models.RatedPaperAssessment
+	if err := query.Find(&all).Error; err != nil {
+		return nil, err
+	}
+	byPaper := map[uint][]models.RatedPaperAssessment{}
+	var order []uint
+	for _, a := range all {
+		if _, ok := byPaper[a.RatedPaperID]; !ok {
+			order = append(order, a.RatedPaperID)
+		}
+		byPaper[a.RatedPaperID] = append(byPaper[a.RatedPaperID], a)
+	}
+
+	report := &AgreementReport{Pairs: []RaterPairAgreement{}}
+	var items [][]string // Kategorien je Paper (nur Paper mit >= 2 Kategorien)
+	type pairKey struct{ A, B string }
+	pairs := map[pairKey]*pairStats{}
+	for _, id := range order {
+		group := byPaper[id]
+		if len(group) < 2 {
+			continue
+		}
+		report.Papers++
+		report.Assessments += len(group)
+		sum := s.Summarize(group, "")
+		report.MeanRatingVariance += sum.Variance
+		if sum.Disagreement {
+			report.Disagreements++
+		}
+		var cats []string
+		for _, a := range group {
+			if key := categoryKey(a.Category); key != "" {
+				cats = append(cats, key)
+			}
+		}
+		if len(cats) >= 2 {
+			items = append(items, cats)
+		}
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if raterName(a) > raterName(b) {
+					a, b = b, a
+				}
+				k := pairKey{raterName(a), raterName(b)}
+				if pairs[k] == nil {
+					pairs[k] = &pairStats{}
+				}
+				pairs[k].add(categoryKey(a.Category), categoryKey(b.Category), math.Abs(a.Rating-b.Rating))
+			}
+		}
+	}
+	if report.Papers > 0 {
+		report.MeanRatingVariance = roundTo(report.MeanRatingVariance/float64(report.Papers), 3)
+	}
+	report.FleissKappa = fleissKappa(items)
+	for k, p := range pairs {
+		report.Pairs = append(report.Pairs, p.result(k.A, k.B))
+	}
+	sort.Slice(report.Pairs, func(i, j int) bool {
+		if report.Pairs[i].RaterA != report.Pairs[j].RaterA {
+			return report.Pairs[i].RaterA < report.Pairs[j].RaterA
+		}
+		return report.Pairs[i].RaterB < report.Pairs[j].RaterB
+	})
+	return report, nil
+}
+
+// pairStats sammelt die gemeinsamen Einschätzungen zweier Rater.
+type pairStats struct {
+	papers, categorized, agree int
+	diff                       float64
+	a, b                       map[string]int
+}
+
+func (p *pairStats) add(catA, catB string, diff float64) {
+	p.papers++
+	p.diff += diff
+	if catA == "" || catB == "" {
+		return
+	}
+	if p.a == nil {
+		p.a, p.b = map[string]int{}, map[string]int{}
+	}
+	p.categorized++
+	p.a[catA]++
+	p.b[catB]++
+	if catA == catB {
+		p.agree++
+	}
+}
+
+func (p *pairStats) result(a, b string) RaterPairAgreement {
+	out := RaterPairAgreement{RaterA: a, RaterB: b, Papers: p.papers, MeanRatingDiff: roundTo(p.diff/float64(p.papers), 2)}
+	if p.categorized == 0 {
+		return out
+	}
+	n := float64(p.categorized)
+	po := float64(p.agree) / n
+	pe := 0.0
+	for cat, count := range p.a {
+		pe += float64(count) / n * float64(p.b[cat]) / n
+	}
+	out.ObservedAgreement = roundTo(po, 3)
+	out.CohenKappa = kappa(po, pe)
+	return out
+}
+
+// fleissKappa berechnet Fleiss' Kappa; Paper dürfen unterschiedlich viele Rater haben.
+func fleissKappa(items [][]string) *float64 {
+	if len(items) == 0 {
+		return nil
+	}
+	totals := map[string]float64{}
+	ratings, pBar := 0.0, 0.0
+	for _, cats := range items {
+		counts := map[string]float64{}
+		for _, c := range cats {
+			counts[c]++
+			totals[c]++
+		}
+		n := float64(len(cats))
+		ratings += n
+		sq := 0.0
+		for _, c := range counts {
+			sq += c * c
+		}
+		pBar += (sq - n) / (n * (n - 1))
+	}
+	pBar /= float64(len(items))
+	pe := 0.0
+	for _, t := range totals {
+		pe += (t / ratings) * (t / ratings)
+	}
+	return kappa(pBar, pe)
+}
+
+// kappa liefert (po-pe)/(1-pe); bei pe = 1 ist Kappa nur für vollständige Übereinstimmung definiert.
+func kappa(po, pe float64) *float64 {
+	var k float64
+	switch {
+	case pe < 1:
+		k = roundTo((po-pe)/(1-pe), 3)
+	case po >= 1:
+		k = 1
+	default:
+		return nil
+	}
+	return &k
+}
+
+// Backfill legt für Paper ohne Einschätzung (Bestand vor den Ratern) eine Einschätzung aus der
+// gespeicherten Bewertung an; Rater ist das Modell der aktuellen Revision bzw. DefaultRaterName.
+func (s *AssessmentService) Backfill() (int, error) {
+	created := 0
+	var lastID uint
+	for {
+		var papers []models.RatedPaper
+		err := s.DB.Where("id > ? AND NOT EXISTS (SELECT 1 FROM rated_paper_assessments a WHERE a.rated_paper_id = rated_papers.id)", lastID).
+			Order("id").Limit(500).Find(&papers).Error
+		if err != nil {
+			return created, err
+		}
+		if len(papers) == 0 {
+			return created, nil
+		}
+		for i := range papers {
+			p := &papers[i]
+			lastID = p.ID
+			err := s.DB.Transaction(func(tx *gorm.DB) error {
+				_, err := s.Record(tx, p, AIAssessment(p))
+				return err
+			})
+			if err != nil {
+				return created, err
+			}
+			created++
+		}
+	}
+}
+
+// AIAssessment leitet aus der gespeicherten KI-Bewertung eines Papers die Einschätzung ihres Raters ab.
+func AIAssessment(p *models.RatedPaper) AssessmentInput {
+	rater := p.Model
+	if rater == "" {
+		rater = DefaultRaterName
+	}
+	rating := p.Rating
+	return AssessmentInput{
+		Rater: rater, Kind: models.RaterKindLLM, Rating: &rating,
+		ConfidenceScore: p.ConfidenceScore, Category: p.Category, RevisionID: p.CurrentRevisionID,
+	}
+}
+
+func raterName(a models.RatedPaperAssessment) string {
+	if a.Rater != nil {
+		return a.Rater.Name
+	}
+	return fmt.Sprint(a.RaterID)
+}
+
+// categoryKey normalisiert Kategorien für den Vergleich (Groß-/Kleinschreibung, Leerraum).
+func categoryKey(category string) string {
+	return strings.ToLower(strings.Join(strings.Fields(category), " "))
+}
+
+// pluralityCategory liefert die Kategorie mit den meisten Stimmen; bei Gleichstand die alphabetisch erste.
+func pluralityCategory(votes map[string]float64) string {
+	best := ""
+	for key, v := range votes {
+		if best == "" || v > votes[best] || (v == votes[best] && key < best) {
+			best = key
+		}
+	}
+	return best
+}
+
+func roundTo(v float64, digits int) float64 {
+	f := math.Pow(10, float64(digits))
+	return math.Round(v*f) / f
+}
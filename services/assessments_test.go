@@ -0,0 +1,168 @@
+package services
+
+import (
+	"testing"
+
+	"paper-hand/models"
+)
+
+func TestSummarize(t *testing.T) {
+	llm := &models.Rater{Name: "gpt", Kind: models.RaterKindLLM, Trust: 1}
+	human := &models.Rater{Name: "anna", Kind: models.RaterKindHuman, Trust: 1}
+	trusted := &models.Rater{Name: "claude", Kind: models.RaterKindLLM, Trust: 3}
+	assessment := func(r *models.Rater, rating float64, category string) models.RatedPaperAssessment {
+		return models.RatedPaperAssessment{Rater: r, Rating: rating, Category: category}
+	}
+
+	tests := []struct {
+		name        string
+		aggregation string
+		assessments []models.RatedPaperAssessment
+		want        AssessmentSummary
+	}{
+		{
+			name: "no assessments",
+			want: AssessmentSummary{Aggregation: AggregationMean},
+		},
+		{
+			name:        "single rater never disagrees",
+			assessments: []models.RatedPaperAssessment{assessment(llm, 7, "RCT")},
+			want: AssessmentSummary{
+				Raters: 1, Aggregation: AggregationMean, Rating: 7, Category: "RCT",
+				Mean: 7, Median: 7, TrustWeighted: 7, CategoryAgreement: 1,
+			},
+		},
+		{
+			name:        "spread above threshold needs review",
+			assessments: []models.RatedPaperAssessment{assessment(llm, 4, "RCT"), assessment(trusted, 8, " rct ")},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationMean, Rating: 6, Category: "RCT",
+				Mean: 6, Median: 6, TrustWeighted: 7, Variance: 4, Spread: 4, CategoryAgreement: 1,
+				Disagreement: true, NeedsReview: true,
+			},
+		},
+		{
+			name:        "human rater clears review",
+			assessments: []models.RatedPaperAssessment{assessment(llm, 4, "RCT"), assessment(human, 8, "RCT")},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationMean, Rating: 6, Category: "RCT",
+				Mean: 6, Median: 6, TrustWeighted: 6, Variance: 4, Spread: 4, CategoryAgreement: 1,
+				Disagreement: true, HumanReviewed: true,
+			},
+		},
+		{
+			name:        "category tie is a disagreement",
+			assessments: []models.RatedPaperAssessment{assessment(llm, 6, "Review"), assessment(trusted, 6.5, "Meta-Analyse")},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationMean, Rating: 6.25, Category: "Meta-Analyse",
+				Mean: 6.25, Median: 6.25, TrustWeighted: 6.38, Variance: 0.063, Spread: 0.5, CategoryAgreement: 0.5,
+				Disagreement: true, NeedsReview: true,
+			},
+		},
+		{
+			name:        "median ignores outlier",
+			aggregation: AggregationMedian,
+			assessments: []models.RatedPaperAssessment{assessment(llm, 1, ""), assessment(llm, 2, ""), assessment(llm, 9, "")},
+			want: AssessmentSummary{
+				Raters: 3, Aggregation: AggregationMedian, Rating: 2,
+				Mean: 4, Median: 2, TrustWeighted: 4, Variance: 12.667, Spread: 8,
+				Disagreement: true, NeedsReview: true,
+			},
+		},
+		{
+			name:        "trust weighted rating and category",
+			aggregation: AggregationTrustWeighted,
+			assessments: []models.RatedPaperAssessment{assessment(trusted, 8, "Kohorte"), assessment(human, 4, "RCT")},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationTrustWeighted, Rating: 7, Category: "Kohorte",
+				Mean: 6, Median: 6, TrustWeighted: 7, Variance: 4, Spread: 4, CategoryAgreement: 0.75,
+				Disagreement: true, HumanReviewed: true,
+			},
+		},
+		{
+			name:        "trust weighted category agreement",
+			aggregation: AggregationTrustWeighted,
+			assessments: []models.RatedPaperAssessment{assessment(trusted, 7, "Kohorte"), assessment(llm, 6, "RCT")},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationTrustWeighted, Rating: 6.75, Category: "Kohorte",
+				Mean: 6.5, Median: 6.5, TrustWeighted: 6.75, Variance: 0.25, Spread: 1, CategoryAgreement: 0.75,
+			},
+		},
+		{
+			name:        "unloaded rater counts with trust 1",
+			assessments: []models.RatedPaperAssessment{{Rating: 5}, {Rating: 6}},
+			want: AssessmentSummary{
+				Raters: 2, Aggregation: AggregationMean, Rating: 5.5,
+				Mean: 5.5, Median: 5.5, TrustWeighted: 5.5, Variance: 0.25, Spread: 1,
+			},
+		},
+	}
+	s := &AssessmentService{Aggregation: AggregationMean, DisagreementThreshold: 2}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Summarize(tt.assessments, tt.aggregation); got != tt.want {
+				t.Errorf("Summarize() = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFleissKappa(t *testing.T) {
+	tests := []struct {
+		name  string
+		items [][]string
+		want  *float64
+	}{
+		{"no items", nil, nil},
+		{"perfect agreement", [][]string{{"a", "a"}, {"b", "b"}}, ptr(1.0)},
+		{"single category everywhere", [][]string{{"a", "a"}, {"a", "a", "a"}}, ptr(1.0)},
+		{"systematic disagreement", [][]string{{"a", "b"}, {"a", "b"}}, ptr(-1.0)},
+		{"varying rater count", [][]string{{"a", "a", "b"}, {"a", "b"}}, ptr(-0.736)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertKappa(t, fleissKappa(tt.items), tt.want)
+		})
+	}
+}
+
+func TestKappa(t *testing.T) {
+	tests := []struct {
+		name   string
+		po, pe float64
+		want   *float64
+	}{
+		{"chance agreement", 0.5, 0.5, ptr(0.0)},
+		{"above chance", 0.8, 0.5, ptr(0.6)},
+		{"below chance", 0.2, 0.6, ptr(-1.0)},
+		{"expected agreement one, observed one", 1, 1, ptr(1.0)},
+		{"expected agreement one, observed less", 0.5, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertKappa(t, kappa(tt.po, tt.pe), tt.want)
+		})
+	}
+}
+
+func assertKappa(t *testing.T, got, want *float64) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("kappa = %v, want %v", fmtKappa(got), fmtKappa(want))
+	case *got != *want:
+		t.Errorf("kappa = %v, want %v", *got, *want)
+	}
+}
+
+func fmtKappa(k *float64) any {
+	if k == nil {
+		return "nil"
+	}
+	return *k
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
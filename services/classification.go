@@ -0,0 +1,118 @@
+package services
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// LinkPaperClassification verknüpft ein Paper mit Substanzen und gematchten Suchfiltern.
+// Bestehende Zuordnungen bleiben erhalten, doppelte werden ignoriert.
+func LinkPaperClassification(db *gorm.DB, paperID uint, substances []models.Substance, filters []models.SearchFilter) error {
+	if paperID == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, sub := range substances {
+			if sub.ID == 0 {
+				continue
+			}
+			link := models.PaperSubstance{PaperID: paperID, SubstanceID: sub.ID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+				return err
+			}
+		}
+		for _, filter := range filters {
+			if filter.ID == 0 {
+				continue
+			}
+			link := models.PaperFilter{PaperID: paperID, SearchFilterID: filter.ID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PaperClassificationScope filtert papers auf Paper, die mindestens einer der Substanzen bzw. einem der
+// Studiendesigns (Suchfilter-Namen) zugeordnet sind. Die denormalisierten Spalten zählen ebenfalls als Treffer.
+func PaperClassificationScope(substances, studyDesigns []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(substances) > 0 {
+			db = db.Where("papers.substance IN ? OR EXISTS (SELECT 1 FROM paper_substances ps JOIN substances s ON s.id = ps.substance_id WHERE ps.paper_id = papers.id AND s.name IN ?)",
+				substances, substances)
+		}
+		if len(studyDesigns) > 0 {
+			db = db.Where("papers.study_design IN ? OR EXISTS (SELECT 1 FROM paper_filters pf JOIN search_filters f ON f.id = pf.search_filter_id WHERE pf.paper_id = papers.id AND f.name IN ?)",
+				studyDesigns, studyDesigns)
+		}
+		return db
+	}
+}
+
+// ClassifiedPapersTable ist die temporäre Tabelle (pmid, doi), die WithClassifiedPapers befüllt.
+const ClassifiedPapersTable = "classified_papers"
+
+// classifiedPapersBatch begrenzt die Zeilen je Lese- und Schreib-Batch (2 Bind-Parameter je Zeile).
+const classifiedPapersBatch = 5000
+
+// WithClassifiedPapers überträgt PMIDs und normalisierte DOIs aller Paper, die zur Klassifikation passen,
+// batchweise aus rawDB in die temporäre Tabelle classified_papers einer Transaktion auf ratedDB und führt
+// fn in dieser Transaktion aus. Die Join-Tabellen liegen nur in der Raw-DB; über die temporäre Tabelle
+// bleibt die Zahl der Bind-Parameter auch bei großen Substanzen begrenzt.
+func WithClassifiedPapers(rawDB, ratedDB *gorm.DB, substances, studyDesigns []string, fn func(tx *gorm.DB) error) error {
+	return ratedDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TEMP TABLE " + ClassifiedPapersTable + " (pmid text, doi text) ON COMMIT DROP").Error; err != nil {
+			return err
+		}
+		type classifiedPaper struct {
+			PMID string `gorm:"column:pmid"`
+			DOI  string `gorm:"column:doi"`
+		}
+		var papers []models.Paper
+		err := rawDB.Model(&models.Paper{}).Select("id", "pmid", "doi").
+			Scopes(PaperClassificationScope(substances, studyDesigns)).
+			FindInBatches(&papers, classifiedPapersBatch, func(_ *gorm.DB, _ int) error {
+				rows := make([]classifiedPaper, 0, len(papers))
+				for _, p := range papers {
+					if p.PMID != "" || p.DOI != "" {
+						rows = append(rows, classifiedPaper{PMID: p.PMID, DOI: RatedDOI(p.DOI)})
+					}
+				}
+				if len(rows) == 0 {
+					return nil
+				}
+				return tx.Table(ClassifiedPapersTable).Create(&rows).Error
+			}).Error
+		if err != nil {
+			return err
+		}
+		for _, stmt := range []string{
+			"CREATE INDEX ON " + ClassifiedPapersTable + " (doi)",
+			"CREATE INDEX ON " + ClassifiedPapersTable + " (pmid)",
+			"ANALYZE " + ClassifiedPapersTable,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// BackfillPaperClassification überträgt die denormalisierten Spalten substance und study_design
+// in die Join-Tabellen. Idempotent, läuft beim Start.
+func BackfillPaperClassification(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO paper_substances (paper_id, substance_id, created_at)
+			SELECT p.id, s.id, NOW() FROM papers p JOIN substances s ON s.name = p.substance
+			ON CONFLICT DO NOTHING`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO paper_filters (paper_id, search_filter_id, created_at)
+			SELECT p.id, f.id, NOW() FROM papers p JOIN search_filters f ON f.name = p.study_design
+			ON CONFLICT DO NOTHING`).Error
+	})
+}
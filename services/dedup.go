@@ -0,0 +1,569 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+const (
+	// minHashSize ist die Signaturlänge; lshBands × lshRows muss ihr entsprechen.
+	minHashSize = 128
+	lshBands    = 32
+	lshRows     = 4 // Kandidaten ab einer Jaccard-Ähnlichkeit von etwa 0.4
+
+	// Gewichtung von Titel und Abstract, wenn beide Paper einen Abstract haben
+	titleWeight    = 0.4
+	abstractWeight = 0.6
+)
+
+var (
+	// ErrDedupRunning wird geliefert, wenn bereits ein Duplikat-Scan läuft.
+	ErrDedupRunning = errors.New("duplicate scan already running")
+	// ErrClusterResolved wird geliefert, wenn über einen Cluster bereits entschieden wurde.
+	ErrClusterResolved = errors.New("duplicate cluster is already resolved")
+	// ErrInvalidDuplicateDecision kennzeichnet Paper-IDs, die nicht zum Cluster gehören (HTTP 400).
+	ErrInvalidDuplicateDecision = errors.New("invalid duplicate decision")
+)
+
+// DuplicateDetector erkennt inhaltlich gleiche Paper innerhalb einer Substanz über Shingling/MinHash
+// auf Titel und Abstract und schlägt sie als Cluster zur Zusammenführung vor.
+type DuplicateDetector struct {
+	DB        *gorm.DB
+	Logger    *zap.Logger
+	Threshold float64 // Mindest-Score eines Paares
+
+	running atomic.Bool
+}
+
+// NewDuplicateDetector erstellt einen neuen Detector.
+func NewDuplicateDetector(db *gorm.DB, threshold float64, logger *zap.Logger) *DuplicateDetector {
+	return &DuplicateDetector{DB: db, Logger: logger, Threshold: threshold}
+}
+
+// DedupScanResult fasst einen Scan zusammen.
+type DedupScanResult struct {
+	Substances int `json:"substances"`
+	Papers     int `json:"papers"`
+	Clusters   int `json:"clusters"`
+}
+
+// Scan sucht Duplikat-Cluster in einer Substanz (leer = alle Substanzen). Offene Vorschläge der
+// gescannten Substanzen werden ersetzt; angenommene und abgelehnte Entscheidungen bleiben bestehen.
+// threshold <= 0 verwendet den konfigurierten Schwellwert.
+func (d *DuplicateDetector) Scan(ctx context.Context, substance string, threshold float64) (*DedupScanResult, error) {
+	if !d.running.CompareAndSwap(false, true) {
+		return nil, ErrDedupRunning
+	}
+	defer d.running.Store(false)
+	if threshold <= 0 {
+		threshold = d.Threshold
+	}
+
+	substances := []string{substance}
+	if substance == "" {
+		if err := d.DB.Model(&models.Substance{}).Order("name").Pluck("name", &substances).Error; err != nil {
+			return nil, err
+		}
+	}
+	rejected, err := d.rejectedPairs()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DedupScanResult{}
+	for _, sub := range substances {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		papers, clusters, err := d.scanSubstance(sub, threshold, rejected)
+		if err != nil {
+			return result, fmt.Errorf("duplicate scan for %q: %w", sub, err)
+		}
+		result.Substances++
+		result.Papers += papers
+		result.Clusters += clusters
+	}
+	d.Logger.Info("Duplikat-Scan abgeschlossen", zap.Int("substances", result.Substances),
+		zap.Int("papers", result.Papers), zap.Int("clusters", result.Clusters))
+	return result, nil
+}
+
+// StartScan startet Scan im Hintergrund; done wird mit dem Ergebnis aufgerufen.
+func (d *DuplicateDetector) StartScan(ctx context.Context, substance string, threshold float64, done func(*DedupScanResult, error)) error {
+	if d.running.Load() {
+		return ErrDedupRunning
+	}
+	go func() {
+		done(d.Scan(ctx, substance, threshold))
+	}()
+	return nil
+}
+
+// shingleDoc hält die Shingle-Mengen und MinHash-Signaturen eines Papers.
+type shingleDoc struct {
+	ID          uint
+	Title       map[uint64]struct{}
+	Abstract    map[uint64]struct{}
+	TitleSig    []uint64
+	AbstractSig []uint64
+}
+
+func (d *DuplicateDetector) scanSubstance(substance string, threshold float64, rejected map[[2]uint]bool) (int, int, error) {
+	var papers []models.Paper
+	err := d.DB.Model(&models.Paper{}).Select("id", "title", "abstract").
+		Scopes(PaperClassificationScope([]string{substance}, nil)).Order("id").Find(&papers).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	docs := make([]shingleDoc, 0, len(papers))
+	for _, p := range papers {
+		doc := shingleDoc{ID: p.ID, Title: shingles(p.Title, 2), Abstract: shingles(p.Abstract, 3)}
+		if len(doc.Title) == 0 && len(doc.Abstract) == 0 {
+			continue
+		}
+		doc.TitleSig = minHash(doc.Title)
+		doc.AbstractSig = minHash(doc.Abstract)
+		docs = append(docs, doc)
+	}
+
+	// Kandidaten über LSH auf Titel- und Abstract-Signaturen, danach exakte Jaccard-Ähnlichkeit
+	candidates := make(map[[2]int]bool)
+	lshCandidates(docs, func(doc shingleDoc) []uint64 { return doc.TitleSig }, candidates)
+	lshCandidates(docs, func(doc shingleDoc) []uint64 { return doc.AbstractSig }, candidates)
+
+	var pairs []models.DuplicatePair
+	for c := range candidates {
+		a, b := docs[c[0]], docs[c[1]]
+		if rejected[pairKey(a.ID, b.ID)] {
+			continue
+		}
+		pair := models.DuplicatePair{PaperA: a.ID, PaperB: b.ID, TitleSimilarity: jaccard(a.Title, b.Title)}
+		pair.Score = pair.TitleSimilarity
+		if len(a.Abstract) > 0 && len(b.Abstract) > 0 {
+			pair.AbstractSimilarity = jaccard(a.Abstract, b.Abstract)
+			pair.Score = titleWeight*pair.TitleSimilarity + abstractWeight*pair.AbstractSimilarity
+		}
+		if pair.Score >= threshold {
+			pairs = append(pairs, pair)
+		}
+	}
+	clusters := clusterPairs(pairs, substance)
+
+	created := 0
+	err = d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("substance = ? AND status = ?", substance, models.DuplicatePending).Delete(&models.DuplicateCluster{}).Error; err != nil {
+			return err
+		}
+		for i := range clusters {
+			// Derselbe Cluster kann über eine andere Substanz bereits vorgeschlagen oder entschieden sein
+			var existing int64
+			if err := tx.Model(&models.DuplicateCluster{}).Where("fingerprint = ?", clusters[i].Fingerprint).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				continue
+			}
+			if err := tx.Create(&clusters[i]).Error; err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+	return len(docs), created, err
+}
+
+// rejectedPairs liefert alle Paper-Paare, die in abgelehnten Clustern gemeinsam vorkamen.
+func (d *DuplicateDetector) rejectedPairs() (map[[2]uint]bool, error) {
+	var clusters []models.DuplicateCluster
+	if err := d.DB.Select("paper_ids").Where("status = ?", models.DuplicateRejected).Find(&clusters).Error; err != nil {
+		return nil, err
+	}
+	rejected := make(map[[2]uint]bool)
+	for _, c := range clusters {
+		for i := range c.PaperIDs {
+			for j := i + 1; j < len(c.PaperIDs); j++ {
+				rejected[pairKey(c.PaperIDs[i], c.PaperIDs[j])] = true
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// Accept führt die Paper eines Clusters in das kanonische Paper zusammen (Standard: kleinste ID).
+// paperIDs schränkt die Zusammenführung auf einen Teil des Clusters ein; die übrigen Paper bleiben
+// zusammen mit dem kanonischen Paper als neuer offener Cluster (remainder) zur Entscheidung stehen.
+// Zusammenführung, Entscheidung und das Umstellen anderer offener Cluster laufen in einer Transaktion.
+func (d *DuplicateDetector) Accept(clusterID, canonicalID uint, paperIDs []uint, note string) (cluster, remainder *models.DuplicateCluster, paper *models.Paper, err error) {
+	err = d.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if cluster, err = pendingCluster(tx, clusterID); err != nil {
+			return err
+		}
+		members := cluster.PaperIDs
+		if len(paperIDs) > 0 {
+			members = paperIDs
+		}
+		for _, id := range append([]uint{canonicalID}, members...) {
+			if id != 0 && !containsID(cluster.PaperIDs, id) {
+				return fmt.Errorf("%w: paper %d is not part of cluster %d", ErrInvalidDuplicateDecision, id, cluster.ID)
+			}
+		}
+		if canonicalID == 0 {
+			canonicalID = slices.Min(members)
+		}
+		var merged []uint
+		for _, id := range members {
+			if id != canonicalID && !containsID(merged, id) {
+				merged = append(merged, id)
+			}
+		}
+		if len(merged) == 0 {
+			return fmt.Errorf("%w: selection merges no paper into %d", ErrInvalidDuplicateDecision, canonicalID)
+		}
+
+		if paper, err = mergePapersTx(tx, canonicalID, merged); err != nil {
+			return err
+		}
+		if err := remapDuplicateClusters(tx, canonicalID, merged, cluster.ID); err != nil {
+			return err
+		}
+
+		var rest []uint
+		for _, id := range cluster.PaperIDs {
+			if id != canonicalID && !containsID(merged, id) {
+				rest = append(rest, id)
+			}
+		}
+		if len(rest) > 0 {
+			accepted := append([]uint{canonicalID}, merged...)
+			var acceptedPairs, restPairs []models.DuplicatePair
+			for _, p := range cluster.Pairs {
+				if containsID(accepted, p.PaperA) && containsID(accepted, p.PaperB) {
+					acceptedPairs = append(acceptedPairs, p)
+				} else {
+					restPairs = append(restPairs, p)
+				}
+			}
+			// Paare mit zusammengeführten Papern gelten nun für das kanonische Paper
+			remainder = newDuplicateCluster(cluster.Substance, append([]uint{canonicalID}, rest...), remapPairs(restPairs, canonicalID, merged))
+			exists, err := clusterExists(tx, remainder.Fingerprint, 0)
+			if err != nil {
+				return err
+			}
+			if exists {
+				remainder = nil
+			} else if err := tx.Create(remainder).Error; err != nil {
+				return err
+			}
+			cluster.PaperIDs, cluster.Pairs = sortedIDs(accepted), acceptedPairs
+			cluster.Fingerprint = clusterFingerprint(cluster.PaperIDs)
+		}
+		now := time.Now()
+		cluster.Status, cluster.CanonicalID, cluster.ResolvedAt, cluster.Note = models.DuplicateAccepted, &canonicalID, &now, note
+		return tx.Save(cluster).Error
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cluster, remainder, paper, nil
+}
+
+// Reject markiert einen Cluster als verschiedene Studien; seine Paare werden nicht erneut vorgeschlagen.
+func (d *DuplicateDetector) Reject(clusterID uint, note string) (*models.DuplicateCluster, error) {
+	var cluster *models.DuplicateCluster
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if cluster, err = pendingCluster(tx, clusterID); err != nil {
+			return err
+		}
+		now := time.Now()
+		cluster.Status, cluster.ResolvedAt, cluster.Note = models.DuplicateRejected, &now, note
+		return tx.Save(cluster).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// pendingCluster lädt einen offenen Cluster und sperrt ihn bis zum Ende von tx.
+func pendingCluster(tx *gorm.DB, id uint) (*models.DuplicateCluster, error) {
+	var cluster models.DuplicateCluster
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cluster, id).Error; err != nil {
+		return nil, err
+	}
+	if cluster.Status != models.DuplicatePending {
+		return nil, fmt.Errorf("%w: cluster %d is %s", ErrClusterResolved, cluster.ID, cluster.Status)
+	}
+	return &cluster, nil
+}
+
+// remapDuplicateClusters stellt offene Cluster, die zusammengeführte Paper enthalten, auf das kanonische
+// Paper um. Bleibt nur ein Paper übrig oder gibt es den Cluster danach schon, wird der Vorschlag gelöscht.
+// except sind Cluster, die der Aufrufer selbst entscheidet.
+func remapDuplicateClusters(tx *gorm.DB, canonicalID uint, merged []uint, except ...uint) error {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", models.DuplicatePending).
+		Where("EXISTS (SELECT 1 FROM jsonb_array_elements_text(paper_ids) AS e(id) WHERE e.id::bigint IN ?)", merged)
+	if len(except) > 0 {
+		query = query.Where("id NOT IN ?", except)
+	}
+	var clusters []models.DuplicateCluster
+	if err := query.Find(&clusters).Error; err != nil {
+		return err
+	}
+	for i := range clusters {
+		c := &clusters[i]
+		var ids []uint
+		for _, id := range c.PaperIDs {
+			if containsID(merged, id) {
+				id = canonicalID
+			}
+			if !containsID(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		remapped := newDuplicateCluster(c.Substance, ids, remapPairs(c.Pairs, canonicalID, merged))
+		exists, err := clusterExists(tx, remapped.Fingerprint, c.ID)
+		if err != nil {
+			return err
+		}
+		if len(ids) < 2 || exists {
+			if err := tx.Delete(c).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		c.PaperIDs, c.Pairs, c.Score, c.Fingerprint = remapped.PaperIDs, remapped.Pairs, remapped.Score, remapped.Fingerprint
+		if err := tx.Save(c).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remapPairs ersetzt zusammengeführte Paper durch das kanonische Paper; Paare eines Papers mit sich selbst
+// entfallen, doppelte Paare behalten den höchsten Score.
+func remapPairs(pairs []models.DuplicatePair, canonicalID uint, merged []uint) []models.DuplicatePair {
+	remap := func(id uint) uint {
+		if containsID(merged, id) {
+			return canonicalID
+		}
+		return id
+	}
+	out := make([]models.DuplicatePair, 0, len(pairs))
+	index := make(map[[2]uint]int)
+	for _, p := range pairs {
+		p.PaperA, p.PaperB = remap(p.PaperA), remap(p.PaperB)
+		if p.PaperA == p.PaperB {
+			continue
+		}
+		key := pairKey(p.PaperA, p.PaperB)
+		if i, ok := index[key]; ok {
+			if p.Score > out[i].Score {
+				out[i] = p
+			}
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, p)
+	}
+	return out
+}
+
+// clusterExists prüft, ob es einen anderen Cluster (außer except) mit diesem Fingerprint gibt.
+func clusterExists(tx *gorm.DB, fingerprint string, except uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.DuplicateCluster{}).Where("fingerprint = ? AND id <> ?", fingerprint, except).Count(&count).Error
+	return count > 0, err
+}
+
+// clusterPairs fasst Paare per Union-Find zu Clustern zusammen.
+func clusterPairs(pairs []models.DuplicatePair, substance string) []models.DuplicateCluster {
+	parent := make(map[uint]uint)
+	var find func(uint) uint
+	find = func(id uint) uint {
+		if p, ok := parent[id]; ok && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		parent[id] = id
+		return id
+	}
+	for _, p := range pairs {
+		a, b := find(p.PaperA), find(p.PaperB)
+		if a != b {
+			parent[b] = a
+		}
+	}
+
+	byRoot := make(map[uint]*models.DuplicateCluster)
+	for _, p := range pairs {
+		root := find(p.PaperA)
+		c, ok := byRoot[root]
+		if !ok {
+			c = &models.DuplicateCluster{Substance: substance, Status: models.DuplicatePending}
+			byRoot[root] = c
+		}
+		for _, id := range []uint{p.PaperA, p.PaperB} {
+			if !containsID(c.PaperIDs, id) {
+				c.PaperIDs = append(c.PaperIDs, id)
+			}
+		}
+		c.Pairs = append(c.Pairs, p)
+		if p.Score > c.Score {
+			c.Score = p.Score
+		}
+	}
+
+	clusters := make([]models.DuplicateCluster, 0, len(byRoot))
+	for _, c := range byRoot {
+		clusters = append(clusters, *newDuplicateCluster(substance, c.PaperIDs, c.Pairs))
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Score > clusters[j].Score })
+	return clusters
+}
+
+// newDuplicateCluster baut einen offenen Cluster mit sortierten Paper-IDs, Fingerprint und Score.
+func newDuplicateCluster(substance string, paperIDs []uint, pairs []models.DuplicatePair) *models.DuplicateCluster {
+	c := &models.DuplicateCluster{Substance: substance, Status: models.DuplicatePending, PaperIDs: sortedIDs(paperIDs), Pairs: pairs}
+	sort.Slice(c.Pairs, func(i, j int) bool { return c.Pairs[i].Score > c.Pairs[j].Score })
+	for _, p := range c.Pairs {
+		if p.Score > c.Score {
+			c.Score = p.Score
+		}
+	}
+	c.Fingerprint = clusterFingerprint(c.PaperIDs)
+	return c
+}
+
+// clusterFingerprint verbindet die sortierten Paper-IDs, z.B. "12,57".
+func clusterFingerprint(sorted []uint) string {
+	ids := make([]string, len(sorted))
+	for i, id := range sorted {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+func sortedIDs(ids []uint) []uint {
+	out := slices.Clone(ids)
+	slices.Sort(out)
+	return out
+}
+
+// lshCandidates sammelt Dokumentpaare, die in mindestens einem Band identische Signaturwerte haben.
+func lshCandidates(docs []shingleDoc, sig func(shingleDoc) []uint64, candidates map[[2]int]bool) {
+	for band := 0; band < lshBands; band++ {
+		buckets := make(map[uint64][]int)
+		for i, doc := range docs {
+			s := sig(doc)
+			if s == nil {
+				continue
+			}
+			h := uint64(band)
+			for _, v := range s[band*lshRows : (band+1)*lshRows] {
+				h = splitmix64(h ^ v)
+			}
+			buckets[h] = append(buckets[h], i)
+		}
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					candidates[[2]int{bucket[x], bucket[y]}] = true
+				}
+			}
+		}
+	}
+}
+
+// shingles zerlegt normalisierten Text in gehashte Wort-k-Gramme (kürzere Texte: ein Shingle je Wort).
+func shingles(text string, k int) map[uint64]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[uint64]struct{})
+	if len(words) < k {
+		k = 1
+	}
+	for i := 0; i+k <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+k], " ")))
+		set[h.Sum64()] = struct{}{}
+	}
+	return set
+}
+
+// minHash berechnet die MinHash-Signatur einer Shingle-Menge (nil bei leerer Menge).
+func minHash(set map[uint64]struct{}) []uint64 {
+	if len(set) == 0 {
+		return nil
+	}
+	sig := make([]uint64, minHashSize)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for x := range set {
+		for i := range sig {
+			if h := splitmix64(x ^ minHashSeeds[i]); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+var minHashSeeds = func() []uint64 {
+	seeds := make([]uint64, minHashSize)
+	for i := range seeds {
+		seeds[i] = splitmix64(uint64(i) + 1)
+	}
+	return seeds
+}()
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	inter := 0
+	for x := range a {
+		if _, ok := b[x]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+func pairKey(a, b uint) [2]uint {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]uint{a, b}
+}
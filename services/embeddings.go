@@ -0,0 +1,595 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/embeddings"
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// MaxSimilarResults begrenzt limit bei /papers/similar.
+const MaxSimilarResults = 100
+
+// maxEmbedChars kürzt sehr lange Texte vor dem Einbetten (Token-Limit der Modelle).
+const maxEmbedChars = 12000
+
+var (
+	// ErrEmbeddingsDisabled wird geliefert, wenn kein Embedder konfiguriert ist (EMBEDDING_BASE_URL).
+	ErrEmbeddingsDisabled = errors.New("embeddings are not configured")
+	// ErrEmbeddingRefreshRunning wird geliefert, wenn bereits eine Aktualisierung läuft.
+	ErrEmbeddingRefreshRunning = errors.New("embedding refresh already running")
+	// ErrInvalidSimilarRequest kennzeichnet fehlende oder widersprüchliche Angaben (HTTP 400).
+	ErrInvalidSimilarRequest = errors.New("invalid similarity request")
+)
+
+// embeddingSource beschreibt, welcher Text einer Tabelle eingebettet wird.
+type embeddingSource struct {
+	EntityType string
+	Table      string
+	Text       string // SQL-Ausdruck über Alias t
+	Condition  string // nur Zeilen mit Inhalt
+}
+
+var (
+	paperEmbeddingSource = embeddingSource{
+		EntityType: models.EmbeddingPaper,
+		Table:      "papers",
+		Text:       `coalesce(t.title, '') || E'\n\n' || coalesce(t.abstract, '')`,
+		Condition:  "coalesce(t.abstract, '') <> ''",
+	}
+	ratedPaperEmbeddingSource = embeddingSource{
+		EntityType: models.EmbeddingRatedPaper,
+		Table:      "rated_papers",
+		Text:       `coalesce(t.ai_summary, '') || E'\n\n' || coalesce(t.key_findings, '')`,
+		Condition:  "coalesce(t.ai_summary, '') <> ''",
+	}
+)
+
+// EmbeddingService hält die Embeddings von Papers (rawDB) und Analysen (ratedDB) aktuell und
+// beantwortet Ähnlichkeitsanfragen über einen In-Process-Index (Kosinus-Ähnlichkeit).
+type EmbeddingService struct {
+	RawDB     *gorm.DB
+	RatedDB   *gorm.DB
+	Embedder  embeddings.Embedder // nil = deaktiviert
+	Logger    *zap.Logger
+	BatchSize int
+
+	running     atomic.Bool
+	papers      *vectorIndex
+	ratedPapers *vectorIndex
+}
+
+// NewEmbeddingService erstellt den Service; embedder darf nil sein.
+func NewEmbeddingService(rawDB, ratedDB *gorm.DB, embedder embeddings.Embedder, batchSize int, logger *zap.Logger) *EmbeddingService {
+	if batchSize <= 0 {
+		batchSize = 64
+	}
+	return &EmbeddingService{
+		RawDB:       rawDB,
+		RatedDB:     ratedDB,
+		Embedder:    embedder,
+		Logger:      logger,
+		BatchSize:   batchSize,
+		papers:      &vectorIndex{entityType: models.EmbeddingPaper},
+		ratedPapers: &vectorIndex{entityType: models.EmbeddingRatedPaper},
+	}
+}
+
+// Enabled gibt an, ob ein Embedder konfiguriert ist.
+func (s *EmbeddingService) Enabled() bool {
+	return s.Embedder != nil
+}
+
+// EmbeddingStatus zeigt den Stand der Embeddings je Entität.
+type EmbeddingStatus struct {
+	Enabled     bool   `json:"enabled"`
+	Model       string `json:"model,omitempty"`
+	Running     bool   `json:"running"`
+	Papers      int64  `json:"papers"`
+	RatedPapers int64  `json:"rated_papers"`
+}
+
+// Status zählt die gespeicherten Embeddings des aktuellen Modells.
+func (s *EmbeddingService) Status() (*EmbeddingStatus, error) {
+	status := &EmbeddingStatus{Enabled: s.Enabled(), Running: s.running.Load()}
+	if !s.Enabled() {
+		return status, nil
+	}
+	status.Model = s.Embedder.Model()
+	if err := s.RawDB.Model(&models.Embedding{}).Where("entity_type = ? AND model = ?", models.EmbeddingPaper, status.Model).Count(&status.Papers).Error; err != nil {
+		return nil, err
+	}
+	if err := s.RatedDB.Model(&models.Embedding{}).Where("entity_type = ? AND model = ?", models.EmbeddingRatedPaper, status.Model).Count(&status.RatedPapers).Error; err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Refresh bettet alle neuen oder geänderten Papers und Analysen ein und entfernt verwaiste Embeddings.
+// Läuft bereits eine Aktualisierung, wird ErrEmbeddingRefreshRunning geliefert.
+func (s *EmbeddingService) Refresh(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, ErrEmbeddingsDisabled
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return 0, ErrEmbeddingRefreshRunning
+	}
+	defer s.running.Store(false)
+
+	total := 0
+	for _, target := range []struct {
+		db     *gorm.DB
+		source embeddingSource
+	}{
+		{s.RawDB, paperEmbeddingSource},
+		{s.RatedDB, ratedPaperEmbeddingSource},
+	} {
+		count, err := s.refreshSource(ctx, target.db, target.source)
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+	s.Logger.Info("Embeddings aktualisiert", zap.String("model", s.Embedder.Model()), zap.Int("embedded", total))
+	return total, nil
+}
+
+// StartRefresh startet Refresh im Hintergrund; done wird mit dem Ergebnis aufgerufen.
+func (s *EmbeddingService) StartRefresh(ctx context.Context, done func(int, error)) error {
+	if !s.Enabled() {
+		return ErrEmbeddingsDisabled
+	}
+	if s.running.Load() {
+		return ErrEmbeddingRefreshRunning
+	}
+	go func() {
+		done(s.Refresh(ctx))
+	}()
+	return nil
+}
+
+func (s *EmbeddingService) refreshSource(ctx context.Context, db *gorm.DB, src embeddingSource) (int, error) {
+	model := s.Embedder.Model()
+	if err := db.Exec(fmt.Sprintf(`DELETE FROM embeddings e WHERE e.entity_type = ?
+		AND NOT EXISTS (SELECT 1 FROM %s t WHERE t.id = e.entity_id)`, src.Table), src.EntityType).Error; err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		ID   uint
+		Text string
+		Hash string
+	}
+	stale := fmt.Sprintf(`SELECT t.id, %[2]s AS text, md5(%[2]s) AS hash FROM %[1]s t
+		LEFT JOIN embeddings e ON e.entity_type = ? AND e.entity_id = t.id AND e.model = ?
+		WHERE %[3]s AND (e.id IS NULL OR e.content_hash <> md5(%[2]s)) AND t.id > ?
+		ORDER BY t.id LIMIT ?`, src.Table, src.Text, src.Condition)
+
+	embedded := 0
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return embedded, err
+		}
+		var batch []pending
+		if err := db.Raw(stale, src.EntityType, model, lastID, s.BatchSize).Scan(&batch).Error; err != nil {
+			return embedded, err
+		}
+		if len(batch) == 0 {
+			return embedded, nil
+		}
+		lastID = batch[len(batch)-1].ID
+
+		texts := make([]string, len(batch))
+		for i, p := range batch {
+			texts[i] = truncateRunes(p.Text, maxEmbedChars)
+		}
+		vectors, err := s.Embedder.Embed(ctx, texts)
+		if err != nil {
+			return embedded, fmt.Errorf("embedding %s batch: %w", src.EntityType, err)
+		}
+		rows := make([]models.Embedding, len(batch))
+		for i, p := range batch {
+			rows[i] = models.Embedding{
+				EntityType:  src.EntityType,
+				EntityID:    p.ID,
+				Model:       model,
+				Dimensions:  len(vectors[i]),
+				ContentHash: p.Hash,
+				Vector:      encodeVector(vectors[i]),
+			}
+		}
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_type"}, {Name: "entity_id"}, {Name: "model"}},
+			DoUpdates: clause.AssignmentColumns([]string{"dimensions", "content_hash", "vector", "updated_at"}),
+		}).Create(&rows).Error
+		if err != nil {
+			return embedded, err
+		}
+		embedded += len(rows)
+	}
+}
+
+// SimilarRequest ist eine "more like this"- oder Freitext-Anfrage. Genau eine Quelle ist anzugeben:
+// paper_id, pmid, doi oder text.
+type SimilarRequest struct {
+	PaperID *uint  `json:"paper_id"`
+	PMID    string `json:"pmid"`
+	DOI     string `json:"doi"`
+	Text    string `json:"text"`
+
+	Targets      []string `json:"targets"` // papers (Standard), rated_papers
+	Limit        int      `json:"limit"`   // Standard 10, max. 100
+	MinScore     float64  `json:"min_score"`
+	Substances   []string `json:"substances"`    // nur Papers dieser Substanzen
+	StudyDesigns []string `json:"study_designs"` // nur Papers dieser Studiendesigns
+	MinRating    *float64 `json:"min_rating"`    // nur Analysen mit Rating >= Wert
+}
+
+// SimilarHit ist ein Treffer; je nach Typ ist Paper oder RatedPaper gesetzt.
+type SimilarHit struct {
+	Type       string             `json:"type"` // paper, rated_paper
+	Score      float64            `json:"score"`
+	Paper      *models.Paper      `json:"paper,omitempty"`
+	RatedPaper *models.RatedPaper `json:"rated_paper,omitempty"`
+}
+
+// SimilarResult enthält die Treffer, absteigend nach Ähnlichkeit.
+type SimilarResult struct {
+	Model   string        `json:"model"`
+	Source  *models.Paper `json:"source,omitempty"` // Ausgangs-Paper bei "more like this"
+	Results []SimilarHit  `json:"results"`
+}
+
+// Similar sucht ähnliche Papers und/oder Analysen zu einem Paper oder Freitext.
+func (s *EmbeddingService) Similar(ctx context.Context, req SimilarRequest) (*SimilarResult, error) {
+	if !s.Enabled() {
+		return nil, ErrEmbeddingsDisabled
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > MaxSimilarResults {
+		limit = MaxSimilarResults
+	}
+	searchPapers, searchRated := len(req.Targets) == 0, false
+	for _, t := range req.Targets {
+		switch t {
+		case "papers":
+			searchPapers = true
+		case "rated_papers":
+			searchRated = true
+		default:
+			return nil, fmt.Errorf("%w: unknown target %q (allowed: papers, rated_papers)", ErrInvalidSimilarRequest, t)
+		}
+	}
+
+	result := &SimilarResult{Model: s.Embedder.Model(), Results: []SimilarHit{}}
+	query, err := s.queryVector(ctx, req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	if searchPapers {
+		hits, err := s.similarPapers(query, req, result.Source, limit)
+		if err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, hits...)
+	}
+	if searchRated {
+		hits, err := s.similarRatedPapers(query, req, result.Source, limit)
+		if err != nil {
+			return nil, err
+		}
+		result.Results = append(result.Results, hits...)
+	}
+	sort.SliceStable(result.Results, func(i, j int) bool { return result.Results[i].Score > result.Results[j].Score })
+	if len(result.Results) > limit {
+		result.Results = result.Results[:limit]
+	}
+	return result, nil
+}
+
+// queryVector liefert den (normalisierten) Anfragevektor: das gespeicherte Embedding des Ausgangs-Papers,
+// sonst ein frisch erzeugtes Embedding von Paper-Text bzw. Freitext.
+func (s *EmbeddingService) queryVector(ctx context.Context, req SimilarRequest, result *SimilarResult) ([]float32, error) {
+	var ids []identifiers.ID
+	if req.PMID != "" {
+		ids = append(ids, identifiers.ID{Kind: identifiers.PMID, Value: req.PMID})
+	}
+	if req.DOI != "" {
+		ids = append(ids, identifiers.ID{Kind: identifiers.DOI, Value: req.DOI})
+	}
+	sources := 0
+	if len(ids) > 0 {
+		sources++ // pmid und doi dürfen gemeinsam dasselbe Paper bezeichnen
+	}
+	if req.PaperID != nil {
+		sources++
+	}
+	if req.Text != "" {
+		sources++
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("%w: exactly one of paper_id, pmid, doi or text is required", ErrInvalidSimilarRequest)
+	}
+
+	if req.Text != "" {
+		return s.embedOne(ctx, req.Text)
+	}
+	var paper *models.Paper
+	var err error
+	if req.PaperID != nil {
+		paper = &models.Paper{}
+		err = s.RawDB.First(paper, *req.PaperID).Error
+	} else {
+		paper, err = FindPaper(s.RawDB, ids...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Source = paper
+
+	if err := s.papers.ensure(s.RawDB, s.Embedder.Model()); err != nil {
+		return nil, err
+	}
+	if v, ok := s.papers.get(paper.ID); ok {
+		return v, nil
+	}
+	text := paper.Title + "\n\n" + paper.Abstract
+	if paper.Abstract == "" && paper.Title == "" {
+		return nil, fmt.Errorf("%w: paper %d has neither title nor abstract", ErrInvalidSimilarRequest, paper.ID)
+	}
+	return s.embedOne(ctx, text)
+}
+
+func (s *EmbeddingService) embedOne(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := s.Embedder.Embed(ctx, []string{truncateRunes(text, maxEmbedChars)})
+	if err != nil {
+		return nil, err
+	}
+	return normalizeVector(vectors[0]), nil
+}
+
+func (s *EmbeddingService) similarPapers(query []float32, req SimilarRequest, source *models.Paper, limit int) ([]SimilarHit, error) {
+	if err := s.papers.ensure(s.RawDB, s.Embedder.Model()); err != nil {
+		return nil, err
+	}
+	var allowed map[uint]bool
+	if len(req.Substances) > 0 || len(req.StudyDesigns) > 0 {
+		var ids []uint
+		if err := s.RawDB.Model(&models.Paper{}).Scopes(PaperClassificationScope(req.Substances, req.StudyDesigns)).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		allowed = make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			allowed[id] = true
+		}
+	}
+	scored := s.papers.search(query, limit, req.MinScore, func(id uint) bool {
+		return (source == nil || id != source.ID) && (allowed == nil || allowed[id])
+	})
+	if len(scored) == 0 {
+		return nil, nil
+	}
+
+	var papers []models.Paper
+	if err := s.RawDB.Where("id IN ?", scoredIDs(scored)).Find(&papers).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Paper, len(papers))
+	for i := range papers {
+		byID[papers[i].ID] = &papers[i]
+	}
+	var hits []SimilarHit
+	for _, sc := range scored {
+		if p, ok := byID[sc.id]; ok {
+			hits = append(hits, SimilarHit{Type: models.EmbeddingPaper, Score: sc.score, Paper: p})
+		}
+	}
+	return hits, nil
+}
+
+func (s *EmbeddingService) similarRatedPapers(query []float32, req SimilarRequest, source *models.Paper, limit int) ([]SimilarHit, error) {
+	if err := s.ratedPapers.ensure(s.RatedDB, s.Embedder.Model()); err != nil {
+		return nil, err
+	}
+	// Filter (Rating, eigene Analyse des Ausgangs-Papers) schränken die Kandidaten vor dem Ranking ein
+	var allowed, excluded map[uint]bool
+	if req.MinRating != nil {
+		var ids []uint
+		if err := s.RatedDB.Model(&models.RatedPaper{}).Where("rating >= ?", *req.MinRating).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		allowed = make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			allowed[id] = true
+		}
+	}
+	if source != nil && source.DOI != "" {
+		var ids []uint
+		if err := s.RatedDB.Model(&models.RatedPaper{}).Where("doi = ?", RatedDOI(source.DOI)).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		excluded = make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			excluded[id] = true
+		}
+	}
+	scored := s.ratedPapers.search(query, limit, req.MinScore, func(id uint) bool {
+		return !excluded[id] && (allowed == nil || allowed[id])
+	})
+	if len(scored) == 0 {
+		return nil, nil
+	}
+
+	var rated []models.RatedPaper
+	if err := s.RatedDB.Where("id IN ?", scoredIDs(scored)).Find(&rated).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.RatedPaper, len(rated))
+	for i := range rated {
+		byID[rated[i].ID] = &rated[i]
+	}
+	var hits []SimilarHit
+	for _, sc := range scored {
+		if r, ok := byID[sc.id]; ok {
+			hits = append(hits, SimilarHit{Type: models.EmbeddingRatedPaper, Score: sc.score, RatedPaper: r})
+		}
+	}
+	return hits, nil
+}
+
+// vectorIndex hält alle Vektoren eines Entitätstyps normalisiert im Speicher. Er wird neu geladen,
+// sobald sich Anzahl oder letzter Änderungszeitpunkt der Embeddings in der Datenbank ändern.
+type vectorIndex struct {
+	entityType string
+
+	mu      sync.RWMutex
+	version string
+	vectors map[uint][]float32
+}
+
+type scoredID struct {
+	id    uint
+	score float64
+}
+
+func (ix *vectorIndex) ensure(db *gorm.DB, model string) error {
+	var state struct {
+		Count   int64
+		Updated *time.Time
+	}
+	if err := db.Model(&models.Embedding{}).Select("COUNT(*) AS count, MAX(updated_at) AS updated").
+		Where("entity_type = ? AND model = ?", ix.entityType, model).Scan(&state).Error; err != nil {
+		return err
+	}
+	version := fmt.Sprintf("%s|%d", model, state.Count)
+	if state.Updated != nil {
+		version += "|" + state.Updated.UTC().Format(time.RFC3339Nano)
+	}
+
+	ix.mu.RLock()
+	current := ix.version == version
+	ix.mu.RUnlock()
+	if current {
+		return nil
+	}
+
+	vectors := make(map[uint][]float32, state.Count)
+	var rows []models.Embedding
+	err := db.Select("id", "entity_id", "vector").Where("entity_type = ? AND model = ?", ix.entityType, model).
+		FindInBatches(&rows, 1000, func(tx *gorm.DB, batch int) error {
+			for _, r := range rows {
+				vectors[r.EntityID] = normalizeVector(decodeVector(r.Vector))
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	ix.vectors, ix.version = vectors, version
+	ix.mu.Unlock()
+	return nil
+}
+
+func (ix *vectorIndex) get(id uint) ([]float32, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	v, ok := ix.vectors[id]
+	return v, ok
+}
+
+// search liefert die limit ähnlichsten Einträge (Skalarprodukt normalisierter Vektoren = Kosinus).
+func (ix *vectorIndex) search(query []float32, limit int, minScore float64, keep func(uint) bool) []scoredID {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var scored []scoredID
+	for id, v := range ix.vectors {
+		if len(v) != len(query) || !keep(id) {
+			continue
+		}
+		var dot float64
+		for i := range v {
+			dot += float64(v[i]) * float64(query[i])
+		}
+		if dot >= minScore {
+			scored = append(scored, scoredID{id: id, score: dot})
+		}
+	}
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].id < scored[j].id
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+	return scored
+}
+
+func scoredIDs(scored []scoredID) []uint {
+	ids := make([]uint, len(scored))
+	for i, sc := range scored {
+		ids[i] = sc.id
+	}
+	return ids
+}
+
+func normalizeVector(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
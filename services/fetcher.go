@@ -4,16 +4,21 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"paper-hand/config"
 	"paper-hand/models"
@@ -48,18 +53,33 @@ type FetchService struct {
 	Logger           *zap.Logger
 	Providers        []providers.Provider
 	UnpaywallFetcher *unpaywall.Fetcher
+	Locker           *JobLocker
+	Precedence       Precedence      // Feld-Präzedenz beim Zusammenführen von Provider-Treffern
+	Webhooks         *WebhookService // optional, meldet paper.ingested und paper.pdf_stored
 	httpClient       *http.Client
+	jobs             sync.WaitGroup
 }
 
 // NewFetchService erstellt eine neue Instanz des FetchService.
 func NewFetchService(cfg *config.Config, db *gorm.DB, s3Client *s3.Client, logger *zap.Logger, providers []providers.Provider, unpaywallFetcher *unpaywall.Fetcher) *FetchService {
+	order := cfg.ProviderPrecedence
+	if order == "" {
+		order = cfg.EnabledProviders
+	}
+	precedence, err := ParsePrecedence(order, cfg.FieldPrecedence)
+	if err != nil {
+		logger.Warn("Ungültige FIELD_PRECEDENCE, Feld-Overrides werden ignoriert", zap.Error(err))
+		precedence.Fields = map[string][]string{}
+	}
 	return &FetchService{
+		Precedence:       precedence,
 		Config:           cfg,
 		DB:               db,
 		S3Client:         s3Client,
 		Logger:           logger,
 		Providers:        providers,
 		UnpaywallFetcher: unpaywallFetcher,
+		Locker:           NewJobLocker(db, logger),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 			Transport: &http.Transport{
@@ -69,170 +89,558 @@ func NewFetchService(cfg *config.Config, db *gorm.DB, s3Client *s3.Client, logge
 	}
 }
 
+// UseWebhooks meldet neue Paper, gespeicherte PDFs und beendete Jobs an die Webhooks.
+func (f *FetchService) UseWebhooks(webhooks *WebhookService) {
+	f.Webhooks = webhooks
+	f.Locker.Webhooks = webhooks
+}
+
+// Trigger-Werte für FetchJobs.
+const (
+	TriggerCron   = "cron"
+	TriggerAPI    = "api"
+	TriggerResume = "resume"
+)
+
+// fetchCheckpoint hält den Fortschritt eines Jobs fest, damit ein unterbrochener Lauf beim nächsten Start fortgesetzt werden kann.
+type fetchCheckpoint struct {
+	SubstanceIDs []uint          `json:"substance_ids,omitempty"` // offene Substanzen eines Gesamtlaufs
+	Substances   []string        `json:"substances,omitempty"`    // ältere Checkpoints: offene Substanzen nach Namen
+	Phase        string          `json:"phase,omitempty"`         // search, process (Substanz-Lauf)
+	Pending      []*models.Paper `json:"pending,omitempty"`       // noch nicht verarbeitete Paper einer Substanz
+}
+
+// Wait blockiert, bis alle im Hintergrund gestarteten Läufe beendet sind.
+func (f *FetchService) Wait() {
+	f.jobs.Wait()
+}
+
 // RunAllSubstances startet den Fetch-Prozess für alle in der DB hinterlegten Substanzen.
-func (f *FetchService) RunAllSubstances(ctx context.Context) (int, error) {
+// Läuft bereits ein Gesamtlauf (auch auf einem anderen Replikat), wird ein *JobRunningError zurückgegeben.
+func (f *FetchService) RunAllSubstances(ctx context.Context, trigger string) (int, error) {
+	lock, err := f.Locker.TryAcquire(ctx, AllSubstancesLockKey, "", trigger)
+	if err != nil {
+		return 0, err
+	}
+	count, err := f.runAllSubstances(ctx, lock, nil, trigger)
+	lock.Release(count, err)
+	return count, err
+}
+
+// StartAllSubstances sichert den Lock synchron und führt den Gesamtlauf im Hintergrund aus.
+// ctx ist der Lauf-Kontext (nicht der Request-Kontext); done wird nach Abschluss mit dem Ergebnis aufgerufen.
+func (f *FetchService) StartAllSubstances(ctx context.Context, trigger string, done func(int, error)) (*models.FetchJob, error) {
+	lock, err := f.Locker.TryAcquire(ctx, AllSubstancesLockKey, "", trigger)
+	if err != nil {
+		return nil, err
+	}
+	f.jobs.Add(1)
+	go func() {
+		defer f.jobs.Done()
+		count, err := f.runAllSubstances(ctx, lock, nil, trigger)
+		lock.Release(count, err)
+		done(count, err)
+	}()
+	return lock.Job, nil
+}
+
+// StartSubstance sichert den Lock für eine Substanz synchron und führt die Suche im Hintergrund aus.
+func (f *FetchService) StartSubstance(ctx context.Context, sub models.Substance, trigger string, done func(int, error)) (*models.FetchJob, error) {
+	filters, err := f.filtersFor(sub)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := f.Locker.TryAcquire(ctx, SubstanceLockKey(sub.ID), sub.Name, trigger)
+	if err != nil {
+		return nil, err
+	}
+	f.jobs.Add(1)
+	go func() {
+		defer f.jobs.Done()
+		count, err := f.runForSubstance(ctx, lock, sub, filters, nil)
+		lock.Release(count, err)
+		done(count, err)
+	}()
+	return lock.Job, nil
+}
+
+// ResumeInterrupted setzt beim Start alle Jobs fort, die beim letzten Shutdown (oder Absturz) unterbrochen wurden.
+// Die Läufe werden im Hintergrund ausgeführt; Schlüssel, die gerade anderswo laufen, werden übersprungen.
+func (f *FetchService) ResumeInterrupted(ctx context.Context) {
+	var keys []string
+	if err := f.DB.Model(&models.FetchJob{}).
+		Where("status IN ? AND checkpoint IS NOT NULL", resumableStatuses).
+		Distinct("lock_key").
+		Pluck("lock_key", &keys).Error; err != nil {
+		f.Logger.Error("Konnte unterbrochene Jobs nicht laden", zap.Error(err))
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	// Gesamtlauf zuerst: er übernimmt die Checkpoints seiner Substanzen.
+	sort.Slice(keys, func(i, j int) bool { return keys[i] == AllSubstancesLockKey && keys[j] != AllSubstancesLockKey })
+	f.Logger.Info("Setze unterbrochene Fetch-Jobs fort", zap.Strings("lock_keys", keys))
+
+	f.jobs.Add(1)
+	go func() {
+		defer f.jobs.Done()
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return
+			}
+			count, err := f.resumeKey(ctx, key)
+			var running *JobRunningError
+			switch {
+			case errors.As(err, &running):
+				f.Logger.Info("Job läuft bereits anderswo, Fortsetzung übersprungen", zap.String("lock_key", key))
+			case err != nil:
+				f.Logger.Error("Fortsetzung fehlgeschlagen", zap.String("lock_key", key), zap.Error(err))
+			default:
+				f.Logger.Info("Unterbrochener Job fortgesetzt", zap.String("lock_key", key), zap.Int("new_papers", count))
+			}
+		}
+	}()
+}
+
+// resumeKey setzt den zuletzt unterbrochenen Job eines Lock-Schlüssels fort.
+func (f *FetchService) resumeKey(ctx context.Context, key string) (int, error) {
+	if key != AllSubstancesLockKey {
+		// Kann inzwischen vom fortgesetzten Gesamtlauf übernommen worden sein.
+		old := f.Locker.latestResumable(key)
+		if old == nil {
+			return 0, nil
+		}
+		id, ok := substanceIDFromLockKey(key)
+		if !ok {
+			return 0, fmt.Errorf("job %d: unknown lock key %q", old.ID, key)
+		}
+		var sub models.Substance
+		if err := f.DB.First(&sub, id).Error; err != nil {
+			return 0, fmt.Errorf("substance %d for job %d: %w", id, old.ID, err)
+		}
+		return f.RunForSubstance(ctx, sub, TriggerResume)
+	}
+
+	lock, err := f.Locker.TryAcquire(ctx, key, "", TriggerResume)
+	if err != nil {
+		return 0, err
+	}
+	var cp fetchCheckpoint
+	old := f.Locker.takeResumable(lock)
+	if old == nil || json.Unmarshal(old.Checkpoint, &cp) != nil {
+		lock.Release(0, nil)
+		return 0, nil
+	}
+	ids := cp.SubstanceIDs
+	if ids == nil && cp.Substances != nil {
+		ids = []uint{}
+		if err := f.DB.Model(&models.Substance{}).Where("name IN ?", cp.Substances).Pluck("id", &ids).Error; err != nil {
+			lock.Release(0, err)
+			return 0, err
+		}
+	}
+	count, err := f.runAllSubstances(ctx, lock, ids, TriggerResume)
+	lock.Release(count, err)
+	return count, err
+}
+
+// runAllSubstances iteriert nach Priorität über alle aktiven Substanzen (oder nur über ids, falls gesetzt).
+// Im Cron-Lauf werden Substanzen mit eigenem Zeitplan ausgelassen, sie laufen über ihren eigenen Eintrag.
+// Substanzen mit laufendem Job werden übersprungen. Der Aufrufer muss den Gesamt-Lock halten.
+func (f *FetchService) runAllSubstances(ctx context.Context, lock *JobLock, ids []uint, trigger string) (int, error) {
+	query := f.DB.Order("priority desc, name")
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Where("active = ?", true)
+		if trigger == TriggerCron {
+			query = query.Where("schedule = '' OR schedule IS NULL")
+		}
+	}
 	var substances []models.Substance
-	if err := f.DB.Find(&substances).Error; err != nil {
+	if err := query.Find(&substances).Error; err != nil {
 		f.Logger.Error("Failed to get substances from DB", zap.Error(err))
 		return 0, err
 	}
 
 	f.Logger.Info("Starting fetch for all substances", zap.Int("count", len(substances)))
 
-	var allFilters []models.SearchFilter
-	if err := f.DB.Find(&allFilters).Error; err != nil {
-		f.Logger.Error("Failed to get search filters from DB", zap.Error(err))
-		return 0, err
+	// Checkpoint: alle Substanzen ab Index i sind noch offen
+	checkpointFrom := func(i int) {
+		remaining := make([]uint, 0, len(substances)-i)
+		for _, sub := range substances[i:] {
+			remaining = append(remaining, sub.ID)
+		}
+		lock.Checkpoint(fetchCheckpoint{SubstanceIDs: remaining})
 	}
+	checkpointFrom(0)
 
 	totalNewPapers := 0
-	for _, sub := range substances {
-		count, err := f.RunForSubstance(ctx, sub, allFilters)
+	for i, sub := range substances {
+		if ctx.Err() != nil {
+			f.Logger.Info("Gesamtlauf unterbrochen", zap.Int("open_substances", len(substances)-i))
+			return totalNewPapers, ctx.Err()
+		}
+		count, err := f.RunForSubstance(ctx, sub, trigger)
+		totalNewPapers += count
+		var running *JobRunningError
+		if errors.As(err, &running) {
+			f.Logger.Info("Substanz wird bereits verarbeitet, überspringe", zap.String("substance", sub.Name))
+			checkpointFrom(i + 1)
+			continue
+		}
+		if ctx.Err() != nil {
+			// Substanz i ist noch nicht fertig und bleibt offen
+			f.Logger.Info("Gesamtlauf unterbrochen", zap.Int("open_substances", len(substances)-i))
+			return totalNewPapers, ctx.Err()
+		}
 		if err != nil {
 			f.Logger.Error("Failed to run fetch for substance", zap.String("substance", sub.Name), zap.Error(err))
 			// Wir brechen hier nicht ab, sondern machen mit der nächsten Substanz weiter
-			continue
 		}
-		totalNewPapers += count
+		checkpointFrom(i + 1)
 	}
 
 	f.Logger.Info("Completed fetch for all substances.", zap.Int("total_new_papers", totalNewPapers))
 	return totalNewPapers, nil
 }
 
-// RunForSubstance führt die Suche für eine Substanz mit allen gegebenen Filtern aus.
-func (f *FetchService) RunForSubstance(ctx context.Context, sub models.Substance, filters []models.SearchFilter) (int, error) {
-	log := f.Logger.With(zap.String("substance", sub.Name))
-	log.Info("Starte Fetch-Prozess für Substanz.")
-
-	allPapers := make(map[string]*models.Paper) // De-duplizierung
+// RunForSubstance führt die Suche für eine Substanz unter dem cluster-weiten Substanz-Lock aus.
+// Mit TriggerResume wird ein vorhandener Checkpoint übernommen und nur die offenen Paper verarbeitet.
+func (f *FetchService) RunForSubstance(ctx context.Context, sub models.Substance, trigger string) (int, error) {
+	filters, err := f.filtersFor(sub)
+	if err != nil {
+		return 0, err
+	}
+	lock, err := f.Locker.TryAcquire(ctx, SubstanceLockKey(sub.ID), sub.Name, trigger)
+	if err != nil {
+		return 0, err
+	}
+	var pending []*models.Paper
+	if trigger == TriggerResume {
+		var cp fetchCheckpoint
+		if old := f.Locker.takeResumable(lock); old != nil && json.Unmarshal(old.Checkpoint, &cp) == nil && cp.Phase == "process" {
+			pending = cp.Pending
+		}
+	}
+	count, err := f.runForSubstance(ctx, lock, sub, filters, pending)
+	lock.Release(count, err)
+	return count, err
+}
 
-	for _, filter := range filters {
-		finalTerm := fmt.Sprintf("(%s[Title/Abstract]) %s", sub.Name, filter.FilterQuery)
-		log.Info("Führe Suche für Filter aus", zap.String("filter_name", filter.Name))
+// runForSubstance führt die Suche für eine Substanz mit allen gegebenen Filtern aus.
+// Ist pending gesetzt, wird die Suche übersprungen und nur diese Paper werden verarbeitet.
+// Der Aufrufer muss den Substanz-Lock halten.
+func (f *FetchService) runForSubstance(ctx context.Context, lock *JobLock, sub models.Substance, filters []models.SearchFilter, pending []*models.Paper) (int, error) {
+	log := f.Logger.With(zap.String("substance", sub.Name))
 
-		for _, provider := range f.Providers {
-			papers, err := provider.Search(finalTerm)
-			if err != nil {
-				log.Error("Provider-Suche fehlgeschlagen", zap.String("provider", provider.Name()), zap.Error(err))
-				continue
-			}
-			log.Info("Provider hat Ergebnisse geliefert", zap.String("provider", provider.Name()), zap.Int("count", len(papers)))
+	uniquePapers := pending
+	if pending == nil {
+		log.Info("Starte Fetch-Prozess für Substanz.")
+		lock.Checkpoint(fetchCheckpoint{Phase: "search"})
 
-			// Ergebnisse de-duplizieren
-			for _, paper := range papers {
-				paper.StudyDesign = filter.Name // Wichtig: Study Design setzen!
-				key := paper.PMID
-				if key == "" && paper.DOI != "" { // Fallback auf DOI, falls keine PMID vorhanden
-					key = paper.DOI
-				}
-
-				if key != "" {
-					if _, exists := allPapers[key]; !exists {
-						allPapers[key] = paper
-					}
-				}
-			}
+		var err error
+		uniquePapers, err = f.searchSubstance(ctx, sub, filters)
+		if err != nil {
+			log.Info("Suche für Substanz unterbrochen", zap.Error(err))
+			return 0, err
 		}
+		log.Info("Suche bei allen Providern abgeschlossen", zap.Int("total_unique_papers", len(uniquePapers)))
+		if sub.MaxResults > 0 && len(uniquePapers) > sub.MaxResults {
+			// Neueste Studien zuerst behalten
+			sort.SliceStable(uniquePapers, func(i, j int) bool {
+				a, b := uniquePapers[i].StudyDate, uniquePapers[j].StudyDate
+				return a != nil && (b == nil || a.After(*b))
+			})
+			uniquePapers = uniquePapers[:sub.MaxResults]
+			log.Info("Ergebnisse auf max_results begrenzt", zap.Int("max_results", sub.MaxResults))
+		}
+	} else {
+		log.Info("Setze Verarbeitung für Substanz fort", zap.Int("pending_papers", len(pending)))
 	}
-
-	// Konvertiere Map zurück in eine Slice
-	var uniquePapers []*models.Paper
-	for _, paper := range allPapers {
-		uniquePapers = append(uniquePapers, paper)
-	}
-
-	log.Info("Suche bei allen Providern abgeschlossen", zap.Int("total_unique_papers", len(uniquePapers)))
+	lock.Checkpoint(fetchCheckpoint{Phase: "process", Pending: uniquePapers})
 
 	// 2. Details für jede ID parallel verarbeiten
 	var wg sync.WaitGroup
-	var newPapersCount int
+	var newPapersCount atomic.Int64
+	var mu sync.Mutex
+	done := make(map[*models.Paper]bool, len(uniquePapers))
 	semaphore := make(chan struct{}, 5) // Limit auf 5 parallele Verarbeitungen
 
+dispatch:
 	for _, paper := range uniquePapers {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
 		wg.Add(1)
-		semaphore <- struct{}{}
 
 		go func(paper *models.Paper) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// Details holen
-			// provider := pubmed.NewFetcher(f.Config, f.Logger) // This line is no longer needed
-			// paper, err := provider.FetchPaperDetails(pmid) // This line is no longer needed
-			// if err != nil { // This line is no longer needed
-			// 	log.Error("Konnte Paper-Details nicht abrufen", zap.String("pmid", pmid), zap.Error(err)) // This line is no longer needed
-			// 	return // This line is no longer needed
-			// } // This line is no longer needed
-			paper.Substance = sub.Name // Setze Substanz für die Verarbeitung
-
-			// ERST JETZT: Duplikatsprüfung mit vollen Paper-Daten
-			var existing models.Paper
-			query := f.DB.Where("pmid = ?", paper.PMID)
-			if paper.DOI != "" {
-				query = query.Or("doi = ?", paper.DOI)
-			}
-			if err := query.First(&existing).Error; err == nil && existing.CloudStored {
-				log.Debug("Paper bereits vorhanden (PMID oder DOI) und in S3 gespeichert, wird übersprungen.",
-					zap.String("pmid", paper.PMID), zap.String("doi", paper.DOI))
-				return
+			paper.Substance = sub.Name // Setze Substanz für die Verarbeitung (Primärwert)
+			paper.Substances = []models.Substance{{ID: sub.ID, Name: sub.Name}}
+
+			processed, isNew, _ := f.ingestPaper(ctx, log, paper)
+			if isNew {
+				newPapersCount.Add(1)
 			}
 
-			// Paper verarbeiten (Download & Upload)
-			if f.processPaper(ctx, paper) {
-				newPapersCount++
+			// Nur abgebrochene Verarbeitungen bleiben für den Checkpoint offen
+			if processed || ctx.Err() == nil {
+				mu.Lock()
+				done[paper] = true
+				mu.Unlock()
 			}
 		}(paper)
 	}
 
 	wg.Wait()
-	log.Info("Verarbeitung für Substanz abgeschlossen", zap.Int("new_papers_found", newPapersCount))
-	return newPapersCount, nil
+	count := int(newPapersCount.Load())
+
+	if ctx.Err() != nil {
+		var open []*models.Paper
+		for _, paper := range uniquePapers {
+			if !done[paper] {
+				open = append(open, paper)
+			}
+		}
+		lock.Checkpoint(fetchCheckpoint{Phase: "process", Pending: open})
+		log.Info("Verarbeitung für Substanz unterbrochen", zap.Int("new_papers_found", count), zap.Int("pending_papers", len(open)))
+		return count, ctx.Err()
+	}
+
+	log.Info("Verarbeitung für Substanz abgeschlossen", zap.Int("new_papers_found", count))
+	return count, nil
+}
+
+// ingestPaper gleicht ein zusammengeführtes Paper mit dem Bestand ab, verarbeitet es bei Bedarf
+// (Download & Upload) und pflegt Klassifikation und Aliase. Liefert, ob das Paper abgeschlossen ist,
+// ob es neu verarbeitet wurde und seine ID (0, wenn es nicht gespeichert wurde).
+func (f *FetchService) ingestPaper(ctx context.Context, log *zap.Logger, paper *models.Paper) (bool, bool, uint) {
+	// ERST JETZT: Duplikatsprüfung mit vollen Paper-Daten (über alle bekannten Identifier)
+	existing, err := FindPaper(f.DB, PaperIDs(paper)...)
+	found := err == nil
+	if found && !existing.CloudStored {
+		// Vorhandenes Paper erneut verarbeiten statt ein zweites anzulegen
+		adoptExisting(paper, existing)
+	}
+	processed, isNew := false, false
+	if found && existing.CloudStored {
+		log.Debug("Paper bereits vorhanden (PMID oder DOI) und in S3 gespeichert, wird übersprungen.",
+			zap.String("pmid", paper.PMID), zap.String("doi", paper.DOI))
+		processed = true
+	} else if f.processPaper(ctx, paper) {
+		// Paper verarbeiten (Download & Upload)
+		processed, isNew = true, true
+	}
+	if !processed {
+		return false, false, 0
+	}
+
+	// Klassifikation auch für bereits vorhandene Paper ergänzen (weitere Substanz/weiteres Studiendesign)
+	paperID := paper.ID
+	if paperID == 0 && found {
+		paperID = existing.ID
+	}
+	if err := LinkPaperClassification(f.DB, paperID, paper.Substances, paper.MatchedFilters); err != nil {
+		log.Warn("Klassifikation konnte nicht gespeichert werden", zap.String("pmid", paper.PMID), zap.Error(err))
+	}
+	// Aliase registrieren; bei übersprungenen Papern ergänzt das z.B. eine neu gelieferte PMCID
+	aliases := &models.Paper{ID: paperID, PMID: paper.PMID, DOI: paper.DOI, PMCID: paper.PMCID}
+	if duplicates, err := RegisterPaperIdentifiers(f.DB, aliases); err != nil {
+		log.Warn("Identifier konnten nicht registriert werden", zap.String("pmid", paper.PMID), zap.Error(err))
+	} else if len(duplicates) > 0 {
+		log.Warn("Mögliches Duplikat gefunden, Merge über /papers/merge", zap.Uint("paper_id", paperID), zap.Uints("duplicate_ids", duplicates))
+	}
+	if isNew && !found && paperID != 0 {
+		f.Webhooks.Emit(models.EventPaperIngested, paper)
+	}
+	return true, isNew, paperID
+}
+
+// CompileSearchTerm baut den Provider-Suchbegriff aus Substanz und Filter-Query.
+func CompileSearchTerm(substance, filterQuery string) string {
+	return fmt.Sprintf("(%s[Title/Abstract]) %s", substance, filterQuery)
+}
+
+// filtersFor liefert das Filter-Set einer Substanz; ohne eigene Zuordnung gelten alle Filter.
+func (f *FetchService) filtersFor(sub models.Substance) ([]models.SearchFilter, error) {
+	var filters []models.SearchFilter
+	if err := f.DB.Model(&sub).Association("Filters").Find(&filters); err != nil {
+		return nil, err
+	}
+	if len(filters) > 0 {
+		return filters, nil
+	}
+	if err := f.DB.Find(&filters).Error; err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// searchSubstance fragt alle Provider mit allen Filtern ab und führt die Treffer feldweise zusammen.
+func (f *FetchService) searchSubstance(ctx context.Context, sub models.Substance, filters []models.SearchFilter) ([]*models.Paper, error) {
+	log := f.Logger.With(zap.String("substance", sub.Name))
+	var records []providerRecord
+
+	for _, filter := range filters {
+		finalTerm := CompileSearchTerm(sub.Name, filter.FilterQuery)
+		log.Info("Führe Suche für Filter aus", zap.String("filter_name", filter.Name))
+
+		for _, provider := range f.Providers {
+			papers, err := provider.Search(ctx, finalTerm)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				log.Error("Provider-Suche fehlgeschlagen", zap.String("provider", provider.Name()), zap.Error(err))
+				continue
+			}
+			log.Info("Provider hat Ergebnisse geliefert", zap.String("provider", provider.Name()), zap.Int("count", len(papers)))
+			for _, paper := range papers {
+				records = append(records, providerRecord{Provider: provider.Name(), Filter: filter, Paper: paper})
+			}
+		}
+	}
+
+	// De-Duplizierung über PMID/DOI/PMCID, Felder nach Provider-Präzedenz
+	return mergeProviderRecords(records, f.Precedence), nil
+}
+
+// adoptExisting übernimmt ID und bereits gepflegte Felder eines vorhandenen Papers, damit ein
+// erneuter Verarbeitungsversuch den Datensatz aktualisiert und den n8n-Status nicht zurücksetzt.
+func adoptExisting(paper, existing *models.Paper) {
+	paper.ID = existing.ID
+	paper.CreatedAt = existing.CreatedAt
+	paper.TransferN8N = existing.TransferN8N
+	paper.LinksFetchedAt = existing.LinksFetchedAt
+	// Herkunft bleibt die der ersten Entdeckung
+	paper.Provenance, paper.Snowball = existing.Provenance, existing.Snowball
+	if existing.Substance != "" {
+		paper.Substance = existing.Substance
+	}
+	if existing.StudyDesign != "" {
+		paper.StudyDesign = existing.StudyDesign
+	}
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&paper.PMID, existing.PMID},
+		{&paper.DOI, existing.DOI},
+		{&paper.PMCID, existing.PMCID},
+		{&paper.Abstract, existing.Abstract},
+		{&paper.MeshTerms, existing.MeshTerms},
+		{&paper.Authors, existing.Authors},
+	} {
+		if *field.dst == "" {
+			*field.dst = field.src
+		}
+	}
+	// Frühere Download-Kandidaten nach den aktuellen Provider-Links erneut versuchen
+	for _, link := range downloadCandidates(existing) {
+		if !containsString(paper.DownloadLinks, link) {
+			paper.DownloadLinks = append(paper.DownloadLinks, link)
+		}
+	}
+}
+
+func hasFilter(filters []models.SearchFilter, id uint) bool {
+	for _, f := range filters {
+		if f.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// savePaper speichert nur das Paper selbst; Zuordnungen werden über LinkPaperClassification gepflegt.
+func (f *FetchService) savePaper(paper *models.Paper) {
+	if err := f.DB.Omit(clause.Associations).Save(paper).Error; err != nil {
+		f.Logger.Warn("Paper konnte nicht gespeichert werden", zap.String("pmid", paper.PMID), zap.String("doi", paper.DOI), zap.Error(err))
+	}
 }
 
 // processPaper verarbeitet ein einzelnes Paper-Objekt.
+// Wird ctx während Download oder Upload abgebrochen, wird nichts gespeichert und false zurückgegeben.
 func (f *FetchService) processPaper(ctx context.Context, paper *models.Paper) bool {
 	log := f.Logger.With(zap.String("pmid", paper.PMID), zap.String("doi", paper.DOI))
 
-	// Zentraler Unpaywall-Fallback, falls kein Download-Link vom Provider kam
-	if paper.DownloadLink == "" && paper.DOI != "" {
-		log.Info("Kein direkter Link vom Provider, versuche Unpaywall-Fallback.", zap.String("doi", paper.DOI))
-		link, err := f.UnpaywallFetcher.GetPDFLink(paper.DOI)
+	// Download-Kandidaten der Provider der Reihe nach versuchen, Unpaywall als letzter Fallback
+	candidates := downloadCandidates(paper)
+	triedUnpaywall := false
+	var data []byte
+	foundPDF := false
+	for i := 0; ; i++ {
+		if i == len(candidates) && !triedUnpaywall && paper.DOI != "" {
+			triedUnpaywall = true
+			log.Info("Kein (funktionierender) Link vom Provider, versuche Unpaywall-Fallback.", zap.String("doi", paper.DOI))
+			link, err := f.UnpaywallFetcher.GetPDFLink(ctx, paper.DOI)
+			if ctx.Err() != nil {
+				return false
+			}
+			if err != nil {
+				log.Warn("Unpaywall-Fallback fehlgeschlagen", zap.Error(err))
+			} else if link != "" && !containsString(candidates, link) {
+				log.Info("Erfolgreich Download-Link über Unpaywall-Fallback gefunden.", zap.String("link", link))
+				candidates = append(candidates, link)
+			}
+		}
+		if i >= len(candidates) {
+			break
+		}
+
+		link := candidates[i]
+		log.Info("Starte Download", zap.String("url", link), zap.Int("candidate", i+1), zap.Int("candidates", len(candidates)))
+		var ok bool
+		var err error
+		data, ok, err = f.downloadResource(ctx, link)
+		if ctx.Err() != nil {
+			log.Info("Download abgebrochen, Paper bleibt offen.")
+			return false
+		}
 		if err != nil {
-			log.Warn("Unpaywall-Fallback fehlgeschlagen", zap.Error(err))
-		} else if link != "" {
-			log.Info("Erfolgreich Download-Link über Unpaywall-Fallback gefunden.", zap.String("link", link))
-			paper.DownloadLink = link
+			log.Warn("Download fehlgeschlagen", zap.Error(err), zap.String("url", link))
+			continue
+		}
+		if !ok {
+			log.Warn("Ressource heruntergeladen, aber keine PDF-Datei darin gefunden.", zap.String("url", link))
+			continue
 		}
+		paper.DownloadLink = link
+		foundPDF = true
+		break
+	}
+	paper.DownloadLinks = candidates
+	if len(candidates) > 0 && paper.DownloadLink == "" {
+		paper.DownloadLink = candidates[0]
 	}
 
-	// Download-Logik
-	if paper.DownloadLink == "" {
+	if len(candidates) == 0 {
 		log.Warn("Kein Download-Link vorhanden, Verarbeitung hier beendet.")
 		paper.NoPDFFound = true
-		f.DB.Save(paper)
+		f.savePaper(paper)
 		return true // Zählt als "neu" verarbeitet, da wir es versucht haben
 	}
-
-	log.Info("Starte Download", zap.String("url", paper.DownloadLink))
-	data, foundPDF, err := f.downloadResource(paper.DownloadLink)
-	if err != nil {
-		log.Warn("Download fehlgeschlagen", zap.Error(err), zap.String("url", paper.DownloadLink))
-		paper.NoPDFFound = true
-		f.DB.Save(paper)
-		return true
-	}
 	if !foundPDF {
-		log.Warn("Ressource heruntergeladen, aber keine PDF-Datei darin gefunden.", zap.String("url", paper.DownloadLink))
+		log.Warn("Kein Download-Kandidat lieferte eine PDF-Datei.", zap.Int("candidates", len(candidates)))
 		paper.NoPDFFound = true
-		f.DB.Save(paper)
+		f.savePaper(paper)
 		return true
 	}
 
 	// S3 Upload
-	key := paper.PMID + ".pdf"
+	key := storageKey(paper)
 	log.Info("Lade PDF nach S3 hoch", zap.String("key", key))
-	s3link, err := storage.UploadFile(f.S3Client, f.Config.StratoS3Bucket, key, data, f.Config)
+	s3link, err := storage.UploadFile(ctx, f.S3Client, f.Config.StratoS3Bucket, key, data, f.Config)
+	if ctx.Err() != nil {
+		log.Info("S3-Upload abgebrochen, Paper bleibt offen.")
+		return false
+	}
 	if err != nil {
 		log.Error("S3-Upload fehlgeschlagen", zap.Error(err))
 		// Wir speichern trotzdem den Rest
@@ -243,15 +651,22 @@ func (f *FetchService) processPaper(ctx context.Context, paper *models.Paper) bo
 	}
 	paper.NoPDFFound = false
 	paper.DownloadDate = timePtr(time.Now())
-	f.DB.Save(paper)
+	f.savePaper(paper)
+	if paper.CloudStored && paper.ID != 0 {
+		f.Webhooks.Emit(models.EventPaperPDFStored, paper)
+	}
 
 	log.Info("Paper erfolgreich verarbeitet.")
 	return true
 }
 
 // downloadResource lädt eine Ressource herunter.
-func (f *FetchService) downloadResource(link string) ([]byte, bool, error) {
-	resp, err := httpClient.Get(link)
+func (f *FetchService) downloadResource(ctx context.Context, link string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, false, err
 	}
@@ -299,6 +714,28 @@ func (f *FetchService) downloadResource(link string) ([]byte, bool, error) {
 	return nil, false, nil // Kein Fehler, aber auch keine PDF gefunden
 }
 
+// downloadCandidates liefert alle bekannten Download-Links in Versuchsreihenfolge.
+func downloadCandidates(paper *models.Paper) []string {
+	var candidates []string
+	for _, link := range append(append([]string{}, paper.DownloadLinks...), paper.DownloadLink) {
+		if link != "" && !containsString(candidates, link) {
+			candidates = append(candidates, link)
+		}
+	}
+	return candidates
+}
+
+// storageKey liefert den S3-Schlüssel eines Papers: PMID, sonst die DOI mit ersetzten Sonderzeichen.
+func storageKey(paper *models.Paper) string {
+	if paper.PMID != "" {
+		return paper.PMID + ".pdf"
+	}
+	if paper.PMCID != "" {
+		return paper.PMCID + ".pdf"
+	}
+	return "doi/" + strings.NewReplacer("/", "_", ":", "_", " ", "_").Replace(strings.ToLower(paper.DOI)) + ".pdf"
+}
+
 // timePtr gibt einen Pointer auf eine time.Time zurück.
 func timePtr(t time.Time) *time.Time {
 	return &t
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"paper-hand/models"
+)
+
+// Sprachen der Volltextsuche; "both" sucht in der deutschen und englischen Konfiguration.
+const (
+	SearchLanguageEnglish = "en"
+	SearchLanguageGerman  = "de"
+	SearchLanguageBoth    = "both"
+)
+
+// headlineOptions steuern die Snippets (ts_headline); Treffer werden mit <mark> hervorgehoben.
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+
+// ftsConfig verbindet eine Postgres-Textsuchkonfiguration mit ihrer tsvector-Spalte.
+type ftsConfig struct {
+	Name   string // regconfig, z.B. english
+	Column string // generierte tsvector-Spalte
+}
+
+var searchConfigs = map[string][]ftsConfig{
+	SearchLanguageEnglish: {{Name: "english", Column: "search_en"}},
+	SearchLanguageGerman:  {{Name: "german", Column: "search_de"}},
+	SearchLanguageBoth:    {{Name: "english", Column: "search_en"}, {Name: "german", Column: "search_de"}},
+}
+
+// ftsTarget beschreibt eine durchsuchbare Tabelle: gewichtete Quellspalten (A = höchstes Gewicht)
+// und die Spalten, aus denen Snippets erzeugt werden.
+type ftsTarget struct {
+	Table    string
+	Weights  [][2]string // {Spalte, Gewicht}
+	Snippets []string
+}
+
+var (
+	paperSearchTarget = ftsTarget{
+		Table:    "papers",
+		Weights:  [][2]string{{"title", "A"}, {"mesh_terms", "B"}, {"abstract", "C"}},
+		Snippets: []string{"title", "abstract"},
+	}
+	ratedPaperSearchTarget = ftsTarget{
+		Table: "rated_papers",
+		Weights: [][2]string{
+			{"ai_summary", "A"}, {"key_findings", "B"}, {"category", "B"},
+			{"study_strengths", "C"}, {"study_limitations", "C"},
+		},
+		Snippets: []string{"ai_summary", "key_findings"},
+	}
+)
+
+// FullTextQuery ist die Suchanfrage; Query folgt der websearch-Syntax ("phrase", OR, -ausschluss).
+type FullTextQuery struct {
+	Query    string `json:"query"`
+	Language string `json:"language"` // en, de oder both (Standard)
+}
+
+// SearchHit enthält Relevanz und hervorgehobene Snippets eines Treffers.
+type SearchHit struct {
+	Rank     float64           `json:"rank"`
+	Snippets map[string]string `json:"snippets,omitempty"`
+}
+
+// PaperHit ist ein Treffer der Paper-Suche.
+type PaperHit struct {
+	models.Paper
+	SearchHit
+}
+
+// RatedPaperHit ist ein Treffer der Suche in den KI-Analysen.
+type RatedPaperHit struct {
+	models.RatedPaper
+	SearchHit
+}
+
+// searchRow ist eine Zeile der Ranking-Abfrage; die Datensätze werden danach per ID geladen.
+type searchRow struct {
+	ID       uint
+	Rank     float64
+	Snippet0 string `gorm:"column:snippet_0"`
+	Snippet1 string `gorm:"column:snippet_1"`
+}
+
+// MigrateFullTextSearch legt die generierten tsvector-Spalten (deutsch/englisch) samt GIN-Indizes an.
+// Muss nach AutoMigrate laufen; rawDB enthält papers, ratedDB enthält rated_papers.
+func MigrateFullTextSearch(rawDB, ratedDB *gorm.DB) error {
+	if err := migrateSearchTarget(rawDB, paperSearchTarget); err != nil {
+		return err
+	}
+	return migrateSearchTarget(ratedDB, ratedPaperSearchTarget)
+}
+
+func migrateSearchTarget(db *gorm.DB, target ftsTarget) error {
+	for _, cfg := range searchConfigs[SearchLanguageBoth] {
+		var parts []string
+		for _, w := range target.Weights {
+			parts = append(parts, fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s, '')), '%s')", cfg.Name, w[0], w[1]))
+		}
+		stmts := []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s tsvector GENERATED ALWAYS AS (%s) STORED",
+				target.Table, cfg.Column, strings.Join(parts, " || ")),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s USING GIN (%s)",
+				target.Table, cfg.Column, target.Table, cfg.Column),
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("full-text migration for %s: %w", target.Table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SearchPapers durchsucht Titel, MeSH-Terme und Abstract der Paper. query enthält bereits die
+// strukturierten Filter (db.Model(&models.Paper{})...); sortiert wird nach Relevanz.
+func SearchPapers(query *gorm.DB, q FullTextQuery, spec ListSpec, params ListParams) ([]PaperHit, *ListPage, error) {
+	rows, page, err := fullTextSearch(query, paperSearchTarget, q, spec, params)
+	if err != nil || len(rows) == 0 {
+		return []PaperHit{}, page, err
+	}
+	load := query.Session(&gorm.Session{NewDB: true})
+	for _, name := range spec.Preloads {
+		load = load.Preload(name)
+	}
+	var papers []models.Paper
+	if err := load.Where("id IN ?", searchRowIDs(rows)).Find(&papers).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]models.Paper, len(papers))
+	for _, p := range papers {
+		byID[p.ID] = p
+	}
+	hits := make([]PaperHit, 0, len(rows))
+	for _, row := range rows {
+		if p, ok := byID[row.ID]; ok {
+			hits = append(hits, PaperHit{Paper: p, SearchHit: row.hit(paperSearchTarget)})
+		}
+	}
+	return hits, page, nil
+}
+
+// SearchRatedPapers durchsucht ai_summary, key_findings, Kategorie, Stärken und Limitationen der Analysen.
+func SearchRatedPapers(query *gorm.DB, q FullTextQuery, spec ListSpec, params ListParams) ([]RatedPaperHit, *ListPage, error) {
+	rows, page, err := fullTextSearch(query, ratedPaperSearchTarget, q, spec, params)
+	if err != nil || len(rows) == 0 {
+		return []RatedPaperHit{}, page, err
+	}
+	var rated []models.RatedPaper
+	if err := query.Session(&gorm.Session{NewDB: true}).Where("id IN ?", searchRowIDs(rows)).Find(&rated).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]models.RatedPaper, len(rated))
+	for _, r := range rated {
+		byID[r.ID] = r
+	}
+	hits := make([]RatedPaperHit, 0, len(rows))
+	for _, row := range rows {
+		if r, ok := byID[row.ID]; ok {
+			hits = append(hits, RatedPaperHit{RatedPaper: r, SearchHit: row.hit(ratedPaperSearchTarget)})
+		}
+	}
+	return hits, page, nil
+}
+
+// fullTextSearch ermittelt die Treffer-IDs einer Seite mit Rang und Snippets.
+// Paginiert wird per Keyset über (rank, id); die einzige Sortierung ist "-rank".
+func fullTextSearch(query *gorm.DB, target ftsTarget, q FullTextQuery, spec ListSpec, params ListParams) ([]searchRow, *ListPage, error) {
+	text := strings.TrimSpace(q.Query)
+	if text == "" {
+		return nil, nil, fmt.Errorf("%w: query is required", ErrInvalidListParams)
+	}
+	lang := strings.ToLower(strings.TrimSpace(q.Language))
+	if lang == "" {
+		lang = SearchLanguageBoth
+	}
+	configs, ok := searchConfigs[lang]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown language %q (allowed: en, de, both)", ErrInvalidListParams, q.Language)
+	}
+	if params.Sort != "" && params.Sort != "-rank" {
+		return nil, nil, fmt.Errorf("%w: search results are always sorted by -rank", ErrInvalidListParams)
+	}
+	if params.Limit < 0 {
+		return nil, nil, fmt.Errorf("%w: limit must not be negative", ErrInvalidListParams)
+	}
+	limit := min(params.Limit, MaxPageSize)
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	stmt := query.Session(&gorm.Session{}).Statement
+	if err := stmt.Parse(stmt.Model); err != nil {
+		return nil, nil, err
+	}
+	spec.ExtraFields = append([]string{"rank", "snippets"}, spec.ExtraFields...)
+	if err := validateFields(stmt, spec, params.Fields); err != nil {
+		return nil, nil, err
+	}
+
+	// Je Konfiguration: Treffer-Bedingung, Rang und Snippet-Ausdruck
+	var matches, ranks []string
+	var matchArgs, rankArgs []any
+	for _, cfg := range configs {
+		tsquery := fmt.Sprintf("websearch_to_tsquery('%s', ?)", cfg.Name)
+		matches = append(matches, fmt.Sprintf("%s.%s @@ %s", target.Table, cfg.Column, tsquery))
+		matchArgs = append(matchArgs, text)
+		ranks = append(ranks, fmt.Sprintf("ts_rank_cd(%s.%s, %s)", target.Table, cfg.Column, tsquery))
+		rankArgs = append(rankArgs, text)
+	}
+	match := gorm.Expr("("+strings.Join(matches, " OR ")+")", matchArgs...)
+	rank := gorm.Expr(ranks[0], rankArgs[0])
+	if len(ranks) > 1 {
+		rank = gorm.Expr("GREATEST("+strings.Join(ranks, ", ")+")", rankArgs...)
+	}
+	headline := func(column string) any {
+		expr := func(cfg ftsConfig) (string, []any) {
+			return fmt.Sprintf("ts_headline('%s', coalesce(%s.%s, ''), websearch_to_tsquery('%s', ?), ?)",
+				cfg.Name, target.Table, column, cfg.Name), []any{text, headlineOptions}
+		}
+		if len(configs) == 1 {
+			sql, args := expr(configs[0])
+			return gorm.Expr(sql, args...)
+		}
+		// Snippet aus der Sprache mit dem höheren Rang (configs[0] = englisch, configs[1] = deutsch)
+		enSQL, enArgs := expr(configs[0])
+		deSQL, deArgs := expr(configs[1])
+		args := append([]any{text, text}, deArgs...)
+		return gorm.Expr(fmt.Sprintf("CASE WHEN %s > %s THEN %s ELSE %s END", ranks[1], ranks[0], deSQL, enSQL), append(args, enArgs...)...)
+	}
+
+	page := &ListPage{Limit: limit, Sort: "-rank"}
+	filtered := query.Session(&gorm.Session{}).Where(match)
+	if err := filtered.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	idColumn := target.Table + ".id"
+	q2 := filtered.Session(&gorm.Session{}).Select(idColumn+", ? AS rank, ? AS snippet_0, ? AS snippet_1",
+		rank, headline(target.Snippets[0]), headline(target.Snippets[1]))
+	if params.Cursor != "" {
+		cur, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cur.Sort != page.Sort {
+			return nil, nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidListParams, cur.Sort)
+		}
+		q2 = q2.Where(fmt.Sprintf("(?, %s) < (?, ?)", idColumn), rank, cur.Value, cur.ID)
+	}
+	q2 = q2.Order("rank DESC, " + idColumn + " DESC")
+	q2 = q2.Limit(limit + 1) // ein Datensatz mehr zeigt an, ob es eine weitere Seite gibt
+	var rows []searchRow
+	if err := q2.Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		// ts_rank_cd liefert real; der Wert wird in float32-Genauigkeit zurückgegeben, damit Postgres ihn exakt parst
+		page.NextCursor = encodeCursor(pageCursor{Sort: page.Sort, Value: cursorValue(float32(last.Rank)), ID: strconv.FormatUint(uint64(last.ID), 10)})
+	}
+	return rows, page, nil
+}
+
+func (r searchRow) hit(target ftsTarget) SearchHit {
+	hit := SearchHit{Rank: r.Rank, Snippets: make(map[string]string)}
+	for i, snippet := range []string{r.Snippet0, r.Snippet1} {
+		if snippet != "" {
+			hit.Snippets[target.Snippets[i]] = snippet
+		}
+	}
+	return hit
+}
+
+func searchRowIDs(rows []searchRow) []uint {
+	ids := make([]uint, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	return ids
+}
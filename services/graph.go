@@ -0,0 +1,716 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// Grenzen der Graph-Abfragen.
+const (
+	MaxGraphDepth = 5
+	MaxGraphNodes = 5000
+
+	graphMaxAge         = 5 * time.Minute // papers/rated_papers-Annotationen werden spätestens dann erneuert
+	pageRankDamping     = 0.85
+	pageRankIterations  = 100
+	pageRankConvergence = 1e-9
+)
+
+// Richtungen der Traversierung: out = zitierte Paper (Referenzen), in = zitierende Paper.
+const (
+	DirectionOut  = "out"
+	DirectionIn   = "in"
+	DirectionBoth = "both"
+)
+
+var (
+	// ErrNodeNotFound wird geliefert, wenn ein Identifier nicht im Zitationsgraphen vorkommt.
+	ErrNodeNotFound = errors.New("paper not found in citation graph")
+	// ErrInvalidGraphQuery kennzeichnet ungültige Parameter (HTTP 400).
+	ErrInvalidGraphQuery = errors.New("invalid graph query")
+)
+
+// GraphNode ist ein Paper im Zitationsgraphen. Identifier verschiedener Kanten (DOI/PMID) desselben
+// Papers werden zu einem Knoten zusammengefasst.
+type GraphNode struct {
+	Key   string `json:"key"` // doi:… oder pmid:…
+	DOI   string `json:"doi,omitempty"`
+	PMID  string `json:"pmid,omitempty"`
+	Title string `json:"title,omitempty"` // aus papers, sonst aus der Evidence der Kante
+	Year  int    `json:"year,omitempty"`
+
+	StudyDesign string `json:"study_design,omitempty"` // aus papers
+
+	InPapers      bool     `json:"in_papers"`
+	PaperID       *uint    `json:"paper_id,omitempty"`
+	InRatedPapers bool     `json:"in_rated_papers"`
+	RatedPaperID  *uint    `json:"rated_paper_id,omitempty"`
+	Rating        *float64 `json:"rating,omitempty"`
+
+	CitedBy    int     `json:"cited_by"`   // Zitierungen innerhalb des Korpus (Eingangsgrad)
+	References int     `json:"references"` // Referenzen innerhalb des Korpus (Ausgangsgrad)
+	PageRank   float64 `json:"pagerank"`
+}
+
+// GraphEdge ist eine gerichtete Kante: Source zitiert Target.
+type GraphEdge struct {
+	Source           string  `json:"source"`
+	Target           string  `json:"target"`
+	Intent           string  `json:"intent,omitempty"` // Zitierabsicht, leer ohne Zitierkontext
+	IntentConfidence float64 `json:"intent_confidence,omitempty"`
+}
+
+// edgeIntent ist die klassifizierte Absicht einer Kante im Graphen.
+type edgeIntent struct {
+	Intent     string
+	Confidence float64
+}
+
+// CitationGraph ist der im Speicher aufgebaute Graph aus paper_links.
+type CitationGraph struct {
+	Nodes  []GraphNode
+	out    [][]int
+	in     [][]int
+	index  map[string]int        // doi:…/pmid:… → Knoten
+	paper  map[uint]int          // papers.id → Knoten
+	intent map[[2]int]edgeIntent // (Source, Target) → Zitierabsicht
+}
+
+// GraphService baut den Zitationsgraphen bei Bedarf neu auf und hält ihn zwischen Anfragen vor.
+type GraphService struct {
+	RawDB   *gorm.DB
+	RatedDB *gorm.DB
+
+	mu      sync.Mutex
+	graph   *CitationGraph
+	version string
+	builtAt time.Time
+}
+
+// NewGraphService erstellt einen neuen GraphService.
+func NewGraphService(rawDB, ratedDB *gorm.DB) *GraphService {
+	return &GraphService{RawDB: rawDB, RatedDB: ratedDB}
+}
+
+// Graph liefert den aktuellen Graphen; er wird neu aufgebaut, wenn sich paper_links geändert haben
+// oder der Stand älter als graphMaxAge ist.
+func (s *GraphService) Graph() (*CitationGraph, error) {
+	var state struct {
+		Count   int64
+		Updated *time.Time
+	}
+	if err := s.RawDB.Model(&models.PaperLink{}).Select("COUNT(*) AS count, MAX(updated_at) AS updated").Scan(&state).Error; err != nil {
+		return nil, err
+	}
+	version := fmt.Sprint(state.Count)
+	if state.Updated != nil {
+		version += "|" + state.Updated.UTC().Format(time.RFC3339Nano)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.graph != nil && s.version == version && time.Since(s.builtAt) < graphMaxAge {
+		return s.graph, nil
+	}
+	g, err := buildCitationGraph(s.RawDB, s.RatedDB)
+	if err != nil {
+		return nil, err
+	}
+	s.graph, s.version, s.builtAt = g, version, time.Now()
+	return g, nil
+}
+
+// buildCitationGraph lädt alle Kanten, fasst Identifier desselben Papers zusammen und annotiert die Knoten.
+func buildCitationGraph(rawDB, ratedDB *gorm.DB) (*CitationGraph, error) {
+	var links []models.PaperLink
+	if err := rawDB.Select("source_doi_norm", "source_pmid_norm", "target_doi_norm", "target_pmid_norm", "evidence", "intent", "intent_confidence").
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	// Union-Find über Identifier-Schlüssel
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(k string) string {
+		p, ok := parent[k]
+		if !ok {
+			parent[k] = k
+			return k
+		}
+		if p != k {
+			parent[k] = find(p)
+		}
+		return parent[k]
+	}
+	union := func(a, b string) {
+		if a == "" || b == "" {
+			return
+		}
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+	endpoint := func(doi, pmid string) string {
+		d, p := nodeKey(identifiers.DOI, doi), nodeKey(identifiers.PMID, pmid)
+		union(d, p)
+		if d != "" {
+			find(d)
+			return d
+		}
+		if p != "" {
+			find(p)
+		}
+		return p
+	}
+	type rawEdge struct {
+		source, target string
+		intent         edgeIntent
+	}
+	edges := make([]rawEdge, 0, len(links))
+	type edgeMeta struct {
+		Title string `json:"title"`
+		Year  int    `json:"year"`
+	}
+	meta := make(map[string]edgeMeta)
+	for _, l := range links {
+		src := endpoint(l.SourceDOINorm, l.SourcePMIDNorm)
+		tgt := endpoint(l.TargetDOINorm, l.TargetPMIDNorm)
+		if src == "" || tgt == "" {
+			continue
+		}
+		edges = append(edges, rawEdge{src, tgt, edgeIntent{l.Intent, l.IntentConfidence}})
+		var ev edgeMeta
+		if len(l.Evidence) > 0 && json.Unmarshal(l.Evidence, &ev) == nil && (ev.Title != "" || ev.Year > 0) {
+			meta[tgt] = ev
+		}
+	}
+
+	// Identifier desselben Papers (inkl. Aliase) verbinden
+	var papers []models.Paper
+	if err := rawDB.Select("id", "pmid", "doi", "title", "study_date", "study_design").Find(&papers).Error; err != nil {
+		return nil, err
+	}
+	var aliases []models.PaperIdentifier
+	if err := rawDB.Where("kind IN ?", []string{string(identifiers.DOI), string(identifiers.PMID)}).Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	paperKeys := make(map[uint][]string)
+	for _, p := range papers {
+		for _, k := range []string{nodeKey(identifiers.DOI, p.DOI), nodeKey(identifiers.PMID, p.PMID)} {
+			if k != "" {
+				paperKeys[p.ID] = append(paperKeys[p.ID], k)
+			}
+		}
+	}
+	for _, a := range aliases {
+		paperKeys[a.PaperID] = append(paperKeys[a.PaperID], nodeKey(identifiers.Kind(a.Kind), a.Value))
+	}
+	for _, keys := range paperKeys {
+		inGraph := false
+		for _, k := range keys {
+			if _, ok := parent[k]; ok {
+				inGraph = true
+			}
+		}
+		if inGraph {
+			for _, k := range keys[1:] {
+				union(keys[0], k)
+			}
+		}
+	}
+
+	// Knoten je Wurzel anlegen; DOI/PMID aus allen Schlüsseln der Gruppe
+	g := &CitationGraph{index: make(map[string]int), paper: make(map[uint]int), intent: make(map[[2]int]edgeIntent)}
+	rootNode := make(map[string]int)
+	keys := make([]string, 0, len(parent))
+	for k := range parent {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		root := find(k)
+		idx, ok := rootNode[root]
+		if !ok {
+			idx = len(g.Nodes)
+			rootNode[root] = idx
+			g.Nodes = append(g.Nodes, GraphNode{})
+		}
+		n := &g.Nodes[idx]
+		kind, value := splitNodeKey(k)
+		switch {
+		case kind == identifiers.DOI && n.DOI == "":
+			n.DOI = value
+		case kind == identifiers.PMID && n.PMID == "":
+			n.PMID = value
+		}
+		g.index[k] = idx
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		if n.DOI != "" {
+			n.Key = nodeKey(identifiers.DOI, n.DOI)
+		} else {
+			n.Key = nodeKey(identifiers.PMID, n.PMID)
+		}
+	}
+	for k, ev := range meta {
+		g.Nodes[g.index[k]].Title, g.Nodes[g.index[k]].Year = ev.Title, ev.Year
+	}
+
+	// Kanten (ohne Mehrfachkanten und Selbstzitate); bei Mehrfachkanten gilt die sicherste Absicht
+	g.out = make([][]int, len(g.Nodes))
+	g.in = make([][]int, len(g.Nodes))
+	seen := make(map[[2]int]bool)
+	for _, e := range edges {
+		s, t := g.index[e.source], g.index[e.target]
+		if s == t {
+			continue
+		}
+		if e.intent.Intent != "" {
+			if cur, ok := g.intent[[2]int{s, t}]; !ok || e.intent.Confidence > cur.Confidence {
+				g.intent[[2]int{s, t}] = e.intent
+			}
+		}
+		if seen[[2]int{s, t}] {
+			continue
+		}
+		seen[[2]int{s, t}] = true
+		g.out[s] = append(g.out[s], t)
+		g.in[t] = append(g.in[t], s)
+	}
+	for i := range g.Nodes {
+		g.Nodes[i].CitedBy = len(g.in[i])
+		g.Nodes[i].References = len(g.out[i])
+	}
+
+	// Annotation: papers (rawDB) und rated_papers (ratedDB, über DOI)
+	for _, p := range papers {
+		for _, k := range paperKeys[p.ID] {
+			if idx, ok := g.index[k]; ok {
+				id := p.ID
+				n := &g.Nodes[idx]
+				n.InPapers, n.PaperID, n.StudyDesign = true, &id, p.StudyDesign
+				if p.Title != "" {
+					n.Title = p.Title
+				}
+				if p.StudyDate != nil {
+					n.Year = p.StudyDate.Year()
+				}
+				g.paper[p.ID] = idx
+				break
+			}
+		}
+	}
+	var rated []models.RatedPaper
+	if err := ratedDB.Select("id", "doi", "rating").Find(&rated).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rated {
+		if idx, ok := g.index[nodeKey(identifiers.DOI, r.DOI)]; ok {
+			id, rating := r.ID, r.Rating
+			g.Nodes[idx].InRatedPapers, g.Nodes[idx].RatedPaperID, g.Nodes[idx].Rating = true, &id, &rating
+		}
+	}
+
+	g.computePageRank()
+	return g, nil
+}
+
+// computePageRank berechnet den PageRank über alle Kanten (Dangling-Knoten verteilen gleichmäßig).
+func (g *CitationGraph) computePageRank() {
+	n := len(g.Nodes)
+	if n == 0 {
+		return
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < pageRankIterations; iter++ {
+		dangling := 0.0
+		for i := range rank {
+			if len(g.out[i]) == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, targets := range g.out {
+			if len(targets) == 0 {
+				continue
+			}
+			share := pageRankDamping * rank[i] / float64(len(targets))
+			for _, t := range targets {
+				next[t] += share
+			}
+		}
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankConvergence {
+			break
+		}
+	}
+	for i := range g.Nodes {
+		g.Nodes[i].PageRank = rank[i]
+	}
+}
+
+// Lookup findet den Knoten zu DOI und/oder PMID.
+func (g *CitationGraph) Lookup(doi, pmid string) (int, error) {
+	for _, k := range []string{nodeKey(identifiers.DOI, doi), nodeKey(identifiers.PMID, pmid)} {
+		if idx, ok := g.index[k]; ok && k != "" {
+			return idx, nil
+		}
+	}
+	if identifiers.NormalizeDOI(doi) == "" && identifiers.NormalizePMID(pmid) == "" {
+		return 0, fmt.Errorf("%w: doi or pmid required", ErrInvalidGraphQuery)
+	}
+	return 0, ErrNodeNotFound
+}
+
+// NeighborNode ist ein Knoten mit seinem Abstand zum nächsten Startknoten.
+type NeighborNode struct {
+	GraphNode
+	Depth int `json:"depth"`
+}
+
+// Subgraph ist das Ergebnis einer Traversierung.
+type Subgraph struct {
+	Root      string         `json:"root,omitempty"`
+	Nodes     []NeighborNode `json:"nodes"`
+	Edges     []GraphEdge    `json:"edges"`
+	Truncated bool           `json:"truncated"` // maxNodes erreicht
+}
+
+// Neighbors liefert alle Knoten bis zur Tiefe depth in der angegebenen Richtung (Breitensuche)
+// sowie alle Kanten zwischen diesen Knoten. Mit intents werden nur Kanten dieser Zitierabsichten
+// verfolgt und geliefert.
+func (g *CitationGraph) Neighbors(start, depth int, direction string, intents []string, maxNodes int) (*Subgraph, error) {
+	if depth < 1 || depth > MaxGraphDepth {
+		return nil, fmt.Errorf("%w: depth must be between 1 and %d", ErrInvalidGraphQuery, MaxGraphDepth)
+	}
+	if maxNodes <= 0 || maxNodes > MaxGraphNodes {
+		maxNodes = MaxGraphNodes
+	}
+	result, err := g.Expand([]int{start}, depth, direction, intents, maxNodes)
+	if err != nil {
+		return nil, err
+	}
+	result.Root = g.Nodes[start].Key
+	return result, nil
+}
+
+// Expand erweitert die Startknoten per Breitensuche bis zur Tiefe depth (0 = nur die Startknoten)
+// und liefert alle Kanten zwischen den erreichten Knoten. maxNodes <= 0 bedeutet unbegrenzt;
+// leere intents bedeuten alle Kanten.
+func (g *CitationGraph) Expand(seeds []int, depth int, direction string, intents []string, maxNodes int) (*Subgraph, error) {
+	if err := validateDirection(direction); err != nil {
+		return nil, err
+	}
+	allowed, err := intentFilter(intents)
+	if err != nil {
+		return nil, err
+	}
+	if depth < 0 || depth > MaxGraphDepth {
+		return nil, fmt.Errorf("%w: depth must be between 0 and %d", ErrInvalidGraphQuery, MaxGraphDepth)
+	}
+
+	result := &Subgraph{Nodes: []NeighborNode{}, Edges: []GraphEdge{}}
+	dist := make(map[int]int, len(seeds))
+	var order []int
+	for _, idx := range seeds {
+		if _, ok := dist[idx]; ok {
+			continue
+		}
+		if maxNodes > 0 && len(order) >= maxNodes {
+			result.Truncated = true
+			break
+		}
+		dist[idx] = 0
+		order = append(order, idx)
+	}
+	for head := 0; head < len(order) && !result.Truncated; head++ {
+		cur := order[head]
+		if dist[cur] == depth {
+			continue
+		}
+		for _, next := range g.adjacentByIntent(cur, direction, allowed) {
+			if _, ok := dist[next]; ok {
+				continue
+			}
+			if maxNodes > 0 && len(order) >= maxNodes {
+				result.Truncated = true
+				break
+			}
+			dist[next] = dist[cur] + 1
+			order = append(order, next)
+		}
+	}
+
+	for _, idx := range order {
+		result.Nodes = append(result.Nodes, NeighborNode{GraphNode: g.Nodes[idx], Depth: dist[idx]})
+		for _, t := range g.out[idx] {
+			if _, ok := dist[t]; ok && g.edgeAllowed(idx, t, allowed) {
+				result.Edges = append(result.Edges, g.edge(idx, t))
+			}
+		}
+	}
+	return result, nil
+}
+
+// PathResult ist ein kürzester Pfad zwischen zwei Knoten.
+type PathResult struct {
+	Found  bool        `json:"found"`
+	Length int         `json:"length"` // Anzahl Kanten
+	Nodes  []GraphNode `json:"nodes"`
+	Edges  []GraphEdge `json:"edges"` // in Zitationsrichtung
+}
+
+// ShortestPath sucht per Breitensuche den kürzesten Pfad von from nach to.
+// Bei direction=out folgt der Pfad den Zitationen, bei both werden Kanten ungerichtet betrachtet.
+func (g *CitationGraph) ShortestPath(from, to int, direction string) (*PathResult, error) {
+	if err := validateDirection(direction); err != nil {
+		return nil, err
+	}
+	prev := map[int]int{from: -1}
+	queue := []int{from}
+	for len(queue) > 0 && !containsKey(prev, to) {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range g.adjacent(cur, direction) {
+			if _, ok := prev[next]; !ok {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	result := &PathResult{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	if !containsKey(prev, to) {
+		return result, nil
+	}
+	var path []int
+	for cur := to; cur != -1; cur = prev[cur] {
+		path = append([]int{cur}, path...)
+	}
+	result.Found, result.Length = true, len(path)-1
+	for i, idx := range path {
+		result.Nodes = append(result.Nodes, g.Nodes[idx])
+		if i == 0 {
+			continue
+		}
+		a, b := path[i-1], idx
+		if !containsInt(g.out[a], b) {
+			a, b = b, a // Kante gegen die Laufrichtung (in/both)
+		}
+		result.Edges = append(result.Edges, g.edge(a, b))
+	}
+	return result, nil
+}
+
+// PairSimilarity enthält Co-Zitation und bibliographische Kopplung zweier Paper.
+type PairSimilarity struct {
+	A GraphNode `json:"a"`
+	B GraphNode `json:"b"`
+
+	CoCitations      int      `json:"co_citations"`      // Paper, die beide zitieren
+	CoCitationScore  float64  `json:"co_citation_score"` // Salton: co / sqrt(cited_by_a * cited_by_b)
+	CouplingStrength int      `json:"coupling_strength"` // gemeinsame Referenzen
+	CouplingScore    float64  `json:"coupling_score"`    // Salton: shared / sqrt(refs_a * refs_b)
+	CoCitingPapers   []string `json:"co_citing_papers"`  // Schlüssel der gemeinsam zitierenden Paper
+	SharedReferences []string `json:"shared_references"` // Schlüssel der gemeinsamen Referenzen
+	DirectCitation   bool     `json:"direct_citation"`   // A zitiert B oder B zitiert A
+}
+
+// Similarity berechnet Co-Zitation und bibliographische Kopplung zweier Knoten.
+func (g *CitationGraph) Similarity(a, b int) *PairSimilarity {
+	result := &PairSimilarity{A: g.Nodes[a], B: g.Nodes[b], CoCitingPapers: []string{}, SharedReferences: []string{}}
+	for _, idx := range intersect(g.in[a], g.in[b]) {
+		result.CoCitingPapers = append(result.CoCitingPapers, g.Nodes[idx].Key)
+	}
+	for _, idx := range intersect(g.out[a], g.out[b]) {
+		result.SharedReferences = append(result.SharedReferences, g.Nodes[idx].Key)
+	}
+	result.CoCitations, result.CouplingStrength = len(result.CoCitingPapers), len(result.SharedReferences)
+	result.CoCitationScore = salton(result.CoCitations, len(g.in[a]), len(g.in[b]))
+	result.CouplingScore = salton(result.CouplingStrength, len(g.out[a]), len(g.out[b]))
+	result.DirectCitation = containsInt(g.out[a], b) || containsInt(g.out[b], a)
+	return result
+}
+
+// RankingFilter schränkt die Rangliste ein.
+type RankingFilter struct {
+	InPapers      *bool // nur (nicht) ingestierte Paper
+	InRatedPapers *bool // nur (nicht) bewertete Paper
+	MinCitedBy    int
+}
+
+// Rankings liefert die Top-Knoten nach metric (pagerank oder citations).
+func (g *CitationGraph) Rankings(metric string, filter RankingFilter, limit int) ([]GraphNode, error) {
+	if metric != "pagerank" && metric != "citations" {
+		return nil, fmt.Errorf("%w: unknown metric %q (allowed: pagerank, citations)", ErrInvalidGraphQuery, metric)
+	}
+	var nodes []GraphNode
+	for _, n := range g.Nodes {
+		if filter.InPapers != nil && n.InPapers != *filter.InPapers {
+			continue
+		}
+		if filter.InRatedPapers != nil && n.InRatedPapers != *filter.InRatedPapers {
+			continue
+		}
+		if n.CitedBy < filter.MinCitedBy {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if metric == "citations" && nodes[i].CitedBy != nodes[j].CitedBy {
+			return nodes[i].CitedBy > nodes[j].CitedBy
+		}
+		if nodes[i].PageRank != nodes[j].PageRank {
+			return nodes[i].PageRank > nodes[j].PageRank
+		}
+		return nodes[i].Key < nodes[j].Key
+	})
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	if nodes == nil {
+		nodes = []GraphNode{}
+	}
+	return nodes, nil
+}
+
+func (g *CitationGraph) adjacent(idx int, direction string) []int {
+	switch direction {
+	case DirectionOut:
+		return g.out[idx]
+	case DirectionIn:
+		return g.in[idx]
+	}
+	return append(append([]int{}, g.out[idx]...), g.in[idx]...)
+}
+
+// adjacentByIntent liefert die Nachbarn über Kanten mit erlaubter Zitierabsicht (nil = alle).
+func (g *CitationGraph) adjacentByIntent(idx int, direction string, allowed map[string]bool) []int {
+	if allowed == nil {
+		return g.adjacent(idx, direction)
+	}
+	var out []int
+	if direction != DirectionIn {
+		for _, t := range g.out[idx] {
+			if g.edgeAllowed(idx, t, allowed) {
+				out = append(out, t)
+			}
+		}
+	}
+	if direction != DirectionOut {
+		for _, s := range g.in[idx] {
+			if g.edgeAllowed(s, idx, allowed) {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func (g *CitationGraph) edgeAllowed(s, t int, allowed map[string]bool) bool {
+	return allowed == nil || allowed[g.intent[[2]int{s, t}].Intent]
+}
+
+// edge liefert die Kante s → t samt Zitierabsicht.
+func (g *CitationGraph) edge(s, t int) GraphEdge {
+	intent := g.intent[[2]int{s, t}]
+	return GraphEdge{Source: g.Nodes[s].Key, Target: g.Nodes[t].Key, Intent: intent.Intent, IntentConfidence: intent.Confidence}
+}
+
+// intentFilter prüft die angefragten Zitierabsichten; leer bedeutet kein Filter (nil).
+func intentFilter(intents []string) (map[string]bool, error) {
+	if len(intents) == 0 {
+		return nil, nil
+	}
+	allowed := make(map[string]bool, len(intents))
+	for _, intent := range intents {
+		if !containsString(CitationIntents, intent) {
+			return nil, fmt.Errorf("%w: unknown intent %q (allowed: %s)", ErrInvalidGraphQuery, intent, strings.Join(CitationIntents, ", "))
+		}
+		allowed[intent] = true
+	}
+	return allowed, nil
+}
+
+func validateDirection(direction string) error {
+	switch direction {
+	case DirectionOut, DirectionIn, DirectionBoth:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown direction %q (allowed: out, in, both)", ErrInvalidGraphQuery, direction)
+}
+
+// nodeKey bildet den Schlüssel eines normalisierten Identifiers ("" bei leerem Wert).
+func nodeKey(kind identifiers.Kind, value string) string {
+	if v := identifiers.Normalize(kind, value); v != "" {
+		return string(kind) + ":" + v
+	}
+	return ""
+}
+
+func splitNodeKey(key string) (identifiers.Kind, string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] == ':' {
+			return identifiers.Kind(key[:i]), key[i+1:]
+		}
+	}
+	return "", key
+}
+
+func salton(shared, a, b int) float64 {
+	if shared == 0 || a == 0 || b == 0 {
+		return 0
+	}
+	return float64(shared) / math.Sqrt(float64(a)*float64(b))
+}
+
+func intersect(a, b []int) []int {
+	set := make(map[int]bool, len(a))
+	for _, x := range a {
+		set[x] = true
+	}
+	var out []int
+	for _, y := range b {
+		if set[y] {
+			out = append(out, y)
+		}
+	}
+	return out
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKey(m map[int]int, k int) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"paper-hand/models"
+)
+
+// Exportformate für GET /graph/export.
+const (
+	ExportGraphML   = "graphml"
+	ExportGEXF      = "gexf"
+	ExportCytoscape = "cytoscape"
+
+	// MaxExportNodes begrenzt die Knotenzahl eines Exports.
+	MaxExportNodes = 50000
+)
+
+// GraphExportOptions wählt den exportierten Teilgraphen.
+type GraphExportOptions struct {
+	Substance string   // Startknoten: Paper dieser Substanz (leer = alle Knoten)
+	MinRating float64  // Startknoten: nur Paper mit Analyse und Rating >= Wert (0 = kein Filter)
+	Depth     int      // Erweiterung der Startknoten um Nachbarn bis zu dieser Tiefe
+	Direction string   // out, in oder both
+	Intents   []string // nur Kanten dieser Zitierabsichten (leer = alle)
+	MaxNodes  int
+}
+
+// Export liefert den Teilgraphen zu den Optionen. Ohne Substanz- und Rating-Filter ist das der ganze Graph.
+func (s *GraphService) Export(opts GraphExportOptions) (*Subgraph, error) {
+	g, err := s.Graph()
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxNodes <= 0 || opts.MaxNodes > MaxExportNodes {
+		opts.MaxNodes = MaxExportNodes
+	}
+
+	var seeds []int
+	if opts.Substance != "" {
+		var paperIDs []uint
+		if err := s.RawDB.Model(&models.Paper{}).Scopes(PaperClassificationScope([]string{opts.Substance}, nil)).
+			Order("papers.id").Pluck("papers.id", &paperIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range paperIDs {
+			if idx, ok := g.paper[id]; ok {
+				seeds = append(seeds, idx)
+			}
+		}
+	} else {
+		for i := range g.Nodes {
+			seeds = append(seeds, i)
+		}
+	}
+	if opts.MinRating > 0 {
+		filtered := seeds[:0]
+		for _, idx := range seeds {
+			if r := g.Nodes[idx].Rating; r != nil && *r >= opts.MinRating {
+				filtered = append(filtered, idx)
+			}
+		}
+		seeds = filtered
+	}
+	return g.Expand(seeds, opts.Depth, opts.Direction, opts.Intents, opts.MaxNodes)
+}
+
+// exportAttribute beschreibt ein Knotenattribut der Exportformate.
+type exportAttribute struct {
+	Name  string
+	Type  string                  // GraphML/GEXF-Typ: string, int, double, boolean
+	Value func(*NeighborNode) any // nil = Attribut fehlt am Knoten
+}
+
+var exportAttributes = []exportAttribute{
+	{"doi", "string", func(n *NeighborNode) any { return nonEmpty(n.DOI) }},
+	{"pmid", "string", func(n *NeighborNode) any { return nonEmpty(n.PMID) }},
+	{"title", "string", func(n *NeighborNode) any { return nonEmpty(n.Title) }},
+	{"year", "int", func(n *NeighborNode) any {
+		if n.Year == 0 {
+			return nil
+		}
+		return n.Year
+	}},
+	{"study_design", "string", func(n *NeighborNode) any { return nonEmpty(n.StudyDesign) }},
+	{"rating", "double", func(n *NeighborNode) any {
+		if n.Rating == nil {
+			return nil
+		}
+		return *n.Rating
+	}},
+	{"in_papers", "boolean", func(n *NeighborNode) any { return n.InPapers }},
+	{"in_rated_papers", "boolean", func(n *NeighborNode) any { return n.InRatedPapers }},
+	{"cited_by", "int", func(n *NeighborNode) any { return n.CitedBy }},
+	{"references", "int", func(n *NeighborNode) any { return n.References }},
+	{"pagerank", "double", func(n *NeighborNode) any { return n.PageRank }},
+	{"depth", "int", func(n *NeighborNode) any { return n.Depth }},
+}
+
+// edgeAttributes sind die Kantenattribute der Exportformate.
+var edgeAttributes = []struct {
+	Name  string
+	Type  string
+	Value func(*GraphEdge) any
+}{
+	{"intent", "string", func(e *GraphEdge) any { return nonEmpty(e.Intent) }},
+	{"intent_confidence", "double", func(e *GraphEdge) any {
+		if e.Intent == "" {
+			return nil
+		}
+		return e.IntentConfidence
+	}},
+}
+
+func nonEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// formatAttribute wandelt einen Attributwert in die Textform der XML-Formate um.
+func formatAttribute(v any) string {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// nodeLabel ist der Anzeigename eines Knotens (Titel, sonst Schlüssel).
+func nodeLabel(n *NeighborNode) string {
+	if n.Title != "" {
+		return n.Title
+	}
+	return n.Key
+}
+
+type graphMLDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML schreibt den Teilgraphen als GraphML (z.B. für Gephi, yEd, Cytoscape Desktop).
+func WriteGraphML(w io.Writer, sub *Subgraph) error {
+	doc := graphMLDoc{Xmlns: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = append(doc.Keys, graphMLKey{ID: "label", For: "node", Name: "label", Type: "string"})
+	for _, a := range exportAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: a.Name, For: "node", Name: a.Name, Type: a.Type})
+	}
+	for _, a := range edgeAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: "edge_" + a.Name, For: "edge", Name: a.Name, Type: a.Type})
+	}
+	doc.Graph.ID, doc.Graph.EdgeDefault = "citations", "directed"
+	for i := range sub.Nodes {
+		n := &sub.Nodes[i]
+		node := graphMLNode{ID: n.Key, Data: []graphMLData{{Key: "label", Value: nodeLabel(n)}}}
+		for _, a := range exportAttributes {
+			if v := a.Value(n); v != nil {
+				node.Data = append(node.Data, graphMLData{Key: a.Name, Value: formatAttribute(v)})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for i := range sub.Edges {
+		e := &sub.Edges[i]
+		edge := graphMLEdge{ID: "e" + strconv.Itoa(i), Source: e.Source, Target: e.Target}
+		for _, a := range edgeAttributes {
+			if v := a.Value(e); v != nil {
+				edge.Data = append(edge.Data, graphMLData{Key: "edge_" + a.Name, Value: formatAttribute(v)})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+	return writeXML(w, doc)
+}
+
+type gexfDoc struct {
+	XMLName xml.Name `xml:"gexf"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string               `xml:"defaultedgetype,attr"`
+		Mode            string               `xml:"mode,attr"`
+		Attributes      []gexfAttributeClass `xml:"attributes"`
+		Nodes           []gexfNode           `xml:"nodes>node"`
+		Edges           []gexfEdge           `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+type gexfAttributeClass struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string      `xml:"id,attr"`
+	Label     string      `xml:"label,attr"`
+	AttValues []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID        string      `xml:"id,attr"`
+	Source    string      `xml:"source,attr"`
+	Target    string      `xml:"target,attr"`
+	AttValues []gexfValue `xml:"attvalues>attvalue"`
+}
+
+// WriteGEXF schreibt den Teilgraphen als GEXF 1.3 (Gephi).
+func WriteGEXF(w io.Writer, sub *Subgraph) error {
+	doc := gexfDoc{Xmlns: "http://gexf.net/1.3", Version: "1.3"}
+	doc.Graph.DefaultEdgeType, doc.Graph.Mode = "directed", "static"
+	nodeAttrs, edgeAttrs := gexfAttributeClass{Class: "node"}, gexfAttributeClass{Class: "edge"}
+	for _, a := range exportAttributes {
+		nodeAttrs.Attributes = append(nodeAttrs.Attributes, gexfAttribute{ID: a.Name, Title: a.Name, Type: a.Type})
+	}
+	for _, a := range edgeAttributes {
+		edgeAttrs.Attributes = append(edgeAttrs.Attributes, gexfAttribute{ID: a.Name, Title: a.Name, Type: a.Type})
+	}
+	doc.Graph.Attributes = []gexfAttributeClass{nodeAttrs, edgeAttrs}
+	for i := range sub.Nodes {
+		n := &sub.Nodes[i]
+		node := gexfNode{ID: n.Key, Label: nodeLabel(n)}
+		for _, a := range exportAttributes {
+			if v := a.Value(n); v != nil {
+				node.AttValues = append(node.AttValues, gexfValue{For: a.Name, Value: formatAttribute(v)})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for i := range sub.Edges {
+		e := &sub.Edges[i]
+		edge := gexfEdge{ID: strconv.Itoa(i), Source: e.Source, Target: e.Target}
+		for _, a := range edgeAttributes {
+			if v := a.Value(e); v != nil {
+				edge.AttValues = append(edge.AttValues, gexfValue{For: a.Name, Value: formatAttribute(v)})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// CytoscapeGraph ist das Elements-Format von Cytoscape.js bzw. der Cytoscape-JSON-Import.
+type CytoscapeGraph struct {
+	Elements struct {
+		Nodes []CytoscapeElement `json:"nodes"`
+		Edges []CytoscapeElement `json:"edges"`
+	} `json:"elements"`
+}
+
+// CytoscapeElement hält die Daten eines Knotens oder einer Kante.
+type CytoscapeElement struct {
+	Data map[string]any `json:"data"`
+}
+
+// ToCytoscape wandelt den Teilgraphen in Cytoscape-JSON um.
+func ToCytoscape(sub *Subgraph) *CytoscapeGraph {
+	out := &CytoscapeGraph{}
+	out.Elements.Nodes = make([]CytoscapeElement, 0, len(sub.Nodes))
+	out.Elements.Edges = make([]CytoscapeElement, 0, len(sub.Edges))
+	for i := range sub.Nodes {
+		n := &sub.Nodes[i]
+		data := map[string]any{"id": n.Key, "label": nodeLabel(n)}
+		for _, a := range exportAttributes {
+			if v := a.Value(n); v != nil {
+				data[a.Name] = v
+			}
+		}
+		out.Elements.Nodes = append(out.Elements.Nodes, CytoscapeElement{Data: data})
+	}
+	for i := range sub.Edges {
+		e := &sub.Edges[i]
+		data := map[string]any{"id": "e" + strconv.Itoa(i), "source": e.Source, "target": e.Target}
+		for _, a := range edgeAttributes {
+			if v := a.Value(e); v != nil {
+				data[a.Name] = v
+			}
+		}
+		out.Elements.Edges = append(out.Elements.Edges, CytoscapeElement{Data: data})
+	}
+	return out
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// ErrNothingToMerge wird geliefert, wenn außer dem kanonischen Paper keine Duplikate angegeben sind.
+var ErrNothingToMerge = errors.New("no duplicates to merge")
+
+// PaperIDs liefert die normalisierten Identifier eines Papers.
+func PaperIDs(p *models.Paper) []identifiers.ID {
+	var ids []identifiers.ID
+	for _, id := range []identifiers.ID{
+		{Kind: identifiers.PMID, Value: identifiers.NormalizePMID(p.PMID)},
+		{Kind: identifiers.DOI, Value: identifiers.NormalizeDOI(p.DOI)},
+		{Kind: identifiers.PMCID, Value: identifiers.NormalizePMCID(p.PMCID)},
+	} {
+		if id.Value != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// FindPaper sucht das kanonische Paper zu einem der Identifier. Gesucht wird in der Alias-Tabelle
+// und zusätzlich in den Spalten (für noch nicht registrierte Paper). Liefert gorm.ErrRecordNotFound.
+func FindPaper(db *gorm.DB, ids ...identifiers.ID) (*models.Paper, error) {
+	var pairs [][]any
+	var pmids, dois, pmcids []string
+	for _, id := range ids {
+		value := identifiers.Normalize(id.Kind, id.Value)
+		if value == "" {
+			continue
+		}
+		pairs = append(pairs, []any{string(id.Kind), value})
+		switch id.Kind {
+		case identifiers.PMID:
+			pmids = append(pmids, value)
+		case identifiers.DOI:
+			dois = append(dois, value)
+		case identifiers.PMCID:
+			pmcids = append(pmcids, value)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	cond := db.Where("id IN (?)", db.Model(&models.PaperIdentifier{}).Select("paper_id").Where("(kind, value) IN ?", pairs))
+	if len(pmids) > 0 {
+		cond = cond.Or("pmid IN ?", pmids)
+	}
+	if len(dois) > 0 {
+		cond = cond.Or("LOWER(doi) IN ?", dois)
+	}
+	if len(pmcids) > 0 {
+		cond = cond.Or("UPPER(pmcid) IN ?", pmcids)
+	}
+	var paper models.Paper
+	if err := db.Where(cond).Order("id").First(&paper).Error; err != nil {
+		return nil, err
+	}
+	return &paper, nil
+}
+
+// RegisterPaperIdentifiers trägt die Identifier eines Papers in die Alias-Tabelle ein.
+// Gehört ein Identifier bereits einem anderen Paper, wird dessen ID als Duplikat zurückgegeben.
+func RegisterPaperIdentifiers(db *gorm.DB, p *models.Paper) ([]uint, error) {
+	if p.ID == 0 {
+		return nil, nil
+	}
+	var duplicates []uint
+	for _, id := range PaperIDs(p) {
+		alias := models.PaperIdentifier{PaperID: p.ID, Kind: string(id.Kind), Value: id.Value}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alias)
+		if res.Error != nil {
+			return duplicates, res.Error
+		}
+		if res.RowsAffected > 0 {
+			continue
+		}
+		var owner models.PaperIdentifier
+		if err := db.Where("kind = ? AND value = ?", id.Kind, id.Value).First(&owner).Error; err != nil {
+			return duplicates, err
+		}
+		if owner.PaperID != p.ID && !containsID(duplicates, owner.PaperID) {
+			duplicates = append(duplicates, owner.PaperID)
+		}
+	}
+	return duplicates, nil
+}
+
+// MergePapers führt Duplikate in das kanonische Paper zusammen: leere Felder werden aufgefüllt,
+// Aliase und Klassifikation übernommen, die Duplikate gelöscht. Offene Duplikat-Cluster mit den
+// gelöschten Papern werden in derselben Transaktion auf das kanonische Paper umgestellt.
+func MergePapers(db *gorm.DB, canonicalID uint, duplicateIDs []uint) (*models.Paper, error) {
+	var dupIDs []uint
+	for _, id := range duplicateIDs {
+		if id != canonicalID && !containsID(dupIDs, id) {
+			dupIDs = append(dupIDs, id)
+		}
+	}
+	if len(dupIDs) == 0 {
+		return nil, ErrNothingToMerge
+	}
+
+	var canonical *models.Paper
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if canonical, err = mergePapersTx(tx, canonicalID, dupIDs); err != nil {
+			return err
+		}
+		return remapDuplicateClusters(tx, canonicalID, dupIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return canonical, nil
+}
+
+// mergePapersTx führt die Duplikate dupIDs (ohne canonicalID, ohne Wiederholungen) in tx zusammen.
+func mergePapersTx(tx *gorm.DB, canonicalID uint, dupIDs []uint) (*models.Paper, error) {
+	var canonical models.Paper
+	err := func() error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&canonical, canonicalID).Error; err != nil {
+			return err
+		}
+		var duplicates []models.Paper
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", dupIDs).Order("id").Find(&duplicates).Error; err != nil {
+			return err
+		}
+		if len(duplicates) != len(dupIDs) {
+			return fmt.Errorf("%w: duplicate paper", gorm.ErrRecordNotFound)
+		}
+		for i := range duplicates {
+			mergePaperFields(&canonical, &duplicates[i])
+		}
+
+		// Aliase sind global eindeutig und können direkt umgehängt werden
+		if err := tx.Model(&models.PaperIdentifier{}).Where("paper_id IN ?", dupIDs).Update("paper_id", canonicalID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO paper_substances (paper_id, substance_id, created_at)
+			SELECT ?, substance_id, created_at FROM paper_substances WHERE paper_id IN ? ON CONFLICT DO NOTHING`, canonicalID, dupIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO paper_filters (paper_id, search_filter_id, created_at)
+			SELECT ?, search_filter_id, created_at FROM paper_filters WHERE paper_id IN ? ON CONFLICT DO NOTHING`, canonicalID, dupIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("paper_id IN ?", dupIDs).Delete(&models.PaperSubstance{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("paper_id IN ?", dupIDs).Delete(&models.PaperFilter{}).Error; err != nil {
+			return err
+		}
+
+		// Erst löschen, dann speichern: PMID/DOI der Duplikate sind eindeutig indiziert
+		if err := tx.Where("id IN ?", dupIDs).Delete(&models.Paper{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(&canonical).Error; err != nil {
+			return err
+		}
+		_, err := RegisterPaperIdentifiers(tx, &canonical)
+		return err
+	}()
+	if err != nil {
+		return nil, err
+	}
+	return &canonical, nil
+}
+
+// mergePaperFields füllt leere Felder von dst mit den Werten aus src.
+func mergePaperFields(dst, src *models.Paper) {
+	fill := func(d *string, s string) {
+		if *d == "" {
+			*d = s
+		}
+	}
+	fill(&dst.PMID, src.PMID)
+	fill(&dst.DOI, src.DOI)
+	fill(&dst.PMCID, src.PMCID)
+	fill(&dst.Substance, src.Substance)
+	fill(&dst.Title, src.Title)
+	fill(&dst.Abstract, src.Abstract)
+	fill(&dst.MeshTerms, src.MeshTerms)
+	fill(&dst.Authors, src.Authors)
+	fill(&dst.PublicURL, src.PublicURL)
+	fill(&dst.StudyType, src.StudyType)
+	fill(&dst.PublicationType, src.PublicationType)
+	fill(&dst.StudyDesign, src.StudyDesign)
+	if dst.StudyDate == nil {
+		dst.StudyDate = src.StudyDate
+	}
+	// Ein gespeichertes PDF des Duplikats gewinnt, wenn das kanonische Paper keines hat
+	if !dst.CloudStored && src.CloudStored {
+		dst.S3Link, dst.DownloadLink, dst.DownloadDate = src.S3Link, src.DownloadLink, src.DownloadDate
+		dst.CloudStored, dst.NoPDFFound = true, false
+	}
+	fill(&dst.DownloadLink, src.DownloadLink)
+	for _, link := range downloadCandidates(src) {
+		if !containsString(dst.DownloadLinks, link) {
+			dst.DownloadLinks = append(dst.DownloadLinks, link)
+		}
+	}
+	for field, source := range src.FieldSources {
+		if dst.FieldSources == nil {
+			dst.FieldSources = make(map[string]string)
+		}
+		if _, ok := dst.FieldSources[field]; !ok {
+			dst.FieldSources[field] = source
+		}
+	}
+	dst.TransferN8N = dst.TransferN8N || src.TransferN8N
+}
+
+// PrepareIdentityMigration entfernt die alten, nicht-partiellen Unique-Indizes auf papers.pmid/doi,
+// an denen das zweite DOI-only-Paper scheiterte. Muss vor AutoMigrate laufen.
+func PrepareIdentityMigration(db *gorm.DB) error {
+	for _, index := range []string{"idx_papers_pmid", "idx_papers_doi"} {
+		if err := db.Exec("DROP INDEX IF EXISTS " + index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillPaperIdentity registriert die Identifier aller noch nicht erfassten Paper und führt dabei
+// gefundene Duplikate (gleiche normalisierte PMID/DOI/PMCID) in das älteste Paper zusammen.
+func BackfillPaperIdentity(db *gorm.DB, logger *zap.Logger) error {
+	var pairs [][2]uint
+	var registered int
+	var papers []models.Paper
+	err := db.Where("NOT EXISTS (SELECT 1 FROM paper_identifiers pi WHERE pi.paper_id = papers.id)").
+		FindInBatches(&papers, 500, func(tx *gorm.DB, batch int) error {
+			for i := range papers {
+				duplicates, err := RegisterPaperIdentifiers(db, &papers[i])
+				if err != nil {
+					return err
+				}
+				registered++
+				for _, other := range duplicates {
+					pairs = append(pairs, [2]uint{other, papers[i].ID})
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	// Duplikat-Paare zu Gruppen auflösen, kanonisch ist jeweils die kleinste ID
+	mergedInto := make(map[uint]uint)
+	resolve := func(id uint) uint {
+		for mergedInto[id] != 0 {
+			id = mergedInto[id]
+		}
+		return id
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	merged := 0
+	for _, pair := range pairs {
+		a, b := resolve(pair[0]), resolve(pair[1])
+		if a == b {
+			continue
+		}
+		canonical, duplicate := a, b
+		if b < a {
+			canonical, duplicate = b, a
+		}
+		if _, err := MergePapers(db, canonical, []uint{duplicate}); err != nil {
+			logger.Warn("Duplikat konnte nicht zusammengeführt werden",
+				zap.Uint("canonical_id", canonical), zap.Uint("duplicate_id", duplicate), zap.Error(err))
+			continue
+		}
+		mergedInto[duplicate] = canonical
+		merged++
+	}
+	if registered > 0 || merged > 0 {
+		logger.Info("Paper-Identitäten nachgetragen", zap.Int("registered_papers", registered), zap.Int("merged_duplicates", merged))
+	}
+	return nil
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RatedDOI liefert die DOI in der Form, in der sie in der rated-Datenbank gespeichert und verglichen
+// wird: normalisiert wie im Identity-Index, bei nicht erkennbarer DOI zumindest getrimmt und kleingeschrieben.
+func RatedDOI(s string) string {
+	if doi := identifiers.NormalizeDOI(s); doi != "" {
+		return doi
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// BackfillRatedDOIs normalisiert die DOIs bestehender Einträge der rated-Datenbank. Ein Rated Paper,
+// dessen normalisierte DOI bereits ein anderer Eintrag trägt, bleibt unverändert und wird gemeldet.
+func BackfillRatedDOIs(db *gorm.DB, logger *zap.Logger) error {
+	tables := []struct {
+		name   string
+		unique bool
+	}{
+		{"rated_papers", true}, {"rated_paper_revisions", false}, {"rated_paper_quarantine", false}, {"content_articles", false},
+	}
+	for _, table := range tables {
+		var rows []struct {
+			ID  uint
+			DOI string
+		}
+		if err := db.Table(table.name).Select("id, doi").Where("doi <> ''").Find(&rows).Error; err != nil {
+			return err
+		}
+		updated := 0
+		for _, row := range rows {
+			doi := RatedDOI(row.DOI)
+			if doi == row.DOI {
+				continue
+			}
+			if table.unique {
+				var other uint
+				if err := db.Table(table.name).Select("id").Where("doi = ?", doi).Limit(1).Scan(&other).Error; err != nil {
+					return err
+				}
+				if other != 0 {
+					logger.Warn("DOI-Duplikat in der rated-Datenbank, bitte manuell zusammenführen",
+						zap.String("table", table.name), zap.Uint("id", row.ID), zap.Uint("duplicate_of", other), zap.String("doi", doi))
+					continue
+				}
+			}
+			if err := db.Table(table.name).Where("id = ?", row.ID).Update("doi", doi).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		if updated > 0 {
+			logger.Info("DOIs normalisiert", zap.String("table", table.name), zap.Int("updated", updated))
+		}
+	}
+	return nil
+}
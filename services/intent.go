@@ -0,0 +1,204 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"paper-hand/models"
+)
+
+// CitationIntents sind die zulässigen Werte für den intent-Filter.
+var CitationIntents = []string{
+	models.IntentBackground, models.IntentMethod, models.IntentSupporting, models.IntentContrasting, models.IntentExtension,
+}
+
+// intentCue ist eine Signalphrase (Englisch oder Deutsch, kleingeschrieben) mit Gewicht.
+// Phrasen gelten nur für ganze Wörter; ein abschließendes "*" erlaubt beliebige Wortendungen
+// ("confirm*" trifft "confirmed", aber nicht "unconfirmed").
+type intentCue struct {
+	Phrase  string
+	Intent  string
+	Weight  float64
+	pattern *regexp.Regexp
+}
+
+// intentCues wird längste Phrase zuerst geprüft; ein Treffer wird aus dem Satz entfernt, damit z.B.
+// "failed to confirm" oder "not confirmed" nicht zusätzlich als "confirm*" zählt.
+var intentCues = func() []intentCue {
+	cues := []intentCue{}
+	add := func(intent string, weight float64, phrases ...string) {
+		for _, p := range phrases {
+			expr := " " + regexp.QuoteMeta(strings.TrimSuffix(p, "*"))
+			if strings.HasSuffix(p, "*") {
+				expr += `\S*`
+			}
+			cues = append(cues, intentCue{Phrase: p, Intent: intent, Weight: weight, pattern: regexp.MustCompile(expr + " ")})
+		}
+	}
+	add(models.IntentContrasting, 1,
+		"in contrast to", "contrary to", "inconsistent with", "not consistent with", "in disagreement with", "at odds with",
+		"contradict*", "conflicts with", "in conflict with", "did not confirm", "could not confirm", "failed to confirm",
+		"failed to replicate", "could not replicate", "did not replicate", "not confirm*", "not support*",
+		"not replicat*", "not corroborat*", "refut*", "challenges the", "differ from", "differs from", "unlike",
+		"im gegensatz zu", "im widerspruch zu", "widerspricht", "widersprechen", "entgegen den", "anders als",
+		"nicht bestätigt", "nicht bestätigen", "nicht reproduziert", "nicht unterstützt", "nicht gestützt",
+		"abweichend von", "steht im gegensatz")
+	add(models.IntentContrasting, 0.5, "however", "whereas", "although", "jedoch", "hingegen", "wohingegen", "allerdings")
+	add(models.IntentSupporting, 1,
+		"consistent with", "in line with", "in agreement with", "agrees with", "in accordance with", "confirm*",
+		"corroborat*", "replicat*", "as reported by", "similar to", "similarly", "support the", "supports the", "comparable to",
+		"im einklang mit", "übereinstimmend mit", "in übereinstimmung mit", "stimmt überein", "stimmen überein",
+		"bestätigt", "bestätigen", "ähnlich wie", "vergleichbar mit", "deckt sich", "unterstützt", "untermauert")
+	add(models.IntentMethod, 1,
+		"we used", "was used", "were used", "using the method", "method of", "methods of", "method described",
+		"as described", "as previously described", "according to the method", "according to the protocol",
+		"following the protocol", "protocol of", "adapted from", "was measured using", "were measured using",
+		"was assessed using", "were assessed using", "was calculated according", "as defined by", "questionnaire*", "scale developed",
+		"wie beschrieben", "wie zuvor beschrieben", "nach der methode", "nach dem protokoll", "gemäß",
+		"in anlehnung an", "modifiziert nach", "verwendet*", "eingesetzt", "mithilfe", "erhoben mit", "gemessen mit")
+	add(models.IntentExtension, 1,
+		"extend*", "build on", "builds on", "building on", "builds upon", "expand on", "expands on", "go beyond",
+		"goes beyond", "further develop", "based on the work", "following up on", "modified version of", "improve upon",
+		"improves upon", "erweitert*", "erweitern", "aufbauend auf", "baut auf", "weiterentwickelt", "weiterführend",
+		"ergänzt", "ergänzen", "knüpft an")
+	add(models.IntentBackground, 0.6,
+		"has been shown", "have been shown", "has been reported", "have been reported", "is known", "are known",
+		"previous studies", "previously reported", "several studies", "many studies", "numerous studies", "studies have",
+		"reviewed in", "for review", "for a review", "is widely", "has been implicated", "have been implicated",
+		"bisherige studien", "frühere studien", "wurde gezeigt", "wurde berichtet", "ist bekannt", "sind bekannt",
+		"bekanntlich", "übersicht*", "zahlreiche studien", "mehrere studien", "zahlreiche arbeiten")
+	sort.SliceStable(cues, func(i, j int) bool {
+		return len(strings.TrimSuffix(cues[i].Phrase, "*")) > len(strings.TrimSuffix(cues[j].Phrase, "*"))
+	})
+	return cues
+}()
+
+// sectionPriors gewichtet die Abschnitte, in denen zitiert wird (Präfix des kleingeschriebenen Titels).
+var sectionPriors = []struct {
+	Prefixes []string
+	Intent   string
+	Weight   float64
+}{
+	{[]string{"method", "material", "patients and method", "study design", "methode", "material und methode"}, models.IntentMethod, 0.5},
+	{[]string{"introduction", "background", "einleitung", "hintergrund"}, models.IntentBackground, 0.3},
+}
+
+// intentPriority entscheidet bei Gleichstand; Widerspruch ist für die Redaktion am wichtigsten.
+var intentPriority = map[string]int{
+	models.IntentContrasting: 5, models.IntentSupporting: 4, models.IntentExtension: 3, models.IntentMethod: 2, models.IntentBackground: 1,
+}
+
+// ClassifyCitationIntent bestimmt die Zitierabsicht aus den Zitiersätzen per Signalphrasen (EN/DE)
+// und Abschnitt. Ohne Kontext bleibt das Ergebnis leer; ohne Signal ist es background mit geringer Konfidenz.
+func ClassifyCitationIntent(contexts []models.CitationContext) (string, float64) {
+	scores := map[string]float64{}
+	used := 0
+	for _, c := range contexts {
+		sentence := cueText(c.Sentence)
+		if strings.TrimSpace(sentence) == "" {
+			continue
+		}
+		used++
+		for _, cue := range intentCues {
+			if !cue.pattern.MatchString(sentence) {
+				continue
+			}
+			scores[cue.Intent] += cue.Weight
+			// Wiederholen, da sich direkt aufeinanderfolgende Treffer das trennende Leerzeichen teilen
+			for cue.pattern.MatchString(sentence) {
+				sentence = cue.pattern.ReplaceAllString(sentence, " ")
+			}
+		}
+		section := strings.ToLower(strings.TrimSpace(c.Section))
+		for _, prior := range sectionPriors {
+			for _, prefix := range prior.Prefixes {
+				if strings.HasPrefix(section, prefix) {
+					scores[prior.Intent] += prior.Weight
+					break
+				}
+			}
+		}
+	}
+	if used == 0 {
+		return "", 0
+	}
+
+	best, total := "", 0.0
+	for intent, score := range scores {
+		total += score
+		if best == "" || score > scores[best] || (score == scores[best] && intentPriority[intent] > intentPriority[best]) {
+			best = intent
+		}
+	}
+	if best == "" {
+		return models.IntentBackground, 0.3
+	}
+	// Anteil am Gesamtsignal, gedämpft bei wenig Signal
+	confidence := scores[best] / (total + 0.5)
+	return best, math.Round(confidence*100) / 100
+}
+
+// cueText bereitet einen Satz für den Phrasenvergleich vor: kleingeschrieben, Satzzeichen als
+// Leerzeichen und jedes Wort von genau einem Leerzeichen umgeben.
+func cueText(sentence string) string {
+	words := strings.FieldsFunc(strings.ToLower(sentence), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// classifyPaperLink berechnet die Absicht einer Kante aus den Kontexten aller Quellen und speichert sie.
+// Liefert true, wenn sich Label oder Konfidenz geändert haben.
+func classifyPaperLink(tx *gorm.DB, link *models.PaperLink) (bool, error) {
+	var evidence []models.PaperLinkEvidence
+	if err := tx.Select("contexts").Where("paper_link_id = ?", link.ID).Find(&evidence).Error; err != nil {
+		return false, err
+	}
+	var contexts []models.CitationContext
+	for _, ev := range evidence {
+		contexts = append(contexts, ev.Contexts...)
+	}
+	intent, confidence := ClassifyCitationIntent(mergeContexts(nil, contexts))
+	if intent == link.Intent && confidence == link.IntentConfidence {
+		return false, nil
+	}
+	link.Intent, link.IntentConfidence = intent, confidence
+	return true, tx.Model(link).UpdateColumns(map[string]any{"intent": intent, "intent_confidence": confidence}).Error
+}
+
+// ClassifyPaperLinks bestimmt die Zitierabsicht für Kanten mit Zitierkontext; ohne all nur für
+// noch nicht klassifizierte. Liefert die Anzahl geänderter Kanten.
+func ClassifyPaperLinks(db *gorm.DB, all bool) (int, error) {
+	changed := 0
+	var lastID uint
+	for {
+		query := db.Select("id", "intent", "intent_confidence").
+			Where("id > ? AND EXISTS (SELECT 1 FROM paper_link_evidence e WHERE e.paper_link_id = paper_links.id AND e.contexts @> '[{}]')", lastID).
+			Order("id").Limit(500)
+		if !all {
+			query = query.Where("intent = ''")
+		}
+		var links []models.PaperLink
+		if err := query.Find(&links).Error; err != nil {
+			return changed, err
+		}
+		if len(links) == 0 {
+			return changed, nil
+		}
+		for i := range links {
+			lastID = links[i].ID
+			ok, err := classifyPaperLink(db, &links[i])
+			if err != nil {
+				return changed, err
+			}
+			if ok {
+				changed++
+			}
+		}
+	}
+}
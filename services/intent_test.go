@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+
+	"paper-hand/models"
+)
+
+func TestClassifyCitationIntent(t *testing.T) {
+	tests := []struct {
+		name     string
+		contexts []models.CitationContext
+		want     string
+	}{
+		{"no context", nil, ""},
+		{"blank sentence", []models.CitationContext{{Sentence: "   "}}, ""},
+		{"no cue", []models.CitationContext{{Sentence: "Curcumin is a polyphenol [3]."}}, models.IntentBackground},
+		{"supporting", []models.CitationContext{{Sentence: "Our results are consistent with Smith et al. [4]."}}, models.IntentSupporting},
+		{"supporting stem", []models.CitationContext{{Sentence: "This was confirmed in a larger cohort [5]."}}, models.IntentSupporting},
+		{"contrasting", []models.CitationContext{{Sentence: "In contrast to [6], we found no effect."}}, models.IntentContrasting},
+		{"negated confirm", []models.CitationContext{{Sentence: "The effect was not confirmed (Smith, 2010)."}}, models.IntentContrasting},
+		{"negated support", []models.CitationContext{{Sentence: "These data do not support the hypothesis of [7]."}}, models.IntentContrasting},
+		{"failed to replicate", []models.CitationContext{{Sentence: "We failed to replicate the findings of [8]."}}, models.IntentContrasting},
+		{"unlikely is not unlike", []models.CitationContext{{Sentence: "It is unlikely that the dose explains this [9]."}}, models.IntentBackground},
+		{"unconfirmed is not confirm", []models.CitationContext{{Sentence: "An unconfirmed report exists [10]."}}, models.IntentBackground},
+		{"unlike as word", []models.CitationContext{{Sentence: "Unlike earlier trials [11], we randomized."}}, models.IntentContrasting},
+		{"method", []models.CitationContext{{Sentence: "Depression was measured using the scale developed by [12]."}}, models.IntentMethod},
+		{"method section prior", []models.CitationContext{{Sentence: "Samples were stored at -80 °C [13].", Section: "Methods"}}, models.IntentMethod},
+		{"extension", []models.CitationContext{{Sentence: "This study builds on the work of [14]."}}, models.IntentExtension},
+		{"german negation", []models.CitationContext{{Sentence: "Der Befund wurde nicht bestätigt [15]."}}, models.IntentContrasting},
+		{"german supporting", []models.CitationContext{{Sentence: "Die Ergebnisse stehen im Einklang mit [16]."}}, models.IntentSupporting},
+		{"german umlaut word", []models.CitationContext{{Sentence: "Eine Übersicht gibt [17]."}}, models.IntentBackground},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := ClassifyCitationIntent(tt.contexts)
+			if got != tt.want {
+				t.Errorf("ClassifyCitationIntent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyCitationIntentConfidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		contexts []models.CitationContext
+		want     float64
+	}{
+		{"no context", nil, 0},
+		{"no cue", []models.CitationContext{{Sentence: "Curcumin is a polyphenol [3]."}}, 0.3},
+		{"single cue", []models.CitationContext{{Sentence: "Our results are consistent with [4]."}}, 0.67},
+		{"negated cue counts once", []models.CitationContext{{Sentence: "This was not confirmed [5]."}}, 0.67},
+		{"mixed cues", []models.CitationContext{{Sentence: "Consistent with [4], however smaller."}}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := ClassifyCitationIntent(tt.contexts); got != tt.want {
+				t.Errorf("confidence = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
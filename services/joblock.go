@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"paper-hand/models"
+)
+
+// advisoryLockNamespace trennt die Advisory-Locks dieses Dienstes von anderen Nutzern derselben Datenbank.
+const advisoryLockNamespace = 4242
+
+// AllSubstancesLockKey ist der Lock-Schlüssel für einen Lauf über alle Substanzen.
+const AllSubstancesLockKey = "fetch:all"
+
+// substanceLockPrefix ist das Präfix der Lock-Schlüssel einzelner Substanzen.
+const substanceLockPrefix = "fetch:substance:"
+
+// SubstanceLockKey liefert den Lock-Schlüssel für eine einzelne Substanz.
+// Der Schlüssel hängt an der ID, damit eine Umbenennung während eines Laufs keinen zweiten Lauf zulässt.
+func SubstanceLockKey(id uint) string {
+	return substanceLockPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// substanceIDFromLockKey liest die Substanz-ID aus einem Lock-Schlüssel.
+func substanceIDFromLockKey(key string) (uint, bool) {
+	rest, ok := strings.CutPrefix(key, substanceLockPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// MigrateSubstanceLockKeys stellt Jobs mit namensbasierten Lock-Schlüsseln ("fetch:substance:<name>")
+// auf die Substanz-ID um, damit ihre Checkpoints weiterhin fortgesetzt werden.
+func MigrateSubstanceLockKeys(db *gorm.DB) error {
+	return db.Exec(`UPDATE fetch_jobs j SET lock_key = ? || s.id
+		FROM substances s
+		WHERE j.lock_key = ? || s.name AND j.lock_key !~ ?`,
+		substanceLockPrefix, substanceLockPrefix, "^"+substanceLockPrefix+"[0-9]+$").Error
+}
+
+// resumableStatuses sind Job-Status, deren Checkpoint beim nächsten Start fortgesetzt wird.
+// "running" umfasst Jobs abgestürzter Prozesse, die noch niemand als "aborted" markiert hat.
+var resumableStatuses = []string{"interrupted", "aborted", "running"}
+
+// JobRunningError wird zurückgegeben, wenn für einen Lock-Schlüssel bereits ein Job läuft.
+type JobRunningError struct {
+	Key string
+	Job *models.FetchJob // kann nil sein, wenn kein Job-Eintrag gefunden wurde
+}
+
+func (e *JobRunningError) Error() string {
+	return fmt.Sprintf("job %q is already running", e.Key)
+}
+
+// JobLocker vergibt cluster-weite Locks über Postgres Advisory Locks.
+// Die Locks hängen an einer dedizierten DB-Session und werden beim Abbruch der Verbindung
+// (z.B. Absturz eines Replikats) automatisch von Postgres freigegeben.
+type JobLocker struct {
+	DB       *gorm.DB
+	Logger   *zap.Logger
+	Webhooks *WebhookService // optional, meldet fetch_job.finished
+	holder   string
+}
+
+// NewJobLocker erstellt einen neuen JobLocker.
+func NewJobLocker(db *gorm.DB, logger *zap.Logger) *JobLocker {
+	host, _ := os.Hostname()
+	return &JobLocker{
+		DB:     db,
+		Logger: logger,
+		holder: fmt.Sprintf("%s/%d", host, os.Getpid()),
+	}
+}
+
+// JobLock repräsentiert einen gehaltenen Lock samt zugehörigem Job-Eintrag.
+type JobLock struct {
+	Key    string
+	Job    *models.FetchJob
+	conn   *sql.Conn
+	locker *JobLocker
+}
+
+// TryAcquire versucht, den Lock für key zu bekommen, ohne zu blockieren.
+// Ist der Lock bereits vergeben, wird ein *JobRunningError mit dem laufenden Job zurückgegeben.
+func (l *JobLocker) TryAcquire(ctx context.Context, key, substance, trigger string) (*JobLock, error) {
+	sqlDB, err := l.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, hashtext($2))", advisoryLockNamespace, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, &JobRunningError{Key: key, Job: l.runningJob(key)}
+	}
+
+	// Wer den Lock bekommt, weiß sicher, dass niemand sonst läuft: Alte "running"-Einträge stammen aus abgebrochenen Prozessen.
+	now := time.Now()
+	if err := l.DB.Model(&models.FetchJob{}).
+		Where("lock_key = ? AND status = ?", key, "running").
+		Updates(map[string]any{"status": "aborted", "finished_at": now}).Error; err != nil {
+		l.Logger.Warn("Konnte verwaiste Jobs nicht als abgebrochen markieren", zap.String("key", key), zap.Error(err))
+	}
+
+	job := &models.FetchJob{
+		LockKey:   key,
+		Substance: substance,
+		Trigger:   trigger,
+		Status:    "running",
+		Holder:    l.holder,
+		StartedAt: now,
+	}
+	if err := l.DB.Create(job).Error; err != nil {
+		l.unlock(conn, key)
+		return nil, err
+	}
+	return &JobLock{Key: key, Job: job, conn: conn, locker: l}, nil
+}
+
+// Checkpoint speichert den aktuellen Arbeitsstand des Jobs, damit er nach einer Unterbrechung fortgesetzt werden kann.
+func (j *JobLock) Checkpoint(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		j.locker.Logger.Warn("Checkpoint konnte nicht serialisiert werden", zap.Uint("job_id", j.Job.ID), zap.Error(err))
+		return
+	}
+	if err := j.locker.DB.Model(j.Job).Update("checkpoint", b).Error; err != nil {
+		j.locker.Logger.Warn("Checkpoint konnte nicht gespeichert werden", zap.Uint("job_id", j.Job.ID), zap.Error(err))
+	}
+}
+
+// Release schreibt das Ergebnis in den Job-Eintrag und gibt den Lock frei.
+// Bei abgebrochenem Kontext bleibt der Checkpoint erhalten und der Job wird als "interrupted" markiert.
+func (j *JobLock) Release(newPapers int, runErr error) {
+	now := time.Now()
+	updates := map[string]any{
+		"status":      "completed",
+		"finished_at": now,
+		"new_papers":  newPapers,
+		"checkpoint":  nil,
+	}
+	switch {
+	case errors.Is(runErr, context.Canceled), errors.Is(runErr, context.DeadlineExceeded):
+		updates["status"] = "interrupted"
+		delete(updates, "checkpoint")
+	case runErr != nil:
+		updates["status"] = "failed"
+		updates["error"] = runErr.Error()
+	}
+	if err := j.locker.DB.Model(j.Job).Updates(updates).Error; err != nil {
+		j.locker.Logger.Warn("Konnte Job-Status nicht speichern", zap.Uint("job_id", j.Job.ID), zap.Error(err))
+	} else {
+		j.locker.Webhooks.Emit(models.EventFetchJobFinished, j.Job)
+	}
+	if updates["status"] == "completed" {
+		// Ein erfolgreicher Lauf erledigt auch ältere, unterbrochene Arbeit für denselben Schlüssel.
+		if err := j.locker.DB.Model(&models.FetchJob{}).
+			Where("lock_key = ? AND id <> ? AND status IN ? AND checkpoint IS NOT NULL", j.Key, j.Job.ID, resumableStatuses).
+			Updates(map[string]any{"status": "superseded", "checkpoint": nil}).Error; err != nil {
+			j.locker.Logger.Warn("Konnte ältere Checkpoints nicht verwerfen", zap.String("key", j.Key), zap.Error(err))
+		}
+	}
+	j.locker.unlock(j.conn, j.Key)
+}
+
+// latestResumable liefert den jüngsten fortsetzbaren Job für key oder nil.
+func (l *JobLocker) latestResumable(key string) *models.FetchJob {
+	var job models.FetchJob
+	if err := l.DB.Where("lock_key = ? AND status IN ? AND checkpoint IS NOT NULL", key, resumableStatuses).
+		Order("started_at desc").First(&job).Error; err != nil {
+		return nil
+	}
+	return &job
+}
+
+// takeResumable übernimmt den jüngsten fortsetzbaren Job für den Schlüssel des gehaltenen Locks.
+// Der alte Job wird als "resumed" markiert, damit er nicht erneut fortgesetzt wird.
+func (l *JobLocker) takeResumable(lock *JobLock) *models.FetchJob {
+	var job models.FetchJob
+	if err := l.DB.Where("lock_key = ? AND id <> ? AND status IN ? AND checkpoint IS NOT NULL", lock.Key, lock.Job.ID, resumableStatuses).
+		Order("started_at desc").First(&job).Error; err != nil {
+		return nil
+	}
+	if err := l.DB.Model(&job).Update("status", "resumed").Error; err != nil {
+		l.Logger.Warn("Konnte Job nicht als fortgesetzt markieren", zap.Uint("job_id", job.ID), zap.Error(err))
+		return nil
+	}
+	lock.Job.ResumedFrom = &job.ID
+	l.DB.Model(lock.Job).Update("resumed_from", job.ID)
+	return &job
+}
+
+// unlock gibt den Advisory Lock frei und schließt die dedizierte Verbindung.
+func (l *JobLocker) unlock(conn *sql.Conn, key string) {
+	// Eigener Kontext: der Lauf-Kontext kann bereits abgebrochen sein.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, hashtext($2))", advisoryLockNamespace, key); err != nil {
+		l.Logger.Warn("Advisory-Unlock fehlgeschlagen, Verbindung wird verworfen", zap.String("key", key), zap.Error(err))
+		// Verbindung nicht in den Pool zurückgeben, sonst bliebe der Lock an der Session hängen.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// runningJob sucht den zuletzt gestarteten laufenden Job für key.
+func (l *JobLocker) runningJob(key string) *models.FetchJob {
+	var job models.FetchJob
+	if err := l.DB.Where("lock_key = ? AND status = ?", key, "running").Order("started_at desc").First(&job).Error; err != nil {
+		return nil
+	}
+	return &job
+}
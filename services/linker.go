@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+// linkBatchSize ist die Anzahl Paper, die pro Datenbankabfrage geladen werden.
+const linkBatchSize = 100
+
+// ErrLinkingRunning wird geliefert, wenn bereits ein Lauf zum Befüllen von paper_links läuft.
+var ErrLinkingRunning = errors.New("citation linking already running")
+
+// CitationLinker befüllt paper_links aus den Referenzlisten und Zitierungen der Provider
+// (Europe PMC references/citations, PubMed ELink, PMC JATS).
+type CitationLinker struct {
+	DB      *gorm.DB
+	Logger  *zap.Logger
+	Sources []providers.Provider // nur Provider, die providers.CitationSource implementieren
+	MaxAge  time.Duration        // Paper werden erneut abgefragt, wenn der letzte Abruf älter ist
+
+	running atomic.Bool
+}
+
+// NewCitationLinker erstellt einen neuen Linker aus allen Providern, die Zitationsdaten liefern.
+func NewCitationLinker(db *gorm.DB, all []providers.Provider, maxAge time.Duration, logger *zap.Logger) *CitationLinker {
+	var sources []providers.Provider
+	for _, p := range all {
+		if _, ok := p.(providers.CitationSource); ok {
+			sources = append(sources, p)
+		}
+	}
+	return &CitationLinker{DB: db, Logger: logger, Sources: sources, MaxAge: maxAge}
+}
+
+// LinkRunResult fasst einen Lauf zusammen.
+type LinkRunResult struct {
+	Papers   int `json:"papers"`
+	Inserted int `json:"inserted"` // neue Kanten
+	Updated  int `json:"updated"`  // Kanten mit neuen oder geänderten Aussagen
+	Failed   int `json:"failed"`
+}
+
+// Run fragt Referenzen und Zitierungen ab und schreibt sie als Kanten nach paper_links. Ohne paperIDs
+// werden alle Paper verarbeitet, deren letzter Abruf fehlt oder älter als MaxAge ist; mit paperIDs
+// genau diese Paper, unabhängig vom Alter.
+func (l *CitationLinker) Run(ctx context.Context, paperIDs []uint) (*LinkRunResult, error) {
+	if !l.running.CompareAndSwap(false, true) {
+		return nil, ErrLinkingRunning
+	}
+	defer l.running.Store(false)
+	return l.run(ctx, paperIDs)
+}
+
+// StartRun sichert den Lauf synchron und führt ihn im Hintergrund aus; done wird mit dem Ergebnis aufgerufen.
+// Läuft bereits ein Lauf, wird ErrLinkingRunning zurückgegeben.
+func (l *CitationLinker) StartRun(ctx context.Context, paperIDs []uint, done func(*LinkRunResult, error)) error {
+	if !l.running.CompareAndSwap(false, true) {
+		return ErrLinkingRunning
+	}
+	go func() {
+		defer l.running.Store(false)
+		done(l.run(ctx, paperIDs))
+	}()
+	return nil
+}
+
+// run führt den Lauf aus; der Aufrufer muss das running-Flag gesetzt haben.
+func (l *CitationLinker) run(ctx context.Context, paperIDs []uint) (*LinkRunResult, error) {
+	result := &LinkRunResult{}
+	if len(l.Sources) == 0 {
+		return result, nil
+	}
+	cutoff := time.Now().Add(-l.MaxAge)
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		query := l.DB.Select("id", "pmid", "doi", "pmcid").Where("id > ?", lastID).Order("id").Limit(linkBatchSize)
+		if len(paperIDs) > 0 {
+			query = query.Where("id IN ?", paperIDs)
+		} else {
+			query = query.Where("links_fetched_at IS NULL OR links_fetched_at < ?", cutoff)
+		}
+		var papers []models.Paper
+		if err := query.Find(&papers).Error; err != nil {
+			return result, err
+		}
+		if len(papers) == 0 {
+			break
+		}
+		for i := range papers {
+			lastID = papers[i].ID
+			inserted, updated, err := l.LinkPaper(ctx, &papers[i])
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				l.Logger.Warn("Zitationen konnten nicht abgerufen werden", zap.Uint("paper_id", papers[i].ID), zap.Error(err))
+				result.Failed++
+				continue
+			}
+			result.Papers++
+			result.Inserted += inserted
+			result.Updated += updated
+		}
+	}
+	l.Logger.Info("paper_links aktualisiert", zap.Int("papers", result.Papers), zap.Int("inserted", result.Inserted), zap.Int("updated", result.Updated), zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// LinkPaper schreibt die Kanten eines Papers (Paper → Referenz, zitierende Arbeit → Paper) und liefert
+// die Anzahl neuer und geänderter Kanten.
+// Schlagen alle Abrufe fehl, bleibt der Abrufzeitpunkt unverändert, damit der nächste Lauf es erneut versucht.
+func (l *CitationLinker) LinkPaper(ctx context.Context, paper *models.Paper) (int, int, error) {
+	self := providers.Reference{PMID: paper.PMID, DOI: paper.DOI}
+	if referenceKey(self) == "" {
+		return 0, 0, nil
+	}
+	var refs, citers []providers.Reference
+	var errs []error
+	for _, p := range l.Sources {
+		source := p.(providers.CitationSource)
+		r, err := source.References(ctx, paper)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s references: %w", p.Name(), err))
+		}
+		refs = append(refs, r...)
+		c, err := source.CitedBy(ctx, paper)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s citations: %w", p.Name(), err))
+		}
+		citers = append(citers, c...)
+	}
+	if len(errs) == 2*len(l.Sources) {
+		return 0, 0, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		l.Logger.Warn("Zitationsquelle fehlgeschlagen", zap.Uint("paper_id", paper.ID), zap.Error(err))
+	}
+
+	inserted, updated := 0, 0
+	upsert := func(in EdgeInput) error {
+		res, err := UpsertPaperLink(l.DB, in)
+		if err != nil {
+			return err
+		}
+		switch res.Result {
+		case EdgeInserted:
+			inserted++
+		case EdgeUpdated:
+			updated++
+		}
+		return nil
+	}
+	paperEnd := LinkEndpoint{DOI: paper.DOI, PMID: paper.PMID}
+	for _, group := range mergeReferences(refs) {
+		if sameWork(self, group.Ref) {
+			continue
+		}
+		in := EdgeInput{Source: paperEnd, Target: group.endpoint(), SourceTable: "papers", Evidence: group.evidence()}
+		if err := upsert(in); err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, group := range mergeReferences(citers) {
+		if sameWork(self, group.Ref) {
+			continue
+		}
+		in := EdgeInput{Source: group.endpoint(), Target: paperEnd, TargetTable: "papers", Evidence: group.evidence()}
+		if err := upsert(in); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := l.DB.Model(paper).UpdateColumn("links_fetched_at", time.Now()).Error; err != nil {
+		return 0, 0, err
+	}
+	return inserted, updated, nil
+}
+
+// referenceGroup ist eine Arbeit, die eine oder mehrere Quellen geliefert haben.
+type referenceGroup struct {
+	Ref   providers.Reference   // zusammengeführte Identifier
+	Parts []providers.Reference // Einträge der einzelnen Quellen
+}
+
+func (g referenceGroup) endpoint() LinkEndpoint {
+	return LinkEndpoint{DOI: g.Ref.DOI, PMID: g.Ref.PMID}
+}
+
+// evidence liefert je Eintrag eine Aussage seiner Quelle.
+func (g referenceGroup) evidence() []EdgeEvidence {
+	out := make([]EdgeEvidence, 0, len(g.Parts))
+	for _, p := range g.Parts {
+		out = append(out, EdgeEvidence{Source: p.Origin, Title: p.Title, Year: p.Year, Journal: p.Journal, Contexts: p.Contexts})
+	}
+	return out
+}
+
+// mergeReferences fasst Einträge verschiedener Quellen zusammen, die dieselbe PMID oder DOI tragen.
+// Fehlende Identifier werden aus späteren Einträgen ergänzt; Einträge ohne PMID und DOI entfallen.
+func mergeReferences(refs []providers.Reference) []referenceGroup {
+	var groups []referenceGroup
+	index := make(map[string]int)
+	for _, ref := range refs {
+		ref.PMID, ref.DOI = identifiers.NormalizePMID(ref.PMID), identifiers.NormalizeDOI(ref.DOI)
+		if ref.Origin == "" {
+			ref.Origin = "unknown"
+		}
+		keys := referenceKeys(ref)
+		if len(keys) == 0 {
+			continue
+		}
+		idx := -1
+		for _, k := range keys {
+			if i, ok := index[k]; ok {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			groups = append(groups, referenceGroup{Ref: providers.Reference{PMID: ref.PMID, DOI: ref.DOI}})
+			idx = len(groups) - 1
+		}
+		g := &groups[idx]
+		if g.Ref.PMID == "" {
+			g.Ref.PMID = ref.PMID
+		}
+		if g.Ref.DOI == "" {
+			g.Ref.DOI = ref.DOI
+		}
+		g.Parts = append(g.Parts, ref)
+		for _, k := range referenceKeys(g.Ref) {
+			index[k] = idx
+		}
+	}
+	return groups
+}
+
+// referenceKeys liefert die Identifier-Schlüssel einer Referenz.
+func referenceKeys(ref providers.Reference) []string {
+	var keys []string
+	if pmid := identifiers.NormalizePMID(ref.PMID); pmid != "" {
+		keys = append(keys, "pmid:"+pmid)
+	}
+	if doi := identifiers.NormalizeDOI(ref.DOI); doi != "" {
+		keys = append(keys, "doi:"+doi)
+	}
+	return keys
+}
+
+// referenceKey liefert den ersten Schlüssel oder "".
+func referenceKey(ref providers.Reference) string {
+	if keys := referenceKeys(ref); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// sameWork prüft, ob zwei Referenzen einen Identifier teilen (Selbstzitat).
+func sameWork(a, b providers.Reference) bool {
+	for _, ka := range referenceKeys(a) {
+		for _, kb := range referenceKeys(b) {
+			if ka == kb {
+				return true
+			}
+		}
+	}
+	return false
+}
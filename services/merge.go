@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// Felder, die beim Zusammenführen von Provider-Treffern einzeln entschieden werden.
+var mergeFields = []string{
+	"pmid", "doi", "pmcid", "title", "abstract", "mesh_terms", "authors", "public_url",
+	"study_date", "study_type", "publication_type", "download_link",
+}
+
+// Precedence legt fest, welcher Provider beim Zusammenführen je Feld gewinnt.
+type Precedence struct {
+	Default []string
+	Fields  map[string][]string
+}
+
+// ParsePrecedence liest die Standard-Reihenfolge ("pubmed,europepmc") und optionale
+// Feld-Overrides ("abstract=europepmc,pubmed;download_link=europepmc").
+func ParsePrecedence(defaultOrder, fieldSpec string) (Precedence, error) {
+	p := Precedence{Default: splitList(defaultOrder), Fields: make(map[string][]string)}
+	for _, entry := range strings.Split(fieldSpec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, order, ok := strings.Cut(entry, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || !isMergeField(field) {
+			return p, fmt.Errorf("invalid field precedence %q", entry)
+		}
+		p.Fields[field] = splitList(order)
+	}
+	return p, nil
+}
+
+// order liefert die Provider-Reihenfolge für ein Feld.
+func (p Precedence) order(field string) []string {
+	if o, ok := p.Fields[field]; ok && len(o) > 0 {
+		return o
+	}
+	return p.Default
+}
+
+// rank sortiert Provider nach Reihenfolge; nicht gelistete Provider kommen zuletzt.
+func (p Precedence) rank(field, provider string) int {
+	for i, name := range p.order(field) {
+		if name == provider {
+			return i
+		}
+	}
+	return len(p.order(field))
+}
+
+// providerRecord ist ein einzelner Treffer eines Providers für einen Suchfilter.
+type providerRecord struct {
+	Provider string
+	Filter   models.SearchFilter
+	Paper    *models.Paper
+}
+
+// mergeProviderRecords gruppiert Treffer über ihre normalisierten Identifier (PMID, DOI, PMCID)
+// und führt jede Gruppe feldweise zu einem Paper zusammen.
+func mergeProviderRecords(records []providerRecord, precedence Precedence) []*models.Paper {
+	// Union-Find über die Identifier: ein DOI-only-Treffer landet so beim PubMed-Treffer mit gleicher DOI
+	parent := make([]int, len(records))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	owner := make(map[identifiers.ID]int)
+	for i, r := range records {
+		for _, id := range PaperIDs(r.Paper) {
+			if j, ok := owner[id]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				owner[id] = i
+			}
+		}
+	}
+
+	groups := make(map[int][]providerRecord)
+	var roots []int
+	for i, r := range records {
+		if len(PaperIDs(r.Paper)) == 0 {
+			continue // ohne Identifier nicht zuordenbar
+		}
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], r)
+	}
+
+	papers := make([]*models.Paper, 0, len(roots))
+	for _, root := range roots {
+		papers = append(papers, mergeGroup(groups[root], precedence))
+	}
+	return papers
+}
+
+// mergeGroup führt die Treffer einer Gruppe feldweise zusammen und merkt sich die Herkunft jedes Feldes.
+func mergeGroup(group []providerRecord, precedence Precedence) *models.Paper {
+	merged := &models.Paper{FieldSources: make(map[string]string)}
+	for _, field := range mergeFields {
+		best := -1
+		for i, r := range group {
+			if isEmptyField(r.Paper, field) {
+				continue
+			}
+			if best < 0 || precedence.rank(field, r.Provider) < precedence.rank(field, group[best].Provider) {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		copyField(merged, group[best].Paper, field)
+		merged.FieldSources[field] = group[best].Provider
+	}
+
+	// Alle Download-Kandidaten in Präzedenz-Reihenfolge behalten
+	for rank := 0; rank <= len(precedence.order("download_link")); rank++ {
+		for _, r := range group {
+			if precedence.rank("download_link", r.Provider) != rank {
+				continue
+			}
+			for _, link := range append([]string{r.Paper.DownloadLink}, r.Paper.DownloadLinks...) {
+				if link != "" && !containsString(merged.DownloadLinks, link) {
+					merged.DownloadLinks = append(merged.DownloadLinks, link)
+				}
+			}
+		}
+	}
+
+	// Alle gematchten Filter sammeln; der erste bleibt als StudyDesign-Primärwert erhalten
+	for _, r := range group {
+		if r.Filter.ID != 0 && !hasFilter(merged.MatchedFilters, r.Filter.ID) {
+			merged.MatchedFilters = append(merged.MatchedFilters, models.SearchFilter{ID: r.Filter.ID, Name: r.Filter.Name})
+		}
+	}
+	if len(merged.MatchedFilters) > 0 {
+		merged.StudyDesign = merged.MatchedFilters[0].Name
+	}
+	return merged
+}
+
+func isMergeField(field string) bool {
+	return containsString(mergeFields, field)
+}
+
+func isEmptyField(p *models.Paper, field string) bool {
+	switch field {
+	case "study_date":
+		return p.StudyDate == nil
+	default:
+		return *stringField(p, field) == ""
+	}
+}
+
+func copyField(dst, src *models.Paper, field string) {
+	switch field {
+	case "study_date":
+		t := *src.StudyDate
+		dst.StudyDate = &t
+	default:
+		*stringField(dst, field) = *stringField(src, field)
+	}
+}
+
+// stringField liefert einen Pointer auf das String-Feld eines Papers.
+func stringField(p *models.Paper, field string) *string {
+	switch field {
+	case "pmid":
+		return &p.PMID
+	case "doi":
+		return &p.DOI
+	case "pmcid":
+		return &p.PMCID
+	case "title":
+		return &p.Title
+	case "abstract":
+		return &p.Abstract
+	case "mesh_terms":
+		return &p.MeshTerms
+	case "authors":
+		return &p.Authors
+	case "public_url":
+		return &p.PublicURL
+	case "study_type":
+		return &p.StudyType
+	case "publication_type":
+		return &p.PublicationType
+	case "download_link":
+		return &p.DownloadLink
+	}
+	panic("unknown merge field " + field)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxPageSize begrenzt limit auf allen List-/Query-Endpunkten.
+const MaxPageSize = 1000
+
+// DefaultPageSize gilt, wenn kein limit angegeben ist.
+const DefaultPageSize = 100
+
+// ErrInvalidListParams kennzeichnet ungültige sort-, fields- oder cursor-Angaben (HTTP 400).
+var ErrInvalidListParams = errors.New("invalid list parameters")
+
+// ListParams sind die gemeinsamen Paginierungs-Parameter aller List-/Query-Endpunkte.
+type ListParams struct {
+	Limit    int      `json:"limit"`
+	Cursor   string   `json:"cursor"`   // opaker Cursor aus next_cursor der vorherigen Seite
+	Sort     string   `json:"sort"`     // Feldname, "-" als Präfix für absteigend, z.B. "-created_at"
+	Fields   []string `json:"fields"`   // nur diese JSON-Felder ausliefern
+	Envelope bool     `json:"envelope"` // {items, total, next_cursor} statt reinem Array
+}
+
+// SortField beschreibt ein erlaubtes Sortierfeld.
+type SortField struct {
+	Column   string
+	Nullable bool // NULL-Werte werden als -infinity einsortiert (nur Zeitstempel)
+}
+
+// ListSpec legt die erlaubten Sortierfelder und die Standard-Sortierung eines Endpunkts fest.
+type ListSpec struct {
+	Sorts       map[string]SortField
+	DefaultSort string
+	ExtraFields []string // zusätzliche JSON-Felder der Antwort (Anreicherung), die in fields erlaubt sind
+	Preloads    []string // Assoziationen, die nur für die Seite (nicht für den Count) geladen werden
+}
+
+// ListPage enthält die Metadaten einer Ergebnisseite.
+type ListPage struct {
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Sort       string `json:"sort"`
+}
+
+// pageCursor ist der Inhalt des (base64-kodierten) Cursors.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Paginate wendet Sortierung, Cursor und Limit auf query an, lädt die Seite in dest (Pointer auf Slice)
+// und liefert Gesamtanzahl und nächsten Cursor. Ohne limit gilt DefaultPageSize.
+func Paginate(query *gorm.DB, spec ListSpec, params ListParams, dest any) (*ListPage, error) {
+	sortName := params.Sort
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	desc := strings.HasPrefix(sortName, "-")
+	field, ok := spec.Sorts[strings.TrimPrefix(sortName, "-")]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort field %q (allowed: %s)", ErrInvalidListParams, strings.TrimPrefix(sortName, "-"), strings.Join(sortNames(spec), ", "))
+	}
+	if params.Limit < 0 {
+		return nil, fmt.Errorf("%w: limit must not be negative", ErrInvalidListParams)
+	}
+	limit := min(params.Limit, MaxPageSize)
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	stmt := query.Session(&gorm.Session{}).Statement
+	if err := stmt.Parse(stmt.Model); err != nil {
+		return nil, err
+	}
+	if err := validateFields(stmt, spec, params.Fields); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+	expr := table + "." + field.Column
+	if field.Nullable {
+		expr = "COALESCE(" + expr + ", '-infinity')"
+	}
+	idColumn := table + "." + stmt.Schema.PrioritizedPrimaryField.DBName
+
+	page := &ListPage{Limit: limit, Sort: sortName}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	q := query.Session(&gorm.Session{})
+	if params.Cursor != "" {
+		cur, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cur.Sort != sortName {
+			return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidListParams, cur.Sort)
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		q = q.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", expr, idColumn, op), cur.Value, cur.ID)
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	q = q.Order(fmt.Sprintf("%s %s, %s %s", expr, dir, idColumn, dir))
+	for _, name := range spec.Preloads {
+		q = q.Preload(name)
+	}
+	q = q.Limit(limit + 1) // ein Datensatz mehr zeigt an, ob es eine weitere Seite gibt
+	if err := q.Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > limit {
+		rows.Set(rows.Slice(0, limit))
+		last := reflect.Indirect(rows.Index(limit - 1))
+		sortValue, _ := stmt.Schema.LookUpField(field.Column).ValueOf(context.Background(), last)
+		idValue, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(context.Background(), last)
+		page.NextCursor = encodeCursor(pageCursor{Sort: sortName, Value: cursorValue(sortValue), ID: cursorValue(idValue)})
+	}
+	return page, nil
+}
+
+// ProjectFields reduziert die Elemente auf die angegebenen JSON-Felder ("id" bleibt immer erhalten).
+func ProjectFields(items any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return items, nil
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	keep := map[string]bool{"id": true}
+	for _, f := range fields {
+		keep[f] = true
+	}
+	for _, row := range rows {
+		for key := range row {
+			if !keep[key] {
+				delete(row, key)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// validateFields prüft fields gegen die JSON-Namen des Modells und die Zusatzfelder.
+func validateFields(stmt *gorm.Statement, spec ListSpec, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	for _, f := range stmt.Schema.Fields {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		if name != "-" {
+			allowed[name] = true
+		}
+	}
+	for _, name := range spec.ExtraFields {
+		allowed[name] = true
+	}
+	for _, rel := range stmt.Schema.Relationships.Relations {
+		if name := strings.Split(rel.Field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			allowed[name] = true
+		}
+	}
+	for _, f := range fields {
+		if !allowed[f] {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidListParams, f)
+		}
+	}
+	return nil
+}
+
+func sortNames(spec ListSpec) []string {
+	names := make([]string, 0, len(spec.Sorts))
+	for name := range spec.Sorts {
+		names = append(names, name)
+	}
+	return names
+}
+
+// cursorValue wandelt einen Sortierwert in seine Textform; Postgres parst ihn passend zur Spalte.
+func cursorValue(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "-infinity"
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case *time.Time:
+		if t == nil {
+			return "-infinity"
+		}
+		return t.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == "" {
+		return c, fmt.Errorf("%w: malformed cursor", ErrInvalidListParams)
+	}
+	return c, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		c    pageCursor
+	}{
+		{"timestamp", pageCursor{Sort: "-created_at", Value: "2024-05-01T12:30:00.123456789Z", ID: "42"}},
+		{"text with special characters", pageCursor{Sort: "title", Value: `Curcumin "&" Ängste/?`, ID: "7"}},
+		{"empty value", pageCursor{Sort: "doi", ID: "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.c))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if got != tt.c {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.c)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "***"},
+		{"not json", "bm90IGpzb24"},
+		{"missing id", encodeCursor(pageCursor{Sort: "title", Value: "x"})},
+		{"standard base64 padding", "eyJpZCI6IjEifQ=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidListParams) {
+				t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidListParams", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestCursorValue(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	var nilTime *time.Time
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, "-infinity"},
+		{"time", ts, "2024-05-01T12:30:00.123456789Z"},
+		{"time pointer", &ts, "2024-05-01T12:30:00.123456789Z"},
+		{"nil time pointer", nilTime, "-infinity"},
+		{"float64", 7.25, "7.25"},
+		{"float64 integral", float64(8), "8"},
+		{"float32", float32(0.1), "0.1"},
+		{"uint", uint(42), "42"},
+		{"string", "10.1000/abc", "10.1000/abc"},
+		{"bool", true, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursorValue(tt.in); got != tt.want {
+				t.Errorf("cursorValue(%v) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	type item struct {
+		ID     uint    `json:"id"`
+		Title  string  `json:"title"`
+		DOI    string  `json:"doi"`
+		Rating float64 `json:"rating"`
+	}
+	items := []item{{ID: 1, Title: "A", DOI: "10.1/a", Rating: 7}, {ID: 2, Title: "B", DOI: "10.1/b", Rating: 5}}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{"no fields keeps items", nil, `[{"id":1,"title":"A","doi":"10.1/a","rating":7},{"id":2,"title":"B","doi":"10.1/b","rating":5}]`},
+		{"id is always kept", []string{"title"}, `[{"id":1,"title":"A"},{"id":2,"title":"B"}]`},
+		{"several fields", []string{"doi", "rating"}, `[{"doi":"10.1/a","id":1,"rating":7},{"doi":"10.1/b","id":2,"rating":5}]`},
+		{"unknown field is ignored", []string{"missing"}, `[{"id":1},{"id":2}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ProjectFields(items, tt.fields)
+			if err != nil {
+				t.Fatalf("ProjectFields() error = %v", err)
+			}
+			data, err := json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("ProjectFields() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+
+	if _, err := ProjectFields(item{ID: 1}, []string{"title"}); err == nil {
+		t.Error("ProjectFields(non-slice) error = nil, want error")
+	}
+}
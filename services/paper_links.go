@@ -0,0 +1,338 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// Ergebnis eines Kanten-Upserts.
+const (
+	EdgeInserted  = "inserted"
+	EdgeUpdated   = "updated"
+	EdgeUnchanged = "unchanged"
+)
+
+// ErrInvalidEdge kennzeichnet Kanten ohne verwertbare Identifier (HTTP 400).
+var ErrInvalidEdge = errors.New("invalid paper link")
+
+// LinkEndpoint ist ein Ende einer Kante (roh, wie geliefert).
+type LinkEndpoint struct {
+	DOI  string `json:"doi"`
+	PMID string `json:"pmid"`
+}
+
+// EdgeEvidence ist die Aussage einer Quelle über eine Kante.
+type EdgeEvidence struct {
+	Source   string
+	Title    string
+	Year     int
+	Journal  string
+	Contexts []models.CitationContext
+	Extra    map[string]any
+}
+
+// EdgeInput beschreibt eine Kante samt Aussagen einer oder mehrerer Quellen.
+type EdgeInput struct {
+	Source      LinkEndpoint
+	Target      LinkEndpoint
+	SourceTable string
+	TargetTable string
+	Evidence    []EdgeEvidence
+}
+
+// EdgeUpsertResult ist das Ergebnis je Kante.
+type EdgeUpsertResult struct {
+	LinkID     uint           `json:"link_id"`
+	TargetDOI  string         `json:"target_doi,omitempty"`
+	TargetPMID string         `json:"target_pmid,omitempty"`
+	Result     string         `json:"result"`             // inserted, updated, unchanged
+	Versions   map[string]int `json:"versions,omitempty"` // Evidence-Version je Quelle
+	Intent     string         `json:"intent,omitempty"`   // Zitierabsicht nach dem Upsert
+}
+
+// UpsertPaperLink legt eine Kante an oder aktualisiert sie. Die Aussagen werden je Quelle als
+// PaperLinkEvidence gespeichert (neue Werte ergänzen bzw. überschreiben Felder, Kontexte werden
+// vereinigt); die zusammengeführte Evidence der Kante behält bestehende Schlüssel bei.
+func UpsertPaperLink(db *gorm.DB, in EdgeInput) (*EdgeUpsertResult, error) {
+	link := models.PaperLink{
+		SourceDOINorm: identifiers.NormalizeDOI(in.Source.DOI), SourcePMIDNorm: identifiers.NormalizePMID(in.Source.PMID),
+		TargetDOINorm: identifiers.NormalizeDOI(in.Target.DOI), TargetPMIDNorm: identifiers.NormalizePMID(in.Target.PMID),
+		SourceDOI: in.Source.DOI, SourcePMID: in.Source.PMID,
+		TargetDOI: in.Target.DOI, TargetPMID: in.Target.PMID,
+		SourceTable: in.SourceTable, TargetTable: in.TargetTable,
+	}
+	if link.SourceDOINorm == "" && link.SourcePMIDNorm == "" {
+		return nil, fmt.Errorf("%w: source doi or pmid required", ErrInvalidEdge)
+	}
+	if link.TargetDOINorm == "" && link.TargetPMIDNorm == "" {
+		return nil, fmt.Errorf("%w: target doi or pmid required", ErrInvalidEdge)
+	}
+	result := &EdgeUpsertResult{TargetDOI: link.TargetDOINorm, TargetPMID: link.TargetPMIDNorm, Versions: map[string]int{}}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		summary, _ := json.Marshal(mergeEvidenceSummary(nil, in.Evidence))
+		link.Evidence = summary
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link)
+		if res.Error != nil {
+			return res.Error
+		}
+		changed := false
+		if res.RowsAffected == 1 {
+			result.Result = EdgeInserted
+		} else {
+			var existing models.PaperLink
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("source_doi_norm = ? AND source_pmid_norm = ? AND target_doi_norm = ? AND target_pmid_norm = ?",
+					link.SourceDOINorm, link.SourcePMIDNorm, link.TargetDOINorm, link.TargetPMIDNorm).
+				First(&existing).Error; err != nil {
+				return err
+			}
+			updates := map[string]any{}
+			for col, pair := range map[string][2]string{
+				"source_doi":   {existing.SourceDOI, link.SourceDOI},
+				"source_pmid":  {existing.SourcePMID, link.SourcePMID},
+				"target_doi":   {existing.TargetDOI, link.TargetDOI},
+				"target_pmid":  {existing.TargetPMID, link.TargetPMID},
+				"source_table": {existing.SourceTable, link.SourceTable},
+				"target_table": {existing.TargetTable, link.TargetTable},
+			} {
+				if pair[1] != "" && pair[1] != pair[0] {
+					updates[col] = pair[1]
+				}
+			}
+			var current map[string]any
+			if len(existing.Evidence) > 0 {
+				_ = json.Unmarshal(existing.Evidence, &current)
+			}
+			merged, _ := json.Marshal(mergeEvidenceSummary(current, in.Evidence))
+			if !jsonEqual(existing.Evidence, merged) {
+				updates["evidence"] = merged
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+					return err
+				}
+				changed = true
+			}
+			link = existing
+		}
+
+		evidenceChanged := false
+		for _, ev := range in.Evidence {
+			version, evChanged, err := upsertEdgeEvidence(tx, link.ID, ev)
+			if err != nil {
+				return err
+			}
+			result.Versions[ev.Source] = version
+			evidenceChanged = evidenceChanged || evChanged
+		}
+		if evidenceChanged {
+			if _, err := classifyPaperLink(tx, &link); err != nil {
+				return err
+			}
+			changed = true
+		}
+		result.Intent = link.Intent
+		if result.Result == "" {
+			result.Result = EdgeUnchanged
+			if changed {
+				result.Result = EdgeUpdated
+				// updated_at auch bei reinen Evidence-Änderungen setzen (Graph-Cache erkennt so die Änderung)
+				if err := tx.Model(&link).UpdateColumn("updated_at", gorm.Expr("NOW()")).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.LinkID = link.ID
+	return result, nil
+}
+
+// upsertEdgeEvidence legt die Aussage einer Quelle an oder führt sie mit der vorhandenen zusammen.
+// Liefert die aktuelle Version und ob sich etwas geändert hat.
+func upsertEdgeEvidence(tx *gorm.DB, linkID uint, ev EdgeEvidence) (int, bool, error) {
+	var record models.PaperLinkEvidence
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("paper_link_id = ? AND source = ?", linkID, ev.Source).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record = models.PaperLinkEvidence{
+			PaperLinkID: linkID, Source: ev.Source, Version: 1,
+			Title: ev.Title, Year: ev.Year, Journal: ev.Journal,
+			Contexts: mergeContexts(nil, ev.Contexts), Extra: ev.Extra,
+		}
+		return 1, true, tx.Create(&record).Error
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	before := record
+	before.Contexts = append([]models.CitationContext(nil), record.Contexts...)
+	before.Extra = mergeExtra(nil, record.Extra)
+	if ev.Title != "" {
+		record.Title = ev.Title
+	}
+	if ev.Year > 0 {
+		record.Year = ev.Year
+	}
+	if ev.Journal != "" {
+		record.Journal = ev.Journal
+	}
+	record.Contexts = mergeContexts(record.Contexts, ev.Contexts)
+	record.Extra = mergeExtra(record.Extra, ev.Extra)
+	if record.Title == before.Title && record.Year == before.Year && record.Journal == before.Journal &&
+		reflect.DeepEqual(record.Contexts, before.Contexts) && reflect.DeepEqual(record.Extra, before.Extra) {
+		return record.Version, false, nil
+	}
+	record.Version++
+	return record.Version, true, tx.Save(&record).Error
+}
+
+// mergeEvidenceSummary ergänzt die zusammengeführte Evidence einer Kante: bestehende Schlüssel bleiben,
+// neue Werte überschreiben gleichnamige, "sources" listet alle Quellen.
+func mergeEvidenceSummary(current map[string]any, evidence []EdgeEvidence) map[string]any {
+	out := make(map[string]any, len(current)+4)
+	for k, v := range current {
+		out[k] = v
+	}
+	sources := map[string]bool{}
+	if list, ok := out["sources"].([]any); ok {
+		for _, s := range list {
+			if str, ok := s.(string); ok {
+				sources[str] = true
+			}
+		}
+	}
+	for _, ev := range evidence {
+		sources[ev.Source] = true
+		for k, v := range ev.Extra {
+			out[k] = v
+		}
+		if ev.Title != "" {
+			out["title"] = ev.Title
+		}
+		if ev.Year > 0 {
+			out["year"] = ev.Year
+		}
+		if ev.Journal != "" {
+			out["journal"] = ev.Journal
+		}
+	}
+	list := make([]string, 0, len(sources))
+	for s := range sources {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	out["sources"] = list
+	return out
+}
+
+// mergeContexts vereinigt Kontexte; gleiche Sätze (ohne Leerraum-Unterschiede) zählen einmal.
+func mergeContexts(existing, add []models.CitationContext) []models.CitationContext {
+	out := make([]models.CitationContext, 0, len(existing)+len(add))
+	seen := map[string]bool{}
+	for _, c := range append(append([]models.CitationContext(nil), existing...), add...) {
+		c.Sentence = strings.Join(strings.Fields(c.Sentence), " ")
+		c.Section = strings.TrimSpace(c.Section)
+		if c.Sentence == "" || seen[c.Section+"\x00"+c.Sentence] {
+			continue
+		}
+		seen[c.Section+"\x00"+c.Sentence] = true
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func mergeExtra(existing, add map[string]any) map[string]any {
+	if len(existing) == 0 && len(add) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(existing)+len(add))
+	for k, v := range existing {
+		out[k] = v
+	}
+	for k, v := range add {
+		out[k] = v
+	}
+	return out
+}
+
+// EvidenceFromMap übernimmt eine freie Evidence-Map (z.B. von n8n) in die typisierte Form. Bekannte
+// Schlüssel sind title, year, journal, contexts ([{sentence, section}]) sowie sentence/section für
+// einen einzelnen Kontext; alle übrigen landen in Extra.
+func EvidenceFromMap(source string, m map[string]any) EdgeEvidence {
+	ev := EdgeEvidence{Source: source}
+	var sentence, section string
+	for k, v := range m {
+		switch k {
+		case "title":
+			ev.Title = anyString(v)
+		case "journal":
+			ev.Journal = anyString(v)
+		case "year":
+			ev.Year, _ = strconv.Atoi(anyString(v))
+		case "sentence":
+			sentence = anyString(v)
+		case "section":
+			section = anyString(v)
+		case "contexts":
+			b, _ := json.Marshal(v)
+			var contexts []models.CitationContext
+			if json.Unmarshal(b, &contexts) == nil {
+				ev.Contexts = append(ev.Contexts, contexts...)
+			}
+		default:
+			if ev.Extra == nil {
+				ev.Extra = map[string]any{}
+			}
+			ev.Extra[k] = v
+		}
+	}
+	if sentence != "" {
+		ev.Contexts = append(ev.Contexts, models.CitationContext{Sentence: sentence, Section: section})
+	}
+	return ev
+}
+
+func anyString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// jsonEqual vergleicht zwei JSON-Dokumente inhaltlich.
+func jsonEqual(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+// FilterPreview ist das Ergebnis eines Dry-Runs für Substanz + Filter über alle aktiven Provider.
+type FilterPreview struct {
+	Term            string            `json:"term"`
+	Providers       []ProviderPreview `json:"providers"`
+	Overlap         []ProviderOverlap `json:"overlap"`
+	UniqueIDs       int               `json:"unique_ids"`        // Vereinigung aller geladenen Identifier
+	AlreadyInPapers int               `json:"already_in_papers"` // davon bereits in der papers-Tabelle
+	NewPapers       int               `json:"new_papers"`        // davon noch nicht vorhanden
+	Truncated       bool              `json:"truncated"`         // mindestens ein Provider hatte mehr Treffer als max_ids
+}
+
+// ProviderPreview fasst die Vorschau eines einzelnen Providers zusammen.
+type ProviderPreview struct {
+	Provider        string          `json:"provider"`
+	Total           int             `json:"total"`
+	FetchedIDs      int             `json:"fetched_ids"`
+	Truncated       bool            `json:"truncated"`
+	AlreadyInPapers int             `json:"already_in_papers"`
+	Sample          []PreviewSample `json:"sample"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// PreviewSample ist ein Stichproben-Treffer.
+type PreviewSample struct {
+	PMID   string `json:"pmid,omitempty"`
+	DOI    string `json:"doi,omitempty"`
+	Title  string `json:"title"`
+	Year   int    `json:"year,omitempty"`
+	Exists bool   `json:"exists"` // bereits in papers
+}
+
+// ProviderOverlap zählt gemeinsame Treffer zweier Provider (über PMID, sonst DOI).
+type ProviderOverlap struct {
+	A      string `json:"a"`
+	B      string `json:"b"`
+	Shared int    `json:"shared"`
+	OnlyA  int    `json:"only_a"`
+	OnlyB  int    `json:"only_b"`
+}
+
+// PreviewFilter führt die kompilierte Suche gegen alle Provider mit Vorschau-Unterstützung aus, ohne etwas zu speichern.
+func (f *FetchService) PreviewFilter(ctx context.Context, substance, filterQuery string, maxIDs, sampleSize int) (*FilterPreview, error) {
+	term := CompileSearchTerm(substance, filterQuery)
+	result := &FilterPreview{Term: term}
+
+	type providerResult struct {
+		name    string
+		preview *providers.Preview
+		err     error
+	}
+	var previewers []providers.Provider
+	for _, p := range f.Providers {
+		if _, ok := p.(providers.Previewer); ok {
+			previewers = append(previewers, p)
+		}
+	}
+	results := make([]providerResult, len(previewers))
+	var wg sync.WaitGroup
+	for i, p := range previewers {
+		wg.Add(1)
+		go func(i int, p providers.Provider) {
+			defer wg.Done()
+			preview, err := p.(providers.Previewer).Preview(ctx, term, maxIDs, sampleSize)
+			results[i] = providerResult{name: p.Name(), preview: preview, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Identifier je Provider als Schlüsselmenge (PMID bevorzugt, sonst DOI)
+	keySets := make(map[string]map[string]bool)
+	union := make(map[string]providers.PaperID)
+	for _, r := range results {
+		pp := ProviderPreview{Provider: r.name, Sample: []PreviewSample{}}
+		if r.err != nil {
+			f.Logger.Warn("Provider-Vorschau fehlgeschlagen", zap.String("provider", r.name), zap.Error(r.err))
+			pp.Error = r.err.Error()
+			result.Providers = append(result.Providers, pp)
+			continue
+		}
+		pp.Total = r.preview.Total
+		pp.FetchedIDs = len(r.preview.IDs)
+		pp.Truncated = r.preview.Truncated
+		result.Truncated = result.Truncated || pp.Truncated
+
+		keys := make(map[string]bool, len(r.preview.IDs))
+		for _, id := range r.preview.IDs {
+			if k := previewKey(id); k != "" {
+				keys[k] = true
+				if prev, ok := union[k]; !ok || (prev.DOI == "" && id.DOI != "") {
+					union[k] = id
+				}
+			}
+		}
+		keySets[r.name] = keys
+		for _, paper := range r.preview.Sample {
+			sample := PreviewSample{PMID: paper.PMID, DOI: paper.DOI, Title: paper.Title}
+			if paper.StudyDate != nil {
+				sample.Year = paper.StudyDate.Year()
+			}
+			pp.Sample = append(pp.Sample, sample)
+		}
+		result.Providers = append(result.Providers, pp)
+	}
+
+	// Paarweise Überschneidung
+	for i := 0; i < len(result.Providers); i++ {
+		for j := i + 1; j < len(result.Providers); j++ {
+			a, b := keySets[result.Providers[i].Provider], keySets[result.Providers[j].Provider]
+			if a == nil || b == nil {
+				continue
+			}
+			shared := 0
+			for k := range a {
+				if b[k] {
+					shared++
+				}
+			}
+			result.Overlap = append(result.Overlap, ProviderOverlap{
+				A: result.Providers[i].Provider, B: result.Providers[j].Provider,
+				Shared: shared, OnlyA: len(a) - shared, OnlyB: len(b) - shared,
+			})
+		}
+	}
+
+	// Abgleich mit der papers-Tabelle
+	ids := make([]providers.PaperID, 0, len(union))
+	for _, id := range union {
+		ids = append(ids, id)
+	}
+	existing, err := f.existingKeys(ids)
+	if err != nil {
+		return nil, err
+	}
+	result.UniqueIDs = len(union)
+	result.AlreadyInPapers = len(existing)
+	result.NewPapers = result.UniqueIDs - result.AlreadyInPapers
+	for i := range result.Providers {
+		pp := &result.Providers[i]
+		for k := range keySets[pp.Provider] {
+			if existing[k] {
+				pp.AlreadyInPapers++
+			}
+		}
+		for j := range pp.Sample {
+			s := &pp.Sample[j]
+			s.Exists = existing[previewKey(providers.PaperID{PMID: s.PMID, DOI: s.DOI})]
+		}
+	}
+	return result, nil
+}
+
+// previewKey liefert den Vergleichsschlüssel eines Treffers.
+func previewKey(id providers.PaperID) string {
+	if id.PMID != "" {
+		return "pmid:" + strings.TrimSpace(id.PMID)
+	}
+	if id.DOI != "" {
+		return "doi:" + strings.ToLower(strings.TrimSpace(id.DOI))
+	}
+	return ""
+}
+
+// existingKeys prüft in Batches, welche Treffer bereits in papers liegen (über PMID oder DOI).
+func (f *FetchService) existingKeys(ids []providers.PaperID) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	const batchSize = 500
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		var pmids, dois []string
+		for _, id := range batch {
+			if id.PMID != "" {
+				pmids = append(pmids, strings.TrimSpace(id.PMID))
+			}
+			if id.DOI != "" {
+				dois = append(dois, strings.ToLower(strings.TrimSpace(id.DOI)))
+			}
+		}
+		query := f.DB.Select("pmid", "doi")
+		switch {
+		case len(pmids) > 0 && len(dois) > 0:
+			query = query.Where("pmid IN ? OR LOWER(doi) IN ?", pmids, dois)
+		case len(pmids) > 0:
+			query = query.Where("pmid IN ?", pmids)
+		case len(dois) > 0:
+			query = query.Where("LOWER(doi) IN ?", dois)
+		default:
+			continue
+		}
+		var found []models.Paper
+		if err := query.Find(&found).Error; err != nil {
+			return nil, err
+		}
+		byPMID := make(map[string]bool, len(found))
+		byDOI := make(map[string]bool, len(found))
+		for _, p := range found {
+			if p.PMID != "" {
+				byPMID[p.PMID] = true
+			}
+			if p.DOI != "" {
+				byDOI[strings.ToLower(p.DOI)] = true
+			}
+		}
+		for _, id := range batch {
+			if byPMID[strings.TrimSpace(id.PMID)] || byDOI[strings.ToLower(strings.TrimSpace(id.DOI))] {
+				existing[previewKey(id)] = true
+			}
+		}
+	}
+	return existing, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// ErrQuarantineResolved wird geliefert, wenn eine Quarantäne-Einreichung bereits freigegeben oder
+// verworfen wurde (HTTP 409).
+var ErrQuarantineResolved = errors.New("quarantine entry already resolved")
+
+// QuarantineRatedPaper speichert eine ungültige Bewertung samt Fehlerliste in der Quarantäne.
+func QuarantineRatedPaper(db *gorm.DB, raw map[string]any, errs ValidationErrors) (*models.RatedPaperQuarantine, error) {
+	payload, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := json.Marshal(errs)
+	entry := models.RatedPaperQuarantine{
+		DOI: RatedDOI(anyString(raw["doi"])), Payload: payload, Errors: list, Status: models.QuarantineOpen,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// OpenQuarantineEntry lädt eine offene Quarantäne-Einreichung zur Bearbeitung (gesperrt bis Transaktionsende).
+func OpenQuarantineEntry(tx *gorm.DB, id uint) (*models.RatedPaperQuarantine, error) {
+	var entry models.RatedPaperQuarantine
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	if entry.Status != models.QuarantineOpen {
+		return nil, ErrQuarantineResolved
+	}
+	return &entry, nil
+}
+
+// RecheckQuarantineEntry speichert einen korrigierten, aber weiterhin ungültigen Payload mit der neuen Fehlerliste.
+func RecheckQuarantineEntry(db *gorm.DB, entry *models.RatedPaperQuarantine, raw map[string]any, errs ValidationErrors) error {
+	payload, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	list, _ := json.Marshal(errs)
+	entry.Payload, entry.Errors, entry.DOI = payload, list, RatedDOI(anyString(raw["doi"]))
+	return db.Model(entry).Updates(map[string]any{"payload": payload, "errors": list, "doi": entry.DOI}).Error
+}
+
+// ResolveQuarantineEntry schließt eine Einreichung als freigegeben (mit ratedPaperID) oder verworfen ab.
+func ResolveQuarantineEntry(db *gorm.DB, entry *models.RatedPaperQuarantine, status string, ratedPaperID *uint) error {
+	now := time.Now()
+	entry.Status, entry.ResolvedAt, entry.RatedPaperID = status, &now, ratedPaperID
+	return db.Model(entry).Updates(map[string]any{"status": status, "resolved_at": now, "rated_paper_id": ratedPaperID}).Error
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+)
+
+// MaxResolveInputs begrenzt die Anzahl der Eingaben pro Resolve-Aufruf.
+const MaxResolveInputs = 500
+
+// minTitleMatchLength verhindert, dass kurze Titel in beliebigen Zitaten "gefunden" werden.
+const minTitleMatchLength = 25
+
+const (
+	titleMatchBatchSize  = 100 // Zitate pro Kandidatenabfrage
+	titleMatchCandidates = 5   // Kandidaten pro Zitat aus dem Volltextindex
+	titleMatchThreshold  = 0.8 // Mindest-Jaccard-Ähnlichkeit von Titel und Zitat-Abschnitt
+	titleMaxSegments     = 3   // maximal zusammengefasste Zitat-Abschnitte
+)
+
+// ResolveResult ist das Ergebnis für eine einzelne Eingabe.
+type ResolveResult struct {
+	Input    string `json:"input"`
+	Kind     string `json:"kind"`             // doi, pmid, pmcid, arxiv, citation, unknown
+	Method   string `json:"method,omitempty"` // identifier, title_match
+	Resolved bool   `json:"resolved"`
+
+	// Kanonische Identifier (aus dem Paper, sonst aus der Eingabe)
+	PMID  string `json:"pmid,omitempty"`
+	DOI   string `json:"doi,omitempty"`
+	PMCID string `json:"pmcid,omitempty"`
+	ArXiv string `json:"arxiv,omitempty"`
+
+	InPapers      bool  `json:"in_papers"`
+	PaperID       *uint `json:"paper_id,omitempty"`
+	InRatedPapers bool  `json:"in_rated_papers"`
+	RatedPaperID  *uint `json:"rated_paper_id,omitempty"`
+}
+
+// IdentifierResolver löst gemischte Eingaben (IDs, URLs, Zitate) gegen papers und rated_papers auf.
+type IdentifierResolver struct {
+	RawDB   *gorm.DB
+	RatedDB *gorm.DB
+}
+
+// NewIdentifierResolver erstellt einen neuen Resolver.
+func NewIdentifierResolver(rawDB, ratedDB *gorm.DB) *IdentifierResolver {
+	return &IdentifierResolver{RawDB: rawDB, RatedDB: ratedDB}
+}
+
+// Resolve löst alle Eingaben auf; die Reihenfolge der Ergebnisse entspricht der Eingabe.
+// Zitate ohne Identifier werden gesammelt und in einer Abfrage über den Titel abgeglichen.
+func (r *IdentifierResolver) Resolve(inputs []string) ([]ResolveResult, error) {
+	results := make([]ResolveResult, len(inputs))
+	ids := make([][]identifiers.ID, len(inputs))
+	var citations []string
+	var citationIdx []int
+	for i, input := range inputs {
+		results[i], ids[i] = parseResolveInput(input)
+		if len(ids[i]) == 0 && results[i].Kind == "citation" {
+			citations = append(citations, strings.TrimSpace(input))
+			citationIdx = append(citationIdx, i)
+		}
+	}
+
+	matches, err := r.matchTitles(citations)
+	if err != nil {
+		return nil, err
+	}
+	titleMatches := make(map[int]*models.Paper, len(matches))
+	for j, paper := range matches {
+		titleMatches[citationIdx[j]] = paper
+	}
+
+	for i := range results {
+		if err := r.resolveOne(&results[i], ids[i], titleMatches[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// parseResolveInput erkennt einen einzelnen Identifier bzw. eine URL, sonst ein Freitext-Zitat.
+func parseResolveInput(input string) (ResolveResult, []identifiers.ID) {
+	text := strings.TrimSpace(input)
+	result := ResolveResult{Input: input, Kind: "unknown"}
+	if text == "" {
+		return result, nil
+	}
+
+	var ids []identifiers.ID
+	if !strings.ContainsAny(text, " \t\n") {
+		if id, ok := identifiers.Detect(text); ok {
+			ids = []identifiers.ID{id}
+			result.Kind = string(id.Kind)
+		}
+	} else {
+		result.Kind = "citation"
+		ids = identifiers.Extract(text)
+	}
+	for _, id := range ids {
+		setCanonical(&result, id)
+	}
+	return result, ids
+}
+
+// resolveOne sucht das Paper zu einer geparsten Eingabe; titleMatch ist der Treffer aus matchTitles.
+func (r *IdentifierResolver) resolveOne(result *ResolveResult, ids []identifiers.ID, titleMatch *models.Paper) error {
+	var paper *models.Paper
+	var err error
+	if len(ids) > 0 {
+		paper, err = FindPaper(r.RawDB, ids...)
+		result.Method = "identifier"
+	} else if titleMatch != nil {
+		paper = titleMatch
+		result.Method = "title_match"
+	}
+	switch {
+	case err == nil && paper != nil:
+		if err := r.applyPaper(result, paper); err != nil {
+			return err
+		}
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	if result.DOI != "" {
+		var rated models.RatedPaper
+		err := r.RatedDB.Select("id").Where("LOWER(doi) = ?", result.DOI).First(&rated).Error
+		if err == nil {
+			result.InRatedPapers = true
+			result.RatedPaperID = &rated.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	result.Resolved = result.PMID != "" || result.DOI != "" || result.PMCID != "" || result.ArXiv != ""
+	return nil
+}
+
+// applyPaper übernimmt die kanonischen Identifier eines gefundenen Papers inkl. Aliasen.
+func (r *IdentifierResolver) applyPaper(result *ResolveResult, paper *models.Paper) error {
+	result.InPapers = true
+	result.PaperID = &paper.ID
+	for _, id := range PaperIDs(paper) {
+		setCanonical(result, id)
+	}
+	var aliases []models.PaperIdentifier
+	if err := r.RawDB.Where("paper_id = ?", paper.ID).Find(&aliases).Error; err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		kind := identifiers.Kind(alias.Kind)
+		if kind == identifiers.ArXiv || canonicalValue(result, kind) == "" {
+			setCanonical(result, identifiers.ID{Kind: kind, Value: alias.Value})
+		}
+	}
+	return nil
+}
+
+// matchTitles gleicht Zitate ohne Identifier über den Titel ab; das Ergebnis ist nach
+// citations ausgerichtet (nil = kein Treffer). Kandidaten liefert der Volltextindex
+// (search_en, nur Titelgewicht), je Batch in einer Abfrage. Ein Kandidat zählt erst, wenn
+// sein Titel einem Abschnitt des Zitats ausreichend ähnlich ist; ein kurzer Titel, der nur
+// innerhalb eines längeren Zitat-Titels vorkommt, reicht nicht.
+func (r *IdentifierResolver) matchTitles(citations []string) ([]*models.Paper, error) {
+	matches := make([]*models.Paper, len(citations))
+	ids := make(map[int]uint)
+	for start := 0; start < len(citations); start += titleMatchBatchSize {
+		batch := citations[start:min(start+titleMatchBatchSize, len(citations))]
+		values := make([]string, len(batch))
+		args := make([]any, 0, 2*len(batch)+2)
+		for i, citation := range batch {
+			values[i] = "(?::int, ?::text)"
+			args = append(args, start+i, citation)
+		}
+		args = append(args, minTitleMatchLength, titleMatchCandidates)
+
+		var rows []titleCandidate
+		err := r.RawDB.Raw(`SELECT c.idx, p.id, p.title
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS c(idx, citation)
+			CROSS JOIN LATERAL (
+				SELECT CAST(replace(plainto_tsquery('english', c.citation)::text, ' & ', ' | ') AS tsquery) AS q
+			) AS t
+			CROSS JOIN LATERAL (
+				SELECT papers.id, papers.title FROM papers
+				WHERE papers.search_en @@ t.q AND LENGTH(papers.title) >= ?
+				ORDER BY ts_rank('{0,0,0,1}', papers.search_en, t.q) DESC, papers.id
+				LIMIT ?
+			) AS p`, args...).Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		best := make(map[int]float64)
+		for _, row := range rows {
+			score := titleSimilarity(row.Title, citations[row.Idx])
+			if score >= titleMatchThreshold && score > best[row.Idx] {
+				best[row.Idx] = score
+				ids[row.Idx] = row.ID
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return matches, nil
+	}
+
+	var papers []models.Paper
+	if err := r.RawDB.Where("id IN ?", slices.Collect(maps.Values(ids))).Find(&papers).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Paper, len(papers))
+	for i := range papers {
+		byID[papers[i].ID] = &papers[i]
+	}
+	for i, id := range ids {
+		matches[i] = byID[id]
+	}
+	return matches, nil
+}
+
+type titleCandidate struct {
+	Idx   int
+	ID    uint
+	Title string
+}
+
+// citationSegments trennt ein Zitat an Satzzeichen (Autoren. Titel. Journal. ...).
+var citationSegments = regexp.MustCompile(`[.?!]\s+`)
+
+// titleSimilarity ist die beste Jaccard-Ähnlichkeit der Titelwörter zu einem Abschnitt des
+// Zitats. Benachbarte Abschnitte werden auch zusammen geprüft, falls der Titel selbst
+// einen Punkt enthält ("vs. placebo").
+func titleSimilarity(title, citation string) float64 {
+	words := shingles(title, 1)
+	parts := citationSegments.Split(citation, -1)
+	best := 0.0
+	for i := range parts {
+		for j := i + 1; j <= min(i+titleMaxSegments, len(parts)); j++ {
+			if score := jaccard(words, shingles(strings.Join(parts[i:j], " "), 1)); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+func setCanonical(result *ResolveResult, id identifiers.ID) {
+	switch id.Kind {
+	case identifiers.PMID:
+		result.PMID = id.Value
+	case identifiers.DOI:
+		result.DOI = id.Value
+	case identifiers.PMCID:
+		result.PMCID = id.Value
+	case identifiers.ArXiv:
+		result.ArXiv = id.Value
+	}
+}
+
+func canonicalValue(result *ResolveResult, kind identifiers.Kind) string {
+	switch kind {
+	case identifiers.PMID:
+		return result.PMID
+	case identifiers.DOI:
+		return result.DOI
+	case identifiers.PMCID:
+		return result.PMCID
+	case identifiers.ArXiv:
+		return result.ArXiv
+	}
+	return ""
+}
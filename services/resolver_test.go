@@ -0,0 +1,51 @@
+package services
+
+import "testing"
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		citation string
+		wantOK   bool
+	}{
+		{
+			name:     "vancouver citation",
+			title:    "Curcumin and depression: a randomized controlled trial.",
+			citation: "Smith J, Doe A. Curcumin and depression: a randomized controlled trial. J Affect Disord. 2019;12(3):45-67.",
+			wantOK:   true,
+		},
+		{
+			name:     "case and punctuation differ",
+			title:    "Curcumin and Depression - A Randomized Controlled Trial",
+			citation: "Smith J. curcumin and depression: a randomized controlled trial? J Affect Disord 2019",
+			wantOK:   true,
+		},
+		{
+			name:     "title with inner period",
+			title:    "Curcumin vs. placebo in major depressive disorder",
+			citation: "Lee K. Curcumin vs. placebo in major depressive disorder. Nutrients. 2020.",
+			wantOK:   true,
+		},
+		{
+			name:     "short title inside longer title",
+			title:    "Curcumin and depression",
+			citation: "Smith J. Curcumin and depression in elderly patients with chronic kidney disease on hemodialysis. Nephrology. 2018.",
+			wantOK:   false,
+		},
+		{
+			name:     "unrelated citation",
+			title:    "Omega-3 fatty acids in bipolar disorder",
+			citation: "Smith J. Curcumin and depression: a randomized controlled trial. J Affect Disord. 2019.",
+			wantOK:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := titleSimilarity(tt.title, tt.citation)
+			if ok := got >= titleMatchThreshold; ok != tt.wantOK {
+				t.Errorf("titleSimilarity() = %v, match %v, want %v", got, ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// ErrInvalidRevision kennzeichnet ungültige Revisionsangaben (HTTP 400).
+var ErrInvalidRevision = errors.New("invalid revision")
+
+// revisionModelKeys sind die Body-Schlüssel, unter denen n8n den Modellnamen liefert.
+var revisionModelKeys = []string{"model", "model_name"}
+
+// RecordRatedPaperRevision legt für das gespeicherte paper eine neue Revision mit dem vollständigen
+// payload an und setzt den Zeiger auf die aktuelle Revision; eine offene Neubewertung gilt damit als
+// erledigt. Ist payload identisch mit der aktuellen Revision (z.B. wiederholter Request), entsteht
+// keine neue Revision. Muss in derselben Transaktion wie das Speichern des Papers laufen.
+func RecordRatedPaperRevision(tx *gorm.DB, paper *models.RatedPaper, payload map[string]any) (*models.RatedPaperRevision, bool, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, err
+	}
+	model := ""
+	for _, key := range revisionModelKeys {
+		if model = anyString(payload[key]); model != "" {
+			break
+		}
+	}
+	promptVersion := anyString(payload["prompt_version"])
+
+	// Paper sperren, damit parallele Requests keine doppelte Revisionsnummer vergeben
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.RatedPaper{}, paper.ID).Error; err != nil {
+		return nil, false, err
+	}
+	if paper.CurrentRevisionID != nil {
+		var current models.RatedPaperRevision
+		err := tx.First(&current, *paper.CurrentRevisionID).Error
+		if err == nil && current.Model == model && current.PromptVersion == promptVersion && jsonEqual(current.Payload, body) {
+			return &current, false, clearReevaluation(tx, paper)
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+
+	var last int
+	if err := tx.Model(&models.RatedPaperRevision{}).Where("rated_paper_id = ?", paper.ID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&last).Error; err != nil {
+		return nil, false, err
+	}
+	revision := models.RatedPaperRevision{
+		RatedPaperID: paper.ID, Revision: last + 1, DOI: paper.DOI,
+		Model: model, PromptVersion: promptVersion,
+		Rating: paper.Rating, ConfidenceScore: paper.ConfidenceScore, Category: paper.Category,
+		AiSummary: paper.AiSummary, KeyFindings: paper.KeyFindings,
+		StudyStrengths: paper.StudyStrengths, StudyLimitations: paper.StudyLimitations,
+		Payload: body,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, false, err
+	}
+	updates := map[string]any{
+		"current_revision_id": revision.ID, "model": model, "prompt_version": promptVersion,
+		"needs_reevaluation": false, "reevaluation_reason": "",
+	}
+	if err := tx.Model(paper).Updates(updates).Error; err != nil {
+		return nil, false, err
+	}
+	paper.CurrentRevisionID = &revision.ID
+	paper.Model, paper.PromptVersion = model, promptVersion
+	paper.NeedsReevaluation, paper.ReevaluationReason = false, ""
+	return &revision, true, nil
+}
+
+func clearReevaluation(tx *gorm.DB, paper *models.RatedPaper) error {
+	if !paper.NeedsReevaluation {
+		return nil
+	}
+	paper.NeedsReevaluation, paper.ReevaluationReason = false, ""
+	return tx.Model(paper).Updates(map[string]any{"needs_reevaluation": false, "reevaluation_reason": ""}).Error
+}
+
+// ListRatedPaperRevisions liefert alle Revisionen eines Papers, neueste zuerst.
+func ListRatedPaperRevisions(db *gorm.DB, paperID uint) ([]models.RatedPaperRevision, error) {
+	var revisions []models.RatedPaperRevision
+	err := db.Where("rated_paper_id = ?", paperID).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// findRevision lädt eine Revision über ihre Nummer; 0 steht für die aktuelle Revision.
+func findRevision(db *gorm.DB, paper *models.RatedPaper, number int) (*models.RatedPaperRevision, error) {
+	var revision models.RatedPaperRevision
+	query := db.Where("rated_paper_id = ?", paper.ID)
+	switch {
+	case number < 0:
+		return nil, fmt.Errorf("%w: revision must be positive", ErrInvalidRevision)
+	case number > 0:
+		query = query.Where("revision = ?", number)
+	case paper.CurrentRevisionID != nil:
+		query = query.Where("id = ?", *paper.CurrentRevisionID)
+	default:
+		query = query.Order("revision DESC")
+	}
+	if err := query.First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RevisionRef beschreibt eine Seite eines Diffs.
+type RevisionRef struct {
+	Revision      int       `json:"revision"`
+	Model         string    `json:"model"`
+	PromptVersion string    `json:"prompt_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// FieldChange ist ein geändertes Feld des Payloads; fehlende Felder sind null.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// RevisionDiff vergleicht die Payloads zweier Revisionen feldweise (oberste Ebene).
+type RevisionDiff struct {
+	DOI     string        `json:"doi"`
+	From    RevisionRef   `json:"from"`
+	To      RevisionRef   `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// DiffRatedPaperRevisions vergleicht die Revisionen from und to. Ohne to gilt die aktuelle Revision,
+// ohne from die Revision davor.
+func DiffRatedPaperRevisions(db *gorm.DB, paper *models.RatedPaper, from, to int) (*RevisionDiff, error) {
+	toRev, err := findRevision(db, paper, to)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		if toRev.Revision == 1 {
+			return nil, fmt.Errorf("%w: revision 1 has no predecessor", ErrInvalidRevision)
+		}
+		from = toRev.Revision - 1
+	}
+	fromRev, err := findRevision(db, paper, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var a, b map[string]any
+	if len(fromRev.Payload) > 0 {
+		_ = json.Unmarshal(fromRev.Payload, &a)
+	}
+	if len(toRev.Payload) > 0 {
+		_ = json.Unmarshal(toRev.Payload, &b)
+	}
+	fields := map[string]bool{}
+	for k := range a {
+		fields[k] = true
+	}
+	for k := range b {
+		fields[k] = true
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	diff := &RevisionDiff{DOI: paper.DOI, From: revisionRef(fromRev), To: revisionRef(toRev), Changes: []FieldChange{}}
+	for _, k := range keys {
+		va, _ := json.Marshal(a[k])
+		vb, _ := json.Marshal(b[k])
+		if !jsonEqual(va, vb) {
+			diff.Changes = append(diff.Changes, FieldChange{Field: k, From: a[k], To: b[k]})
+		}
+	}
+	return diff, nil
+}
+
+func revisionRef(r *models.RatedPaperRevision) RevisionRef {
+	return RevisionRef{Revision: r.Revision, Model: r.Model, PromptVersion: r.PromptVersion, CreatedAt: r.CreatedAt}
+}
+
+// SetCurrentRatedPaperRevision macht eine frühere Revision wieder zur aktuellen und übernimmt deren
+// Bewertung in das Paper. Die Einschätzung des damaligen Raters wird wiederhergestellt und die
+// Aggregation neu berechnet. Die Revisionen selbst bleiben unverändert.
+func SetCurrentRatedPaperRevision(db *gorm.DB, assessments *AssessmentService, paper *models.RatedPaper, number int) (*models.RatedPaperRevision, error) {
+	if number <= 0 {
+		return nil, fmt.Errorf("%w: revision must be positive", ErrInvalidRevision)
+	}
+	var revision *models.RatedPaperRevision
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if revision, err = findRevision(tx, paper, number); err != nil {
+			return err
+		}
+		updates := map[string]any{
+			"current_revision_id": revision.ID, "model": revision.Model, "prompt_version": revision.PromptVersion,
+			"rating": revision.Rating, "confidence_score": revision.ConfidenceScore, "category": revision.Category,
+			"ai_summary": revision.AiSummary, "key_findings": revision.KeyFindings,
+			"study_strengths": revision.StudyStrengths, "study_limitations": revision.StudyLimitations,
+		}
+		if err := tx.Model(paper).Updates(updates).Error; err != nil {
+			return err
+		}
+		paper.CurrentRevisionID = &revision.ID
+		assessment := AIAssessment(paper)
+		if rater := revisionRater(revision); rater != "" {
+			assessment.Rater = rater
+		}
+		_, err = assessments.Record(tx, paper, assessment)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// revisionRater liefert den im Payload der Revision angegebenen Rater (leer = Modell der Revision).
+func revisionRater(revision *models.RatedPaperRevision) string {
+	var payload struct {
+		Rater any `json:"rater"`
+	}
+	if len(revision.Payload) == 0 || json.Unmarshal(revision.Payload, &payload) != nil {
+		return ""
+	}
+	rater, _ := payload.Rater.(string)
+	return strings.TrimSpace(rater)
+}
+
+// MarkRatedPapersForReevaluation markiert alle Paper aus query, deren aktuelle Bewertung nicht mit
+// promptVersion erstellt wurde, zur Neubewertung. Bereits markierte Paper bleiben unverändert.
+// Liefert die Anzahl neu markierter Paper.
+func MarkRatedPapersForReevaluation(query *gorm.DB, promptVersion string) (int64, error) {
+	promptVersion = strings.TrimSpace(promptVersion)
+	if promptVersion == "" {
+		return 0, fmt.Errorf("%w: prompt_version required", ErrInvalidRevision)
+	}
+	res := query.Where("prompt_version <> ? AND needs_reevaluation = ?", promptVersion, false).
+		Updates(map[string]any{
+			"needs_reevaluation":  true,
+			"reevaluation_reason": "prompt_version " + promptVersion,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// BackfillRatedPaperRevisions legt für Paper ohne Revision (Bestand vor der Revisionierung) eine erste
+// Revision aus den gespeicherten Feldern an.
+func BackfillRatedPaperRevisions(db *gorm.DB) (int, error) {
+	created := 0
+	var lastID uint
+	for {
+		var papers []models.RatedPaper
+		if err := db.Where("id > ? AND current_revision_id IS NULL", lastID).Order("id").Limit(500).Find(&papers).Error; err != nil {
+			return created, err
+		}
+		if len(papers) == 0 {
+			return created, nil
+		}
+		for i := range papers {
+			lastID = papers[i].ID
+			p := &papers[i]
+			payload := map[string]any{
+				"doi": p.DOI, "rating": p.Rating, "confidence_score": p.ConfidenceScore, "category": p.Category,
+				"ai_summary": p.AiSummary, "key_findings": p.KeyFindings,
+				"study_strengths": p.StudyStrengths, "study_limitations": p.StudyLimitations,
+			}
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, _, err := RecordRatedPaperRevision(tx, p, payload)
+				return err
+			})
+			if err != nil {
+				return created, err
+			}
+			created++
+		}
+	}
+}
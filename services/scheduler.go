@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+
+	"paper-hand/models"
+)
+
+// Scheduler verwaltet die Cron-Einträge: einen globalen Lauf für alle Substanzen ohne eigenen Zeitplan
+// und je einen Eintrag pro aktiver Substanz mit eigenem Zeitplan.
+type Scheduler struct {
+	Fetch           *FetchService
+	Logger          *zap.Logger
+	DefaultSchedule string
+	// OnFinished wird nach jedem erfolgreichen geplanten Lauf mit der Anzahl neuer Paper aufgerufen.
+	OnFinished func(newPapers int)
+
+	ctx     context.Context
+	cron    *cron.Cron
+	mu      sync.Mutex
+	entries map[uint]cron.EntryID
+	loaded  substanceFingerprint // Stand der Substanzen beim letzten Reload
+}
+
+// scheduleSyncInterval ist der Abstand, in dem der Scheduler Substanz-Änderungen anderer Replikate übernimmt.
+const scheduleSyncInterval = "@every 1m"
+
+// substanceFingerprint erkennt Änderungen an der Substanz-Tabelle: die Anzahl fängt Löschungen ab,
+// der jüngste Zeitstempel Anlagen und Bearbeitungen.
+type substanceFingerprint struct {
+	Count     int64
+	UpdatedAt *time.Time
+}
+
+func (a substanceFingerprint) equal(b substanceFingerprint) bool {
+	if a.Count != b.Count || (a.UpdatedAt == nil) != (b.UpdatedAt == nil) {
+		return false
+	}
+	return a.UpdatedAt == nil || a.UpdatedAt.Equal(*b.UpdatedAt)
+}
+
+// NewScheduler erstellt einen neuen Scheduler. ctx ist der Lauf-Kontext der geplanten Jobs.
+func NewScheduler(ctx context.Context, fetch *FetchService, defaultSchedule string, logger *zap.Logger, onFinished func(int)) *Scheduler {
+	return &Scheduler{
+		Fetch:           fetch,
+		Logger:          logger,
+		DefaultSchedule: defaultSchedule,
+		OnFinished:      onFinished,
+		ctx:             ctx,
+		cron:            cron.New(),
+		entries:         make(map[uint]cron.EntryID),
+	}
+}
+
+// ValidateSchedule prüft einen Cron-Ausdruck oder ein Intervall ("@every 24h", "@weekly").
+func ValidateSchedule(expr string) error {
+	_, err := cron.ParseStandard(expr)
+	return err
+}
+
+// Start registriert den globalen Lauf sowie die Substanz-Einträge und startet den Cron.
+func (s *Scheduler) Start() error {
+	if _, err := s.cron.AddFunc(s.DefaultSchedule, s.runDefault); err != nil {
+		return err
+	}
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	// Änderungen über die API landen per Reload nur im bearbeitenden Replikat; die übrigen gleichen periodisch ab.
+	if _, err := s.cron.AddFunc(scheduleSyncInterval, s.syncSubstances); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// AddFunc registriert einen weiteren periodischen Hintergrundjob (z.B. Embedding-Aktualisierung).
+func (s *Scheduler) AddFunc(schedule string, fn func()) error {
+	_, err := s.cron.AddFunc(schedule, fn)
+	return err
+}
+
+// Stop stoppt den Cron; der zurückgegebene Kontext ist erledigt, sobald laufende Cron-Jobs beendet sind.
+func (s *Scheduler) Stop() context.Context {
+	return s.cron.Stop()
+}
+
+// Reload gleicht die Substanz-Einträge mit der Datenbank ab. Wird nach lokalen Änderungen an Substanzen
+// direkt aufgerufen, Änderungen anderer Replikate übernimmt syncSubstances.
+func (s *Scheduler) Reload() error {
+	fp, err := s.fingerprint()
+	if err != nil {
+		return err
+	}
+	var substances []models.Substance
+	if err := s.Fetch.DB.Where("active = ? AND schedule <> ''", true).Find(&substances).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, entry := range s.entries {
+		s.cron.Remove(entry)
+		delete(s.entries, id)
+	}
+	for _, sub := range substances {
+		id := sub.ID
+		entry, err := s.cron.AddFunc(sub.Schedule, func() { s.runSubstance(id) })
+		if err != nil {
+			s.Logger.Warn("Ungültiger Zeitplan für Substanz, wird ignoriert",
+				zap.String("substance", sub.Name), zap.String("schedule", sub.Schedule), zap.Error(err))
+			continue
+		}
+		s.entries[id] = entry
+	}
+	s.loaded = fp
+	s.Logger.Info("Substanz-Zeitpläne geladen", zap.Int("scheduled_substances", len(s.entries)))
+	return nil
+}
+
+// fingerprint liest Anzahl und jüngsten Änderungszeitpunkt der Substanzen.
+func (s *Scheduler) fingerprint() (substanceFingerprint, error) {
+	var fp substanceFingerprint
+	err := s.Fetch.DB.Model(&models.Substance{}).
+		Select("COUNT(*) AS count, MAX(updated_at) AS updated_at").
+		Scan(&fp).Error
+	return fp, err
+}
+
+// syncSubstances lädt die Zeitpläne neu, wenn sich die Substanzen seit dem letzten Reload geändert haben.
+func (s *Scheduler) syncSubstances() {
+	fp, err := s.fingerprint()
+	if err != nil {
+		s.Logger.Warn("Konnte Substanz-Stand nicht prüfen", zap.Error(err))
+		return
+	}
+	s.mu.Lock()
+	unchanged := fp.equal(s.loaded)
+	s.mu.Unlock()
+	if unchanged {
+		return
+	}
+	if err := s.Reload(); err != nil {
+		s.Logger.Warn("Konnte Substanz-Zeitpläne nicht neu laden", zap.Error(err))
+	}
+}
+
+// runDefault führt den globalen Lauf über alle Substanzen ohne eigenen Zeitplan aus.
+func (s *Scheduler) runDefault() {
+	s.Logger.Info("Running scheduled fetch job...")
+	count, err := s.Fetch.RunAllSubstances(s.ctx, TriggerCron)
+	s.finish(AllSubstancesLockKey, count, err)
+}
+
+// runSubstance führt den geplanten Lauf einer einzelnen Substanz aus.
+func (s *Scheduler) runSubstance(id uint) {
+	// Aktuellen Stand laden: die Substanz kann inzwischen pausiert oder gelöscht worden sein.
+	var sub models.Substance
+	if err := s.Fetch.DB.First(&sub, id).Error; err != nil || !sub.Active {
+		return
+	}
+	s.Logger.Info("Running scheduled fetch job for substance", zap.String("substance", sub.Name))
+	count, err := s.Fetch.RunForSubstance(s.ctx, sub, TriggerCron)
+	s.finish(SubstanceLockKey(sub.ID), count, err)
+}
+
+func (s *Scheduler) finish(key string, count int, err error) {
+	var running *JobRunningError
+	switch {
+	case errors.As(err, &running):
+		s.Logger.Info("Skipping scheduled fetch job, another run is in progress", zap.String("lock_key", running.Key))
+	case err != nil:
+		s.Logger.Error("Cron job failed", zap.String("lock_key", key), zap.Error(err))
+	default:
+		s.Logger.Info("Cron job completed", zap.String("lock_key", key), zap.Int("new_papers", count))
+		if s.OnFinished != nil {
+			s.OnFinished(count)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"paper-hand/identifiers"
+	"paper-hand/models"
+	"paper-hand/providers"
+)
+
+// SnowballLockKey ist der Lock-Schlüssel der Snowball-Suche (ein Lauf gleichzeitig im Cluster).
+const SnowballLockKey = "snowball"
+
+// Richtungen der Snowball-Suche: backward = Referenzen, forward = zitierende Arbeiten.
+const (
+	SnowballBackward = "backward"
+	SnowballForward  = "forward"
+	SnowballBoth     = "both"
+
+	// MaxSnowballDepth begrenzt die Tiefe; ab Tiefe 3 wächst die Treffermenge meist ins Uferlose.
+	MaxSnowballDepth = 3
+)
+
+// ErrInvalidSnowball kennzeichnet ungültige Optionen (HTTP 400).
+var ErrInvalidSnowball = errors.New("invalid snowball options")
+
+// SnowballOptions steuert einen Lauf. Nullwerte werden durch die Defaults des Service ersetzt.
+type SnowballOptions struct {
+	MinRating float64  `json:"min_rating"`          // Seeds: rated_papers mit Rating >= Wert
+	Depth     int      `json:"depth"`               // 1 = direkte Referenzen/Zitierungen der Seeds
+	Direction string   `json:"direction"`           // backward, forward oder both
+	Substance string   `json:"substance,omitempty"` // nur Seeds dieser Substanz
+	SeedDOIs  []string `json:"seed_dois,omitempty"` // nur diese rated_papers (Rating-Filter gilt weiterhin)
+	MaxPapers int      `json:"max_papers"`          // Obergrenze neu verarbeiteter Paper je Lauf
+}
+
+// SnowballResult fasst einen Lauf zusammen.
+type SnowballResult struct {
+	Seeds      int  `json:"seeds"`
+	Discovered int  `json:"discovered"` // verschiedene gefundene Arbeiten
+	NewPapers  int  `json:"new_papers"` // neu verarbeitet (Download & Upload)
+	Known      int  `json:"known"`      // bereits im Bestand
+	Failed     int  `json:"failed"`
+	Truncated  bool `json:"truncated"` // max_papers erreicht
+}
+
+// SnowballService verfolgt Referenzen und Zitierungen hoch bewerteter rated_papers und übernimmt die
+// gefundenen Arbeiten über den FetchService (Herkunft "snowball").
+type SnowballService struct {
+	Fetch    *FetchService
+	RatedDB  *gorm.DB
+	Sources  []providers.Provider // nur Provider, die providers.CitationSource implementieren
+	Defaults SnowballOptions
+}
+
+// NewSnowballService erstellt einen neuen SnowballService mit den Zitationsquellen des FetchService.
+func NewSnowballService(fetch *FetchService, ratedDB *gorm.DB, defaults SnowballOptions) *SnowballService {
+	var sources []providers.Provider
+	for _, p := range fetch.Providers {
+		if _, ok := p.(providers.CitationSource); ok {
+			sources = append(sources, p)
+		}
+	}
+	return &SnowballService{Fetch: fetch, RatedDB: ratedDB, Sources: sources, Defaults: defaults}
+}
+
+// Options ergänzt fehlende Werte aus den Defaults und prüft das Ergebnis.
+func (s *SnowballService) Options(opts SnowballOptions) (SnowballOptions, error) {
+	if opts.MinRating == 0 {
+		opts.MinRating = s.Defaults.MinRating
+	}
+	if opts.Depth == 0 {
+		opts.Depth = s.Defaults.Depth
+	}
+	if opts.Direction == "" {
+		opts.Direction = SnowballBoth
+	}
+	if opts.MaxPapers == 0 {
+		opts.MaxPapers = s.Defaults.MaxPapers
+	}
+	opts.Substance = strings.TrimSpace(opts.Substance)
+	switch {
+	case opts.Depth < 1 || opts.Depth > MaxSnowballDepth:
+		return opts, fmt.Errorf("%w: depth must be between 1 and %d", ErrInvalidSnowball, MaxSnowballDepth)
+	case opts.Direction != SnowballBackward && opts.Direction != SnowballForward && opts.Direction != SnowballBoth:
+		return opts, fmt.Errorf("%w: direction must be backward, forward or both", ErrInvalidSnowball)
+	case opts.MinRating < 0:
+		return opts, fmt.Errorf("%w: min_rating must not be negative", ErrInvalidSnowball)
+	case opts.MaxPapers < 0:
+		return opts, fmt.Errorf("%w: max_papers must not be negative", ErrInvalidSnowball)
+	}
+	return opts, nil
+}
+
+// Run führt einen Lauf unter dem cluster-weiten Snowball-Lock aus; der Lauf erscheint als FetchJob.
+// Ein unterbrochener Lauf wird nicht fortgesetzt: ein neuer Lauf überspringt bereits gespeicherte Paper.
+func (s *SnowballService) Run(ctx context.Context, opts SnowballOptions, trigger string) (*SnowballResult, error) {
+	opts, err := s.Options(opts)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := s.Fetch.Locker.TryAcquire(ctx, SnowballLockKey, opts.Substance, trigger)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.run(ctx, lock.Job.ID, opts)
+	lock.Release(result.NewPapers, err)
+	return result, err
+}
+
+// Start sichert den Lock synchron und führt den Lauf im Hintergrund aus; done wird mit dem Ergebnis aufgerufen.
+func (s *SnowballService) Start(ctx context.Context, opts SnowballOptions, trigger string, done func(*SnowballResult, error)) (*models.FetchJob, error) {
+	opts, err := s.Options(opts)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := s.Fetch.Locker.TryAcquire(ctx, SnowballLockKey, opts.Substance, trigger)
+	if err != nil {
+		return nil, err
+	}
+	s.Fetch.jobs.Add(1)
+	go func() {
+		defer s.Fetch.jobs.Done()
+		result, err := s.run(ctx, lock.Job.ID, opts)
+		lock.Release(result.NewPapers, err)
+		done(result, err)
+	}()
+	return lock.Job, nil
+}
+
+// snowballNode ist ein Paper, dessen Referenzen bzw. Zitierungen verfolgt werden.
+type snowballNode struct {
+	Paper      *models.Paper // Identifier für die Zitationsquellen
+	SeedDOI    string
+	Substances []models.Substance // werden an gefundene Paper vererbt
+}
+
+// snowballCandidate ist eine gefundene Arbeit samt Weg dorthin.
+type snowballCandidate struct {
+	Group     referenceGroup
+	Direction string
+}
+
+// run verfolgt die Seeds per Breitensuche bis opts.Depth. Jede Arbeit wird pro Lauf nur einmal betrachtet.
+func (s *SnowballService) run(ctx context.Context, jobID uint, opts SnowballOptions) (*SnowballResult, error) {
+	log := s.Fetch.Logger.With(zap.Uint("job_id", jobID))
+	result := &SnowballResult{}
+	if len(s.Sources) == 0 {
+		log.Warn("Keine Zitationsquelle aktiv, Snowball-Suche übersprungen")
+		return result, nil
+	}
+	frontier, err := s.seeds(opts)
+	if err != nil {
+		return result, err
+	}
+	result.Seeds = len(frontier)
+	log.Info("Starte Snowball-Suche", zap.Int("seeds", len(frontier)), zap.Int("depth", opts.Depth), zap.String("direction", opts.Direction))
+
+	// Zitationsquellen brauchen PMID oder PMCID; Seeds ohne Paper in rawDB darüber nachschlagen
+	for _, node := range frontier {
+		if node.Paper.PMID == "" && node.Paper.PMCID == "" {
+			found := s.lookup(ctx, log, referenceGroup{}, providers.Reference{DOI: node.Paper.DOI})
+			node.Paper.PMID, node.Paper.PMCID = found.PMID, found.PMCID
+		}
+	}
+
+	visited := make(map[string]bool)
+	for _, node := range frontier {
+		for _, k := range referenceKeys(providers.Reference{PMID: node.Paper.PMID, DOI: node.Paper.DOI}) {
+			visited[k] = true
+		}
+	}
+
+	var mu sync.Mutex
+	for depth := 1; depth <= opts.Depth && len(frontier) > 0; depth++ {
+		var next []snowballNode
+		for _, node := range frontier {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			candidates := s.expand(ctx, log, node, opts.Direction, visited)
+			result.Discovered += len(candidates)
+
+			var wg sync.WaitGroup
+			semaphore := make(chan struct{}, 5) // wie bei der Substanzsuche höchstens 5 parallele Verarbeitungen
+			for _, cand := range candidates {
+				mu.Lock()
+				full := opts.MaxPapers > 0 && result.NewPapers >= opts.MaxPapers
+				mu.Unlock()
+				if full {
+					result.Truncated = true
+					break
+				}
+				select {
+				case semaphore <- struct{}{}:
+				case <-ctx.Done():
+				}
+				if ctx.Err() != nil {
+					break
+				}
+				wg.Add(1)
+				go func(cand snowballCandidate) {
+					defer wg.Done()
+					defer func() { <-semaphore }()
+					paper, known, ok := s.ingest(ctx, log, jobID, node, cand, depth)
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case !ok:
+						result.Failed++
+					case known:
+						result.Known++
+					default:
+						result.NewPapers++
+					}
+					if ok && depth < opts.Depth {
+						next = append(next, snowballNode{Paper: paper, SeedDOI: node.SeedDOI, Substances: node.Substances})
+					}
+				}(cand)
+			}
+			wg.Wait()
+			if result.Truncated {
+				break
+			}
+		}
+		if result.Truncated {
+			break
+		}
+		frontier = next
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	log.Info("Snowball-Suche abgeschlossen", zap.Int("seeds", result.Seeds), zap.Int("discovered", result.Discovered),
+		zap.Int("new_papers", result.NewPapers), zap.Int("known", result.Known), zap.Int("failed", result.Failed), zap.Bool("truncated", result.Truncated))
+	return result, nil
+}
+
+// seeds lädt die hoch bewerteten rated_papers samt zugehörigem Paper aus rawDB (falls vorhanden).
+func (s *SnowballService) seeds(opts SnowballOptions) ([]snowballNode, error) {
+	query := s.RatedDB.Model(&models.RatedPaper{}).Where("rating >= ?", opts.MinRating).Order("rating DESC, id")
+	if len(opts.SeedDOIs) > 0 {
+		var dois []string
+		for _, d := range opts.SeedDOIs {
+			if doi := identifiers.NormalizeDOI(d); doi != "" {
+				dois = append(dois, doi)
+			}
+		}
+		query = query.Where("LOWER(doi) IN ?", dois)
+	}
+	var dois []string
+	if err := query.Pluck("doi", &dois).Error; err != nil {
+		return nil, err
+	}
+
+	var nodes []snowballNode
+	for _, raw := range dois {
+		doi := identifiers.NormalizeDOI(raw)
+		if doi == "" {
+			continue
+		}
+		node := snowballNode{Paper: &models.Paper{DOI: doi}, SeedDOI: doi}
+		if found, err := FindPaper(s.Fetch.DB, identifiers.ID{Kind: identifiers.DOI, Value: doi}); err == nil {
+			var paper models.Paper
+			if err := s.Fetch.DB.Preload("Substances").First(&paper, found.ID).Error; err != nil {
+				return nil, err
+			}
+			node.Paper = &models.Paper{ID: paper.ID, PMID: paper.PMID, DOI: paper.DOI, PMCID: paper.PMCID}
+			node.Substances = paper.Substances
+			if len(node.Substances) == 0 && paper.Substance != "" {
+				var sub models.Substance
+				if s.Fetch.DB.Where("name = ?", paper.Substance).First(&sub).Error == nil {
+					node.Substances = []models.Substance{{ID: sub.ID, Name: sub.Name}}
+				}
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if opts.Substance != "" && !hasSubstance(node.Substances, opts.Substance) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func hasSubstance(subs []models.Substance, name string) bool {
+	for _, sub := range subs {
+		if strings.EqualFold(sub.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand fragt Referenzen bzw. Zitierungen eines Knotens ab, schreibt sie als Kanten nach paper_links
+// und liefert die noch nicht besuchten Arbeiten.
+func (s *SnowballService) expand(ctx context.Context, log *zap.Logger, node snowballNode, direction string, visited map[string]bool) []snowballCandidate {
+	var refs, citers []providers.Reference
+	for _, p := range s.Sources {
+		source := p.(providers.CitationSource)
+		if direction != SnowballForward {
+			r, err := source.References(ctx, node.Paper)
+			if err != nil {
+				log.Warn("Referenzen konnten nicht abgerufen werden", zap.String("provider", p.Name()), zap.String("doi", node.Paper.DOI), zap.Error(err))
+			}
+			refs = append(refs, r...)
+		}
+		if direction != SnowballBackward {
+			c, err := source.CitedBy(ctx, node.Paper)
+			if err != nil {
+				log.Warn("Zitierungen konnten nicht abgerufen werden", zap.String("provider", p.Name()), zap.String("doi", node.Paper.DOI), zap.Error(err))
+			}
+			citers = append(citers, c...)
+		}
+	}
+
+	self := providers.Reference{PMID: node.Paper.PMID, DOI: node.Paper.DOI}
+	selfEnd := LinkEndpoint{DOI: node.Paper.DOI, PMID: node.Paper.PMID}
+	var candidates []snowballCandidate
+	collect := func(groups []referenceGroup, dir string) {
+		for _, group := range groups {
+			if sameWork(self, group.Ref) {
+				continue
+			}
+			// Kante festhalten, auch wenn die Arbeit schon besucht wurde
+			in := EdgeInput{Source: selfEnd, Target: group.endpoint(), Evidence: group.evidence()}
+			if dir == SnowballForward {
+				in.Source, in.Target = group.endpoint(), selfEnd
+			}
+			if _, err := UpsertPaperLink(s.Fetch.DB, in); err != nil {
+				log.Warn("Kante konnte nicht gespeichert werden", zap.String("doi", node.Paper.DOI), zap.Error(err))
+			}
+
+			keys := referenceKeys(group.Ref)
+			seen := false
+			for _, k := range keys {
+				seen = seen || visited[k]
+				visited[k] = true
+			}
+			if !seen {
+				candidates = append(candidates, snowballCandidate{Group: group, Direction: dir})
+			}
+		}
+	}
+	collect(mergeReferences(refs), SnowballBackward)
+	collect(mergeReferences(citers), SnowballForward)
+	return candidates
+}
+
+// ingest übernimmt eine gefundene Arbeit: bekannte, bereits gespeicherte Paper erhalten nur die Substanzen
+// des Seeds, alle anderen werden über die Provider nachgeschlagen und per processPaper verarbeitet.
+// Liefert das Paper (für die nächste Tiefe), ob es bereits im Bestand war und ob es abgeschlossen wurde.
+func (s *SnowballService) ingest(ctx context.Context, log *zap.Logger, jobID uint, node snowballNode, cand snowballCandidate, depth int) (*models.Paper, bool, bool) {
+	ref := cand.Group.Ref
+	for _, part := range cand.Group.Parts {
+		if ref.PMCID == "" {
+			ref.PMCID = part.PMCID
+		}
+	}
+	log = log.With(zap.String("pmid", ref.PMID), zap.String("doi", ref.DOI))
+
+	known := false
+	var paper *models.Paper
+	existing, err := FindPaper(s.Fetch.DB, PaperIDs(&models.Paper{PMID: ref.PMID, DOI: ref.DOI, PMCID: ref.PMCID})...)
+	if err == nil && existing.CloudStored {
+		known = true
+		paper = &models.Paper{PMID: existing.PMID, DOI: existing.DOI, PMCID: existing.PMCID}
+	} else {
+		paper = s.lookup(ctx, log, cand.Group, ref)
+		known = err == nil
+	}
+	if ctx.Err() != nil {
+		return nil, false, false
+	}
+
+	sources := make([]string, 0, len(cand.Group.Parts))
+	for _, part := range cand.Group.Parts {
+		if !containsString(sources, part.Origin) {
+			sources = append(sources, part.Origin)
+		}
+	}
+	paper.Provenance = models.ProvenanceSnowball
+	paper.Snowball = &models.SnowballOrigin{
+		JobID: jobID, SeedDOI: node.SeedDOI, ViaDOI: node.Paper.DOI, ViaPMID: node.Paper.PMID,
+		Direction: cand.Direction, Depth: depth, Sources: sources,
+	}
+	paper.Substances = node.Substances
+	if paper.Substance == "" && len(node.Substances) > 0 {
+		paper.Substance = node.Substances[0].Name
+	}
+
+	processed, _, paperID := s.Fetch.ingestPaper(ctx, log, paper)
+	if !processed {
+		return nil, false, false
+	}
+	paper.ID = paperID
+	return paper, known, true
+}
+
+// lookup holt Metadaten und Download-Links der Arbeit bei allen Providern, die Identifier-Abfragen
+// unterstützen, und führt sie nach Provider-Präzedenz zusammen. Kennt kein Provider die Arbeit,
+// bleibt es bei den Angaben der Zitationsquelle (Download dann nur über Unpaywall).
+func (s *SnowballService) lookup(ctx context.Context, log *zap.Logger, group referenceGroup, ref providers.Reference) *models.Paper {
+	var records []providerRecord
+	for _, p := range s.Fetch.Providers {
+		l, ok := p.(providers.Lookup)
+		if !ok {
+			continue
+		}
+		paper, err := l.LookupPaper(ctx, providers.PaperID{PMID: ref.PMID, DOI: ref.DOI})
+		if err != nil {
+			log.Warn("Provider-Abfrage fehlgeschlagen", zap.String("provider", p.Name()), zap.Error(err))
+			continue
+		}
+		if paper != nil {
+			records = append(records, providerRecord{Provider: p.Name(), Paper: paper})
+		}
+	}
+	if merged := mergeProviderRecords(records, s.Fetch.Precedence); len(merged) > 0 {
+		paper := merged[0]
+		for _, field := range []struct {
+			dst *string
+			src string
+		}{{&paper.PMID, ref.PMID}, {&paper.DOI, ref.DOI}, {&paper.PMCID, ref.PMCID}} {
+			if *field.dst == "" {
+				*field.dst = field.src
+			}
+		}
+		return paper
+	}
+
+	paper := &models.Paper{PMID: ref.PMID, DOI: ref.DOI, PMCID: ref.PMCID}
+	for _, part := range group.Parts {
+		if paper.Title == "" {
+			paper.Title = part.Title
+		}
+		if paper.StudyDate == nil && part.Year > 0 {
+			paper.StudyDate = timePtr(time.Date(part.Year, time.January, 1, 0, 0, 0, 0, time.UTC))
+		}
+	}
+	return paper
+}
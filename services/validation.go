@@ -0,0 +1,319 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrValidation kennzeichnet Eingaben, die die Validierung nicht bestehen (HTTP 400 mit Fehlerliste).
+var ErrValidation = errors.New("validation failed")
+
+// FieldError ist ein maschinenlesbarer Validierungsfehler zu einem Feld (Pfad in Punktnotation,
+// z.B. appraisal.outcomes[0].direction).
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // required, type, range, enum, unknown_field, shape
+	Message string `json:"message"`
+}
+
+// ValidationErrors sammelt alle Fehler einer Eingabe.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	parts := make([]string, 0, len(v))
+	for _, e := range v {
+		parts = append(parts, e.Field+": "+e.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Unwrap erlaubt errors.Is(err, ErrValidation).
+func (v ValidationErrors) Unwrap() error { return ErrValidation }
+
+func (v *ValidationErrors) add(field, code, message string) {
+	*v = append(*v, FieldError{Field: field, Code: code, Message: message})
+}
+
+// normalizeEnum vereinheitlicht Aufzählungswerte: klein, Leerzeichen und Bindestriche als Unterstrich.
+func normalizeEnum(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(s)
+}
+
+// Validierungsmodi für eingehende Bewertungen.
+const (
+	ValidationStrict  = "strict"  // ungültige Eingaben werden mit 400 und Fehlerliste abgelehnt
+	ValidationLenient = "lenient" // ungültige Eingaben werden gespeichert, aber in Quarantäne gestellt
+)
+
+// ValidationModes sind die zulässigen Werte für RATED_PAPER_VALIDATION und ?validation=.
+var ValidationModes = []string{ValidationStrict, ValidationLenient}
+
+// Feldarten einer FieldRule.
+const (
+	FieldString     = "string"      // Text; Zahlen und Booleans werden als Text übernommen
+	FieldNumber     = "number"      // Zahl; numerische Strings werden umgewandelt
+	FieldBool       = "bool"        // Boolean; "true"/"false"/"1"/"0" werden umgewandelt
+	FieldStringList = "string_list" // Liste von Strings, auch als JSON-String; wird als JSON-String gespeichert
+	FieldDocument   = "document"    // JSON-Objekt oder -Array, auch als JSON-String
+)
+
+// FieldRule beschreibt ein zulässiges Feld eines Payloads.
+type FieldRule struct {
+	Field string
+	Kind  string
+	Min   *float64
+	Max   *float64
+	Enum  []string // erlaubte Werte (leer = beliebig)
+	// Nested validiert und normalisiert verschachtelte Strukturen selbst (Kind wird dann ignoriert)
+	Nested func(v any, field string) ValidationErrors
+}
+
+// PayloadSchema ist ein deklaratives Schema für freie JSON-Payloads (z.B. aus n8n).
+type PayloadSchema struct {
+	Rules         []FieldRule
+	Required      []string            // Pflichtfelder
+	RequiredOneOf [][]string          // je Gruppe mindestens ein Feld
+	StatusField   string              // Feld, nach dem RequiredBy unterscheidet
+	RequiredBy    map[string][]string // Status → zusätzliche Pflichtfelder
+}
+
+// Validate prüft raw gegen das Schema und liefert eine normalisierte Kopie (Typen umgewandelt, Strings
+// getrimmt) sowie alle Fehler. Unbekannte Felder sind Fehler.
+func (s PayloadSchema) Validate(raw map[string]any) (map[string]any, ValidationErrors) {
+	var errs ValidationErrors
+	out := make(map[string]any, len(raw))
+	rules := make(map[string]FieldRule, len(s.Rules))
+	for _, r := range s.Rules {
+		rules[r.Field] = r
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		rule, ok := rules[k]
+		if !ok {
+			errs.add(k, "unknown_field", "unknown field")
+			continue
+		}
+		if raw[k] == nil {
+			continue
+		}
+		if rule.Nested != nil {
+			errs = append(errs, rule.Nested(raw[k], k)...)
+			out[k] = raw[k]
+			continue
+		}
+		if v, ok := rule.normalize(raw[k], &errs); ok {
+			out[k] = v
+		}
+	}
+
+	present := func(field string) bool {
+		v, ok := out[field]
+		if !ok {
+			return false
+		}
+		str, isString := v.(string)
+		return !isString || str != ""
+	}
+	required := append([]string(nil), s.Required...)
+	if s.StatusField != "" {
+		if status, _ := out[s.StatusField].(string); status != "" {
+			required = append(required, s.RequiredBy[status]...)
+		}
+	}
+	for _, field := range required {
+		if !present(field) && !hasField(errs, field) {
+			errs.add(field, "required", "required")
+		}
+	}
+	for _, group := range s.RequiredOneOf {
+		found := false
+		for _, field := range group {
+			found = found || present(field)
+		}
+		if !found {
+			errs.add(group[0], "required", "one of "+strings.Join(group, ", ")+" is required")
+		}
+	}
+	return out, errs
+}
+
+// normalize wandelt v in den Typ der Regel um und prüft Bereich und erlaubte Werte.
+func (r FieldRule) normalize(v any, errs *ValidationErrors) (any, bool) {
+	switch r.Kind {
+	case FieldNumber:
+		var f float64
+		switch t := v.(type) {
+		case float64:
+			f = t
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				errs.add(r.Field, "type", "must be a number")
+				return nil, false
+			}
+			f = parsed
+		default:
+			errs.add(r.Field, "type", "must be a number")
+			return nil, false
+		}
+		if (r.Min != nil && f < *r.Min) || (r.Max != nil && f > *r.Max) {
+			errs.add(r.Field, "range", fmt.Sprintf("must be between %s and %s", formatBound(r.Min), formatBound(r.Max)))
+			return nil, false
+		}
+		return f, true
+	case FieldBool:
+		switch t := v.(type) {
+		case bool:
+			return t, true
+		case string:
+			switch strings.ToLower(strings.TrimSpace(t)) {
+			case "true", "1":
+				return true, true
+			case "false", "0":
+				return false, true
+			}
+		case float64:
+			if t == 0 || t == 1 {
+				return t == 1, true
+			}
+		}
+		errs.add(r.Field, "type", "must be a boolean")
+		return nil, false
+	case FieldStringList:
+		list := v
+		if s, ok := v.(string); ok {
+			if strings.TrimSpace(s) == "" {
+				return "", true
+			}
+			if json.Unmarshal([]byte(s), &list) != nil {
+				errs.add(r.Field, "shape", "must be a JSON array of strings")
+				return nil, false
+			}
+		}
+		items, ok := list.([]any)
+		if !ok {
+			errs.add(r.Field, "shape", "must be a JSON array of strings")
+			return nil, false
+		}
+		strs := make([]string, 0, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				errs.add(fmt.Sprintf("%s[%d]", r.Field, i), "type", "must be a string")
+				return nil, false
+			}
+			strs = append(strs, strings.TrimSpace(s))
+		}
+		b, _ := json.Marshal(strs)
+		return string(b), true
+	case FieldDocument:
+		doc := v
+		if s, ok := v.(string); ok {
+			if json.Unmarshal([]byte(s), &doc) != nil {
+				errs.add(r.Field, "shape", "must be a JSON object or array")
+				return nil, false
+			}
+		}
+		switch doc.(type) {
+		case map[string]any, []any:
+			return doc, true
+		}
+		errs.add(r.Field, "shape", "must be a JSON object or array")
+		return nil, false
+	default:
+		var s string
+		switch t := v.(type) {
+		case string:
+			s = strings.TrimSpace(t)
+		case float64:
+			s = strconv.FormatFloat(t, 'f', -1, 64)
+		case bool:
+			s = strconv.FormatBool(t)
+		default:
+			errs.add(r.Field, "type", "must be a string")
+			return nil, false
+		}
+		if s == "" || len(r.Enum) == 0 {
+			return s, true
+		}
+		// Groß-/Kleinschreibung tolerieren, gespeichert wird die kanonische Schreibweise
+		for _, allowed := range r.Enum {
+			if strings.EqualFold(allowed, s) {
+				return allowed, true
+			}
+		}
+		errs.add(r.Field, "enum", "must be one of "+strings.Join(r.Enum, ", "))
+		return nil, false
+	}
+}
+
+func formatBound(b *float64) string {
+	if b == nil {
+		return "∞"
+	}
+	return strconv.FormatFloat(*b, 'f', -1, 64)
+}
+
+func hasField(errs ValidationErrors, field string) bool {
+	for _, e := range errs {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func float64Ptr(f float64) *float64 { return &f }
+
+// RatedPaperSchema ist das Schema für POST /rated-papers. categories schränkt category ein (leer = beliebig).
+// content_status muss ein Zustand aus workflow sein; dessen Guards sind je Status zusätzlich Pflicht
+// (Standard: idee → content_idea, produziert → content_url).
+func RatedPaperSchema(categories []string, workflow *Workflow) PayloadSchema {
+	text := func(names ...string) []FieldRule {
+		rules := make([]FieldRule, 0, len(names))
+		for _, n := range names {
+			rules = append(rules, FieldRule{Field: n, Kind: FieldString})
+		}
+		return rules
+	}
+	rules := text("doi", "pmid", "pmid_pdf_id", "s3_link", "ai_summary", "study_strengths", "study_limitations",
+		"content_idea", "content_url", "outline", "citations", "deep_research",
+		"model", "model_name", "prompt_version", "rater")
+	rules = append(rules,
+		FieldRule{Field: "rating", Kind: FieldNumber, Min: float64Ptr(0), Max: float64Ptr(10)},
+		FieldRule{Field: "confidence_score", Kind: FieldNumber, Min: float64Ptr(0), Max: float64Ptr(1)},
+		FieldRule{Field: "category", Kind: FieldString, Enum: categories},
+		FieldRule{Field: "content_status", Kind: FieldString, Enum: workflow.States()},
+		FieldRule{Field: "key_findings", Kind: FieldStringList},
+		FieldRule{Field: "references_json", Kind: FieldDocument},
+		FieldRule{Field: "processed", Kind: FieldBool},
+		FieldRule{Field: "added_rag", Kind: FieldBool},
+		FieldRule{Field: "appraisal", Nested: func(v any, field string) ValidationErrors {
+			if _, err := DecodeAppraisal(v, field); err != nil {
+				var verrs ValidationErrors
+				if errors.As(err, &verrs) {
+					return verrs
+				}
+				return ValidationErrors{{Field: field, Code: "shape", Message: err.Error()}}
+			}
+			return nil
+		}},
+	)
+	return PayloadSchema{
+		Rules:         rules,
+		Required:      []string{"rating", "category"},
+		RequiredOneOf: [][]string{{"doi", "pmid", "pmid_pdf_id"}},
+		StatusField:   "content_status",
+		RequiredBy:    workflow.Guards,
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPayloadSchemaValidate(t *testing.T) {
+	schema := PayloadSchema{
+		Rules: []FieldRule{
+			{Field: "doi", Kind: FieldString},
+			{Field: "pmid", Kind: FieldString},
+			{Field: "rating", Kind: FieldNumber, Min: float64Ptr(0), Max: float64Ptr(10)},
+			{Field: "score", Kind: FieldNumber, Min: float64Ptr(0)},
+			{Field: "category", Kind: FieldString, Enum: []string{"RCT", "Review"}},
+			{Field: "status", Kind: FieldString},
+			{Field: "url", Kind: FieldString},
+			{Field: "processed", Kind: FieldBool},
+			{Field: "key_findings", Kind: FieldStringList},
+			{Field: "references", Kind: FieldDocument},
+		},
+		Required:      []string{"rating"},
+		RequiredOneOf: [][]string{{"doi", "pmid"}},
+		StatusField:   "status",
+		RequiredBy:    map[string][]string{"published": {"url"}},
+	}
+
+	tests := []struct {
+		name     string
+		raw      map[string]any
+		wantOut  map[string]any
+		wantErrs ValidationErrors
+	}{
+		{
+			name:    "valid payload is normalized",
+			raw:     map[string]any{"doi": " 10.1000/abc ", "rating": "7.5", "category": "rct", "processed": "1", "key_findings": `["a ", " b"]`},
+			wantOut: map[string]any{"doi": "10.1000/abc", "rating": 7.5, "category": "RCT", "processed": true, "key_findings": `["a","b"]`},
+		},
+		{
+			name:    "numbers and booleans as text",
+			raw:     map[string]any{"pmid": float64(123), "rating": float64(0), "processed": float64(0)},
+			wantOut: map[string]any{"pmid": "123", "rating": float64(0), "processed": false},
+		},
+		{
+			name:    "null fields are dropped",
+			raw:     map[string]any{"doi": "10.1000/abc", "rating": float64(5), "category": nil},
+			wantOut: map[string]any{"doi": "10.1000/abc", "rating": float64(5)},
+		},
+		{
+			name:     "number type error",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": "high"},
+			wantOut:  map[string]any{"doi": "10.1000/abc"},
+			wantErrs: ValidationErrors{{Field: "rating", Code: "type", Message: "must be a number"}},
+		},
+		{
+			name:     "non-finite number",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": "NaN"},
+			wantOut:  map[string]any{"doi": "10.1000/abc"},
+			wantErrs: ValidationErrors{{Field: "rating", Code: "type", Message: "must be a number"}},
+		},
+		{
+			name:     "above range",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": float64(11)},
+			wantOut:  map[string]any{"doi": "10.1000/abc"},
+			wantErrs: ValidationErrors{{Field: "rating", Code: "range", Message: "must be between 0 and 10"}},
+		},
+		{
+			name:     "below open range",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": float64(1), "score": float64(-1)},
+			wantOut:  map[string]any{"doi": "10.1000/abc", "rating": float64(1)},
+			wantErrs: ValidationErrors{{Field: "score", Code: "range", Message: "must be between 0 and ∞"}},
+		},
+		{
+			name:     "range bounds are inclusive",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": float64(10), "score": float64(0)},
+			wantOut:  map[string]any{"doi": "10.1000/abc", "rating": float64(10), "score": float64(0)},
+			wantErrs: nil,
+		},
+		{
+			name:    "type errors per kind",
+			raw:     map[string]any{"doi": []any{"x"}, "rating": float64(5), "processed": "yes", "key_findings": []any{"a", float64(1)}, "references": "42"},
+			wantOut: map[string]any{"rating": float64(5)},
+			wantErrs: ValidationErrors{
+				{Field: "doi", Code: "type", Message: "must be a string"},
+				{Field: "key_findings[1]", Code: "type", Message: "must be a string"},
+				{Field: "processed", Code: "type", Message: "must be a boolean"},
+				{Field: "references", Code: "shape", Message: "must be a JSON object or array"},
+				{Field: "doi", Code: "required", Message: "one of doi, pmid is required"},
+			},
+		},
+		{
+			name:     "enum",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": float64(5), "category": "Kohorte"},
+			wantOut:  map[string]any{"doi": "10.1000/abc", "rating": float64(5)},
+			wantErrs: ValidationErrors{{Field: "category", Code: "enum", Message: "must be one of RCT, Review"}},
+		},
+		{
+			name:    "unknown and missing fields",
+			raw:     map[string]any{"title": "x"},
+			wantOut: map[string]any{},
+			wantErrs: ValidationErrors{
+				{Field: "title", Code: "unknown_field", Message: "unknown field"},
+				{Field: "rating", Code: "required", Message: "required"},
+				{Field: "doi", Code: "required", Message: "one of doi, pmid is required"},
+			},
+		},
+		{
+			name:     "invalid required field is reported once",
+			raw:      map[string]any{"pmid": "1", "rating": "x"},
+			wantOut:  map[string]any{"pmid": "1"},
+			wantErrs: ValidationErrors{{Field: "rating", Code: "type", Message: "must be a number"}},
+		},
+		{
+			name:     "empty string does not satisfy required",
+			raw:      map[string]any{"doi": "  ", "rating": float64(5)},
+			wantOut:  map[string]any{"doi": "", "rating": float64(5)},
+			wantErrs: ValidationErrors{{Field: "doi", Code: "required", Message: "one of doi, pmid is required"}},
+		},
+		{
+			name:     "status dependent required field",
+			raw:      map[string]any{"doi": "10.1000/abc", "rating": float64(5), "status": "published"},
+			wantOut:  map[string]any{"doi": "10.1000/abc", "rating": float64(5), "status": "published"},
+			wantErrs: ValidationErrors{{Field: "url", Code: "required", Message: "required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errs := schema.Validate(tt.raw)
+			if !reflect.DeepEqual(out, tt.wantOut) {
+				t.Errorf("out = %#v\nwant %#v", out, tt.wantOut)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errs = %#v\nwant %#v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// WebhookEvents sind alle Ereignistypen, die Endpoints abonnieren können.
+var WebhookEvents = []string{
+	models.EventPaperIngested,
+	models.EventPaperPDFStored,
+	models.EventRatedPaperSaved,
+	models.EventContentArticleStatusChanged,
+	models.EventFetchJobFinished,
+}
+
+// Header einer Webhook-Zustellung.
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderEventID   = "X-Webhook-Event-Id"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// webhookBatchSize begrenzt die Zustellungen, die ein Dispatcher-Durchlauf auf einmal übernimmt.
+const webhookBatchSize = 50
+
+// webhookResponseLimit begrenzt den im Log gespeicherten Antwort-Body.
+const webhookResponseLimit = 1024
+
+var (
+	// ErrInvalidWebhook kennzeichnet eine ungültige Endpoint-Definition (HTTP 400).
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrWebhookNotFound kennzeichnet einen unbekannten Endpoint, ein Ereignis oder eine Zustellung (HTTP 404).
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrWebhookDispatchRunning wird geliefert, wenn bereits ein Dispatcher-Durchlauf läuft.
+	ErrWebhookDispatchRunning = errors.New("webhook dispatch already running")
+)
+
+// WebhookService schreibt Ereignisse in eine Outbox (webhook_events/webhook_deliveries) und stellt sie
+// signiert an die registrierten Endpoints zu. Fehlgeschlagene Zustellungen werden mit exponentiellem
+// Backoff wiederholt; das Log bleibt erhalten und kann erneut zugestellt werden.
+// Ein nil-Service verwirft Ereignisse stillschweigend.
+type WebhookService struct {
+	DB          *gorm.DB
+	Logger      *zap.Logger
+	MaxAttempts int           // danach gilt eine Zustellung als fehlgeschlagen
+	Backoff     time.Duration // Wartezeit nach dem ersten Fehlversuch, verdoppelt sich je Versuch
+	BackoffMax  time.Duration // Obergrenze für die Wartezeit
+
+	client  *http.Client
+	running atomic.Bool
+}
+
+// NewWebhookService erstellt den Service; timeout gilt je Zustellversuch.
+func NewWebhookService(db *gorm.DB, logger *zap.Logger, maxAttempts int, backoff, backoffMax, timeout time.Duration) (*WebhookService, error) {
+	if maxAttempts < 1 {
+		return nil, fmt.Errorf("%w: max attempts must be at least 1", ErrInvalidWebhook)
+	}
+	if backoff <= 0 || backoffMax < backoff {
+		return nil, fmt.Errorf("%w: backoff must be positive and not exceed backoff max", ErrInvalidWebhook)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%w: timeout must be positive", ErrInvalidWebhook)
+	}
+	return &WebhookService{
+		DB: db, Logger: logger, MaxAttempts: maxAttempts, Backoff: backoff, BackoffMax: backoffMax,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// NewWebhookSecret erzeugt einen zufälligen Signatur-Schlüssel.
+func NewWebhookSecret() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return "whsec_" + hex.EncodeToString(buf)
+}
+
+// SignWebhook berechnet die Signatur "sha256=<hex>" als HMAC-SHA256 über "<timestamp>.<body>".
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhookEndpoint normalisiert und prüft URL und abonnierte Ereignisse eines Endpoints.
+// Erlaubt sind exakte Typen, Präfix-Muster wie "paper.*" und "*" für alle Ereignisse.
+func ValidateWebhookEndpoint(ep *models.WebhookEndpoint) error {
+	ep.URL = strings.TrimSpace(ep.URL)
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	if len(ep.Events) == 0 {
+		return fmt.Errorf("%w: events must not be empty (types: %s)", ErrInvalidWebhook, strings.Join(WebhookEvents, ", "))
+	}
+	for i, pattern := range ep.Events {
+		pattern = strings.TrimSpace(pattern)
+		ep.Events[i] = pattern
+		matched := false
+		for _, event := range WebhookEvents {
+			if matchEventPattern(pattern, event) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: event %q matches no type (types: %s)", ErrInvalidWebhook, pattern, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	for path := range ep.Filter {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("%w: filter keys must not be empty", ErrInvalidWebhook)
+		}
+	}
+	return nil
+}
+
+func matchEventPattern(pattern, event string) bool {
+	if pattern == "*" || pattern == event {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return ok && strings.HasPrefix(event, prefix)
+}
+
+// webhookMatches meldet, ob ep das Ereignis abonniert hat und data (JSON-Objekt) den Filter erfüllt.
+// Filter-Schlüssel sind Feldpfade mit Punkten ("paper.journal"); der Wert ist ein einzelner Wert oder
+// eine Liste erlaubter Werte. Ist das Feld selbst eine Liste, genügt ein passendes Element.
+func webhookMatches(ep *models.WebhookEndpoint, eventType string, data map[string]any) bool {
+	subscribed := false
+	for _, pattern := range ep.Events {
+		if matchEventPattern(pattern, eventType) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return false
+	}
+	for path, want := range ep.Filter {
+		got, ok := lookupPath(data, path)
+		if !ok || !filterValueMatches(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupPath(data map[string]any, path string) (any, bool) {
+	var current any = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func filterValueMatches(got, want any) bool {
+	wants, ok := want.([]any)
+	if !ok {
+		wants = []any{want}
+	}
+	gots, ok := got.([]any)
+	if !ok {
+		gots = []any{got}
+	}
+	for _, w := range wants {
+		for _, g := range gots {
+			if ws, ok := w.(string); ok {
+				if gs, ok := g.(string); ok && strings.EqualFold(ws, gs) {
+					return true
+				}
+				continue
+			}
+			if reflect.DeepEqual(w, g) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Emit schreibt ein Ereignis in die Outbox der Webhook-Datenbank. Für Änderungen in derselben
+// Datenbank EmitTx verwenden, damit Ereignis und Änderung gemeinsam committet werden.
+func (s *WebhookService) Emit(eventType string, data any) {
+	if s == nil {
+		return
+	}
+	if _, err := s.EmitTx(s.DB, eventType, data); err != nil {
+		s.Logger.Error("Failed to record webhook event", zap.String("event", eventType), zap.Error(err))
+	}
+}
+
+// EmitTx legt das Ereignis und je passendem aktiven Endpoint eine offene Zustellung an. Ohne passenden
+// Endpoint wird nichts gespeichert (nil, nil).
+func (s *WebhookService) EmitTx(tx *gorm.DB, eventType string, data any) (*models.WebhookEvent, error) {
+	if s == nil {
+		return nil, nil
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s: %w", eventType, err)
+	}
+	var fields map[string]any
+	_ = json.Unmarshal(body, &fields)
+
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("active = ?", true).Order("id").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	var matching []uint
+	for i := range endpoints {
+		if webhookMatches(&endpoints[i], eventType, fields) {
+			matching = append(matching, endpoints[i].ID)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, nil
+	}
+
+	event := models.WebhookEvent{Type: eventType, Data: body}
+	if err := tx.Create(&event).Error; err != nil {
+		return nil, err
+	}
+	deliveries := make([]models.WebhookDelivery, 0, len(matching))
+	for _, endpointID := range matching {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EventID: event.ID, EndpointID: endpointID, Status: models.DeliveryPending, NextAttemptAt: event.CreatedAt,
+		})
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// WebhookDispatchResult fasst einen Dispatcher-Durchlauf zusammen.
+type WebhookDispatchResult struct {
+	Attempted int `json:"attempted"`
+	Succeeded int `json:"succeeded"`
+	Retrying  int `json:"retrying"`
+	Failed    int `json:"failed"`
+}
+
+// Dispatch stellt alle fälligen Zustellungen zu. Mehrere Instanzen können parallel laufen: fällige
+// Zustellungen werden mit FOR UPDATE SKIP LOCKED übernommen und für die Dauer des Versuchs zurückgestellt,
+// sodass ein abgebrochener Versuch nach Ablauf erneut zugestellt wird.
+func (s *WebhookService) Dispatch(ctx context.Context) (*WebhookDispatchResult, error) {
+	if !s.running.CompareAndSwap(false, true) {
+		return nil, ErrWebhookDispatchRunning
+	}
+	defer s.running.Store(false)
+
+	result := &WebhookDispatchResult{}
+	for ctx.Err() == nil {
+		batch, err := s.claimDue()
+		if err != nil {
+			return result, err
+		}
+		for i := range batch {
+			if ctx.Err() != nil {
+				break
+			}
+			status, err := s.deliver(ctx, &batch[i])
+			if err != nil {
+				return result, err
+			}
+			result.Attempted++
+			switch {
+			case status == models.DeliverySucceeded:
+				result.Succeeded++
+			case status == models.DeliveryFailed:
+				result.Failed++
+			default:
+				result.Retrying++
+			}
+		}
+		if len(batch) < webhookBatchSize {
+			break
+		}
+	}
+	if result.Attempted > 0 {
+		s.Logger.Info("Webhook deliveries dispatched",
+			zap.Int("attempted", result.Attempted), zap.Int("succeeded", result.Succeeded),
+			zap.Int("retrying", result.Retrying), zap.Int("failed", result.Failed))
+	}
+	return result, nil
+}
+
+// claimDue übernimmt fällige Zustellungen samt Ereignis und verschiebt sie um die Versuchsdauer.
+func (s *WebhookService) claimDue() ([]models.WebhookDelivery, error) {
+	var batch []models.WebhookDelivery
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var ids []uint
+		err := tx.Model(&models.WebhookDelivery{}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at, id").Limit(webhookBatchSize).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		inFlight := now.Add(s.client.Timeout + time.Minute)
+		if err := tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", inFlight).Error; err != nil {
+			return err
+		}
+		return tx.Preload("Event").Where("id IN ?", ids).Order("id").Find(&batch).Error
+	})
+	return batch, err
+}
+
+// deliver führt einen Zustellversuch aus und speichert das Ergebnis; geliefert wird der neue Status.
+func (s *WebhookService) deliver(ctx context.Context, d *models.WebhookDelivery) (string, error) {
+	updates := map[string]any{"attempts": d.Attempts + 1}
+
+	var endpoint models.WebhookEndpoint
+	err := s.DB.First(&endpoint, d.EndpointID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		updates["last_error"] = "endpoint deleted"
+		updates["status"] = models.DeliveryFailed
+	case err != nil:
+		return "", err
+	case !endpoint.Active:
+		updates["last_error"] = "endpoint inactive"
+		updates["status"] = models.DeliveryFailed
+	case d.Event == nil:
+		updates["last_error"] = "event missing"
+		updates["status"] = models.DeliveryFailed
+	default:
+		code, response, sendErr := s.send(ctx, &endpoint, d)
+		updates["last_status"] = code
+		updates["last_response"] = response
+		updates["last_error"] = ""
+		now := time.Now()
+		switch {
+		case sendErr == nil:
+			updates["status"] = models.DeliverySucceeded
+			updates["delivered_at"] = now
+		case d.Attempts+1 >= s.MaxAttempts:
+			updates["last_error"] = sendErr.Error()
+			updates["status"] = models.DeliveryFailed
+		default:
+			updates["last_error"] = sendErr.Error()
+			updates["next_attempt_at"] = now.Add(s.backoff(d.Attempts + 1))
+		}
+	}
+	if err := s.DB.Model(d).Updates(updates).Error; err != nil {
+		return "", err
+	}
+	if status, ok := updates["status"].(string); ok {
+		if status == models.DeliveryFailed {
+			s.Logger.Warn("Webhook delivery failed permanently", zap.Uint("delivery_id", d.ID),
+				zap.Uint("endpoint_id", d.EndpointID), zap.String("error", d.LastError))
+		}
+		return status, nil
+	}
+	return models.DeliveryPending, nil
+}
+
+// send stellt das Ereignis signiert zu; jeder andere Status als 2xx ist ein Fehlschlag.
+func (s *WebhookService) send(ctx context.Context, endpoint *models.WebhookEndpoint, d *models.WebhookDelivery) (int, string, error) {
+	body, err := json.Marshal(struct {
+		ID        uint            `json:"id"`
+		Type      string          `json:"type"`
+		CreatedAt time.Time       `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}{d.Event.ID, d.Event.Type, d.Event.CreatedAt, d.Event.Data})
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "paper-hand-webhooks/1.0")
+	req.Header.Set(WebhookHeaderEvent, d.Event.Type)
+	req.Header.Set(WebhookHeaderEventID, strconv.FormatUint(uint64(d.Event.ID), 10))
+	req.Header.Set(WebhookHeaderDelivery, strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhook(endpoint.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(snippet), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(snippet), nil
+}
+
+// backoff liefert die Wartezeit nach dem attempts-ten Fehlversuch.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	wait := s.Backoff
+	for i := 1; i < attempts && wait < s.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > s.BackoffMax {
+		wait = s.BackoffMax
+	}
+	return wait
+}
+
+// Replay legt für eine protokollierte Zustellung eine neue, sofort fällige Zustellung an den selben
+// Endpoint an; der ursprüngliche Eintrag bleibt unverändert im Log.
+func (s *WebhookService) Replay(deliveryID uint) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := s.DB.First(&original, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: delivery %d", ErrWebhookNotFound, deliveryID)
+		}
+		return nil, err
+	}
+	var endpoint models.WebhookEndpoint
+	if err := s.DB.First(&endpoint, original.EndpointID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: endpoint %d", ErrWebhookNotFound, original.EndpointID)
+		}
+		return nil, err
+	}
+	replay := models.WebhookDelivery{
+		EventID: original.EventID, EndpointID: original.EndpointID, ReplayOf: &original.ID,
+		Status: models.DeliveryPending, NextAttemptAt: time.Now(),
+	}
+	if err := s.DB.Create(&replay).Error; err != nil {
+		return nil, err
+	}
+	return &replay, nil
+}
+
+// ReplayEvent stellt ein protokolliertes Ereignis erneut zu: an die angegebenen Endpoints oder, ohne
+// Angabe, an alle aktiven Endpoints, deren Abonnement und Filter heute passen.
+func (s *WebhookService) ReplayEvent(eventID uint, endpointIDs []uint) ([]models.WebhookDelivery, error) {
+	var event models.WebhookEvent
+	if err := s.DB.First(&event, eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: event %d", ErrWebhookNotFound, eventID)
+		}
+		return nil, err
+	}
+	var endpoints []models.WebhookEndpoint
+	query := s.DB.Order("id")
+	if len(endpointIDs) > 0 {
+		query = query.Where("id IN ?", endpointIDs)
+	} else {
+		query = query.Where("active = ?", true)
+	}
+	if err := query.Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	if len(endpointIDs) > 0 && len(endpoints) != len(endpointIDs) {
+		return nil, fmt.Errorf("%w: unknown endpoint in %v", ErrWebhookNotFound, endpointIDs)
+	}
+	var fields map[string]any
+	_ = json.Unmarshal(event.Data, &fields)
+
+	deliveries := []models.WebhookDelivery{}
+	now := time.Now()
+	for i := range endpoints {
+		if len(endpointIDs) == 0 && !webhookMatches(&endpoints[i], event.Type, fields) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EventID: event.ID, EndpointID: endpoints[i].ID, Status: models.DeliveryPending, NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+	if err := s.DB.Create(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// WebhookStats zählt die Zustellungen je Endpoint und Status.
+type WebhookStats struct {
+	EndpointID uint   `json:"endpoint_id"`
+	Status     string `json:"status"`
+	Count      int64  `json:"count"`
+}
+
+// Stats liefert die Anzahl der Zustellungen je Endpoint und Status.
+func (s *WebhookService) Stats() ([]WebhookStats, error) {
+	stats := []WebhookStats{}
+	err := s.DB.Model(&models.WebhookDelivery{}).Select("endpoint_id, status, COUNT(*) AS count").
+		Group("endpoint_id, status").Order("endpoint_id, status").Scan(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"paper-hand/models"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"body", "whsec_test", 1700000000, `{"type":"paper.created"}`, "sha256=a1f17b88a915da20b6ba2b7b8bb2ec8b7e172df3729792642c319704a7e56651"},
+		{"empty body", "whsec_test", 1700000000, "", "sha256=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("SignWebhook() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	base := SignWebhook("whsec_test", 1700000000, []byte("{}"))
+	if SignWebhook("whsec_other", 1700000000, []byte("{}")) == base {
+		t.Error("signature does not depend on the secret")
+	}
+	if SignWebhook("whsec_test", 1700000001, []byte("{}")) == base {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestMatchEventPattern(t *testing.T) {
+	tests := []struct {
+		pattern, event string
+		want           bool
+	}{
+		{"*", "paper.created", true},
+		{"paper.created", "paper.created", true},
+		{"paper.created", "paper.updated", false},
+		{"paper.*", "paper.updated", true},
+		{"paper.*", "rated_paper.created", false},
+		{"paper.", "paper.created", false},
+		{"", "paper.created", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.event, func(t *testing.T) {
+			if got := matchEventPattern(tt.pattern, tt.event); got != tt.want {
+				t.Errorf("matchEventPattern(%q, %q) = %v, want %v", tt.pattern, tt.event, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	data := map[string]any{
+		"paper": map[string]any{"journal": "Nutrients", "year": float64(2020), "substances": []any{"Curcumin", "Piperin"}},
+	}
+	tests := []struct {
+		name   string
+		events []string
+		filter map[string]any
+		want   bool
+	}{
+		{"subscribed without filter", []string{"paper.*"}, nil, true},
+		{"not subscribed", []string{"rated_paper.*"}, nil, false},
+		{"nested field", []string{"*"}, map[string]any{"paper.journal": "nutrients"}, true},
+		{"nested field mismatch", []string{"*"}, map[string]any{"paper.journal": "Lancet"}, false},
+		{"missing field", []string{"*"}, map[string]any{"paper.doi": "10.1/a"}, false},
+		{"path through non-object", []string{"*"}, map[string]any{"paper.journal.name": "Nutrients"}, false},
+		{"all filters must match", []string{"*"}, map[string]any{"paper.journal": "Nutrients", "paper.year": float64(2019)}, false},
+		{"list field", []string{"*"}, map[string]any{"paper.substances": "piperin"}, true},
+		{"allowed values", []string{"*"}, map[string]any{"paper.year": []any{float64(2019), float64(2020)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := &models.WebhookEndpoint{Events: tt.events, Filter: tt.filter}
+			if got := webhookMatches(ep, "paper.created", data); got != tt.want {
+				t.Errorf("webhookMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValueMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want any
+		match     bool
+	}{
+		{"equal strings ignore case", "RCT", "rct", true},
+		{"different strings", "RCT", "Review", false},
+		{"number", float64(7), float64(7), true},
+		{"number vs string", float64(7), "7", false},
+		{"bool", true, true, true},
+		{"null", nil, nil, true},
+		{"one of wanted values", "Review", []any{"RCT", "review"}, true},
+		{"none of wanted values", "Kohorte", []any{"RCT", "Review"}, false},
+		{"list contains value", []any{"a", "B"}, "b", true},
+		{"lists overlap", []any{"a", "b"}, []any{"c", "A"}, true},
+		{"empty list", []any{}, "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterValueMatches(tt.got, tt.want); got != tt.match {
+				t.Errorf("filterValueMatches(%v, %v) = %v, want %v", tt.got, tt.want, got, tt.match)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	s := &WebhookService{Backoff: 30 * time.Second, BackoffMax: 5 * time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := s.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
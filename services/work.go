@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"paper-hand/models"
+)
+
+// Namen der Arbeits-Queues für n8n-Worker.
+const (
+	QueueRating         = "rating"          // Rohpaper zur KI-Bewertung (transfer_n8n = false)
+	QueueRAGIngest      = "rag-ingest"      // bewertete Paper für LightRAG (added_rag = false)
+	QueueArticleWriting = "article-writing" // bewertete Paper mit Content-Idee (content_status = idee)
+)
+
+// MaxWorkClaim begrenzt die Anzahl Einträge je Claim.
+const MaxWorkClaim = 100
+
+var (
+	// ErrUnknownQueue kennzeichnet eine nicht registrierte Queue (HTTP 404).
+	ErrUnknownQueue = errors.New("unknown queue")
+	// ErrInvalidClaim kennzeichnet ungültige Claim- oder Lease-Angaben (HTTP 400).
+	ErrInvalidClaim = errors.New("invalid claim")
+	// ErrLeaseNotFound wird geliefert, wenn das Token unbekannt ist oder der Eintrag nach Ablauf neu
+	// vergeben wurde (HTTP 404).
+	ErrLeaseNotFound = errors.New("lease not found")
+	// ErrLeaseResolved wird geliefert, wenn der Lease bereits abgeschlossen oder fehlgeschlagen ist (HTTP 409).
+	ErrLeaseResolved = errors.New("lease already resolved")
+)
+
+// WorkQueue beschreibt eine Queue über einer Tabelle: welche Einträge offen sind, wie ein Claim sie
+// zusätzlich filtern kann und was complete am Eintrag setzt.
+type WorkQueue struct {
+	Name   string
+	DB     *gorm.DB
+	Model  any    // GORM-Modell der Einträge
+	Table  string // Tabellenname der Einträge
+	Order  string // Vergabereihenfolge
+	Open   func(*gorm.DB) *gorm.DB
+	Filter func(query *gorm.DB, filter json.RawMessage) (*gorm.DB, error) // optional, Filter aus dem Claim
+	Load   func(db *gorm.DB, ids []uint) (map[uint]any, error)
+	Done   map[string]any // Spaltenwerte am Eintrag bei complete (leer = nur Lease abschließen)
+}
+
+// RatingQueue vergibt Rohpaper, die noch nicht an n8n übergeben wurden; complete setzt transfer_n8n.
+func RatingQueue(db *gorm.DB, filter func(*gorm.DB, json.RawMessage) (*gorm.DB, error)) WorkQueue {
+	return WorkQueue{
+		Name: QueueRating, DB: db, Model: &models.Paper{}, Table: "papers", Order: "papers.id",
+		Open: func(q *gorm.DB) *gorm.DB {
+			return q.Where("papers.transfer_n8n = ?", false)
+		},
+		Filter: filter,
+		Load:   loadWorkItems(func(p *models.Paper) uint { return p.ID }),
+		Done:   map[string]any{"transfer_n8n": true},
+	}
+}
+
+// RAGIngestQueue vergibt bewertete Paper, die noch nicht im RAG liegen (beste Bewertung zuerst);
+// complete setzt added_rag.
+func RAGIngestQueue(db *gorm.DB, filter func(*gorm.DB, json.RawMessage) (*gorm.DB, error)) WorkQueue {
+	return WorkQueue{
+		Name: QueueRAGIngest, DB: db, Model: &models.RatedPaper{}, Table: "rated_papers",
+		Order: "rated_papers.rating DESC, rated_papers.id",
+		Open: func(q *gorm.DB) *gorm.DB {
+			return q.Where("(rated_papers.added_rag = ? OR rated_papers.added_rag IS NULL)", false)
+		},
+		Filter: filter,
+		Load:   loadWorkItems(func(p *models.RatedPaper) uint { return p.ID }),
+		Done:   map[string]any{"added_rag": true},
+	}
+}
+
+// ArticleWritingQueue vergibt bewertete Paper im Content-Status state (beste Bewertung zuerst). Den
+// Statuswechsel nach dem Schreiben übernimmt der Content-Workflow; complete schließt nur den Lease ab,
+// ohne Statuswechsel ist der Eintrag danach wieder offen.
+func ArticleWritingQueue(db *gorm.DB, state string, filter func(*gorm.DB, json.RawMessage) (*gorm.DB, error)) WorkQueue {
+	return WorkQueue{
+		Name: QueueArticleWriting, DB: db, Model: &models.RatedPaper{}, Table: "rated_papers",
+		Order: "rated_papers.rating DESC, rated_papers.id",
+		Open: func(q *gorm.DB) *gorm.DB {
+			return q.Where("rated_papers.content_status = ?", state)
+		},
+		Filter: filter,
+		Load:   loadWorkItems(func(p *models.RatedPaper) uint { return p.ID }),
+	}
+}
+
+func loadWorkItems[T any](id func(*T) uint) func(*gorm.DB, []uint) (map[uint]any, error) {
+	return func(db *gorm.DB, ids []uint) (map[uint]any, error) {
+		var items []T
+		if err := db.Where("id IN ?", ids).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		out := make(map[uint]any, len(items))
+		for i := range items {
+			out[id(&items[i])] = items[i]
+		}
+		return out, nil
+	}
+}
+
+// DecodeWorkFilter liest den Filter eines Claims strikt (unbekannte Felder sind ein Fehler).
+func DecodeWorkFilter(raw json.RawMessage, dest any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dest); err != nil {
+		return fmt.Errorf("%w: filter: %v", ErrInvalidClaim, err)
+	}
+	return nil
+}
+
+// WorkService vergibt Queue-Einträge atomar an Worker (SELECT … FOR UPDATE SKIP LOCKED). Abgelaufene
+// Leases gelten beim nächsten Claim automatisch wieder als offen.
+type WorkService struct {
+	LeaseTTL    time.Duration // Standard-Laufzeit eines Leases
+	MaxAttempts int           // nach so vielen Fehlversuchen wird ein Eintrag nicht mehr vergeben
+
+	queues map[string]WorkQueue
+	names  []string
+}
+
+// NewWorkService erstellt den Service ohne Queues; diese registrieren die Routen der jeweiligen Entität.
+func NewWorkService(ttl time.Duration, maxAttempts int) (*WorkService, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: lease ttl must be positive", ErrInvalidClaim)
+	}
+	if maxAttempts < 1 {
+		return nil, fmt.Errorf("%w: max attempts must be at least 1", ErrInvalidClaim)
+	}
+	return &WorkService{LeaseTTL: ttl, MaxAttempts: maxAttempts, queues: map[string]WorkQueue{}}, nil
+}
+
+// Register fügt eine Queue hinzu.
+func (s *WorkService) Register(q WorkQueue) {
+	if _, ok := s.queues[q.Name]; !ok {
+		s.names = append(s.names, q.Name)
+	}
+	s.queues[q.Name] = q
+}
+
+// Queues liefert die registrierten Queue-Namen in Registrierungsreihenfolge.
+func (s *WorkService) Queues() []string {
+	return s.names
+}
+
+// ClaimRequest fordert bis zu Limit Einträge einer Queue an.
+type ClaimRequest struct {
+	Queue        string          `json:"queue"`
+	Worker       string          `json:"worker"`        // z.B. n8n-Execution-ID, nur zur Nachverfolgung
+	Limit        int             `json:"limit"`         // Standard 1, höchstens MaxWorkClaim
+	LeaseSeconds int             `json:"lease_seconds"` // Standard WORK_LEASE_TTL
+	Filter       json.RawMessage `json:"filter"`        // Filter wie bei der Query der Entität
+}
+
+// ClaimedItem ist ein vergebener Eintrag mit seinem Lease.
+type ClaimedItem struct {
+	Token     string    `json:"token"`
+	ItemID    uint      `json:"item_id"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Item      any       `json:"item"`
+}
+
+// Claim vergibt bis zu req.Limit offene Einträge. Parallele Claims erhalten disjunkte Einträge; offen ist
+// ein Eintrag, der die Bedingung der Queue erfüllt und weder abgeschlossen, aufgegeben, aktiv vergeben
+// noch nach einem Fehlschlag zurückgestellt ist.
+func (s *WorkService) Claim(req ClaimRequest) ([]ClaimedItem, error) {
+	q, ok := s.queues[req.Queue]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (queues: %s)", ErrUnknownQueue, req.Queue, strings.Join(s.names, ", "))
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 1
+	}
+	if limit < 0 || limit > MaxWorkClaim {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidClaim, MaxWorkClaim)
+	}
+	ttl, err := s.leaseTTL(req.LeaseSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := []ClaimedItem{}
+	err = q.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Model(q.Model).Scopes(q.Open)
+		if q.Filter != nil && len(req.Filter) > 0 && string(req.Filter) != "null" {
+			var err error
+			if query, err = q.Filter(query, req.Filter); err != nil {
+				return err
+			}
+		}
+		var ids []uint
+		err := query.Scopes(s.available(q, now)).Order(q.Order).Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: q.Table}, Options: "SKIP LOCKED"}).
+			Pluck(q.Table+".id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		tokens := make([]string, 0, len(ids))
+		for _, id := range ids {
+			lease := models.WorkLease{
+				Queue: q.Name, ItemID: id, Token: newLeaseToken(q.Name), Worker: req.Worker,
+				Status: models.LeaseActive, Attempts: 1, ExpiresAt: now.Add(ttl),
+			}
+			// Nur ein freier Lease wird übernommen: Hat ein paralleler Claim den Eintrag seit dem Snapshot
+			// dieser Abfrage vergeben (oder zurückgestellt), bleibt dessen Lease unangetastet. Ein
+			// abgeschlossener Lease gehört zu einem wieder offenen Eintrag und beginnt neu.
+			res := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "queue"}, {Name: "item_id"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"token": lease.Token, "worker": lease.Worker, "status": models.LeaseActive,
+					"attempts":   gorm.Expr("CASE WHEN work_leases.status = ? THEN 1 ELSE work_leases.attempts + 1 END", models.LeaseCompleted),
+					"expires_at": lease.ExpiresAt, "retry_at": nil, "completed_at": nil, "last_error": "", "updated_at": now,
+				}),
+				Where: clause.Where{Exprs: []clause.Expression{
+					clause.Expr{SQL: "NOT " + blockingLease("work_leases"), Vars: blockingLeaseVars(now)},
+				}},
+			}).Create(&lease)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				tokens = append(tokens, lease.Token)
+			}
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+
+		var leases []models.WorkLease
+		if err := tx.Where("token IN ?", tokens).Find(&leases).Error; err != nil {
+			return err
+		}
+		byItem := make(map[uint]models.WorkLease, len(leases))
+		for _, l := range leases {
+			byItem[l.ItemID] = l
+		}
+		items, err := q.Load(tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			lease, ok := byItem[id]
+			if !ok {
+				continue
+			}
+			claimed = append(claimed, ClaimedItem{
+				Token: lease.Token, ItemID: id, Attempts: lease.Attempts, ExpiresAt: lease.ExpiresAt, Item: items[id],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// available schließt Einträge mit aktivem, aufgegebenem oder zurückgestelltem Lease aus. Ob ein Eintrag
+// offen ist, entscheidet sonst allein die Open-Bedingung der Queue; ein abgeschlossener Lease sperrt
+// nicht, damit z.B. ein zurückgesetztes added_rag den Eintrag wieder vergebbar macht.
+func (s *WorkService) available(q WorkQueue, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("NOT EXISTS (SELECT 1 FROM work_leases l WHERE l.queue = ? AND l.item_id = "+q.Table+".id AND "+
+			blockingLease("l")+")", append([]any{q.Name}, blockingLeaseVars(now)...)...)
+	}
+}
+
+// blockingLease ist die Bedingung für einen Lease, der die erneute Vergabe seines Eintrags verhindert:
+// aufgegeben, aktiv und nicht abgelaufen oder nach einem Fehlschlag noch zurückgestellt. Claim nutzt sie
+// auch beim Upsert, damit Abfrage und Übernahme dieselbe Regel anwenden.
+func blockingLease(alias string) string {
+	return "(" + alias + ".status = ? OR (" + alias + ".status = ? AND " + alias + ".expires_at > ?) OR (" +
+		alias + ".status = ? AND " + alias + ".retry_at > ?))"
+}
+
+func blockingLeaseVars(now time.Time) []any {
+	return []any{models.LeaseDead, models.LeaseActive, now, models.LeaseFailed, now}
+}
+
+func (s *WorkService) leaseTTL(seconds int) (time.Duration, error) {
+	switch {
+	case seconds < 0:
+		return 0, fmt.Errorf("%w: lease_seconds must not be negative", ErrInvalidClaim)
+	case seconds == 0:
+		return s.LeaseTTL, nil
+	default:
+		return time.Duration(seconds) * time.Second, nil
+	}
+}
+
+// newLeaseToken erzeugt ein zufälliges Token mit dem Queue-Namen als Präfix, damit complete, fail und
+// extend ohne Queue-Angabe die richtige Datenbank finden.
+func newLeaseToken(queue string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return queue + "." + hex.EncodeToString(b)
+}
+
+// withLease sperrt den aktiven Lease zu token und führt fn in derselben Transaktion aus. Ein abgelaufener,
+// aber noch nicht neu vergebener Lease gehört weiterhin dem Worker.
+func (s *WorkService) withLease(token string, fn func(tx *gorm.DB, q WorkQueue, lease *models.WorkLease) error) (*models.WorkLease, error) {
+	name, _, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidClaim)
+	}
+	q, ok := s.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownQueue, name)
+	}
+	var lease models.WorkLease
+	err := q.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token = ?", strings.TrimSpace(token)).First(&lease).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLeaseNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if lease.Status != models.LeaseActive {
+			return fmt.Errorf("%w (status %s)", ErrLeaseResolved, lease.Status)
+		}
+		return fn(tx, q, &lease)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// Complete schließt einen Lease ab und setzt die Done-Werte der Queue am Eintrag.
+func (s *WorkService) Complete(token string) (*models.WorkLease, error) {
+	return s.withLease(token, func(tx *gorm.DB, q WorkQueue, lease *models.WorkLease) error {
+		now := time.Now()
+		if len(q.Done) > 0 {
+			updates := map[string]any{"updated_at": now}
+			for k, v := range q.Done {
+				updates[k] = v
+			}
+			if err := tx.Table(q.Table).Where("id = ?", lease.ItemID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		lease.Status, lease.CompletedAt = models.LeaseCompleted, &now
+		return tx.Model(lease).Updates(map[string]any{"status": lease.Status, "completed_at": now}).Error
+	})
+}
+
+// Fail gibt einen Eintrag nach einem Fehler zurück; er wird frühestens nach retryAfter erneut vergeben.
+// Nach MaxAttempts Vergaben bleibt er als dead liegen.
+func (s *WorkService) Fail(token, message string, retryAfter time.Duration) (*models.WorkLease, error) {
+	if retryAfter < 0 {
+		return nil, fmt.Errorf("%w: retry_after_seconds must not be negative", ErrInvalidClaim)
+	}
+	return s.withLease(token, func(tx *gorm.DB, q WorkQueue, lease *models.WorkLease) error {
+		retryAt := time.Now().Add(retryAfter)
+		lease.Status, lease.LastError, lease.RetryAt = models.LeaseFailed, strings.TrimSpace(message), &retryAt
+		if lease.Attempts >= s.MaxAttempts {
+			lease.Status, lease.RetryAt = models.LeaseDead, nil
+		}
+		return tx.Model(lease).Updates(map[string]any{
+			"status": lease.Status, "last_error": lease.LastError, "retry_at": lease.RetryAt,
+		}).Error
+	})
+}
+
+// Extend verlängert einen Lease ab jetzt um seconds (0 = Standard-Laufzeit).
+func (s *WorkService) Extend(token string, seconds int) (*models.WorkLease, error) {
+	ttl, err := s.leaseTTL(seconds)
+	if err != nil {
+		return nil, err
+	}
+	return s.withLease(token, func(tx *gorm.DB, q WorkQueue, lease *models.WorkLease) error {
+		lease.ExpiresAt = time.Now().Add(ttl)
+		return tx.Model(lease).Update("expires_at", lease.ExpiresAt).Error
+	})
+}
+
+// QueueStats zählt die Einträge einer Queue je Zustand.
+type QueueStats struct {
+	Queue     string `json:"queue"`
+	Available int64  `json:"available"` // jetzt vergebbar (inkl. abgelaufener Leases)
+	Leased    int64  `json:"leased"`    // aktiv vergeben
+	Expired   int64  `json:"expired"`   // Lease abgelaufen, wieder in der Queue
+	Failed    int64  `json:"failed"`    // fehlgeschlagen, wartet auf erneute Vergabe
+	Dead      int64  `json:"dead"`
+	Completed int64  `json:"completed"`
+}
+
+// Stats liefert die Zählung für alle Queues.
+func (s *WorkService) Stats() ([]QueueStats, error) {
+	out := make([]QueueStats, 0, len(s.names))
+	for _, name := range s.names {
+		q := s.queues[name]
+		now := time.Now()
+		st := QueueStats{Queue: name}
+		if err := q.DB.Model(q.Model).Scopes(q.Open, s.available(q, now)).Count(&st.Available).Error; err != nil {
+			return nil, err
+		}
+		var rows []struct {
+			Status  string
+			Expired bool
+			Count   int64
+		}
+		err := q.DB.Model(&models.WorkLease{}).
+			Select("status, status = ? AND expires_at <= ? AS expired, COUNT(*) AS count", models.LeaseActive, now).
+			Where("queue = ?", name).Group("status, expired").Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			switch {
+			case r.Expired:
+				st.Expired += r.Count
+			case r.Status == models.LeaseActive:
+				st.Leased += r.Count
+			case r.Status == models.LeaseFailed:
+				st.Failed += r.Count
+			case r.Status == models.LeaseDead:
+				st.Dead += r.Count
+			case r.Status == models.LeaseCompleted:
+				st.Completed += r.Count
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockingLease(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cond := blockingLease("l")
+	vars := blockingLeaseVars(now)
+	if n := strings.Count(cond, "?"); n != len(vars) {
+		t.Fatalf("blockingLease has %d placeholders, blockingLeaseVars %d values", n, len(vars))
+	}
+
+	sql := cond
+	for _, v := range vars {
+		if ts, ok := v.(time.Time); ok {
+			v = ts.Format(time.RFC3339)
+		}
+		sql = strings.Replace(sql, "?", fmt.Sprintf("'%v'", v), 1)
+	}
+	want := "(l.status = 'dead' OR (l.status = 'leased' AND l.expires_at > '2024-05-01T12:00:00Z') OR " +
+		"(l.status = 'failed' AND l.retry_at > '2024-05-01T12:00:00Z'))"
+	if sql != want {
+		t.Errorf("blockingLease() = %s\nwant %s", sql, want)
+	}
+}
+
+func TestLeaseTTL(t *testing.T) {
+	s := &WorkService{LeaseTTL: 10 * time.Minute}
+	tests := []struct {
+		name    string
+		seconds int
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", 0, 10 * time.Minute, false},
+		{"explicit", 90, 90 * time.Second, false},
+		{"negative", -1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.leaseTTL(tt.seconds)
+			if tt.wantErr != errors.Is(err, ErrInvalidClaim) {
+				t.Fatalf("leaseTTL(%d) error = %v, wantErr %v", tt.seconds, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("leaseTTL(%d) = %v, want %v", tt.seconds, got, tt.want)
+			}
+		})
+	}
+}
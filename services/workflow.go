@@ -0,0 +1,316 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"paper-hand/models"
+)
+
+// ErrInvalidTransition kennzeichnet einen im Workflow nicht erlaubten Statuswechsel (HTTP 409).
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// ErrUnknownState kennzeichnet einen Zustand, den der Workflow nicht kennt (HTTP 400).
+var ErrUnknownState = errors.New("unknown state")
+
+// WorkflowStart ist der Pseudo-Zustand vor der Anlage; seine Folgezustände sind die zulässigen
+// Anfangszustände.
+const WorkflowStart = "*"
+
+// DefaultWorkflowActor wird protokolliert, wenn ein Übergang keinen Auslöser angibt.
+const DefaultWorkflowActor = "api"
+
+// Workflow ist der Zustandsautomat für das Status-Feld einer Entität.
+type Workflow struct {
+	Entity      string
+	Field       string              // Status-Spalte (gleichzeitig JSON-Feld)
+	Initial     string              // Zustand bei Anlage ohne Angabe ("" = keiner)
+	Transitions map[string][]string // Zustand → erlaubte Folgezustände; WorkflowStart → Anfangszustände
+	Guards      map[string][]string // Zielzustand → Felder, die nicht leer sein dürfen
+	Timestamps  map[string]string   // Zielzustand → Zeitstempel-Spalte, gesetzt beim ersten Erreichen
+
+	// OnTransition läuft nach jedem protokollierten Wechsel in derselben Transaktion (z.B. Webhooks);
+	// ein Fehler rollt den Wechsel zurück.
+	OnTransition func(tx *gorm.DB, model any, transition *models.ContentTransition) error
+}
+
+// ContentArticleWorkflow ist der Standard-Workflow für content_articles.
+func ContentArticleWorkflow() *Workflow {
+	return &Workflow{
+		Entity:  models.WorkflowEntityContentArticle,
+		Field:   "content_status",
+		Initial: "draft",
+		Transitions: map[string][]string{
+			WorkflowStart: {"draft", "review", "published"},
+			"draft":       {"review", "archived"},
+			"review":      {"draft", "published", "archived"},
+			"published":   {"review", "archived"},
+			"archived":    {"draft"},
+		},
+		Guards: map[string][]string{
+			"review":    {"title", "text"},
+			"published": {"title", "text", "slug", "meta_description"},
+		},
+		Timestamps: map[string]string{"published": "published_at"},
+	}
+}
+
+// RatedPaperWorkflow ist der Standard-Workflow für den Content-Status von rated_papers
+// (Zustände wie in den n8n-Workflows).
+func RatedPaperWorkflow() *Workflow {
+	return &Workflow{
+		Entity: models.WorkflowEntityRatedPaper,
+		Field:  "content_status",
+		Transitions: map[string][]string{
+			WorkflowStart:   {"idee"},
+			"idee":          {"produziert", "posted Social"},
+			"produziert":    {"posted Social", "idee"},
+			"posted Social": {"produziert"},
+		},
+		Guards: map[string][]string{
+			"idee":       {"content_idea"},
+			"produziert": {"content_url"},
+		},
+	}
+}
+
+// Configure überschreibt Übergänge und Guards im Format von FIELD_PRECEDENCE, z.B.
+// transitions "*=draft;draft=review,archived;review=draft,published" und guards "published=slug,meta_description".
+// Gesetzte Übergänge ersetzen die Standardübergänge vollständig, Guards nur für die genannten Zustände.
+func (w *Workflow) Configure(transitions, guards string) error {
+	if strings.TrimSpace(transitions) != "" {
+		parsed, err := parseStateMap(transitions)
+		if err != nil {
+			return fmt.Errorf("%s transitions: %w", w.Entity, err)
+		}
+		if len(parsed[WorkflowStart]) == 0 {
+			return fmt.Errorf("%s transitions: no start states (%s=…)", w.Entity, WorkflowStart)
+		}
+		w.Transitions = parsed
+	}
+	if strings.TrimSpace(guards) != "" {
+		parsed, err := parseStateMap(guards)
+		if err != nil {
+			return fmt.Errorf("%s guards: %w", w.Entity, err)
+		}
+		for state, fields := range parsed {
+			w.Guards[state] = fields
+		}
+	}
+	if w.Initial != "" && !w.HasState(w.Initial) {
+		return fmt.Errorf("%s: initial state %q missing in transitions", w.Entity, w.Initial)
+	}
+	for state := range w.Guards {
+		if !w.HasState(state) {
+			return fmt.Errorf("%s guards: %w %q", w.Entity, ErrUnknownState, state)
+		}
+	}
+	return nil
+}
+
+// parseStateMap liest "a=x,y;b=z"; Zustandsnamen behalten ihre Schreibweise.
+func parseStateMap(spec string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, entry := range strings.Split(spec, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		state, list, ok := strings.Cut(entry, "=")
+		state = strings.TrimSpace(state)
+		if !ok || state == "" {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+		var values []string
+		for _, v := range strings.Split(list, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		out[state] = values
+	}
+	return out, nil
+}
+
+// States liefert alle Zustände in der Reihenfolge ihres ersten Auftretens (Anfangszustände zuerst).
+func (w *Workflow) States() []string {
+	var states []string
+	add := func(s string) {
+		if s != WorkflowStart && !slices.Contains(states, s) {
+			states = append(states, s)
+		}
+	}
+	for _, s := range w.Transitions[WorkflowStart] {
+		add(s)
+	}
+	keys := make([]string, 0, len(w.Transitions))
+	for k := range w.Transitions {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		add(k)
+		for _, s := range w.Transitions[k] {
+			add(s)
+		}
+	}
+	return states
+}
+
+// HasState meldet, ob state im Workflow vorkommt.
+func (w *Workflow) HasState(state string) bool {
+	return slices.Contains(w.States(), state)
+}
+
+// Allowed liefert die erlaubten Folgezustände; from "" steht für eine neue bzw. statuslose Entität.
+func (w *Workflow) Allowed(from string) []string {
+	if from == "" {
+		from = WorkflowStart
+	}
+	return w.Transitions[from]
+}
+
+// Check prüft den Übergang from → to und die Guards des Zielzustands gegen item (Struct oder Map mit
+// den JSON-Feldern der Entität). Fehlende Pflichtfelder kommen als ValidationErrors.
+func (w *Workflow) Check(from, to string, item any) error {
+	if !w.HasState(to) {
+		return fmt.Errorf("%w %q (states: %s)", ErrUnknownState, to, strings.Join(w.States(), ", "))
+	}
+	allowed := w.Allowed(from)
+	if !slices.Contains(allowed, to) {
+		shown := from
+		if shown == "" {
+			shown = WorkflowStart
+		}
+		return fmt.Errorf("%w: %s → %s (allowed: %s)", ErrInvalidTransition, shown, to, strings.Join(allowed, ", "))
+	}
+	guards := w.Guards[to]
+	if len(guards) == 0 {
+		return nil
+	}
+	var fields map[string]any
+	body, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+	var errs ValidationErrors
+	for _, field := range guards {
+		if isEmptyValue(fields[field]) {
+			errs.add(field, "guard", "required for state "+to)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isEmptyValue(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(t) == ""
+	case bool:
+		return !t
+	case float64:
+		return t == 0
+	case []any:
+		return len(t) == 0
+	case map[string]any:
+		return len(t) == 0
+	}
+	return false
+}
+
+// TransitionInput ist ein angeforderter Statuswechsel.
+type TransitionInput struct {
+	To     string `json:"to"`
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// Apply führt den Übergang from → in.To für die geladene Entität model (Zeiger, Primärschlüssel id)
+// aus: Status, status_changed_at und konfigurierte Zeitstempel setzen und den Wechsel protokollieren.
+// Die Guards werden gegen model geprüft. Ein Wechsel in den aktuellen Zustand ist ein No-op (nil, nil).
+// Muss in einer Transaktion laufen, damit ein abgelehnter Wechsel vorherige Änderungen zurückrollt.
+func (w *Workflow) Apply(tx *gorm.DB, model any, id uint, from string, in TransitionInput) (*models.ContentTransition, error) {
+	to := strings.TrimSpace(in.To)
+	if to == from {
+		return nil, nil
+	}
+	if err := w.Check(from, to, model); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	updates := map[string]any{w.Field: to, "status_changed_at": now}
+	if column := w.Timestamps[to]; column != "" {
+		updates[column] = gorm.Expr("COALESCE("+column+", ?)", now)
+	}
+	if err := tx.Model(model).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	actor := strings.TrimSpace(in.Actor)
+	if actor == "" {
+		actor = DefaultWorkflowActor
+	}
+	transition := models.ContentTransition{
+		Entity: w.Entity, EntityID: id, FromState: from, ToState: to,
+		Actor: actor, Reason: strings.TrimSpace(in.Reason),
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return nil, err
+	}
+	if w.OnTransition != nil {
+		if err := w.OnTransition(tx, model, &transition); err != nil {
+			return nil, err
+		}
+	}
+	return &transition, nil
+}
+
+// ListTransitions liefert das Übergangsprotokoll einer Entität, neueste zuerst.
+func (w *Workflow) ListTransitions(db *gorm.DB, id uint) ([]models.ContentTransition, error) {
+	var transitions []models.ContentTransition
+	err := db.Where("entity = ? AND entity_id = ?", w.Entity, id).Order("id DESC").Find(&transitions).Error
+	return transitions, err
+}
+
+// WorkflowSummary beschreibt einen Workflow samt Anzahl der Entitäten je Zustand.
+type WorkflowSummary struct {
+	Entity      string              `json:"entity"`
+	Initial     string              `json:"initial,omitempty"`
+	States      []string            `json:"states"`
+	Transitions map[string][]string `json:"transitions"`
+	Guards      map[string][]string `json:"guards"`
+	Timestamps  map[string]string   `json:"timestamps"`
+	Counts      map[string]int64    `json:"counts"` // Zustand → Anzahl ("" = ohne Status)
+}
+
+// Summary zählt die Entitäten aus table je Zustand.
+func (w *Workflow) Summary(db *gorm.DB, table any) (*WorkflowSummary, error) {
+	var rows []struct {
+		State string
+		Count int64
+	}
+	if err := db.Model(table).Select("COALESCE(" + w.Field + ", '') AS state, COUNT(*) AS count").
+		Group("state").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, r := range rows {
+		counts[r.State] = r.Count
+	}
+	return &WorkflowSummary{
+		Entity: w.Entity, Initial: w.Initial, States: w.States(),
+		Transitions: w.Transitions, Guards: w.Guards, Timestamps: w.Timestamps, Counts: counts,
+	}, nil
+}
@@ -37,8 +37,9 @@ func NewS3Client(cfg *config.Config) (*s3.Client, error) {
 }
 
 // UploadFile lädt eine Datei ins S3 hoch und gibt den Link zurück.
-func UploadFile(client *s3.Client, bucket, key string, data []byte, cfg *config.Config) (string, error) {
-	_, err := client.PutObject(context.TODO(), &s3.PutObjectInput{
+// Ein abgebrochener ctx bricht den Upload ab.
+func UploadFile(ctx context.Context, client *s3.Client, bucket, key string, data []byte, cfg *config.Config) (string, error) {
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
 		Body:   bytes.NewReader(data),